@@ -23,24 +23,99 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/file_stash_url": {
+        "/audit": {
             "get": {
+                "description": "Returns configuration and infrastructure changes, newest first.\nUse format=ndjson (or Accept: application/x-ndjson) to export all matching entries as newline delimited JSON.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "FileStash"
+                    "Audit"
+                ],
+                "summary": "Lists audit log entries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username of the actor",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "create",
+                            "update",
+                            "delete"
+                        ],
+                        "type": "string",
+                        "description": "Action",
+                        "name": "action",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resource type, e.g. machine or machine_conf",
+                        "name": "resource",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Resource ID",
+                        "name": "resource_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start (unix seconds or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End (unix seconds or RFC3339)",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of entries",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of entries to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "ndjson"
+                        ],
+                        "type": "string",
+                        "description": "Output format",
+                        "name": "format",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Retrieves the File Stash URL",
                 "responses": {
                     "200": {
-                        "description": "Retrieved File Stash URL",
+                        "description": "Audit log entries",
                         "schema": {
-                            "$ref": "#/definitions/api.FileStashUrl"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.AuditEntry"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -52,32 +127,51 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
+            }
+        },
+        "/config/{type}/diff": {
+            "get": {
+                "description": "Returns the fields that differ between the versions from and to. Credentials are redacted.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "FileStash"
+                    "ConfigVersions"
                 ],
-                "summary": "Updates the File Stash URL",
+                "summary": "Compares two versions of a config",
                 "parameters": [
                     {
+                        "enum": [
+                            "rabbitmq_config",
+                            "influxdb_config",
+                            "file_stash_url"
+                        ],
                         "type": "string",
-                        "description": "File Stash URL",
-                        "name": "url",
-                        "in": "formData",
+                        "description": "Config type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Old version",
+                        "name": "from",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "New version",
+                        "name": "to",
+                        "in": "query",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated File Stash URL",
+                        "description": "Difference",
                         "schema": {
-                            "$ref": "#/definitions/api.FileStashUrl"
+                            "$ref": "#/definitions/api.ConfigVersionDiff"
                         }
                     },
                     "400": {
@@ -86,6 +180,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -93,32 +199,44 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/config/{type}/rollback/{version}": {
             "post": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
+                "description": "The state of the given version is applied and recorded as new version.\nRolling back to a version in which the config was deleted deletes it.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "FileStash"
+                    "ConfigVersions"
                 ],
-                "summary": "Creates or updates File Stash URL",
+                "summary": "Restores a previous version of a config",
                 "parameters": [
                     {
+                        "enum": [
+                            "rabbitmq_config",
+                            "influxdb_config",
+                            "file_stash_url"
+                        ],
                         "type": "string",
-                        "description": "File Stash URL",
-                        "name": "url",
-                        "in": "formData",
+                        "description": "Config type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version to restore",
+                        "name": "version",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Created/Updated File Stash URL",
+                        "description": "New version",
                         "schema": {
-                            "$ref": "#/definitions/api.FileStashUrl"
+                            "$ref": "#/definitions/repository.ConfigVersion"
                         }
                     },
                     "400": {
@@ -127,6 +245,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -134,18 +264,52 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/config/{type}/versions": {
+            "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "FileStash"
+                    "ConfigVersions"
+                ],
+                "summary": "Lists the version history of a config",
+                "parameters": [
+                    {
+                        "enum": [
+                            "rabbitmq_config",
+                            "influxdb_config",
+                            "file_stash_url"
+                        ],
+                        "type": "string",
+                        "description": "Config type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Deletes the File Stash URL",
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "Versions, newest first",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.ConfigVersion"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     },
                     "500": {
                         "description": "Internal Server Error",
@@ -156,20 +320,53 @@ const docTemplate = `{
                 }
             }
         },
-        "/influxdb_config": {
+        "/config/{type}/versions/{version}": {
             "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "InfluxDB"
+                    "ConfigVersions"
+                ],
+                "summary": "Returns a single version of a config",
+                "parameters": [
+                    {
+                        "enum": [
+                            "rabbitmq_config",
+                            "influxdb_config",
+                            "file_stash_url"
+                        ],
+                        "type": "string",
+                        "description": "Config type",
+                        "name": "type",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Version",
+                        "name": "version",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
-                "summary": "Retrieves the InfluxDB configuration",
                 "responses": {
                     "200": {
-                        "description": "Retrieved InfluxDB configuration",
+                        "description": "Version",
                         "schema": {
-                            "$ref": "#/definitions/api.InfluxdbConfiguration"
+                            "$ref": "#/definitions/repository.ConfigVersion"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "404": {
@@ -185,126 +382,130 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
+            }
+        },
+        "/configuration/": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The value has to match the schema of the key, see /configuration/keys.",
                 "consumes": [
                     "multipart/form-data"
                 ],
                 "produces": [
-                    "application/json"
+                    "text/plain"
                 ],
                 "tags": [
-                    "InfluxDB"
+                    "UIConfig"
                 ],
-                "summary": "Updates the InfluxDB configuration",
+                "summary": "Changes a setting of the web UI for the requesting user",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Type",
-                        "name": "type",
+                        "description": "Key, for per cluster keys optionally suffixed by ':\u003ccluster\u003e'",
+                        "name": "key",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Database Name",
-                        "name": "database_name",
+                        "description": "JSON encoded value",
+                        "name": "value",
                         "in": "formData",
                         "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "success",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
-                    {
-                        "type": "string",
-                        "description": "Host",
-                        "name": "host",
-                        "in": "formData",
-                        "required": true
+                    "422": {
+                        "description": "Unknown key or invalid value",
+                        "schema": {
+                            "type": "string"
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
                     {
-                        "type": "integer",
-                        "description": "Port",
-                        "name": "port",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "User",
-                        "name": "user",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Password",
-                        "name": "password",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Organization",
-                        "name": "organization",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "boolean",
-                        "description": "SSL Enabled",
-                        "name": "ssl_enabled",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Batch Size",
-                        "name": "batch_size",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Retry Interval",
-                        "name": "retry_interval",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Retry Exponential Base",
-                        "name": "retry_exponential_base",
-                        "in": "formData",
-                        "required": true
-                    },
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Resets the given keys of the requesting user, all keys if none are given.",
+                "tags": [
+                    "UIConfig"
+                ],
+                "summary": "Resets settings of the web UI to their defaults",
+                "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Max Retries",
-                        "name": "max_retries",
-                        "in": "formData",
-                        "required": true
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Keys to reset",
+                        "name": "key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
-                    {
-                        "type": "string",
-                        "description": "Max Retry Time",
-                        "name": "max_retry_time",
-                        "in": "formData",
-                        "required": true
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
                     },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/configuration/export": {
+            "get": {
+                "security": [
                     {
-                        "type": "string",
-                        "description": "Meta as Tags",
-                        "name": "meta_as_tags",
-                        "in": "formData"
+                        "ApiKeyAuth": []
                     }
                 ],
+                "description": "Returns the settings the requesting user changed as JSON object, for /configuration/import.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "UIConfig"
+                ],
+                "summary": "Exports the settings of the web UI",
                 "responses": {
                     "200": {
-                        "description": "Updated InfluxDB configuration",
+                        "description": "Settings by key",
                         "schema": {
-                            "$ref": "#/definitions/api.InfluxdbConfiguration"
+                            "type": "object",
+                            "additionalProperties": true
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -316,126 +517,53 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/configuration/import": {
             "post": {
-                "consumes": [
-                    "multipart/form-data"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
-                "produces": [
+                "description": "Replaces all settings of the requesting user by the exported ones, unless merge is set.\nNothing is changed if any key or value is invalid.",
+                "consumes": [
                     "application/json"
                 ],
                 "tags": [
-                    "InfluxDB"
+                    "UIConfig"
                 ],
-                "summary": "Creates or updates InfluxDB configuration",
+                "summary": "Imports settings of the web UI",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Type",
-                        "name": "type",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Database Name",
-                        "name": "database_name",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Host",
-                        "name": "host",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Port",
-                        "name": "port",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "User",
-                        "name": "user",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Password",
-                        "name": "password",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Organization",
-                        "name": "organization",
-                        "in": "formData",
-                        "required": true
+                        "description": "Settings by key",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     },
                     {
                         "type": "boolean",
-                        "description": "SSL Enabled",
-                        "name": "ssl_enabled",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Batch Size",
-                        "name": "batch_size",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Retry Interval",
-                        "name": "retry_interval",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Retry Exponential Base",
-                        "name": "retry_exponential_base",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Max Retries",
-                        "name": "max_retries",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Max Retry Time",
-                        "name": "max_retry_time",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Meta as Tags",
-                        "name": "meta_as_tags",
-                        "in": "formData"
+                        "description": "Keep settings not in the request",
+                        "name": "merge",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Created/Updated InfluxDB configuration",
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
-                            "$ref": "#/definitions/api.InfluxdbConfiguration"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "422": {
+                        "description": "Unknown keys or invalid values",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -447,18 +575,32 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/configuration/keys": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns all keys with description, JSON schema of their values and default.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "InfluxDB"
+                    "UIConfig"
                 ],
-                "summary": "Deletes the InfluxDB configuration",
+                "summary": "Lists the settings of the web UI",
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "UI configuration keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.UIConfigKeyInfo"
+                            }
+                        }
                     },
                     "500": {
                         "description": "Internal Server Error",
@@ -469,64 +611,51 @@ const docTemplate = `{
                 }
             }
         },
-        "/logical_volume": {
-            "post": {
+        "/configuration/profile": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Sets the given settings for every user, replacing their own values of these keys.\nOther settings of the users are kept. Only admins may push profiles.",
                 "consumes": [
-                    "multipart/form-data"
+                    "application/json"
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LogicalVolume"
+                    "UIConfig"
                 ],
-                "summary": "Creates a new logical volume record",
+                "summary": "Applies settings of the web UI to all users",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Logical Volume Name",
-                        "name": "lv_name",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Volume Group Name",
-                        "name": "vg_name",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Logical Volume Attributes",
-                        "name": "lv_attr",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Logical Volume Size",
-                        "name": "lv_size",
-                        "in": "formData",
-                        "required": true
+                        "description": "Settings by key",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created logical volume",
+                    "200": {
+                        "description": "Number of users",
                         "schema": {
-                            "$ref": "#/definitions/api.LogicalVolume"
+                            "$ref": "#/definitions/api.UIConfigProfileResult"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unknown keys or invalid values",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -540,66 +669,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/logical_volume/{lv_id}": {
-            "put": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
+        "/file_stash_url": {
+            "get": {
+                "description": "Credentials are only returned to admins.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LogicalVolume"
-                ],
-                "summary": "Updates a logical volume record",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Logical Volume ID",
-                        "name": "lv_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Logical Volume Name",
-                        "name": "lv_name",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Volume Group Name",
-                        "name": "vg_name",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Logical Volume Attributes",
-                        "name": "lv_attr",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Logical Volume Size",
-                        "name": "lv_size",
-                        "in": "formData",
-                        "required": true
-                    }
+                    "FileStash"
                 ],
+                "summary": "Retrieves the File Stash URL",
                 "responses": {
                     "200": {
-                        "description": "Updated logical volume",
-                        "schema": {
-                            "$ref": "#/definitions/api.LogicalVolume"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                        "description": "Retrieved File Stash URL",
                         "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
+                            "$ref": "#/definitions/api.FileStashUrl"
                         }
                     },
                     "404": {
@@ -616,26 +700,32 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
+            "put": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LogicalVolume"
+                    "FileStash"
                 ],
-                "summary": "Deletes a logical volume record",
+                "summary": "Updates the File Stash URL",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Logical Volume ID",
-                        "name": "lv_id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "File Stash URL",
+                        "name": "url",
+                        "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "204": {
-                        "description": "No Content"
+                    "200": {
+                        "description": "Updated File Stash URL",
+                        "schema": {
+                            "$ref": "#/definitions/api.FileStashUrl"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -650,41 +740,59 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/logical_volumes/{machine_id}": {
-            "get": {
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LogicalVolume"
+                    "FileStash"
                 ],
-                "summary": "Retrieves logical volume records for a machine",
+                "summary": "Creates or updates File Stash URL",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
+                        "description": "File Stash URL",
+                        "name": "url",
+                        "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Retrieved logical volumes",
+                        "description": "Created/Updated File Stash URL",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.LogicalVolume"
-                            }
+                            "$ref": "#/definitions/api.FileStashUrl"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "FileStash"
+                ],
+                "summary": "Deletes the File Stash URL",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
                     },
                     "500": {
                         "description": "Internal Server Error",
@@ -695,8 +803,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/lv_storage_issuer": {
+        "/file_stash_url/test": {
             "post": {
+                "description": "Sends a HEAD request to the URL and retrieves its listing.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -704,63 +813,28 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "LVStorageIssuer"
+                    "FileStash"
                 ],
-                "summary": "Creates a new LV Storage Issuer",
+                "summary": "Tests the File Stash URL",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Increment Buffer",
-                        "name": "inc_buffer",
+                        "description": "File Stash URL, the stored URL is tested if omitted",
+                        "name": "url",
                         "in": "formData"
                     },
                     {
-                        "type": "integer",
-                        "description": "Decrement Buffer",
-                        "name": "dec_buffer",
+                        "type": "boolean",
+                        "description": "Do not verify the TLS certificate",
+                        "name": "insecure_skip_verify",
                         "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Username",
-                        "name": "username",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "number",
-                        "description": "Minimum Available Space in GB",
-                        "name": "minAvailableSpaceGB",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "number",
-                        "description": "Maximum Available Space in GB",
-                        "name": "maxAvailableSpaceGB",
-                        "in": "formData",
-                        "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created LV Storage Issuer",
+                    "200": {
+                        "description": "Diagnostic",
                         "schema": {
-                            "$ref": "#/definitions/api.LvStorageIssuer"
+                            "$ref": "#/definitions/probe.Diagnostic"
                         }
                     },
                     "400": {
@@ -769,6 +843,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -778,78 +864,21 @@ const docTemplate = `{
                 }
             }
         },
-        "/lv_storage_issuer/{id}": {
-            "put": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
+        "/influxdb_config": {
+            "get": {
+                "description": "Credentials are only returned to admins.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LVStorageIssuer"
-                ],
-                "summary": "Updates an LV Storage Issuer",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "LV Storage Issuer ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Increment Buffer",
-                        "name": "inc_buffer",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Decrement Buffer",
-                        "name": "dec_buffer",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Username",
-                        "name": "username",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "number",
-                        "description": "Minimum Available Space in GB",
-                        "name": "minAvailableSpaceGB",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "number",
-                        "description": "Maximum Available Space in GB",
-                        "name": "maxAvailableSpaceGB",
-                        "in": "formData",
-                        "required": true
-                    }
+                    "InfluxDB"
                 ],
+                "summary": "Retrieves the InfluxDB configuration",
                 "responses": {
                     "200": {
-                        "description": "Updated LV Storage Issuer",
-                        "schema": {
-                            "$ref": "#/definitions/api.LvStorageIssuer"
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
+                        "description": "Retrieved InfluxDB configuration",
                         "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
+                            "$ref": "#/definitions/api.InfluxdbConfiguration"
                         }
                     },
                     "404": {
@@ -866,161 +895,125 @@ const docTemplate = `{
                     }
                 }
             },
-            "delete": {
+            "put": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "LVStorageIssuer"
+                    "InfluxDB"
                 ],
-                "summary": "Deletes an LV Storage Issuer",
+                "summary": "Updates the InfluxDB configuration",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "LV Storage Issuer ID",
-                        "name": "id",
-                        "in": "path",
+                        "type": "string",
+                        "description": "Type",
+                        "name": "type",
+                        "in": "formData",
                         "required": true
-                    }
-                ],
-                "responses": {
-                    "204": {
-                        "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
+                    {
+                        "type": "string",
+                        "description": "Database Name",
+                        "name": "database_name",
+                        "in": "formData",
+                        "required": true
                     },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/lv_storage_issuers": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "LVStorageIssuer"
-                ],
-                "summary": "Retrieves all LV Storage Issuers",
-                "responses": {
-                    "200": {
-                        "description": "Retrieved LV Storage Issuers",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.LvStorageIssuer"
-                            }
-                        }
+                    {
+                        "type": "string",
+                        "description": "Host",
+                        "name": "host",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Port",
+                        "name": "port",
+                        "in": "formData",
+                        "required": true
                     },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/machine": {
-            "post": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Machine"
-                ],
-                "summary": "Creates a new machine record",
-                "parameters": [
                     {
                         "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
+                        "description": "User",
+                        "name": "user",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
+                        "description": "Password or secret reference (env:, file:, db:)",
+                        "name": "password",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "OS Version",
-                        "name": "os_version",
+                        "description": "Organization",
+                        "name": "organization",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "SSL Enabled",
+                        "name": "ssl_enabled",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Batch Size",
+                        "name": "batch_size",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "IP Address",
-                        "name": "ip_address",
+                        "description": "Retry Interval",
+                        "name": "retry_interval",
                         "in": "formData",
                         "required": true
-                    }
-                ],
-                "responses": {
-                    "201": {
-                        "description": "Created machine",
-                        "schema": {
-                            "$ref": "#/definitions/api.Machine"
-                        }
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
+                    {
+                        "type": "integer",
+                        "description": "Retry Exponential Base",
+                        "name": "retry_exponential_base",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max Retries",
+                        "name": "max_retries",
+                        "in": "formData",
+                        "required": true
                     },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/machine/{machine_id}": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Machine"
-                ],
-                "summary": "Retrieves a machine record",
-                "parameters": [
                     {
                         "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
+                        "description": "Max Retry Time",
+                        "name": "max_retry_time",
+                        "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Meta as Tags",
+                        "name": "meta_as_tags",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Retrieved machine",
+                        "description": "Updated InfluxDB configuration",
                         "schema": {
-                            "$ref": "#/definitions/api.Machine"
+                            "$ref": "#/definitions/api.InfluxdbConfiguration"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "400": {
+                        "description": "Bad Request",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -1033,7 +1026,7 @@ const docTemplate = `{
                     }
                 }
             },
-            "put": {
+            "post": {
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -1041,44 +1034,113 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Machine"
+                    "InfluxDB"
                 ],
-                "summary": "Updates a machine record",
+                "summary": "Creates or updates InfluxDB configuration",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
-                        "required": true
+                        "description": "Type",
+                        "name": "type",
+                        "in": "formData",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
+                        "description": "Database Name",
+                        "name": "database_name",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "OS Version",
-                        "name": "os_version",
+                        "description": "Host",
+                        "name": "host",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Port",
+                        "name": "port",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "IP Address",
-                        "name": "ip_address",
+                        "description": "User",
+                        "name": "user",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password or secret reference (env:, file:, db:)",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Organization",
+                        "name": "organization",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "SSL Enabled",
+                        "name": "ssl_enabled",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Batch Size",
+                        "name": "batch_size",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Retry Interval",
+                        "name": "retry_interval",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Retry Exponential Base",
+                        "name": "retry_exponential_base",
                         "in": "formData",
                         "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Max Retries",
+                        "name": "max_retries",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Max Retry Time",
+                        "name": "max_retry_time",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Meta as Tags",
+                        "name": "meta_as_tags",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated machine",
+                        "description": "Created/Updated InfluxDB configuration",
                         "schema": {
-                            "$ref": "#/definitions/api.Machine"
+                            "$ref": "#/definitions/api.InfluxdbConfiguration"
                         }
                     },
                     "400": {
@@ -1087,12 +1149,6 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1106,18 +1162,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Machine"
-                ],
-                "summary": "Deletes a machine record",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "InfluxDB"
                 ],
+                "summary": "Deletes the InfluxDB configuration",
                 "responses": {
                     "204": {
                         "description": "No Content"
@@ -1131,81 +1178,31 @@ const docTemplate = `{
                 }
             }
         },
-        "/machine_conf": {
-            "post": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
+        "/influxdb_config/resolved": {
+            "get": {
+                "description": "Secret references in the password are replaced by the secrets they refer to.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "MachineConf"
-                ],
-                "summary": "Creates a new machine configuration",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Username",
-                        "name": "username",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Passphrase",
-                        "name": "passphrase",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Port Number",
-                        "name": "port_number",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Password",
-                        "name": "password",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Host Key",
-                        "name": "host_key",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Folder Path",
-                        "name": "folder_path",
-                        "in": "formData"
-                    }
+                    "InfluxDB"
                 ],
+                "summary": "Retrieves the InfluxDB configuration for its consumers",
                 "responses": {
-                    "201": {
-                        "description": "Created machine configuration",
+                    "200": {
+                        "description": "InfluxDB configuration with resolved credentials",
                         "schema": {
-                            "$ref": "#/definitions/api.MachineConf"
+                            "$ref": "#/definitions/api.InfluxdbConfiguration"
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -1219,8 +1216,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/machine_conf/{id}": {
-            "put": {
+        "/influxdb_config/test": {
+            "post": {
+                "description": "Pings the server, then writes a point to the measurement cc_backend_probe of the bucket (database_name), reads it back and deletes it again.",
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -1228,68 +1226,64 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "MachineConf"
+                    "InfluxDB"
                 ],
-                "summary": "Updates a machine configuration",
+                "summary": "Tests the connection to InfluxDB",
                 "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Machine Configuration ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    },
                     {
                         "type": "string",
-                        "description": "Hostname",
-                        "name": "hostname",
-                        "in": "formData",
-                        "required": true
+                        "description": "Host, the stored configuration is tested if omitted",
+                        "name": "host",
+                        "in": "formData"
                     },
                     {
-                        "type": "string",
-                        "description": "Username",
-                        "name": "username",
-                        "in": "formData",
-                        "required": true
+                        "type": "integer",
+                        "description": "Port",
+                        "name": "port",
+                        "in": "formData"
                     },
                     {
-                        "type": "string",
-                        "description": "Passphrase",
-                        "name": "passphrase",
+                        "type": "boolean",
+                        "description": "SSL Enabled",
+                        "name": "ssl_enabled",
                         "in": "formData"
                     },
                     {
-                        "type": "integer",
-                        "description": "Port Number",
-                        "name": "port_number",
-                        "in": "formData",
-                        "required": true
+                        "type": "string",
+                        "description": "User",
+                        "name": "user",
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "Password",
+                        "description": "Password or API token",
                         "name": "password",
                         "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "Host Key",
-                        "name": "host_key",
+                        "description": "Organization",
+                        "name": "organization",
                         "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "Folder Path",
-                        "name": "folder_path",
+                        "description": "Bucket",
+                        "name": "database_name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Do not verify the TLS certificate",
+                        "name": "insecure_skip_verify",
                         "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated machine configuration",
+                        "description": "Diagnostic",
                         "schema": {
-                            "$ref": "#/definitions/api.MachineConf"
+                            "$ref": "#/definitions/probe.Diagnostic"
                         }
                     },
                     "400": {
@@ -1298,6 +1292,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -1311,70 +1311,34 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
+            }
+        },
+        "/lockouts": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "MachineConf"
-                ],
-                "summary": "Deletes a machine configuration",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Machine Configuration ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/machine_conf/{machine_id}": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "MachineConf"
-                ],
-                "summary": "Retrieves a machine configuration",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
-                        "required": true
-                    }
+                    "Users"
                 ],
+                "summary": "Lists the usernames and source IPs locked after failed logins",
                 "responses": {
                     "200": {
-                        "description": "Retrieved machine configuration",
+                        "description": "Current lockouts",
                         "schema": {
-                            "$ref": "#/definitions/api.MachineConf"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.LoginAttempts"
+                            }
                         }
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -1388,95 +1352,37 @@ const docTemplate = `{
                 }
             }
         },
-        "/machines": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Machine"
-                ],
-                "summary": "Lists all machines",
-                "responses": {
-                    "200": {
-                        "description": "List of machines",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.Machine"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
+        "/lockouts/{kind}/{name}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
                     }
-                }
-            }
-        },
-        "/notifications": {
-            "get": {
-                "produces": [
-                    "application/json"
                 ],
+                "description": "Also forgets the failed logins counted so far.",
                 "tags": [
-                    "Notifications"
+                    "Users"
                 ],
-                "summary": "Retrieves notifications",
+                "summary": "Lifts the lockout of a username or source IP",
                 "parameters": [
                     {
-                        "type": "integer",
-                        "description": "Limit the number of notifications",
-                        "name": "limit",
-                        "in": "query"
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Retrieved notifications",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.Notification"
-                            }
-                        }
+                        "type": "string",
+                        "description": "user or ip",
+                        "name": "kind",
+                        "in": "path",
+                        "required": true
                     },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
-            },
-            "post": {
-                "consumes": [
-                    "multipart/form-data"
-                ],
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Notifications"
-                ],
-                "summary": "Creates a new notification",
-                "parameters": [
                     {
                         "type": "string",
-                        "description": "Notification message",
-                        "name": "message",
-                        "in": "formData",
+                        "description": "Username or IP address",
+                        "name": "name",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created notification",
-                        "schema": {
-                            "$ref": "#/definitions/api.Notification"
-                        }
+                    "204": {
+                        "description": "No Content"
                     },
                     "400": {
                         "description": "Bad Request",
@@ -1484,39 +1390,14 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
-                    }
-                }
-            }
-        },
-        "/notifications/{id}": {
-            "delete": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "Notifications"
-                ],
-                "summary": "Deletes a notification",
-                "parameters": [
-                    {
-                        "type": "integer",
-                        "description": "Notification ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "204": {
-                        "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -1530,7 +1411,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/physical_volume": {
+        "/logical_volume": {
             "post": {
                 "consumes": [
                     "multipart/form-data"
@@ -1539,9 +1420,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PhysicalVolume"
+                    "LogicalVolume"
                 ],
-                "summary": "Creates a new physical volume record",
+                "summary": "Creates a new logical volume record",
                 "parameters": [
                     {
                         "type": "string",
@@ -1552,8 +1433,8 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Physical Volume Name",
-                        "name": "pv_name",
+                        "description": "Logical Volume Name",
+                        "name": "lv_name",
                         "in": "formData",
                         "required": true
                     },
@@ -1566,38 +1447,24 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Physical Volume Format",
-                        "name": "pv_fmt",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Physical Volume Attributes",
-                        "name": "pv_attr",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Physical Volume Size",
-                        "name": "pv_size",
+                        "description": "Logical Volume Attributes",
+                        "name": "lv_attr",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Physical Volume Free Space",
-                        "name": "pv_free",
+                        "description": "Logical Volume Size",
+                        "name": "lv_size",
                         "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created physical volume",
+                        "description": "Created logical volume",
                         "schema": {
-                            "$ref": "#/definitions/api.PhysicalVolume"
+                            "$ref": "#/definitions/api.LogicalVolume"
                         }
                     },
                     "400": {
@@ -1615,7 +1482,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/physical_volume/{pv_id}": {
+        "/logical_volume/{lv_id}": {
             "put": {
                 "consumes": [
                     "multipart/form-data"
@@ -1624,21 +1491,21 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PhysicalVolume"
+                    "LogicalVolume"
                 ],
-                "summary": "Updates a physical volume record",
+                "summary": "Updates a logical volume record",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Physical Volume ID",
-                        "name": "pv_id",
+                        "description": "Logical Volume ID",
+                        "name": "lv_id",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Physical Volume Name",
-                        "name": "pv_name",
+                        "description": "Logical Volume Name",
+                        "name": "lv_name",
                         "in": "formData",
                         "required": true
                     },
@@ -1651,38 +1518,24 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Physical Volume Format",
-                        "name": "pv_fmt",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Physical Volume Attributes",
-                        "name": "pv_attr",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Physical Volume Size",
-                        "name": "pv_size",
+                        "description": "Logical Volume Attributes",
+                        "name": "lv_attr",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Physical Volume Free Space",
-                        "name": "pv_free",
+                        "description": "Logical Volume Size",
+                        "name": "lv_size",
                         "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated physical volume",
+                        "description": "Updated logical volume",
                         "schema": {
-                            "$ref": "#/definitions/api.PhysicalVolume"
+                            "$ref": "#/definitions/api.LogicalVolume"
                         }
                     },
                     "400": {
@@ -1710,14 +1563,14 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "PhysicalVolume"
+                    "LogicalVolume"
                 ],
-                "summary": "Deletes a physical volume record",
+                "summary": "Deletes a logical volume record",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Physical Volume ID",
-                        "name": "pv_id",
+                        "description": "Logical Volume ID",
+                        "name": "lv_id",
                         "in": "path",
                         "required": true
                     }
@@ -1741,15 +1594,15 @@ const docTemplate = `{
                 }
             }
         },
-        "/physical_volumes/{machine_id}": {
+        "/logical_volumes/{machine_id}": {
             "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "PhysicalVolume"
+                    "LogicalVolume"
                 ],
-                "summary": "Retrieves physical volume records for a machine",
+                "summary": "Retrieves logical volume records for a machine",
                 "parameters": [
                     {
                         "type": "string",
@@ -1761,11 +1614,11 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Retrieved physical volumes",
+                        "description": "Retrieved logical volumes",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/api.PhysicalVolume"
+                                "$ref": "#/definitions/api.LogicalVolume"
                             }
                         }
                     },
@@ -1784,20 +1637,68 @@ const docTemplate = `{
                 }
             }
         },
-        "/rabbitmq_config": {
-            "get": {
+        "/lv_requests/{id}": {
+            "put": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "consumes": [
+                    "multipart/form-data"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "RabbitMQ"
+                    "LVRequests"
+                ],
+                "summary": "Reports the progress of an LV request",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Request ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "running",
+                            "done",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "New state",
+                        "name": "status",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Output or error message",
+                        "name": "message",
+                        "in": "formData"
+                    }
                 ],
-                "summary": "Retrieves the RabbitMQ configuration",
                 "responses": {
                     "200": {
-                        "description": "Retrieved RabbitMQ configuration",
+                        "description": "Updated request",
                         "schema": {
-                            "$ref": "#/definitions/api.RabbitMqConfig"
+                            "$ref": "#/definitions/api.LVRequest"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "404": {
@@ -1813,46 +1714,51 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "put": {
-                "consumes": [
-                    "multipart/form-data"
+            }
+        },
+        "/lv_requests/{machine_id}": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "RabbitMQ"
+                    "LVRequests"
                 ],
-                "summary": "Updates the RabbitMQ configuration",
+                "summary": "Lists the LV requests of a machine",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Connection URL",
-                        "name": "conn_url",
-                        "in": "formData",
-                        "required": true
-                    },
-                    {
-                        "type": "string",
-                        "description": "Username",
-                        "name": "username",
-                        "in": "formData",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
                         "required": true
                     },
                     {
+                        "enum": [
+                            "pending",
+                            "running",
+                            "done",
+                            "failed"
+                        ],
                         "type": "string",
-                        "description": "Password",
-                        "name": "password",
-                        "in": "formData",
-                        "required": true
+                        "description": "Only requests in this state",
+                        "name": "status",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated RabbitMQ configuration",
+                        "description": "Requests, oldest first",
                         "schema": {
-                            "$ref": "#/definitions/api.RabbitMqConfig"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.LVRequest"
+                            }
                         }
                     },
                     "400": {
@@ -1861,6 +1767,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1868,7 +1780,9 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/lv_storage_issuer": {
             "post": {
                 "consumes": [
                     "multipart/form-data"
@@ -1877,14 +1791,33 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "RabbitMQ"
+                    "LVStorageIssuer"
                 ],
-                "summary": "Creates or updates RabbitMQ configuration",
+                "summary": "Creates a new LV Storage Issuer",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Connection URL",
-                        "name": "conn_url",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Increment Buffer",
+                        "name": "inc_buffer",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Decrement Buffer",
+                        "name": "dec_buffer",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "formData",
                         "required": true
                     },
@@ -1896,18 +1829,25 @@ const docTemplate = `{
                         "required": true
                     },
                     {
-                        "type": "string",
-                        "description": "Password",
-                        "name": "password",
+                        "type": "number",
+                        "description": "Minimum Available Space in GB",
+                        "name": "minAvailableSpaceGB",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum Available Space in GB",
+                        "name": "maxAvailableSpaceGB",
                         "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Created/Updated RabbitMQ configuration",
+                    "201": {
+                        "description": "Created LV Storage Issuer",
                         "schema": {
-                            "$ref": "#/definitions/api.RabbitMqConfig"
+                            "$ref": "#/definitions/api.LvStorageIssuer"
                         }
                     },
                     "400": {
@@ -1923,30 +1863,10 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "delete": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "RabbitMQ"
-                ],
-                "summary": "Deletes the RabbitMQ configuration",
-                "responses": {
-                    "204": {
-                        "description": "No Content"
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
             }
         },
-        "/realtime_logs": {
-            "post": {
+        "/lv_storage_issuer/{id}": {
+            "put": {
                 "consumes": [
                     "multipart/form-data"
                 ],
@@ -1954,30 +1874,63 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "RealtimeLogs"
+                    "LVStorageIssuer"
                 ],
-                "summary": "Creates a new realtime log",
+                "summary": "Updates an LV Storage Issuer",
                 "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "LV Storage Issuer ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Increment Buffer",
+                        "name": "inc_buffer",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Decrement Buffer",
+                        "name": "dec_buffer",
+                        "in": "formData"
+                    },
                     {
                         "type": "string",
-                        "description": "Log message",
-                        "name": "log_message",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Minimum Available Space in GB",
+                        "name": "minAvailableSpaceGB",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "number",
+                        "description": "Maximum Available Space in GB",
+                        "name": "maxAvailableSpaceGB",
                         "in": "formData",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Created realtime log",
+                    "200": {
+                        "description": "Updated LV Storage Issuer",
                         "schema": {
-                            "$ref": "#/definitions/api.RealtimeLog"
+                            "$ref": "#/definitions/api.LvStorageIssuer"
                         }
                     },
                     "400": {
@@ -1986,6 +1939,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -1993,21 +1952,19 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/realtime_logs/{id}": {
+            },
             "delete": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "RealtimeLogs"
+                    "LVStorageIssuer"
                 ],
-                "summary": "Deletes a realtime log",
+                "summary": "Deletes an LV Storage Issuer",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Realtime Log ID",
+                        "description": "LV Storage Issuer ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -2032,46 +1989,25 @@ const docTemplate = `{
                 }
             }
         },
-        "/realtime_logs/{machine_id}": {
+        "/lv_storage_issuers": {
             "get": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "RealtimeLogs"
-                ],
-                "summary": "Retrieves realtime logs for a machine",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
-                        "required": true
-                    },
-                    {
-                        "type": "integer",
-                        "description": "Limit the number of logs",
-                        "name": "limit",
-                        "in": "query"
-                    }
+                    "LVStorageIssuer"
                 ],
+                "summary": "Retrieves all LV Storage Issuers",
                 "responses": {
                     "200": {
-                        "description": "Retrieved realtime logs",
+                        "description": "Retrieved LV Storage Issuers",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/api.RealtimeLog"
+                                "$ref": "#/definitions/api.LvStorageIssuer"
                             }
                         }
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2081,352 +2017,323 @@ const docTemplate = `{
                 }
             }
         },
-        "/user/{id}": {
+        "/machine": {
             "post": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Modifies user defined by username (id) in one of four possible ways.\nIf more than one formValue is set then only the highest priority field is used.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
                 "consumes": [
                     "multipart/form-data"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Machine"
                 ],
-                "summary": "Updates an existing user",
+                "summary": "Creates a new machine record",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Database ID of User",
-                        "name": "id",
-                        "in": "path",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "formData",
                         "required": true
                     },
                     {
-                        "enum": [
-                            "admin",
-                            "support",
-                            "manager",
-                            "user",
-                            "api"
-                        ],
-                        "type": "string",
-                        "description": "Priority 1: Role to add",
-                        "name": "add-role",
-                        "in": "formData"
-                    },
-                    {
-                        "enum": [
-                            "admin",
-                            "support",
-                            "manager",
-                            "user",
-                            "api"
-                        ],
                         "type": "string",
-                        "description": "Priority 2: Role to remove",
-                        "name": "remove-role",
-                        "in": "formData"
+                        "description": "Hostname",
+                        "name": "hostname",
+                        "in": "formData",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Priority 3: Project to add",
-                        "name": "add-project",
-                        "in": "formData"
+                        "description": "OS Version",
+                        "name": "os_version",
+                        "in": "formData",
+                        "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Priority 4: Project to remove",
-                        "name": "remove-project",
-                        "in": "formData"
+                        "description": "IP Address",
+                        "name": "ip_address",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Success Response Message",
+                    "201": {
+                        "description": "Created machine",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.Machine"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "403": {
-                        "description": "Forbidden",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "422": {
-                        "description": "Unprocessable Entity: The user could not be updated",
-                        "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/users/": {
+        "/machine/{machine_id}": {
             "get": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "Returns a JSON-encoded list of users.\nRequired query-parameter defines if all users or only users with additional special roles are returned.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Machine"
                 ],
-                "summary": "Returns a list of users",
+                "summary": "Retrieves a machine record",
                 "parameters": [
                     {
-                        "type": "boolean",
-                        "description": "If returned list should contain all users or only users with additional special roles",
-                        "name": "not-just-user",
-                        "in": "query",
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of users returned successfully",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.ApiReturnedUser"
-                            }
-                        }
-                    },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
+                        "description": "Retrieved machine",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.Machine"
                         }
                     },
-                    "403": {
-                        "description": "Forbidden",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             },
-            "post": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "User specified in form data will be saved to database.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
+            "put": {
                 "consumes": [
                     "multipart/form-data"
                 ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Machine"
                 ],
-                "summary": "Adds a new user",
+                "summary": "Updates a machine record",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Unique user ID",
-                        "name": "username",
-                        "in": "formData",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "User password",
-                        "name": "password",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "formData",
                         "required": true
                     },
                     {
-                        "enum": [
-                            "admin",
-                            "support",
-                            "manager",
-                            "user",
-                            "api"
-                        ],
                         "type": "string",
-                        "description": "User role",
-                        "name": "role",
+                        "description": "OS Version",
+                        "name": "os_version",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Managed project, required for new manager role user",
-                        "name": "project",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Users name",
-                        "name": "name",
-                        "in": "formData"
-                    },
-                    {
-                        "type": "string",
-                        "description": "Users email",
-                        "name": "email",
-                        "in": "formData"
+                        "description": "IP Address",
+                        "name": "ip_address",
+                        "in": "formData",
+                        "required": true
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Success Response",
+                        "description": "Updated machine",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.Machine"
                         }
                     },
                     "400": {
                         "description": "Bad Request",
                         "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
-                        "schema": {
-                            "type": "string"
-                        }
-                    },
-                    "403": {
-                        "description": "Forbidden",
-                        "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity: creating user failed",
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             },
             "delete": {
-                "security": [
-                    {
-                        "ApiKeyAuth": []
-                    }
-                ],
-                "description": "User defined by username in form data will be deleted from database.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
-                "consumes": [
-                    "multipart/form-data"
-                ],
                 "produces": [
-                    "text/plain"
+                    "application/json"
                 ],
                 "tags": [
-                    "User"
+                    "Machine"
                 ],
-                "summary": "Deletes a user",
+                "summary": "Deletes a machine record",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "User ID to delete",
-                        "name": "username",
-                        "in": "formData",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
                         "required": true
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "User deleted successfully"
+                    "204": {
+                        "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "type": "string"
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
-                    },
-                    "401": {
-                        "description": "Unauthorized",
+                    }
+                }
+            }
+        },
+        "/machine/{machine_id}/groups": {
+            "get": {
+                "description": "Managers may access a machine if one of its groups matches one of their projects.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MachineGroup"
+                ],
+                "summary": "Lists the groups a machine belongs to",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Group names",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
                         }
                     },
                     "403": {
                         "description": "Forbidden",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
-                    "422": {
-                        "description": "Unprocessable Entity: deleting user failed",
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MachineGroup"
+                ],
+                "summary": "Adds a machine to a group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Group (project) name",
+                        "name": "group_name",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/api.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/volume_group/{group_id}": {
+        "/machine/{machine_id}/groups/{group}": {
             "delete": {
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "VolumeGroup"
+                    "MachineGroup"
                 ],
-                "summary": "Deletes a volume group",
+                "summary": "Removes a machine from a group",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Volume Group ID",
-                        "name": "group_id",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Group (project) name",
+                        "name": "group",
                         "in": "path",
                         "required": true
                     }
@@ -2435,14 +2342,8 @@ const docTemplate = `{
                     "204": {
                         "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    },
-                    "404": {
-                        "description": "Not Found",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
@@ -2456,7 +2357,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/volume_groups": {
+        "/machine_conf": {
             "post": {
                 "consumes": [
                     "multipart/form-data"
@@ -2465,9 +2366,9 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "VolumeGroups"
+                    "MachineConf"
                 ],
-                "summary": "Creates a new volume group",
+                "summary": "Creates a new machine configuration",
                 "parameters": [
                     {
                         "type": "string",
@@ -2478,59 +2379,55 @@ const docTemplate = `{
                     },
                     {
                         "type": "string",
-                        "description": "Volume Group Name",
-                        "name": "vg_name",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "PV Count",
-                        "name": "pv_count",
+                        "description": "Username",
+                        "name": "username",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "LV Count",
-                        "name": "lv_count",
-                        "in": "formData",
-                        "required": true
+                        "description": "Passphrase or secret reference (env:, file:, db:)",
+                        "name": "passphrase",
+                        "in": "formData"
                     },
                     {
-                        "type": "string",
-                        "description": "Snap Count",
-                        "name": "snap_count",
+                        "type": "integer",
+                        "description": "Port Number",
+                        "name": "port_number",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "VG Attributes",
-                        "name": "vg_attr",
-                        "in": "formData",
-                        "required": true
+                        "description": "Password or secret reference (env:, file:, db:)",
+                        "name": "password",
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "VG Size",
-                        "name": "vg_size",
-                        "in": "formData",
-                        "required": true
+                        "description": "Host Key",
+                        "name": "host_key",
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "VG Free",
-                        "name": "vg_free",
-                        "in": "formData",
-                        "required": true
+                        "description": "Folder Path",
+                        "name": "folder_path",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created volume group",
+                        "description": "Created machine configuration",
                         "schema": {
-                            "$ref": "#/definitions/api.VolumeGroup"
+                            "$ref": "#/definitions/api.MachineConf"
                         }
                     },
                     "400": {
@@ -2548,44 +2445,7 @@ const docTemplate = `{
                 }
             }
         },
-        "/volume_groups/{machine_id}": {
-            "get": {
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "VolumeGroups"
-                ],
-                "summary": "Retrieves volume groups for a machine",
-                "parameters": [
-                    {
-                        "type": "string",
-                        "description": "Machine ID",
-                        "name": "machine_id",
-                        "in": "path",
-                        "required": true
-                    }
-                ],
-                "responses": {
-                    "200": {
-                        "description": "Retrieved volume groups",
-                        "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/api.VolumeGroup"
-                            }
-                        }
-                    },
-                    "500": {
-                        "description": "Internal Server Error",
-                        "schema": {
-                            "$ref": "#/definitions/api.ErrorResponse"
-                        }
-                    }
-                }
-            }
-        },
-        "/volume_groups/{vg_id}": {
+        "/machine_conf/{id}": {
             "put": {
                 "consumes": [
                     "multipart/form-data"
@@ -2594,72 +2454,68 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "VolumeGroups"
+                    "MachineConf"
                 ],
-                "summary": "Updates a volume group",
+                "summary": "Updates a machine configuration",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Volume Group ID",
-                        "name": "vg_id",
+                        "description": "Machine Configuration ID",
+                        "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "Volume Group Name",
-                        "name": "vg_name",
+                        "description": "Hostname",
+                        "name": "hostname",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "PV Count",
-                        "name": "pv_count",
+                        "description": "Username",
+                        "name": "username",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "LV Count",
-                        "name": "lv_count",
-                        "in": "formData",
-                        "required": true
+                        "description": "Passphrase or secret reference (env:, file:, db:)",
+                        "name": "passphrase",
+                        "in": "formData"
                     },
                     {
-                        "type": "string",
-                        "description": "Snap Count",
-                        "name": "snap_count",
+                        "type": "integer",
+                        "description": "Port Number",
+                        "name": "port_number",
                         "in": "formData",
                         "required": true
                     },
                     {
                         "type": "string",
-                        "description": "VG Attributes",
-                        "name": "vg_attr",
-                        "in": "formData",
-                        "required": true
+                        "description": "Password or secret reference (env:, file:, db:)",
+                        "name": "password",
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "VG Size",
-                        "name": "vg_size",
-                        "in": "formData",
-                        "required": true
+                        "description": "Host Key",
+                        "name": "host_key",
+                        "in": "formData"
                     },
                     {
                         "type": "string",
-                        "description": "VG Free",
-                        "name": "vg_free",
-                        "in": "formData",
-                        "required": true
+                        "description": "Folder Path",
+                        "name": "folder_path",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Updated volume group",
+                        "description": "Updated machine configuration",
                         "schema": {
-                            "$ref": "#/definitions/api.VolumeGroup"
+                            "$ref": "#/definitions/api.MachineConf"
                         }
                     },
                     "400": {
@@ -2668,6 +2524,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/api.ErrorResponse"
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -2675,289 +2537,3310 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        }
-    },
-    "definitions": {
-        "api.ApiReturnedUser": {
-            "type": "object",
-            "properties": {
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MachineConf"
+                ],
+                "summary": "Deletes a machine configuration",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Machine Configuration ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/machine_conf/{machine_id}": {
+            "get": {
+                "description": "Credentials are only returned to admins.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MachineConf"
+                ],
+                "summary": "Retrieves a machine configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retrieved machine configuration",
+                        "schema": {
+                            "$ref": "#/definitions/api.MachineConf"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/machine_conf/{machine_id}/resolved": {
+            "get": {
+                "description": "Secret references in the password and the passphrase are replaced by the secrets they refer to.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "MachineConf"
+                ],
+                "summary": "Retrieves a machine configuration for its consumers",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Machine configuration with resolved credentials",
+                        "schema": {
+                            "$ref": "#/definitions/api.MachineConf"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/machines": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Machine"
+                ],
+                "summary": "Lists all machines",
+                "responses": {
+                    "200": {
+                        "description": "List of machines",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Machine"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Retrieves notifications",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Limit the number of notifications",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retrieved notifications",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.Notification"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Creates a new notification",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Notification message",
+                        "name": "message",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created notification",
+                        "schema": {
+                            "$ref": "#/definitions/api.Notification"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/{id}": {
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Notifications"
+                ],
+                "summary": "Deletes a notification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Notification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/password": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Only for local accounts. All other sessions of the user are ended.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Changes the password of the requesting user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Current password",
+                        "name": "current-password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "New password, has to meet the password policy",
+                        "name": "new-password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden or wrong current password",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Not a local account",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "New password rejected by the policy",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "429": {
+                        "description": "Too many failed attempts",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Password management not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/password-resets": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The link lets the user set a new password once until it expires, earlier links of the user\nbecome invalid. Only for local accounts. Only admins may create links.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Creates a one-time password reset link",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User whose password is reset",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Reset link",
+                        "schema": {
+                            "$ref": "#/definitions/api.PasswordResetLink"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No such user",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Not a local account",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Password management not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/physical_volumes": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PhysicalVolume"
+                ],
+                "summary": "Retrieves physical volume records for a machine",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retrieved physical volumes",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.PhysicalVolume"
+                            }
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PhysicalVolume"
+                ],
+                "summary": "Creates a new physical volume record",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Name",
+                        "name": "pv_name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Volume Group Name",
+                        "name": "vg_name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Format",
+                        "name": "pv_fmt",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Attributes",
+                        "name": "pv_attr",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Size",
+                        "name": "pv_size",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Free Space",
+                        "name": "pv_free",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created physical volume",
+                        "schema": {
+                            "$ref": "#/definitions/api.PhysicalVolume"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/physical_volumes/{pv_id}": {
+            "put": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PhysicalVolume"
+                ],
+                "summary": "Updates a physical volume record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Physical Volume ID",
+                        "name": "pv_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Name",
+                        "name": "pv_name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Volume Group Name",
+                        "name": "vg_name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Format",
+                        "name": "pv_fmt",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Attributes",
+                        "name": "pv_attr",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Size",
+                        "name": "pv_size",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Physical Volume Free Space",
+                        "name": "pv_free",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated physical volume",
+                        "schema": {
+                            "$ref": "#/definitions/api.PhysicalVolume"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "PhysicalVolume"
+                ],
+                "summary": "Deletes a physical volume record",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Physical Volume ID",
+                        "name": "pv_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rabbitmq_config": {
+            "get": {
+                "description": "Credentials are only returned to admins.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RabbitMQ"
+                ],
+                "summary": "Retrieves the RabbitMQ configuration",
+                "responses": {
+                    "200": {
+                        "description": "Retrieved RabbitMQ configuration",
+                        "schema": {
+                            "$ref": "#/definitions/api.RabbitMqConfig"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RabbitMQ"
+                ],
+                "summary": "Updates the RabbitMQ configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Connection URL or secret reference (env:, file:, db:)",
+                        "name": "conn_url",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password or secret reference (env:, file:, db:)",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated RabbitMQ configuration",
+                        "schema": {
+                            "$ref": "#/definitions/api.RabbitMqConfig"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RabbitMQ"
+                ],
+                "summary": "Creates or updates RabbitMQ configuration",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Connection URL or secret reference (env:, file:, db:)",
+                        "name": "conn_url",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password or secret reference (env:, file:, db:)",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Created/Updated RabbitMQ configuration",
+                        "schema": {
+                            "$ref": "#/definitions/api.RabbitMqConfig"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RabbitMQ"
+                ],
+                "summary": "Deletes the RabbitMQ configuration",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rabbitmq_config/resolved": {
+            "get": {
+                "description": "Secret references in the connection URL and the password are replaced by the secrets they refer to.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RabbitMQ"
+                ],
+                "summary": "Retrieves the RabbitMQ configuration for its consumers",
+                "responses": {
+                    "200": {
+                        "description": "RabbitMQ configuration with resolved credentials",
+                        "schema": {
+                            "$ref": "#/definitions/api.RabbitMqConfig"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/rabbitmq_config/test": {
+            "post": {
+                "description": "Performs an AMQP handshake including authentication and opening the virtual host.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RabbitMQ"
+                ],
+                "summary": "Tests the connection to the RabbitMQ broker",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Connection URL, the stored configuration is tested if omitted",
+                        "name": "conn_url",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Password",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Do not verify the TLS certificate",
+                        "name": "insecure_skip_verify",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Diagnostic",
+                        "schema": {
+                            "$ref": "#/definitions/probe.Diagnostic"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/realtime_logs": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RealtimeLogs"
+                ],
+                "summary": "Retrieves realtime logs for a machine",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Limit the number of logs",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retrieved realtime logs",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.RealtimeLog"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RealtimeLogs"
+                ],
+                "summary": "Creates a new realtime log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Log message",
+                        "name": "log_message",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created realtime log",
+                        "schema": {
+                            "$ref": "#/definitions/api.RealtimeLog"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/realtime_logs/{id}": {
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "RealtimeLogs"
+                ],
+                "summary": "Deletes a realtime log",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Realtime Log ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/secrets": {
+            "get": {
+                "description": "Only names and change metadata are returned, never the values.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Secrets"
+                ],
+                "summary": "Lists the secrets stored in the database",
+                "responses": {
+                    "200": {
+                        "description": "Secrets",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.StoredSecret"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/secrets/{name}": {
+            "put": {
+                "description": "The secret can be used as db:\u003cname\u003e reference in place of passwords and tokens.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Secrets"
+                ],
+                "summary": "Stores a secret encrypted in the database",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Secret value",
+                        "name": "value",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Stored secret",
+                        "schema": {
+                            "$ref": "#/definitions/repository.StoredSecret"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "No secrets-key configured",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "tags": [
+                    "Secrets"
+                ],
+                "summary": "Deletes a secret stored in the database",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The session of the request is marked as current. Admins may list the sessions of other users.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Lists the login sessions of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Owner of the sessions (defaults to the requesting user)",
+                        "name": "username",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Sessions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.Session"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Ends all sessions of the requesting user except the current one, e.g. after a password change.\nAdmins may end all sessions of other users.",
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Ends all login sessions of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Owner of the sessions (defaults to the requesting user)",
+                        "name": "username",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The session is logged out with its next request. Admins may end sessions of other users.",
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Ends a login session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Revoked and expired tokens are included. Admins may list the tokens of other users.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tokens"
+                ],
+                "summary": "Lists the API tokens of a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Owner of the tokens (defaults to the requesting user)",
+                        "name": "username",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Tokens",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/repository.ApiToken"
+                            }
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The token is only returned in this response. Its ID can be used to revoke it.\nAdmins may issue tokens for other users.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Tokens"
+                ],
+                "summary": "Issues a named API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Name of the token, e.g. the host of an agent",
+                        "name": "name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated list of scopes (machines:read, machines:write, inventory:write, logs:write, secrets:read, admin)",
+                        "name": "scopes",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Lifetime, e.g. 720h (capped by max-age)",
+                        "name": "expires_in",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Machine the token is bound to, agents with the api role need it to push data",
+                        "name": "machine_id",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Owner of the token (defaults to the requesting user)",
+                        "name": "username",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Issued token",
+                        "schema": {
+                            "$ref": "#/definitions/api.IssuedApiToken"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Token authentication not configured",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/tokens/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The token is rejected from then on. Admins may revoke tokens of other users.",
+                "tags": [
+                    "Tokens"
+                ],
+                "summary": "Revokes an API token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Token ID (jti claim)",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/totp": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Admins may look at the second factor of other users.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TOTP"
+                ],
+                "summary": "Shows whether two-factor authentication is enabled",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User (defaults to the requesting user)",
+                        "name": "username",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Status",
+                        "schema": {
+                            "$ref": "#/definitions/api.TOTPStatus"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Two-factor authentication not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a new secret and its otpauth:// provisioning URI, which can be shown as QR code.\nThe second factor is enabled once a code is confirmed with /totp/confirm.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TOTP"
+                ],
+                "summary": "Starts setting up two-factor authentication",
+                "responses": {
+                    "200": {
+                        "description": "Secret and provisioning URI",
+                        "schema": {
+                            "$ref": "#/definitions/auth.TOTPEnrollment"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Two-factor authentication not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Users have to give a current code. Admins may reset the second factor of other users\nwithout code, e.g. if they lost their device and recovery codes.",
+                "tags": [
+                    "TOTP"
+                ],
+                "summary": "Disables two-factor authentication",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Current code or recovery code, required for the own second factor",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User (defaults to the requesting user)",
+                        "name": "username",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "403": {
+                        "description": "Forbidden or invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not set up",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Two-factor authentication not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/totp/confirm": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Confirms the secret returned by POST /totp with a code of the authenticator app.\nReturns recovery codes, which are only shown once.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TOTP"
+                ],
+                "summary": "Enables two-factor authentication",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Current code of the authenticator app",
+                        "name": "code",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recovery codes",
+                        "schema": {
+                            "$ref": "#/definitions/api.RecoveryCodes"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not set up",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Already enabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Two-factor authentication not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/totp/recovery-codes": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The old recovery codes become invalid. The new ones are only shown once.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "TOTP"
+                ],
+                "summary": "Replaces the recovery codes",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Current code of the authenticator app or a recovery code",
+                        "name": "code",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Recovery codes",
+                        "schema": {
+                            "$ref": "#/definitions/api.RecoveryCodes"
+                        }
+                    },
+                    "403": {
+                        "description": "Invalid code",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not enabled",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Two-factor authentication not available",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/user/{id}": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Modifies user defined by username (id) in one of four possible ways.\nIf more than one formValue is set then only the highest priority field is used.\nUse PATCH to change several fields, including name and email, at once.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Updates an existing user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Database ID of User",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "admin",
+                            "support",
+                            "manager",
+                            "user",
+                            "api"
+                        ],
+                        "type": "string",
+                        "description": "Priority 1: Role to add",
+                        "name": "add-role",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "admin",
+                            "support",
+                            "manager",
+                            "user",
+                            "api"
+                        ],
+                        "type": "string",
+                        "description": "Priority 2: Role to remove",
+                        "name": "remove-role",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Priority 3: Project to add",
+                        "name": "add-project",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Priority 4: Project to remove",
+                        "name": "remove-project",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success Response Message",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity: The user could not be updated",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Fields missing in the body are kept. Roles and projects replace the current ones. Disabled users\ncannot log in, their sessions end and their API tokens are revoked. Only admins may update users.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Updates several fields of a user at once",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Username",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/repository.UserUpdate"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated user",
+                        "schema": {
+                            "$ref": "#/definitions/schema.User"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid roles, projects or password",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/users/": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Returns a JSON-encoded list of users matching all given filters, the number of all matching users is\nreturned in the X-Total-Count header. Use format=csv (or Accept: text/csv) to export the users as CSV.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
+                "produces": [
+                    "application/json",
+                    "text/csv"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Returns a list of users",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only users with additional special roles",
+                        "name": "not-just-user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only users from LDAP (true) or only users not from LDAP (false)",
+                        "name": "via-ldap",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "local",
+                            "ldap",
+                            "token",
+                            "oidc"
+                        ],
+                        "type": "string",
+                        "description": "Comma separated auth sources",
+                        "name": "auth-source",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "admin",
+                            "support",
+                            "manager",
+                            "user",
+                            "api"
+                        ],
+                        "type": "string",
+                        "description": "Role",
+                        "name": "role",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Managed project",
+                        "name": "project",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Case-insensitive part of username, name or email",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "username",
+                            "name",
+                            "email",
+                            "auth-source"
+                        ],
+                        "type": "string",
+                        "description": "Sort field, default username",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "asc",
+                            "desc"
+                        ],
+                        "type": "string",
+                        "description": "Sort order, default asc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum number of users",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of users to skip",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "json",
+                            "csv"
+                        ],
+                        "type": "string",
+                        "description": "Output format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of users returned successfully",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.ApiReturnedUser"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "User specified in form data will be saved to database.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Adds a new user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Unique user ID",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "User password",
+                        "name": "password",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "admin",
+                            "support",
+                            "manager",
+                            "user",
+                            "api"
+                        ],
+                        "type": "string",
+                        "description": "User role",
+                        "name": "role",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Managed project, required for new manager role user",
+                        "name": "project",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Users name",
+                        "name": "name",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Users email",
+                        "name": "email",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Success Response",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity: password rejected by the policy or creating user failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "User defined by username in form data will be deleted from database.\nOnly accessible from IPs registered with apiAllowedIPs configuration option.",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "User"
+                ],
+                "summary": "Deletes a user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID to delete",
+                        "name": "username",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "User deleted successfully"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity: deleting user failed",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/volume_groups": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "VolumeGroups"
+                ],
+                "summary": "Retrieves volume groups for a machine",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Retrieved volume groups",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/api.VolumeGroup"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "VolumeGroups"
+                ],
+                "summary": "Creates a new volume group",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Machine ID",
+                        "name": "machine_id",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Volume Group Name",
+                        "name": "vg_name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "PV Count",
+                        "name": "pv_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "LV Count",
+                        "name": "lv_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Snap Count",
+                        "name": "snap_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "VG Attributes",
+                        "name": "vg_attr",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "VG Size",
+                        "name": "vg_size",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "VG Free",
+                        "name": "vg_free",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created volume group",
+                        "schema": {
+                            "$ref": "#/definitions/api.VolumeGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/volume_groups/{vg_id}": {
+            "put": {
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "VolumeGroups"
+                ],
+                "summary": "Updates a volume group",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Volume Group ID",
+                        "name": "vg_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Volume Group Name",
+                        "name": "vg_name",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "PV Count",
+                        "name": "pv_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "LV Count",
+                        "name": "lv_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Snap Count",
+                        "name": "snap_count",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "VG Attributes",
+                        "name": "vg_attr",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "VG Size",
+                        "name": "vg_size",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "VG Free",
+                        "name": "vg_free",
+                        "in": "formData",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated volume group",
+                        "schema": {
+                            "$ref": "#/definitions/api.VolumeGroup"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "VolumeGroup"
+                ],
+                "summary": "Deletes a volume group",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Volume Group ID",
+                        "name": "vg_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/api.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.ApiReturnedUser": {
+            "type": "object",
+            "properties": {
                 "email": {
                     "type": "string"
                 },
-                "name": {
+                "name": {
+                    "type": "string"
+                },
+                "projects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ConfigVersionDiff": {
+            "type": "object",
+            "properties": {
+                "configType": {
+                    "type": "string"
+                },
+                "diff": {
+                    "type": "object"
+                },
+                "from": {
+                    "type": "integer"
+                },
+                "to": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "description": "Error Message",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "Statustext of Errorcode",
+                    "type": "string"
+                }
+            }
+        },
+        "api.FileStashUrl": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.InfluxdbConfiguration": {
+            "type": "object",
+            "properties": {
+                "batch_size": {
+                    "type": "integer"
+                },
+                "database_name": {
+                    "type": "string"
+                },
+                "host": {
                     "type": "string"
                 },
-                "projects": {
+                "max_retries": {
+                    "type": "integer"
+                },
+                "max_retry_time": {
+                    "type": "string"
+                },
+                "meta_as_tags": {
+                    "type": "string"
+                },
+                "organization": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "port": {
+                    "type": "integer"
+                },
+                "retry_exponential_base": {
+                    "type": "integer"
+                },
+                "retry_interval": {
+                    "type": "string"
+                },
+                "ssl_enabled": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "user": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.IssuedApiToken": {
+            "type": "object",
+            "properties": {
+                "info": {
+                    "$ref": "#/definitions/repository.ApiToken"
+                },
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.LVRequest": {
+            "type": "object",
+            "properties": {
+                "filesystem": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "lvName": {
+                    "type": "string"
+                },
+                "machineId": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                },
+                "operation": {
+                    "type": "string",
+                    "enum": [
+                        "create",
+                        "extend"
+                    ]
+                },
+                "requestedAt": {
+                    "type": "string"
+                },
+                "requestedBy": {
+                    "type": "string"
+                },
+                "sizeGB": {
+                    "type": "number"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "pending",
+                        "running",
+                        "done",
+                        "failed"
+                    ]
+                },
+                "updatedAt": {
+                    "type": "string"
+                },
+                "vgName": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.LogicalVolume": {
+            "type": "object",
+            "properties": {
+                "lv_attr": {
+                    "type": "string"
+                },
+                "lv_name": {
+                    "type": "string"
+                },
+                "lv_size": {
+                    "type": "string"
+                },
+                "machine_id": {
+                    "type": "string"
+                },
+                "vg_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.LvStorageIssuer": {
+            "type": "object",
+            "properties": {
+                "dec_buffer": {
+                    "type": "integer"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "inc_buffer": {
+                    "type": "integer"
+                },
+                "machine_id": {
+                    "type": "string"
+                },
+                "max_available_space_gb": {
+                    "type": "number"
+                },
+                "min_available_space_gb": {
+                    "type": "number"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.Machine": {
+            "type": "object",
+            "properties": {
+                "hostname": {
+                    "type": "string"
+                },
+                "ip_address": {
+                    "type": "string"
+                },
+                "os_version": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.MachineConf": {
+            "type": "object",
+            "properties": {
+                "folder_path": {
+                    "type": "string"
+                },
+                "host_key": {
+                    "type": "string"
+                },
+                "hostname": {
+                    "type": "string"
+                },
+                "machine_id": {
+                    "type": "string"
+                },
+                "passphrase": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "port_number": {
+                    "type": "integer"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.Notification": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.PasswordResetLink": {
+            "type": "object",
+            "properties": {
+                "expiresAt": {
+                    "type": "integer"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.PhysicalVolume": {
+            "type": "object",
+            "properties": {
+                "machine_id": {
+                    "type": "string"
+                },
+                "pv_attr": {
+                    "type": "string"
+                },
+                "pv_fmt": {
+                    "type": "string"
+                },
+                "pv_free": {
+                    "type": "string"
+                },
+                "pv_name": {
+                    "type": "string"
+                },
+                "pv_size": {
+                    "type": "string"
+                },
+                "vg_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.RabbitMqConfig": {
+            "type": "object",
+            "properties": {
+                "conn_url": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.RealtimeLog": {
+            "type": "object",
+            "properties": {
+                "log_message": {
+                    "type": "string"
+                },
+                "machine_id": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.RecoveryCodes": {
+            "type": "object",
+            "properties": {
+                "recoveryCodes": {
                     "type": "array",
                     "items": {
                         "type": "string"
                     }
+                }
+            }
+        },
+        "api.TOTPStatus": {
+            "type": "object",
+            "properties": {
+                "enabled": {
+                    "type": "boolean"
+                },
+                "enabledAt": {
+                    "description": "0 if not enabled",
+                    "type": "integer"
                 },
-                "roles": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
+                "recoveryCodesLeft": {
+                    "type": "integer"
+                },
+                "required": {
+                    "description": "required for one of the roles of the user",
+                    "type": "boolean"
                 },
                 "username": {
                     "type": "string"
                 }
             }
         },
-        "api.ErrorResponse": {
+        "api.UIConfigKeyInfo": {
             "type": "object",
             "properties": {
-                "error": {
-                    "description": "Error Message",
+                "default": {
+                    "type": "object"
+                },
+                "description": {
                     "type": "string"
                 },
-                "status": {
-                    "description": "Statustext of Errorcode",
+                "name": {
                     "type": "string"
+                },
+                "perCluster": {
+                    "description": "The key can also be set for a single cluster as \"\u003cname\u003e:\u003ccluster\u003e\"",
+                    "type": "boolean"
+                },
+                "schema": {
+                    "description": "JSON schema of the value",
+                    "type": "object"
                 }
             }
         },
-        "api.FileStashUrl": {
+        "api.UIConfigProfileResult": {
             "type": "object",
             "properties": {
-                "url": {
-                    "type": "string"
+                "users": {
+                    "type": "integer"
                 }
             }
         },
-        "api.InfluxdbConfiguration": {
+        "api.VolumeGroup": {
             "type": "object",
             "properties": {
-                "batch_size": {
-                    "type": "integer"
+                "lv_count": {
+                    "type": "string"
                 },
-                "database_name": {
+                "machine_id": {
                     "type": "string"
                 },
-                "host": {
+                "pv_count": {
                     "type": "string"
                 },
-                "max_retries": {
-                    "type": "integer"
+                "snap_count": {
+                    "type": "string"
                 },
-                "max_retry_time": {
+                "vg_attr": {
                     "type": "string"
                 },
-                "meta_as_tags": {
+                "vg_free": {
                     "type": "string"
                 },
-                "organization": {
+                "vg_name": {
                     "type": "string"
                 },
-                "password": {
+                "vg_size": {
+                    "type": "string"
+                }
+            }
+        },
+        "auth.TOTPEnrollment": {
+            "type": "object",
+            "properties": {
+                "secret": {
                     "type": "string"
                 },
-                "port": {
-                    "type": "integer"
+                "uri": {
+                    "type": "string"
+                }
+            }
+        },
+        "probe.Diagnostic": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
                 },
-                "retry_exponential_base": {
-                    "type": "integer"
+                "latencyMs": {
+                    "type": "number"
                 },
-                "retry_interval": {
+                "ok": {
+                    "type": "boolean"
+                },
+                "steps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/probe.Step"
+                    }
+                },
+                "target": {
                     "type": "string"
                 },
-                "ssl_enabled": {
-                    "type": "boolean"
+                "tls": {
+                    "$ref": "#/definitions/probe.TLSInfo"
+                }
+            }
+        },
+        "probe.Step": {
+            "type": "object",
+            "properties": {
+                "detail": {
+                    "type": "string"
                 },
-                "type": {
+                "error": {
                     "type": "string"
                 },
-                "user": {
+                "latencyMs": {
+                    "type": "number"
+                },
+                "name": {
                     "type": "string"
+                },
+                "ok": {
+                    "type": "boolean"
                 }
             }
         },
-        "api.LogicalVolume": {
+        "probe.TLSInfo": {
             "type": "object",
             "properties": {
-                "lv_attr": {
+                "cipherSuite": {
                     "type": "string"
                 },
-                "lv_name": {
+                "issuer": {
                     "type": "string"
                 },
-                "lv_size": {
+                "notAfter": {
                     "type": "string"
                 },
-                "machine_id": {
+                "serverName": {
                     "type": "string"
                 },
-                "vg_name": {
+                "subject": {
+                    "type": "string"
+                },
+                "verified": {
+                    "type": "boolean"
+                },
+                "version": {
                     "type": "string"
                 }
             }
         },
-        "api.LvStorageIssuer": {
+        "repository.ApiToken": {
             "type": "object",
             "properties": {
-                "dec_buffer": {
+                "createdAt": {
                     "type": "integer"
                 },
-                "hostname": {
+                "expiresAt": {
+                    "description": "0 if the token does not expire",
+                    "type": "integer"
+                },
+                "id": {
                     "type": "string"
                 },
-                "inc_buffer": {
+                "lastUsedAt": {
+                    "description": "0 if never used",
                     "type": "integer"
                 },
-                "machine_id": {
+                "machineId": {
+                    "description": "machine an agent token is bound to",
                     "type": "string"
                 },
-                "max_available_space_gb": {
-                    "type": "number"
+                "name": {
+                    "type": "string"
                 },
-                "min_available_space_gb": {
-                    "type": "number"
+                "revokedAt": {
+                    "description": "0 if not revoked",
+                    "type": "integer"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
                 "username": {
                     "type": "string"
                 }
             }
         },
-        "api.Machine": {
+        "repository.AuditEntry": {
             "type": "object",
             "properties": {
-                "hostname": {
+                "action": {
                     "type": "string"
                 },
-                "ip_address": {
+                "actor": {
                     "type": "string"
                 },
-                "machine_id": {
+                "authType": {
                     "type": "string"
                 },
-                "os_version": {
+                "createdAt": {
+                    "type": "integer"
+                },
+                "diff": {
+                    "type": "object"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "resource": {
+                    "type": "string"
+                },
+                "resourceId": {
+                    "type": "string"
+                },
+                "sourceIp": {
                     "type": "string"
                 }
             }
         },
-        "api.MachineConf": {
+        "repository.ConfigVersion": {
             "type": "object",
             "properties": {
-                "folder_path": {
-                    "type": "string"
-                },
-                "host_key": {
+                "action": {
                     "type": "string"
                 },
-                "hostname": {
+                "configType": {
                     "type": "string"
                 },
-                "machine_id": {
-                    "type": "string"
+                "createdAt": {
+                    "type": "integer"
                 },
-                "passphrase": {
+                "createdBy": {
                     "type": "string"
                 },
-                "password": {
-                    "type": "string"
+                "data": {
+                    "type": "object"
                 },
-                "port_number": {
+                "version": {
                     "type": "integer"
-                },
-                "username": {
-                    "type": "string"
                 }
             }
         },
-        "api.Notification": {
+        "repository.LoginAttempts": {
             "type": "object",
             "properties": {
-                "created_at": {
-                    "type": "string"
+                "failures": {
+                    "type": "integer"
                 },
-                "id": {
+                "kind": {
                     "type": "string"
                 },
-                "message": {
+                "lastFailure": {
+                    "type": "integer"
+                },
+                "lockedUntil": {
+                    "description": "0 if not locked",
+                    "type": "integer"
+                },
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "api.PhysicalVolume": {
+        "repository.Session": {
             "type": "object",
             "properties": {
-                "machine_id": {
-                    "type": "string"
+                "createdAt": {
+                    "type": "integer"
                 },
-                "pv_attr": {
-                    "type": "string"
+                "current": {
+                    "description": "the session of the request, not stored",
+                    "type": "boolean"
                 },
-                "pv_fmt": {
-                    "type": "string"
+                "expiresAt": {
+                    "description": "0 if only the idle timeout applies",
+                    "type": "integer"
                 },
-                "pv_free": {
+                "id": {
                     "type": "string"
                 },
-                "pv_name": {
+                "lastActivity": {
+                    "type": "integer"
+                },
+                "projects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "description": "Roles and projects at login, used for users not stored in the user\ntable, e.g. those of OIDC without sync",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "sourceIp": {
                     "type": "string"
                 },
-                "pv_size": {
+                "userAgent": {
                     "type": "string"
                 },
-                "vg_name": {
+                "username": {
                     "type": "string"
                 }
             }
         },
-        "api.RabbitMqConfig": {
+        "repository.StoredSecret": {
             "type": "object",
             "properties": {
-                "conn_url": {
-                    "type": "string"
+                "createdAt": {
+                    "type": "integer"
                 },
-                "password": {
+                "name": {
                     "type": "string"
                 },
-                "username": {
+                "updatedAt": {
+                    "type": "integer"
+                },
+                "updatedBy": {
                     "type": "string"
                 }
             }
         },
-        "api.RealtimeLog": {
+        "repository.UserUpdate": {
             "type": "object",
             "properties": {
-                "log_message": {
+                "authSource": {
+                    "type": "integer"
+                },
+                "disabled": {
+                    "type": "boolean"
+                },
+                "email": {
                     "type": "string"
                 },
-                "machine_id": {
+                "name": {
                     "type": "string"
                 },
-                "timestamp": {
+                "password": {
+                    "description": "Plain text, stored as hash. An empty password makes local login\nimpossible.",
                     "type": "string"
+                },
+                "projects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
-        "api.VolumeGroup": {
+        "schema.AuthSource": {
+            "type": "integer",
+            "enum": [
+                0,
+                1,
+                2,
+                3,
+                4
+            ],
+            "x-enum-varnames": [
+                "AuthViaLocalPassword",
+                "AuthViaLDAP",
+                "AuthViaToken",
+                "AuthViaOIDC",
+                "AuthViaAll"
+            ]
+        },
+        "schema.AuthType": {
+            "type": "integer",
+            "enum": [
+                0,
+                1
+            ],
+            "x-enum-varnames": [
+                "AuthToken",
+                "AuthSession"
+            ]
+        },
+        "schema.User": {
             "type": "object",
             "properties": {
-                "lv_count": {
-                    "type": "string"
+                "authSource": {
+                    "$ref": "#/definitions/schema.AuthSource"
                 },
-                "machine_id": {
-                    "type": "string"
+                "authType": {
+                    "$ref": "#/definitions/schema.AuthType"
                 },
-                "pv_count": {
-                    "type": "string"
+                "disabled": {
+                    "description": "Disabled users cannot log in, e.g. after deprovisioning by an\nidentity provider",
+                    "type": "boolean"
                 },
-                "snap_count": {
+                "email": {
                     "type": "string"
                 },
-                "vg_attr": {
+                "machine": {
+                    "description": "Machine the token the user authenticated with is bound to, agents\nwith the api role may only push data for that machine",
                     "type": "string"
                 },
-                "vg_free": {
+                "name": {
                     "type": "string"
                 },
-                "vg_name": {
-                    "type": "string"
+                "projects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 },
-                "vg_size": {
+                "roles": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "scopes": {
+                    "description": "Scopes of the token the user authenticated with, empty if the token\nis not restricted or the user did not authenticate with a token",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "username": {
                     "type": "string"
                 }
             }
@@ -10,7 +10,7 @@ import (
 	"database/sql"
 
 	// "encoding/json"
-	// "errors"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -21,6 +21,7 @@ import (
 
 	// "runtime"
 	"runtime/debug"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
@@ -28,13 +29,13 @@ import (
 
 	// "github.com/99designs/gqlgen/graphql/handler"
 	// "github.com/99designs/gqlgen/graphql/handler"
-	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/Deepbinder-main/cc-backend/internal/api"
 	"github.com/Deepbinder-main/cc-backend/internal/auth"
 	"github.com/Deepbinder-main/cc-backend/internal/config"
 	"github.com/Deepbinder-main/cc-backend/internal/graph"
-	"github.com/Deepbinder-main/cc-backend/internal/graph/generated"
+	"github.com/Deepbinder-main/cc-backend/internal/health"
 
 	// "github.com/Deepbinder-main/cc-backend/internal/graph"
 	// "github.com/Deepbinder-main/cc-backend/internal/graph/generated"
@@ -48,9 +49,11 @@ import (
 	// "github.com/Deepbinder-main/cc-backend/internal/graph"
 	// "github.com/Deepbinder-main/cc-backend/internal/importer"
 	"github.com/Deepbinder-main/cc-backend/internal/metricdata"
+	"github.com/Deepbinder-main/cc-backend/internal/metrics"
 	"github.com/Deepbinder-main/cc-backend/internal/repository"
-	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
 	"github.com/Deepbinder-main/cc-backend/internal/routerConfig"
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
 	"github.com/Deepbinder-main/cc-backend/internal/util"
 	"github.com/Deepbinder-main/cc-backend/pkg/archive"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
@@ -62,6 +65,7 @@ import (
 	"github.com/google/gops/agent"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	httpSwagger "github.com/swaggo/http-swagger"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -85,6 +89,9 @@ JWT_PRIVATE_KEY="dtPC/6dWJFKZK7KZ78CvWuynylOmjBFyMsUWArwmodOTN9itjL5POlqdZkcnmpJ
 
 # Some random bytes used as secret for cookie-based sessions (DO NOT USE THIS ONE IN PRODUCTION)
 SESSION_KEY="67d829bf61dc5f87a73fd814e2c9f629"
+
+# Base64 encoded 32 byte key encrypting secrets stored in the database (DO NOT USE THIS ONE IN PRODUCTION)
+CC_SECRETS_KEY="YlcBg3/lBtQpAbpwqbBUKXMR2UOeNERcWWm18xo3tXU="
 `
 
 const configString = `
@@ -178,9 +185,59 @@ func init() {
 	repository.Connect(config.Keys.DBDriver, config.Keys.DB)
 }
 
+// loginFailureStatus tells clients of throttled logins to retry later.
+func loginFailureStatus(err error) int {
+	if errors.Is(err, auth.ErrLoginThrottled) {
+		return http.StatusTooManyRequests
+	}
+	return http.StatusUnauthorized
+}
+
+// loginPageInfos adds what the login page needs to ask for the second
+// factor after a failed login.
+func loginPageInfos(info map[string]interface{}, err error) map[string]interface{} {
+	var enrollment *auth.TOTPEnrollmentError
+	if !errors.As(err, &enrollment) && !errors.Is(err, auth.ErrTOTPRequired) && !errors.Is(err, auth.ErrTOTPInvalid) {
+		return info
+	}
+
+	infos := withInfo(info, "totpRequired", true)
+	if enrollment != nil {
+		infos["totpEnrollment"] = enrollment.Enrollment
+	}
+	return infos
+}
+
+// withInfo returns a copy of the page infos with key set to value.
+func withInfo(info map[string]interface{}, key string, value interface{}) map[string]interface{} {
+	infos := make(map[string]interface{}, len(info)+1)
+	for k, v := range info {
+		infos[k] = v
+	}
+	infos[key] = value
+	return infos
+}
+
+// websocketAuthenticator verifies the JWTs GraphQL subscriptions pass in
+// their init payload, or else the credentials of the upgrade request.
+func websocketAuthenticator(authentication *auth.Authentication) graph.WebsocketAuthFunc {
+	return func(ctx context.Context, token string) (*schema.User, error) {
+		if authentication == nil {
+			return nil, errors.New("authentication is not configured")
+		}
+		if token == "" {
+			return authentication.WebsocketUser(ctx)
+		}
+		if authentication.JwtAuth == nil {
+			return nil, errors.New("JWT authentication is not configured")
+		}
+		return authentication.JwtAuth.AuthViaToken(token)
+	}
+}
+
 func main() {
-	var flagReinitDB, flagInit, flagServer, flagSyncLDAP, flagGops, flagMigrateDB, flagRevertDB, flagForceDB, flagDev, flagVersion, flagLogDateTime bool
-	var flagNewUser, flagDelUser, flagGenJWT, flagConfigFile, flagImportJob, flagLogLevel string
+	var flagReinitDB, flagInit, flagServer, flagSyncLDAP, flagGops, flagMigrateDB, flagRevertDB, flagForceDB, flagDev, flagVersion, flagLogDateTime, flagRotateJWTKeys bool
+	var flagNewUser, flagDelUser, flagGenJWT, flagJWTScopes, flagJWTMachine, flagConfigFile, flagImportJob, flagLogLevel string
 	flag.BoolVar(&flagInit, "init", false, "Setup var directory, initialize swlite database file, config.json and .env")
 	flag.BoolVar(&flagReinitDB, "init-db", false, "Go through job-archive and re-initialize the 'job', 'tag', and 'jobtag' tables (all running jobs will be lost!)")
 	flag.BoolVar(&flagSyncLDAP, "sync-ldap", false, "Sync the 'user' table with ldap")
@@ -192,10 +249,13 @@ func main() {
 	flag.BoolVar(&flagRevertDB, "revert-db", false, "Migrate database to previous version and exit")
 	flag.BoolVar(&flagForceDB, "force-db", false, "Force database version, clear dirty flag and exit")
 	flag.BoolVar(&flagLogDateTime, "logdate", false, "Set this flag to add date and time to log messages")
+	flag.BoolVar(&flagRotateJWTKeys, "rotate-jwt-keys", false, "Add a new JWT signing key to the key set file (jwts.keysFile) and exit, tokens signed with previous keys stay valid")
 	flag.StringVar(&flagConfigFile, "config", "./config.json", "Specify alternative path to `config.json`")
 	flag.StringVar(&flagNewUser, "add-user", "", "Add a new user. Argument format: `<username>:[admin,support,manager,api,user]:<password>`")
 	flag.StringVar(&flagDelUser, "del-user", "", "Remove user by `username`")
 	flag.StringVar(&flagGenJWT, "jwt", "", "Generate and print a JWT for the user specified by its `username`")
+	flag.StringVar(&flagJWTScopes, "jwt-scopes", "", "Restrict the JWT generated with --jwt to the given `scopes`: [machines:read,machines:write,inventory:write,logs:write,secrets:read,admin]")
+	flag.StringVar(&flagJWTMachine, "jwt-machine", "", "Bind the JWT generated with --jwt to the machine with this `id`, so that an agent with the api role can push data for it")
 	flag.StringVar(&flagImportJob, "import-job", "", "Import a job. Argument format: `<path-to-meta.json>:<path-to-data.json>,...`")
 	flag.StringVar(&flagLogLevel, "loglevel", "warn", "Sets the logging level: `[debug,info,warn (default),err,fatal,crit]`")
 	flag.Parse()
@@ -236,12 +296,54 @@ func main() {
 	// The order here is important!
 	config.Init(flagConfigFile)
 
-	// As a special case for `db`, allow using an environment variable instead of the value
-	// stored in the config. This can be done for people having security concerns about storing
-	// the password for their mysql database in config.json.
+	if err := util.TrustProxies(config.Keys.TrustedProxies); err != nil {
+		log.Fatalf("parsing 'trusted-proxies' failed: %s", err.Error())
+	}
+	// Behind a reverse proxy, apiAllowedIPs would only ever see the proxy
+	if len(config.Keys.TrustedProxies) == 0 && len(config.Keys.ApiAllowedIPs) > 0 &&
+		!slices.Contains(config.Keys.ApiAllowedIPs, "*") {
+		log.Warn("'trusted-proxies' is not set: X-Forwarded-For and X-Real-Ip headers are ignored, " +
+			"so behind a reverse proxy 'apiAllowedIPs' checks the address of the proxy")
+	}
+
+	// Allow an `env:` reference instead of the value stored in the config.
+	// This can be done for people having security concerns about storing the password
+	// for their mysql database in config.json. Other schemes are not resolved, a
+	// sqlite3 URI like `file:./var/job.db?...` is a filename and no secret reference.
 	if strings.HasPrefix(config.Keys.DB, "env:") {
-		envvar := strings.TrimPrefix(config.Keys.DB, "env:")
-		config.Keys.DB = os.Getenv(envvar)
+		if db, err := secrets.Resolve(context.Background(), config.Keys.DB); err != nil {
+			log.Fatalf("resolving 'db' failed: %s", err.Error())
+		} else {
+			config.Keys.DB = db
+		}
+	}
+
+	// Before the database connection, so that the SQL hooks are installed
+	if err := tracing.Init(config.Keys.Tracing); err != nil {
+		log.Fatalf("initializing tracing failed: %s", err.Error())
+	}
+
+	if flagRotateJWTKeys {
+		if config.Keys.JwtConfig == nil || config.Keys.JwtConfig.KeysFile == "" {
+			log.Fatal("rotating JWT keys requires the jwts.keysFile config key")
+		}
+
+		// Tokens signed by a retired key expire within max-age at the latest
+		var keep time.Duration
+		if config.Keys.JwtConfig.MaxAge != "" {
+			d, err := time.ParseDuration(config.Keys.JwtConfig.MaxAge)
+			if err != nil {
+				log.Fatalf("cannot parse max-age config key: %v", err)
+			}
+			keep = d
+		}
+
+		key, err := auth.RotateJWTKeys(config.Keys.JwtConfig.KeysFile, keep)
+		if err != nil {
+			log.Fatalf("rotating JWT keys failed: %v", err)
+		}
+		fmt.Printf("MAIN > New JWT signing key: %s\n", key.Kid)
+		os.Exit(0)
 	}
 
 	if flagMigrateDB {
@@ -272,7 +374,33 @@ func main() {
 	// repository.Connect("mysql", "root:my-secret-pw@(127.0.0.1:3306)/cockpit")
 
 	db := repository.GetConnection()
-	queries := sqlcdb.New(db.DB)
+
+	// Limit the env: and file: references admins can save via the API
+	refPolicy := secrets.Policy{EnvPrefix: secrets.DefaultEnvPrefix}
+	if conf := config.Keys.SecretReferences; conf != nil {
+		if conf.EnvPrefix != "" {
+			refPolicy.EnvPrefix = conf.EnvPrefix
+		}
+		refPolicy.FileDir = conf.FileDir
+	}
+	secrets.Restrict(refPolicy)
+
+	// Enable `db:` secret references if an encryption key is available
+	if key, err := secrets.Resolve(context.Background(), config.Keys.SecretsKey); err != nil && !errors.Is(err, secrets.ErrNotFound) {
+		log.Fatalf("resolving 'secrets-key' failed: %s", err.Error())
+	} else if key == "" {
+		log.Info("no secrets-key configured, db: secret references are disabled")
+	} else {
+		rawKey, err := secrets.ParseKey(key)
+		if err != nil {
+			log.Fatal(err)
+		}
+		provider, err := secrets.NewDBProvider(db.DB, rawKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		secrets.UseDB(provider)
+	}
 
 	var authentication *auth.Authentication
 	if !config.Keys.DisableAuthentication {
@@ -281,8 +409,15 @@ func main() {
 			log.Fatalf("auth initialization failed: %v", err)
 		}
 
-		if d, err := time.ParseDuration(config.Keys.SessionMaxAge); err != nil {
+		if d, err := time.ParseDuration(config.Keys.SessionMaxAge); err == nil {
 			authentication.SessionMaxAge = d
+		} else if config.Keys.SessionMaxAge != "" {
+			log.Fatalf("invalid session-max-age: %v", err)
+		}
+		if d, err := time.ParseDuration(config.Keys.SessionIdleTimeout); err == nil {
+			authentication.SessionIdleTimeout = d
+		} else if config.Keys.SessionIdleTimeout != "" {
+			log.Fatalf("invalid session-idle-timeout: %v", err)
 		}
 
 		if flagNewUser != "" {
@@ -291,6 +426,12 @@ func main() {
 				log.Fatal("invalid argument format for user creation")
 			}
 
+			if parts[2] != "" {
+				if err := authentication.PasswordPolicy.Check(parts[0], parts[2]); err != nil {
+					log.Fatalf("adding '%s' user authentication failed: %v", parts[0], err)
+				}
+			}
+
 			ur := repository.GetUserRepository()
 			if err := ur.AddUser(&schema.User{
 				Username: parts[0], Projects: make([]string, 0), Password: parts[2], Roles: strings.Split(parts[1], ","),
@@ -310,10 +451,11 @@ func main() {
 				log.Fatal("cannot sync: LDAP authentication is not configured")
 			}
 
-			if err := authentication.LdapAuth.Sync(); err != nil {
+			report, err := authentication.LdapAuth.Sync()
+			if err != nil {
 				log.Fatalf("LDAP sync failed: %v", err)
 			}
-			log.Info("LDAP sync successfull")
+			log.Infof("LDAP sync successfull: %s", report)
 		}
 
 		if flagGenJWT != "" {
@@ -327,7 +469,8 @@ func main() {
 				log.Warnf("user '%s' does not have the API role", user.Username)
 			}
 
-			jwt, err := authentication.JwtAuth.ProvideJWT(user)
+			jwt, _, err := authentication.JwtAuth.IssueToken(context.Background(), repository.GetConnection().DB, user,
+				auth.TokenOptions{Name: "default", Scopes: auth.ParseScopes(flagJWTScopes), Machine: flagJWTMachine})
 			if err != nil {
 				log.Fatalf("failed to provide JWT to user '%s': %v", user.Username, err)
 			}
@@ -364,15 +507,19 @@ func main() {
 	}
 
 	// Setup the http.Handler/Router used by the server
-	// TODO : JobRepo
-	// jobRepo := repository.GetJobRepository()
-
-	// resolver := &graph.Resolver{DB: db.DB, Repo: jobRepo}
 	resolver := &graph.Resolver{DB: db.DB}
-
-	// TODO : GrapQL Endpoint
-	// graphQLEndpoint := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: resolver}))
-	graphQLEndpoint := handler.NewDefaultServer(generated.NewExecutableSchema(generated.Config{Resolvers: &graph.Resolver{}}))
+	graphQLEndpoint := graph.NewHandler(resolver, config.Keys.GraphQL)
+	graphQLEndpoint.AddTransport(transport.Websocket{
+		KeepAlivePingInterval: 10 * time.Second,
+		InitFunc:              graph.WebsocketInit(websocketAuthenticator(authentication), time.Minute),
+		Upgrader: websocket.Upgrader{
+			CheckOrigin: graph.CheckOrigin(config.Keys.WebsocketAllowedOrigins),
+		},
+	})
+	graphQLEndpoint.AddTransport(transport.Options{})
+	graphQLEndpoint.AddTransport(transport.GET{})
+	graphQLEndpoint.AddTransport(transport.POST{})
+	graphQLEndpoint.AddTransport(transport.MultipartForm{})
 	if os.Getenv("DEBUG") != "1" {
 		// Having this handler means that a error message is returned via GraphQL instead of the connection simply beeing closed.
 		// The problem with this is that then, no more stacktrace is printed to stderr.
@@ -393,8 +540,26 @@ func main() {
 		log.Fatalf("sql.Open() error: %v", err)
 	}
 
-	queries = sqlcdb.New(dbconn)
-	service := api.NewService(queries)
+	service := api.NewService(dbconn)
+	metrics.RegisterDB("main", db.DB.DB)
+	metrics.RegisterDB("service", dbconn)
+
+	readiness := &health.Checker{AllowedIPs: config.Keys.ApiAllowedIPs}
+	readiness.Add("database", func(ctx context.Context) error { return db.DB.PingContext(ctx) })
+	readiness.Add("database-version", func(ctx context.Context) error { return db.CheckVersion() })
+	readiness.Add("archive", func(ctx context.Context) error { return archive.HealthCheck() })
+	for _, cluster := range metricdata.Clusters() {
+		cluster := cluster
+		readiness.Add("metricdata/"+cluster, func(ctx context.Context) error {
+			return metricdata.HealthCheck(ctx, cluster)
+		})
+	}
+	var shutdownDelay time.Duration
+	if d, err := time.ParseDuration(config.Keys.ShutdownDelay); err == nil {
+		shutdownDelay = d
+	} else if config.Keys.ShutdownDelay != "" {
+		log.Fatalf("invalid shutdown-delay: %v", err)
+	}
 
 	api := &api.RestApi{
 		Service: service,
@@ -436,23 +601,54 @@ func main() {
 		web.RenderTemplate(rw, "404.tmpl", &web.Page{Title: "Page not found", Build: buildInfo})
 	})
 
+	// Probes of load balancers, not authenticated
+	r.HandleFunc("/healthz", readiness.Live).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/readyz", readiness.Ready).Methods(http.MethodGet, http.MethodHead)
+
+	// Scraped by Prometheus, restricted to apiAllowedIPs instead of authentication
+	r.Handle("/metrics", metrics.Handler(config.Keys.ApiAllowedIPs)).Methods(http.MethodGet)
+
+	// Websocket clients cannot send the Authorization header, subscriptions
+	// authenticate with the connection_init payload or the session cookie
+	// instead (see graph.WebsocketInit).
+	if authentication != nil {
+		r.Handle("/query", authentication.WebsocketUpgrade(graphQLEndpoint)).Headers("Upgrade", "websocket")
+	} else {
+		r.Handle("/query", graphQLEndpoint).Headers("Upgrade", "websocket")
+	}
+
 	secured := r.PathPrefix("/").Subrouter()
 
 	if !config.Keys.DisableAuthentication {
 		r.Handle("/login", authentication.Login(
 			// On success:
-			http.RedirectHandler("/", http.StatusTemporaryRedirect),
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				codes := auth.RecoveryCodesFromContext(r.Context())
+				if len(codes) == 0 {
+					http.Redirect(rw, r, "/", http.StatusTemporaryRedirect)
+					return
+				}
+				// Show the recovery codes of a second factor set up during login once
+				rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+				web.RenderTemplate(rw, "login.tmpl", &web.Page{
+					Title:   "Recovery codes - ClusterCockpit",
+					MsgType: "alert-info",
+					Message: "Two-factor authentication enabled",
+					Build:   buildInfo,
+					Infos:   map[string]interface{}{"recoveryCodes": codes},
+				})
+			}),
 
 			// On failure:
 			func(rw http.ResponseWriter, r *http.Request, err error) {
 				rw.Header().Add("Content-Type", "text/html; charset=utf-8")
-				rw.WriteHeader(http.StatusUnauthorized)
+				rw.WriteHeader(loginFailureStatus(err))
 				web.RenderTemplate(rw, "login.tmpl", &web.Page{
 					Title:   "Login failed - ClusterCockpit",
 					MsgType: "alert-warning",
 					Message: err.Error(),
 					Build:   buildInfo,
-					Infos:   info,
+					Infos:   loginPageInfos(info, err),
 				})
 			})).Methods(http.MethodPost)
 
@@ -463,7 +659,7 @@ func main() {
 			// On failure:
 			func(rw http.ResponseWriter, r *http.Request, err error) {
 				rw.Header().Add("Content-Type", "text/html; charset=utf-8")
-				rw.WriteHeader(http.StatusUnauthorized)
+				rw.WriteHeader(loginFailureStatus(err))
 				web.RenderTemplate(rw, "login.tmpl", &web.Page{
 					Title:   "Login failed - ClusterCockpit",
 					MsgType: "alert-warning",
@@ -473,6 +669,51 @@ func main() {
 				})
 			}))
 
+		r.HandleFunc("/password-reset", func(rw http.ResponseWriter, r *http.Request) {
+			rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+			web.RenderTemplate(rw, "login.tmpl", &web.Page{
+				Title: "Reset password - ClusterCockpit",
+				Build: buildInfo,
+				Infos: withInfo(info, "resetToken", r.FormValue("token")),
+			})
+		}).Methods(http.MethodGet)
+		r.Handle("/password-reset", authentication.ResetPassword(
+			// On success:
+			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+				web.RenderTemplate(rw, "login.tmpl", &web.Page{
+					Title:   "Password changed - ClusterCockpit",
+					MsgType: "alert-info",
+					Message: "Password changed, please log in",
+					Build:   buildInfo,
+					Infos:   info,
+				})
+			}),
+
+			// On failure:
+			func(rw http.ResponseWriter, r *http.Request, err error) {
+				infos := info
+				status := http.StatusBadRequest
+				if errors.Is(err, auth.ErrWeakPassword) {
+					// The link stays valid, let the user try another password
+					infos = withInfo(info, "resetToken", r.FormValue("token"))
+					status = http.StatusUnprocessableEntity
+				}
+				rw.Header().Add("Content-Type", "text/html; charset=utf-8")
+				rw.WriteHeader(status)
+				web.RenderTemplate(rw, "login.tmpl", &web.Page{
+					Title:   "Reset password failed - ClusterCockpit",
+					MsgType: "alert-warning",
+					Message: err.Error(),
+					Build:   buildInfo,
+					Infos:   infos,
+				})
+			})).Methods(http.MethodPost)
+
+		if authentication.JwtAuth != nil {
+			r.HandleFunc("/.well-known/jwks.json", authentication.JwtAuth.ServeJWKS).Methods(http.MethodGet)
+		}
+
 		r.Handle("/logout", authentication.Logout(
 			http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 				rw.Header().Add("Content-Type", "text/html; charset=utf-8")
@@ -511,7 +752,7 @@ func main() {
 		//swagger doc
 		// httpSwagger.URL("http://" + config.Keys.Addr + "/swagger/doc.json"))).Methods(http.MethodGet)
 	}
-	// secured.Handle("/query", graphQLEndpoint)
+	secured.Handle("/query", graphQLEndpoint)
 
 	// Send a searchId and then reply with a redirect to a user, or directly send query to job table for jobid and project.
 	// secured.HandleFunc("/search", func(rw http.ResponseWriter, r *http.Request) {
@@ -534,6 +775,10 @@ func main() {
 		r.PathPrefix("/").Handler(http.FileServer(http.Dir(config.Keys.StaticFiles)))
 	}
 
+	r.Use(metrics.Middleware)
+	if tracing.Enabled() {
+		r.Use(tracing.Middleware)
+	}
 	r.Use(handlers.CompressHandler)
 	r.Use(handlers.RecoveryHandler(handlers.PrintRecoveryStack(true)))
 	r.Use(handlers.CORS(
@@ -617,9 +862,18 @@ func main() {
 		<-sigs
 		runtimeEnv.SystemdNotifiy(false, "Shutting down ...")
 
-		// First shut down the server gracefully (waiting for all ongoing requests)
+		// Fail the readiness probe first, so that load balancers stop sending new requests
+		readiness.ShuttingDown()
+		time.Sleep(shutdownDelay)
+
+		// Then shut down the server gracefully (waiting for all ongoing requests)
 		server.Shutdown(context.Background())
 
+		// Export the spans still buffered
+		if err := tracing.Shutdown(context.Background()); err != nil {
+			log.Warnf("exporting the remaining spans failed: %v", err)
+		}
+
 		// Then, wait for any async archivings still pending...
 		// api.JobRepository.WaitForArchiving()
 	}()
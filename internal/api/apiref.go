@@ -84,4 +84,18 @@ type LogicalVolume struct {
     VgName    string `json:"vg_name"`
     LvAttr    string `json:"lv_attr"`
     LvSize    string `json:"lv_size"`
-}
\ No newline at end of file
+}
+type LVRequest struct {
+    ID          int     `json:"id"`
+    MachineID   string  `json:"machineId"`
+    Operation   string  `json:"operation" enums:"create,extend"`
+    VgName      string  `json:"vgName"`
+    LvName      string  `json:"lvName"`
+    SizeGB      float64 `json:"sizeGB"`
+    Filesystem  string  `json:"filesystem,omitempty"`
+    Status      string  `json:"status" enums:"pending,running,done,failed"`
+    Message     string  `json:"message,omitempty"`
+    RequestedBy string  `json:"requestedBy"`
+    RequestedAt string  `json:"requestedAt"`
+    UpdatedAt   string  `json:"updatedAt"`
+}
@@ -0,0 +1,243 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+)
+
+// Default and maximum number of entries returned by GET /api/audit as JSON.
+// NDJSON exports are not limited unless requested.
+const (
+	auditDefaultLimit = 100
+	auditMaxLimit     = 1000
+)
+
+// auditRecord describes a change to be written to the audit log. before and
+// after hold the state of the resource around the change (nil if it did not
+// exist).
+type auditRecord struct {
+	action     string
+	resource   string
+	resourceID string
+	before     interface{}
+	after      interface{}
+
+	// For versioned singleton configs (see repository.IsVersionedConfig),
+	// after is appended to the version history of configType.
+	configType    string
+	versionAction string // defaults to action
+}
+
+// audited runs change in a transaction and writes the audit record it returns
+// within the same transaction, so that either both or none are persisted.
+// Listeners of versioned configs are notified once the change is committed.
+func (api *Service) audited(r *http.Request, change func(q *sqlcdb.Queries) (*auditRecord, error)) error {
+	return api.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		return change(api.r.WithTx(tx))
+	})
+}
+
+// Attempts of a change of a versioned config that raced with another change.
+const configVersionAttempts = 3
+
+// auditedTx is like audited for changes that need the transaction itself.
+// ctx carries the audit actor. Changes of versioned configs that conflict
+// with a concurrent change are run again in a new transaction, so change
+// must not have side effects outside of tx.
+func (api *Service) auditedTx(r *http.Request, change func(ctx context.Context, tx *sql.Tx) (*auditRecord, error)) error {
+	ctx := withAuditActor(r)
+	var err error
+	for attempt := 1; attempt <= configVersionAttempts; attempt++ {
+		if err = api.runAuditedTx(ctx, change); !errors.Is(err, repository.ErrConfigVersionConflict) {
+			return err
+		}
+		log.Infof("retrying config change after concurrent change (attempt %d)", attempt)
+	}
+	return err
+}
+
+func (api *Service) runAuditedTx(ctx context.Context, change func(ctx context.Context, tx *sql.Tx) (*auditRecord, error)) error {
+	tx, err := api.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	rec, err := change(ctx, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := repository.RecordAuditFromContext(ctx, tx, rec.action, rec.resource, rec.resourceID, rec.before, rec.after); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var version *repository.ConfigVersion
+	if rec.configType != "" {
+		action := rec.versionAction
+		if action == "" {
+			action = rec.action
+		}
+		if version, err = repository.RecordConfigVersion(ctx, tx, rec.configType, action, rec.before, rec.after); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	if version != nil {
+		repository.NotifyConfigChange(version)
+	}
+	return nil
+}
+
+// auditState returns v as resource state, or nil if the lookup found no row.
+func auditState[T any](v T, err error) (interface{}, error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// withAuditActor returns the request context extended by the user and source
+// address the audit log attributes changes to.
+func withAuditActor(r *http.Request) context.Context {
+	actor := &repository.AuditActor{SourceIP: util.ClientIP(r)}
+	if user := repository.GetUserFromContext(r.Context()); user != nil {
+		actor.Username = user.Username
+		actor.AuthType = repository.AuditAuthType(user.AuthType)
+	}
+	return repository.WithAuditActor(r.Context(), actor)
+}
+
+func parseAuditTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ts, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// GetAuditLog godoc
+//
+//	@summary    Lists audit log entries
+//	@description Returns configuration and infrastructure changes, newest first.
+//	@description Use format=ndjson (or Accept: application/x-ndjson) to export all matching entries as newline delimited JSON.
+//	@tags       Audit
+//	@produce    json
+//	@param      actor       query       string          false   "Username of the actor"
+//	@param      action      query       string          false   "Action"    Enums(create, update, delete)
+//	@param      resource    query       string          false   "Resource type, e.g. machine or machine_conf"
+//	@param      resource_id query       string          false   "Resource ID"
+//	@param      from        query       string          false   "Start (unix seconds or RFC3339)"
+//	@param      to          query       string          false   "End (unix seconds or RFC3339)"
+//	@param      limit       query       int             false   "Maximum number of entries"
+//	@param      offset      query       int             false   "Number of entries to skip"
+//	@param      format      query       string          false   "Output format"   Enums(json, ndjson)
+//	@success    200         {array}     repository.AuditEntry   "Audit log entries"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /audit [get]
+func (api *Service) GetAuditLog(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
+		return
+	}
+
+	query := r.URL.Query()
+	filter := repository.AuditFilter{
+		Actor:      query.Get("actor"),
+		Action:     query.Get("action"),
+		Resource:   query.Get("resource"),
+		ResourceID: query.Get("resource_id"),
+	}
+
+	var err error
+	if filter.From, err = parseAuditTime(query.Get("from")); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+	if filter.To, err = parseAuditTime(query.Get("to")); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	ndjson := query.Get("format") == "ndjson" || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson")
+
+	if s := query.Get("limit"); s != "" {
+		limit, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			handleError(err, http.StatusBadRequest, rw)
+			return
+		}
+		filter.Limit = limit
+	} else if !ndjson {
+		filter.Limit = auditDefaultLimit
+	}
+	if !ndjson && filter.Limit > auditMaxLimit {
+		filter.Limit = auditMaxLimit
+	}
+
+	if s := query.Get("offset"); s != "" {
+		offset, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			handleError(err, http.StatusBadRequest, rw)
+			return
+		}
+		filter.Offset = offset
+	}
+
+	if ndjson {
+		rw.Header().Set("Content-Type", "application/x-ndjson")
+		rw.Header().Set("Content-Disposition", "attachment; filename=\"audit.ndjson\"")
+		enc := json.NewEncoder(rw)
+		if err := repository.QueryAudit(r.Context(), api.db, filter, func(e *repository.AuditEntry) error {
+			return enc.Encode(e)
+		}); err != nil {
+			// Headers are already sent, the export is truncated
+			log.Warnf("audit export failed: %v", err)
+		}
+		return
+	}
+
+	entries := make([]*repository.AuditEntry, 0)
+	if err := repository.QueryAudit(r.Context(), api.db, filter, func(e *repository.AuditEntry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(entries)
+}
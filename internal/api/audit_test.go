@@ -0,0 +1,103 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+)
+
+func TestAuditMachineConfUpdate(t *testing.T) {
+	r := setupAuthzRouter(t, setupAuthzTemplate(t))
+
+	form := url.Values{"hostname": {"host2"}, "username": {"root"}, "port_number": {"22"}, "password": {"s3cret"}}
+	if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/machine_conf/1", form); rw.Code != http.StatusOK {
+		t.Fatalf("update failed: %d %s", rw.Code, rw.Body.String())
+	}
+
+	rw := doAuthz(t, r, authzUsers["support"], "GET", "/api/audit?resource=machine_conf", nil)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("listing audit log failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if strings.Contains(rw.Body.String(), "s3cret") {
+		t.Fatalf("audit log leaks secret: %s", rw.Body.String())
+	}
+
+	var entries []repository.AuditEntry
+	if err := json.Unmarshal(rw.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	e := entries[0]
+	if e.Actor != "admin" || e.AuthType != "session" || e.Action != repository.AuditUpdate ||
+		e.ResourceID != "1" || e.SourceIP != "192.0.2.1" {
+		t.Errorf("unexpected entry: %#v", e)
+	}
+
+	var diff map[string]map[string]interface{}
+	if err := json.Unmarshal(e.Diff, &diff); err != nil {
+		t.Fatal(err)
+	}
+	if diff["Hostname"]["old"] != "host1" || diff["Hostname"]["new"] != "host2" {
+		t.Errorf("unexpected hostname diff: %v", diff["Hostname"])
+	}
+	if diff["Password"]["new"] != repository.AuditRedacted {
+		t.Errorf("password not redacted: %v", diff["Password"])
+	}
+	if _, ok := diff["Username"]; ok {
+		t.Errorf("unchanged field in diff: %v", diff)
+	}
+}
+
+func TestAuditFilterAndExport(t *testing.T) {
+	r := setupAuthzRouter(t, setupAuthzTemplate(t))
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/machine/m1/groups", url.Values{"group_name": {"projB"}}); rw.Code != http.StatusCreated {
+		t.Fatalf("adding group failed: %d", rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["managerA"], "DELETE", "/api/logical_volume/1", nil); rw.Code != http.StatusNoContent {
+		t.Fatalf("deleting volume failed: %d", rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["admin"], "DELETE", "/api/machine/m1", nil); rw.Code != http.StatusNoContent {
+		t.Fatalf("deleting machine failed: %d", rw.Code)
+	}
+
+	rw := doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?actor=admin&action=delete", nil)
+	var entries []repository.AuditEntry
+	if err := json.Unmarshal(rw.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Resource != "machine" || entries[0].ResourceID != "m1" {
+		t.Fatalf("unexpected filter result: %#v", entries)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?format=ndjson", nil)
+	if ct := rw.Header().Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	lines := 0
+	scanner := bufio.NewScanner(rw.Body)
+	for scanner.Scan() {
+		var e repository.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid NDJSON line %q: %v", scanner.Text(), err)
+		}
+		lines++
+	}
+	// Inventory changes are not audited
+	if lines != 2 {
+		t.Errorf("expected 2 exported entries, got %d", lines)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// machineResolver returns the id of the machine a request operates on.
+type machineResolver func(r *http.Request) (string, error)
+
+func machineFromPath(r *http.Request) (string, error) {
+	return mux.Vars(r)["machine_id"], nil
+}
+
+func machineFromForm(r *http.Request) (string, error) {
+	return r.FormValue("machine_id"), nil
+}
+
+// machineOwnedBy resolves the machine through the record identified by the
+// path variable key using lookup.
+func machineOwnedBy(key string, lookup func(context.Context, int32) (string, error)) machineResolver {
+	return func(r *http.Request) (string, error) {
+		id, err := strconv.Atoi(mux.Vars(r)[key])
+		if err != nil {
+			return "", sql.ErrNoRows
+		}
+		return lookup(r.Context(), int32(id))
+	}
+}
+
+// showCredentials reports whether the user of r may see stored credentials.
+// They are hidden from everybody but admins, like in the GraphQL API.
+func showCredentials(r *http.Request) bool {
+	user := repository.GetUserFromContext(r.Context())
+	return user != nil && user.HasRole(schema.RoleAdmin)
+}
+
+// authorize checks the user in the request context against policy and writes
+// an error response if access is denied. For policies admitting managers or
+// agents, machine is used to find the machine the request targets; it may be
+// nil for endpoints that are not bound to a machine.
+func (api *Service) authorize(rw http.ResponseWriter, r *http.Request, policy auth.AccessPolicy, machine machineResolver) bool {
+	if err := securedCheck(r); err != nil {
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return false
+	}
+
+	var machineID func() (string, error)
+	if machine != nil {
+		machineID = func() (string, error) {
+			id, err := machine(r)
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return "", fmt.Errorf("resolving machine failed: %w", err)
+			}
+			return id, nil
+		}
+	}
+	machineGroups := func(machineID string) ([]string, error) {
+		groups, err := api.r.GetMachineGroups(r.Context(), machineID)
+		if err != nil {
+			return nil, fmt.Errorf("loading machine groups failed: %w", err)
+		}
+		return groups, nil
+	}
+
+	user := repository.GetUserFromContext(r.Context())
+	allowed, err := policy.Allows(user, machineID, machineGroups)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return false
+	}
+	if allowed {
+		return true
+	}
+
+	log.Warnf("access denied: user '%s' (roles %v) on %s %s", user.Username, user.Roles, r.Method, r.URL.Path)
+	http.Error(rw, "forbidden", http.StatusForbidden)
+	return false
+}
@@ -0,0 +1,422 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+	"github.com/gorilla/mux"
+)
+
+// ConfigVersionDiff is the difference between two versions of a config in the
+// format of the audit log.
+type ConfigVersionDiff struct {
+	ConfigType string          `json:"configType"`
+	From       int64           `json:"from"`
+	To         int64           `json:"to"`
+	Diff       json.RawMessage `json:"diff,omitempty" swaggertype:"object"`
+}
+
+// configTypeFromPath returns the versioned config type of the request or
+// writes a 404 response.
+func configTypeFromPath(rw http.ResponseWriter, r *http.Request) (string, bool) {
+	configType := mux.Vars(r)["type"]
+	if !repository.IsVersionedConfig(configType) {
+		handleError(fmt.Errorf("unknown config type '%s'", configType), http.StatusNotFound, rw)
+		return "", false
+	}
+	return configType, true
+}
+
+func parseConfigVersion(s string) (int64, error) {
+	version, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("invalid version '%s'", s)
+	}
+	return version, nil
+}
+
+// GetConfigVersions godoc
+//
+//	@summary    Lists the version history of a config
+//	@tags       ConfigVersions
+//	@produce    json
+//	@param      type        path        string          true    "Config type"   Enums(rabbitmq_config, influxdb_config, file_stash_url)
+//	@success    200         {array}     repository.ConfigVersion   "Versions, newest first"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /config/{type}/versions [get]
+func (api *Service) GetConfigVersions(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
+		return
+	}
+
+	configType, ok := configTypeFromPath(rw, r)
+	if !ok {
+		return
+	}
+
+	versions, err := repository.ListConfigVersions(r.Context(), api.db, configType)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	for i, v := range versions {
+		versions[i] = v.Redacted()
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(versions)
+}
+
+// GetConfigVersion godoc
+//
+//	@summary    Returns a single version of a config
+//	@tags       ConfigVersions
+//	@produce    json
+//	@param      type        path        string          true    "Config type"   Enums(rabbitmq_config, influxdb_config, file_stash_url)
+//	@param      version     path        int             true    "Version"
+//	@success    200         {object}    repository.ConfigVersion   "Version"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /config/{type}/versions/{version} [get]
+func (api *Service) GetConfigVersion(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
+		return
+	}
+
+	configType, ok := configTypeFromPath(rw, r)
+	if !ok {
+		return
+	}
+
+	version, err := parseConfigVersion(mux.Vars(r)["version"])
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	v, err := repository.GetConfigVersion(r.Context(), api.db, configType, version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(v.Redacted())
+}
+
+// DiffConfigVersions godoc
+//
+//	@summary    Compares two versions of a config
+//	@description Returns the fields that differ between the versions from and to. Credentials are redacted.
+//	@tags       ConfigVersions
+//	@produce    json
+//	@param      type        path        string          true    "Config type"   Enums(rabbitmq_config, influxdb_config, file_stash_url)
+//	@param      from        query       int             true    "Old version"
+//	@param      to          query       int             true    "New version"
+//	@success    200         {object}    ConfigVersionDiff   "Difference"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /config/{type}/diff [get]
+func (api *Service) DiffConfigVersions(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
+		return
+	}
+
+	configType, ok := configTypeFromPath(rw, r)
+	if !ok {
+		return
+	}
+
+	result := ConfigVersionDiff{ConfigType: configType}
+	states := make([]json.RawMessage, 2)
+	for i, s := range []string{"from", "to"} {
+		version, err := parseConfigVersion(r.URL.Query().Get(s))
+		if err != nil {
+			handleError(err, http.StatusBadRequest, rw)
+			return
+		}
+
+		v, err := repository.GetConfigVersion(r.Context(), api.db, configType, version)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				handleError(fmt.Errorf("version %d not found", version), http.StatusNotFound, rw)
+			} else {
+				handleError(err, http.StatusInternalServerError, rw)
+			}
+			return
+		}
+
+		if i == 0 {
+			result.From = version
+		} else {
+			result.To = version
+		}
+		states[i] = v.Data
+	}
+
+	var before, after interface{}
+	if states[0] != nil {
+		before = states[0]
+	}
+	if states[1] != nil {
+		after = states[1]
+	}
+
+	diff, err := repository.AuditDiff(before, after)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+	result.Diff = diff
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(result)
+}
+
+// RollbackConfig godoc
+//
+//	@summary    Restores a previous version of a config
+//	@description The state of the given version is applied and recorded as new version.
+//	@description Rolling back to a version in which the config was deleted deletes it.
+//	@tags       ConfigVersions
+//	@produce    json
+//	@param      type        path        string          true    "Config type"   Enums(rabbitmq_config, influxdb_config, file_stash_url)
+//	@param      version     path        int             true    "Version to restore"
+//	@success    200         {object}    repository.ConfigVersion   "New version"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /config/{type}/rollback/{version} [post]
+func (api *Service) RollbackConfig(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	configType, ok := configTypeFromPath(rw, r)
+	if !ok {
+		return
+	}
+
+	version, err := parseConfigVersion(mux.Vars(r)["version"])
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	target, err := repository.GetConfigVersion(r.Context(), api.db, configType, version)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		var before, after interface{}
+		var err error
+		switch configType {
+		case repository.ConfigRabbitMQ:
+			before, after, err = restoreRabbitMQConfig(r.Context(), q, target.Data)
+		case repository.ConfigInfluxDB:
+			before, after, err = restoreInfluxDBConfig(r.Context(), q, target.Data)
+		case repository.ConfigFileStash:
+			before, after, err = restoreFileStashURL(r.Context(), q, target.Data)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &auditRecord{
+			action:        repository.AuditUpdate,
+			resource:      configType,
+			resourceID:    strconv.FormatInt(version, 10),
+			configType:    configType,
+			versionAction: repository.ConfigRollback,
+			before:        before,
+			after:         after,
+		}, nil
+	})
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	versions, err := repository.ListConfigVersions(r.Context(), api.db, configType)
+	if err != nil || len(versions) == 0 {
+		if err == nil {
+			err = errors.New("version history is empty")
+		}
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(versions[0].Redacted())
+}
+
+// The restore functions apply the state data of a version (nil if the config
+// was deleted in it) and return the states around the change.
+
+func restoreRabbitMQConfig(ctx context.Context, q *sqlcdb.Queries, data json.RawMessage) (interface{}, interface{}, error) {
+	before, err := auditState(q.GetRabbitMQConfig(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if data == nil {
+		if before != nil {
+			err = q.DeleteRabbitMQConfig(ctx)
+		}
+		return before, nil, err
+	}
+
+	var conf sqlcdb.RabbitMqConfig
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, nil, err
+	}
+	if before == nil {
+		err = q.CreateRabbitMQConfig(ctx, sqlcdb.CreateRabbitMQConfigParams{
+			ConnUrl: conf.ConnUrl, Username: conf.Username, Password: conf.Password,
+		})
+	} else {
+		err = q.UpdateRabbitMQConfig(ctx, sqlcdb.UpdateRabbitMQConfigParams{
+			ConnUrl: conf.ConnUrl, Username: conf.Username, Password: conf.Password,
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := auditState(q.GetRabbitMQConfig(ctx))
+	return before, after, err
+}
+
+func restoreInfluxDBConfig(ctx context.Context, q *sqlcdb.Queries, data json.RawMessage) (interface{}, interface{}, error) {
+	current, err := q.GetInfluxDBConfiguration(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+	exists := err == nil
+	var before interface{}
+	if exists {
+		before = current
+	}
+
+	if data == nil {
+		if exists {
+			err = q.DeleteInfluxDBConfiguration(ctx, current.ID)
+		} else {
+			err = nil
+		}
+		return before, nil, err
+	}
+
+	var conf sqlcdb.InfluxdbConfiguration
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		err = q.UpdateInfluxDBConfiguration(ctx, sqlcdb.UpdateInfluxDBConfigurationParams{
+			Type:                 conf.Type,
+			DatabaseName:         conf.DatabaseName,
+			Host:                 conf.Host,
+			Port:                 conf.Port,
+			User:                 conf.User,
+			Password:             conf.Password,
+			Organization:         conf.Organization,
+			SslEnabled:           conf.SslEnabled,
+			BatchSize:            conf.BatchSize,
+			RetryInterval:        conf.RetryInterval,
+			RetryExponentialBase: conf.RetryExponentialBase,
+			MaxRetries:           conf.MaxRetries,
+			MaxRetryTime:         conf.MaxRetryTime,
+			MetaAsTags:           conf.MetaAsTags,
+		})
+	} else {
+		err = q.CreateInfluxDBConfiguration(ctx, sqlcdb.CreateInfluxDBConfigurationParams{
+			Type:                 conf.Type,
+			DatabaseName:         conf.DatabaseName,
+			Host:                 conf.Host,
+			Port:                 conf.Port,
+			User:                 conf.User,
+			Password:             conf.Password,
+			Organization:         conf.Organization,
+			SslEnabled:           conf.SslEnabled,
+			BatchSize:            conf.BatchSize,
+			RetryInterval:        conf.RetryInterval,
+			RetryExponentialBase: conf.RetryExponentialBase,
+			MaxRetries:           conf.MaxRetries,
+			MaxRetryTime:         conf.MaxRetryTime,
+			MetaAsTags:           conf.MetaAsTags,
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := auditState(q.GetInfluxDBConfiguration(ctx))
+	return before, after, err
+}
+
+func restoreFileStashURL(ctx context.Context, q *sqlcdb.Queries, data json.RawMessage) (interface{}, interface{}, error) {
+	current, err := q.GetFileStashURL(ctx)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+	exists := err == nil
+	var before interface{}
+	if exists {
+		before = current
+	}
+
+	if data == nil {
+		if exists {
+			err = q.DeleteFileStashURL(ctx, current.ID)
+		} else {
+			err = nil
+		}
+		return before, nil, err
+	}
+
+	var conf sqlcdb.FileStashUrl
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, nil, err
+	}
+	if exists {
+		err = q.UpdateFileStashURL(ctx, conf.Url)
+	} else {
+		err = q.CreateFileStashURL(ctx, conf.Url)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after, err := auditState(q.GetFileStashURL(ctx))
+	return before, after, err
+}
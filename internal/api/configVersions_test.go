@@ -0,0 +1,102 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/api"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+)
+
+func TestConfigVersionRollback(t *testing.T) {
+	template := setupAuthzTemplate(t)
+	db, err := sql.Open("sqlite3", template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO file_stash_url (id, url) VALUES (1, 'http://stash-a')`); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	r := setupAuthzRouter(t, template)
+
+	notified := make(chan *repository.ConfigVersion, 4)
+	repository.OnConfigChange(repository.ConfigFileStash, func(v *repository.ConfigVersion) {
+		select {
+		case notified <- v:
+		default:
+		}
+	})
+
+	for _, u := range []string{"http://stash-b", "http://stash-c"} {
+		if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/file_stash_url", url.Values{"url": {u}}); rw.Code != http.StatusOK {
+			t.Fatalf("update failed: %d %s", rw.Code, rw.Body.String())
+		}
+	}
+	if len(notified) != 2 {
+		t.Fatalf("expected 2 notifications, got %d", len(notified))
+	}
+
+	rw := doAuthz(t, r, authzUsers["support"], "GET", "/api/config/file_stash_url/versions", nil)
+	var versions []repository.ConfigVersion
+	if err := json.Unmarshal(rw.Body.Bytes(), &versions); err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 || versions[0].Version != 3 || versions[2].Action != repository.ConfigBaseline ||
+		versions[0].CreatedBy != "admin" {
+		t.Fatalf("unexpected history: %#v", versions)
+	}
+
+	rw = doAuthz(t, r, authzUsers["support"], "GET", "/api/config/file_stash_url/diff?from=1&to=3", nil)
+	var diff api.ConfigVersionDiff
+	if err := json.Unmarshal(rw.Body.Bytes(), &diff); err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]map[string]interface{}
+	if err := json.Unmarshal(diff.Diff, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if fields["Url"]["old"] != "http://stash-a" || fields["Url"]["new"] != "http://stash-c" {
+		t.Errorf("unexpected diff: %s", diff.Diff)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "POST", "/api/config/file_stash_url/rollback/1", nil)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("rollback failed: %d %s", rw.Code, rw.Body.String())
+	}
+	var v repository.ConfigVersion
+	if err := json.Unmarshal(rw.Body.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 4 || v.Action != repository.ConfigRollback {
+		t.Errorf("unexpected version: %#v", v)
+	}
+	if n := <-notified; n.Version != 2 {
+		t.Errorf("unexpected notification order: %d", n.Version)
+	}
+	<-notified
+	if n := <-notified; n.Version != 4 {
+		t.Errorf("rollback not notified: %#v", n)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/file_stash_url", nil)
+	var stash sqlcdb.FileStashUrl
+	if err := json.Unmarshal(rw.Body.Bytes(), &stash); err != nil {
+		t.Fatal(err)
+	}
+	if stash.Url != "http://stash-a" {
+		t.Errorf("rollback not applied: %s", stash.Url)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "GET", "/api/config/unknown/versions", nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d for unknown config, got %d", http.StatusNotFound, rw.Code)
+	}
+}
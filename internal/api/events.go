@@ -0,0 +1,32 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"net/http"
+
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/pubsub"
+)
+
+// Successful writes are published for GraphQL subscriptions, see package
+// pubsub.
+
+func publishMachineChange(machineID string, change model.MachineChange) {
+	if machineID == "" {
+		return
+	}
+	pubsub.MachineChanged(machineID, change, nil)
+}
+
+// machineOf returns the machine resolved by machine, or "" if the lookup
+// fails. Records have to be resolved before they are deleted.
+func machineOf(r *http.Request, machine machineResolver) string {
+	machineID, err := machine(r)
+	if err != nil {
+		return ""
+	}
+	return machineID
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// GetLoginLockouts godoc
+//
+//	@summary    Lists the usernames and source IPs locked after failed logins
+//	@tags       Users
+//	@produce    json
+//	@success    200         {array}     repository.LoginAttempts   "Current lockouts"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /lockouts [get]
+func (api *Service) GetLoginLockouts(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyLockoutRead, nil) {
+		return
+	}
+
+	lockouts, err := repository.ListLoginLockouts(r.Context(), api.db, time.Now().Unix())
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(lockouts)
+}
+
+// UnlockLogin godoc
+//
+//	@summary    Lifts the lockout of a username or source IP
+//	@description Also forgets the failed logins counted so far.
+//	@tags       Users
+//	@param      kind        path        string          true    "user or ip"
+//	@param      name        path        string          true    "Username or IP address"
+//	@success    204         "No Content"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /lockouts/{kind}/{name} [delete]
+func (api *Service) UnlockLogin(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyLockoutWrite, nil) {
+		return
+	}
+
+	kind, name := mux.Vars(r)["kind"], mux.Vars(r)["name"]
+	if kind != repository.LoginKeyUser && kind != repository.LoginKeyIP {
+		handleError(fmt.Errorf("invalid kind '%s', expected 'user' or 'ip'", kind), http.StatusBadRequest, rw)
+		return
+	}
+
+	err := api.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.GetLoginAttempts(ctx, tx, kind, name)
+		if err != nil {
+			return nil, err
+		}
+		err = repository.DeleteLoginAttempts(ctx, tx, kind, name)
+		return &auditRecord{action: repository.AuditDelete, resource: "login_lockout", resourceID: kind + ":" + name, before: before}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(fmt.Errorf("no failed logins for %s '%s'", kind, name), http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
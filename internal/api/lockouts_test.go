@@ -0,0 +1,69 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+)
+
+func TestLoginLockouts(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	ctx := context.Background()
+
+	now := time.Now().Unix()
+	for _, a := range []*repository.LoginAttempts{
+		{Kind: "user", Name: "bob", Failures: 5, LastFailure: now, LockedUntil: now + 600},
+		{Kind: "ip", Name: "10.0.0.1", Failures: 50, LastFailure: now, LockedUntil: now + 300},
+		{Kind: "user", Name: "carol", Failures: 2, LastFailure: now},
+	} {
+		if _, err := db.ExecContext(ctx, "INSERT INTO login_attempts VALUES (?, ?, ?, ?, ?)",
+			a.Kind, a.Name, a.Failures, a.LastFailure, a.LockedUntil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/lockouts", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for users, got %d", http.StatusForbidden, rw.Code)
+	}
+	rw := doAuthz(t, r, authzUsers["support"], "GET", "/api/lockouts", nil)
+	var lockouts []repository.LoginAttempts
+	if err := json.Unmarshal(rw.Body.Bytes(), &lockouts); err != nil {
+		t.Fatal(err)
+	}
+	if len(lockouts) != 2 || lockouts[0].Name != "bob" || lockouts[1].Name != "10.0.0.1" {
+		t.Errorf("unexpected lockouts: %s", rw.Body.String())
+	}
+
+	for _, tc := range []struct {
+		user, target string
+		expected     int
+	}{
+		{"support", "/api/lockouts/user/bob", http.StatusForbidden},
+		{"admin", "/api/lockouts/group/bob", http.StatusBadRequest},
+		{"admin", "/api/lockouts/user/bob", http.StatusNoContent},
+		{"admin", "/api/lockouts/user/bob", http.StatusNotFound},
+		{"admin", "/api/lockouts/ip/10.0.0.1", http.StatusNoContent},
+	} {
+		if rw := doAuthz(t, r, authzUsers[tc.user], "DELETE", tc.target, nil); rw.Code != tc.expected {
+			t.Errorf("%s DELETE %s: expected %d, got %d", tc.user, tc.target, tc.expected, rw.Code)
+		}
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/lockouts", nil)
+	if strings.TrimSpace(rw.Body.String()) != "[]" {
+		t.Errorf("lockouts not lifted: %s", rw.Body.String())
+	}
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=login_lockout", nil)
+	if !strings.Contains(rw.Body.String(), "user:bob") || !strings.Contains(rw.Body.String(), "ip:10.0.0.1") {
+		t.Errorf("unlocks not audited: %s", rw.Body.String())
+	}
+}
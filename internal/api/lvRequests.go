@@ -0,0 +1,129 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/pubsub"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+// LV requests are created through the GraphQL mutation requestLV. The agent
+// on the machine polls for pending requests and reports their progress.
+
+// GetLVRequests godoc
+//
+//	@summary    Lists the LV requests of a machine
+//	@tags       LVRequests
+//	@produce    json
+//	@param      machine_id  path        string          true    "Machine ID"
+//	@param      status      query       string          false   "Only requests in this state" Enums(pending, running, done, failed)
+//	@success    200         {array}     LVRequest       "Requests, oldest first"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /lv_requests/{machine_id} [get]
+func (api *Service) GetLVRequests(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineFromPath) {
+		return
+	}
+
+	machineID := mux.Vars(r)["machine_id"]
+	status := model.LVRequestStatus(r.URL.Query().Get("status"))
+	if status != "" && !status.IsValid() {
+		handleError(fmt.Errorf("invalid status '%s'", status), http.StatusBadRequest, rw)
+		return
+	}
+
+	requests, err := repository.LoadLVRequests(r.Context(), api.db, []string{machineID}, status)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	list := requests[machineID]
+	if list == nil {
+		list = []*model.LVRequest{}
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(list)
+}
+
+// UpdateLVRequest godoc
+//
+//	@summary    Reports the progress of an LV request
+//	@tags       LVRequests
+//	@accept     mpfd
+//	@produce    json
+//	@param      id          path        int             true    "Request ID"
+//	@param      status      formData    string          true    "New state" Enums(pending, running, done, failed)
+//	@param      message     formData    string          false   "Output or error message"
+//	@success    200         {object}    LVRequest       "Updated request"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /lv_requests/{id} [put]
+func (api *Service) UpdateLVRequest(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineOwnedBy("id", api.lvRequestOwner)) {
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+	status := model.LVRequestStatus(r.FormValue("status"))
+	if !status.IsValid() {
+		handleError(fmt.Errorf("invalid status '%s'", status), http.StatusBadRequest, rw)
+		return
+	}
+
+	var after *model.LVRequest
+	err = api.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.GetLVRequest(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := repository.UpdateLVRequestStatus(ctx, tx, id, status, r.FormValue("message"), time.Now().Unix()); err != nil {
+			return nil, err
+		}
+		after, err = repository.GetLVRequest(ctx, tx, id)
+		return &auditRecord{action: repository.AuditUpdate, resource: "lv_request", resourceID: strconv.Itoa(id), before: before, after: after}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	pubsub.MachineChanged(after.MachineID, model.MachineChangeLvRequest, after)
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(after)
+}
+
+func (api *Service) lvRequestOwner(ctx context.Context, id int32) (string, error) {
+	req, err := repository.GetLVRequest(ctx, api.db, int(id))
+	if err != nil {
+		return "", err
+	}
+	return req.MachineID, nil
+}
@@ -0,0 +1,83 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/pubsub"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+)
+
+func TestLVRequests(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+
+	now := time.Now()
+	req := &model.LVRequest{MachineID: "m1", Operation: model.LVOperationExtend, VgName: "vg0", LvName: "lv0",
+		SizeGb: 10, RequestedBy: "admin", RequestedAt: now, UpdatedAt: now}
+	if err := repository.CreateLVRequest(context.Background(), db, req); err != nil {
+		t.Fatal(err)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/lv_requests/m1", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for users, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["api"], "GET", "/api/lv_requests/m1?status=bogus", nil); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for invalid status, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	var pending []model.LVRequest
+	rw := doAuthz(t, r, authzUsers["api"], "GET", "/api/lv_requests/m1?status=pending", nil)
+	if err := json.Unmarshal(rw.Body.Bytes(), &pending); err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].LvName != "lv0" || pending[0].Operation != model.LVOperationExtend {
+		t.Fatalf("unexpected requests: %s", rw.Body.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := pubsub.MachineStatus.Subscribe(ctx, nil)
+
+	for _, tc := range []struct {
+		user, target, status string
+		expected             int
+	}{
+		{"managerB", "/api/lv_requests/1", "running", http.StatusForbidden},
+		{"api", "/api/lv_requests/1", "started", http.StatusBadRequest},
+		{"api", "/api/lv_requests/2", "done", http.StatusForbidden},
+		{"api", "/api/lv_requests/1", "done", http.StatusOK},
+	} {
+		form := url.Values{"status": {tc.status}, "message": {"extended"}}
+		if rw := doAuthz(t, r, authzUsers[tc.user], "PUT", tc.target, form); rw.Code != tc.expected {
+			t.Errorf("%s PUT %s: expected %d, got %d", tc.user, tc.target, tc.expected, rw.Code)
+		}
+	}
+
+	select {
+	case e := <-events:
+		if e.MachineID != "m1" || e.Change != model.MachineChangeLvRequest || e.LvRequest.Status != model.LVRequestDone {
+			t.Errorf("unexpected event: %+v", e)
+		}
+	default:
+		t.Errorf("update not published")
+	}
+
+	rw = doAuthz(t, r, authzUsers["api"], "GET", "/api/lv_requests/m1?status=pending", nil)
+	if strings.TrimSpace(rw.Body.String()) != "[]" {
+		t.Errorf("request still pending: %s", rw.Body.String())
+	}
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=lv_request", nil)
+	if !strings.Contains(rw.Body.String(), "extended") {
+		t.Errorf("update not audited: %s", rw.Body.String())
+	}
+}
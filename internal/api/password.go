@@ -0,0 +1,181 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// PasswordResetLink is returned once when created, only the hash of its
+// token is stored.
+type PasswordResetLink struct {
+	Username  string `json:"username"`
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+func passwordErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrPasswordInvalid):
+		return http.StatusForbidden
+	case errors.Is(err, auth.ErrLoginThrottled):
+		return http.StatusTooManyRequests
+	case errors.Is(err, auth.ErrPasswordNotLocal):
+		return http.StatusConflict
+	case errors.Is(err, auth.ErrWeakPassword):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// checkPasswordRequest does the checks shared by all password handlers.
+func (api *RestApi) checkPasswordRequest(rw http.ResponseWriter, r *http.Request) bool {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return false
+	}
+	if api.Authentication == nil || api.Authentication.PasswordPolicy == nil {
+		handleError(errors.New("password management not available"), http.StatusServiceUnavailable, rw)
+		return false
+	}
+	return true
+}
+
+// changePassword godoc
+//
+//	@summary    Changes the password of the requesting user
+//	@description Only for local accounts. All other sessions of the user are ended.
+//	@tags       User
+//	@accept     mpfd
+//	@param      current-password    formData    string          true    "Current password"
+//	@param      new-password        formData    string          true    "New password, has to meet the password policy"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden or wrong current password"
+//	@failure    409         {object}    ErrorResponse   "Not a local account"
+//	@failure    422         {object}    ErrorResponse   "New password rejected by the policy"
+//	@failure    429         {object}    ErrorResponse   "Too many failed attempts"
+//	@failure    503         {object}    ErrorResponse   "Password management not available"
+//	@security   ApiKeyAuth
+//	@router     /password [post]
+func (api *RestApi) changePassword(rw http.ResponseWriter, r *http.Request) {
+	if !api.checkPasswordRequest(rw, r) {
+		return
+	}
+
+	me := repository.GetUserFromContext(r.Context())
+	throttle := api.Authentication.LoginThrottle
+	if throttle != nil {
+		if wait, err := throttle.Check(r.Context(), me.Username, ""); err != nil {
+			log.Errorf("checking failed logins of user '%s' failed: %v", me.Username, err)
+		} else if wait > 0 {
+			err := fmt.Errorf("%w, try again in %s", auth.ErrLoginThrottled, wait.Round(time.Second))
+			handleError(err, passwordErrorStatus(err), rw)
+			return
+		}
+	}
+
+	keep := repository.GetSessionIDFromContext(r.Context())
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.LoadUser(ctx, tx, me.Username)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.Authentication.PasswordPolicy.Change(ctx, tx, before,
+			r.FormValue("current-password"), r.FormValue("new-password")); err != nil {
+			return nil, err
+		}
+		if _, err := repository.DeleteUserSessions(ctx, tx, me.Username, keep); err != nil {
+			return nil, err
+		}
+		after, err := repository.LoadUser(ctx, tx, me.Username)
+		if err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditUpdate, resource: "user", resourceID: me.Username,
+			before: map[string]interface{}{"password": before.Password},
+			after:  map[string]interface{}{"password": after.Password}}, nil
+	})
+	if err != nil {
+		if errors.Is(err, auth.ErrPasswordInvalid) && throttle != nil {
+			if err := throttle.Failed(r.Context(), me.Username, util.ClientIP(r)); err != nil {
+				log.Errorf("recording failed password of user '%s' failed: %v", me.Username, err)
+			}
+		}
+		handleError(err, passwordErrorStatus(err), rw)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// resetLinkURL returns the absolute URL of the password reset page for
+// token, as seen by the client of r.
+func resetLinkURL(r *http.Request, token string) string {
+	return requestBaseURL(r) + "/password-reset?" + url.Values{"token": {token}}.Encode()
+}
+
+// createPasswordReset godoc
+//
+//	@summary    Creates a one-time password reset link
+//	@description The link lets the user set a new password once until it expires, earlier links of the user
+//	@description become invalid. Only for local accounts. Only admins may create links.
+//	@tags       User
+//	@accept     mpfd
+//	@produce    json
+//	@param      username    formData    string          true    "User whose password is reset"
+//	@success    200         {object}    api.PasswordResetLink   "Reset link"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "No such user"
+//	@failure    409         {object}    ErrorResponse   "Not a local account"
+//	@failure    503         {object}    ErrorResponse   "Password management not available"
+//	@security   ApiKeyAuth
+//	@router     /password-resets [post]
+func (api *RestApi) createPasswordReset(rw http.ResponseWriter, r *http.Request) {
+	if !api.checkPasswordRequest(rw, r) {
+		return
+	}
+	me := repository.GetUserFromContext(r.Context())
+	if !me.HasRole(schema.RoleAdmin) {
+		handleError(errors.New("only admins are allowed to reset passwords"), http.StatusForbidden, rw)
+		return
+	}
+	username := r.FormValue("username")
+	if username == "" {
+		handleError(errors.New("username is required"), http.StatusBadRequest, rw)
+		return
+	}
+
+	var link PasswordResetLink
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		token, reset, err := api.Authentication.PasswordPolicy.CreateReset(ctx, tx, username, me.Username)
+		if err != nil {
+			return nil, err
+		}
+		link = PasswordResetLink{Username: username, URL: resetLinkURL(r, token), ExpiresAt: reset.ExpiresAt}
+		return &auditRecord{action: repository.AuditCreate, resource: "password_reset", resourceID: username, after: reset}, nil
+	})
+	if err != nil {
+		handleError(err, passwordErrorStatus(err), rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(link)
+}
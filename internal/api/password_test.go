@@ -0,0 +1,104 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/api"
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/gorilla/mux"
+)
+
+func TestPasswordEndpoints(t *testing.T) {
+	_, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, roles, ldap) VALUES ('admin', '["admin"]', 0), ('user', '["user"]', 0), ('managerA', '["manager"]', 1)`); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.SetUserPassword(context.Background(), db, "user", "user-password"); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	if _, err := db.Exec(`INSERT INTO sessions (id, token_hash, username, created_at, last_activity) VALUES ('s1', 'h1', 'user', ?, ?), ('s2', 'h2', 'user', ?, ?)`, now, now, now, now); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := auth.NewPasswordPolicy(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restapi := &api.RestApi{
+		Service:        api.NewService(db),
+		Authentication: &auth.Authentication{PasswordPolicy: policy},
+	}
+	r := mux.NewRouter()
+	restapi.MountRoutes(r)
+
+	change := func(user, current, password string) int {
+		return doAuthz(t, r, authzUsers[user], "POST", "/api/password",
+			url.Values{"current-password": {current}, "new-password": {password}}).Code
+	}
+	if code := change("user", "wrong-password", "new-password"); code != http.StatusForbidden {
+		t.Errorf("expected %d for wrong current password, got %d", http.StatusForbidden, code)
+	}
+	if code := change("user", "user-password", "short"); code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for weak password, got %d", http.StatusUnprocessableEntity, code)
+	}
+	if code := change("managerA", "", "new-password"); code != http.StatusConflict {
+		t.Errorf("expected %d for LDAP user, got %d", http.StatusConflict, code)
+	}
+	if code := change("user", "user-password", "new-password"); code != http.StatusNoContent {
+		t.Errorf("expected %d changing password, got %d", http.StatusNoContent, code)
+	}
+	if code := change("user", "new-password", "newer-password"); code != http.StatusNoContent {
+		t.Errorf("new password not accepted, got %d", code)
+	}
+	if sessions, _ := repository.ListSessions(context.Background(), db, "user"); len(sessions) != 0 {
+		t.Errorf("other sessions not ended: %v", sessions)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["user"], "POST", "/api/password-resets", url.Values{"username": {"admin"}}); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d creating reset link as user, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/password-resets", url.Values{"username": {"managerA"}}); rw.Code != http.StatusConflict {
+		t.Errorf("expected %d creating reset link for LDAP user, got %d", http.StatusConflict, rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/password-resets", url.Values{"username": {"nobody"}}); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d creating reset link for unknown user, got %d", http.StatusNotFound, rw.Code)
+	}
+	rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/password-resets", url.Values{"username": {"user"}})
+	var link api.PasswordResetLink
+	if err := json.Unmarshal(rw.Body.Bytes(), &link); err != nil || link.Username != "user" {
+		t.Fatalf("creating reset link failed: %d %s", rw.Code, rw.Body.String())
+	}
+	u, err := url.Parse(link.URL)
+	if err != nil || u.Path != "/password-reset" {
+		t.Fatalf("unexpected reset link: %s", link.URL)
+	}
+	if username, err := policy.Reset(context.Background(), u.Query().Get("token"), "reset-password", "192.0.2.1"); err != nil || username != "user" {
+		t.Errorf("reset link not usable: %v", err)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/users/", url.Values{"username": {"weak"}, "password": {"weak"}, "role": {"user"}}); rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d creating user with weak password, got %d", http.StatusUnprocessableEntity, rw.Code)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource_id=user", nil)
+	for _, resource := range []string{`"resource":"user"`, `"resource":"password_reset"`, `"authType":"reset-link"`} {
+		if !strings.Contains(rw.Body.String(), resource) {
+			t.Errorf("audit entry %s missing: %s", resource, rw.Body.String())
+		}
+	}
+	if strings.Contains(rw.Body.String(), "$2a$") {
+		t.Errorf("password hash written to audit log")
+	}
+}
@@ -0,0 +1,255 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/probe"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+)
+
+// Connectivity probes check a configuration before (or after) it is saved.
+// If the request carries no configuration, the stored one is checked.
+// Credentials of the stored configuration may be secret references, those
+// in the request must be given in plain text: the probe sends them to a
+// host the request chooses. The response is the diagnostic of the probe,
+// also if the check failed.
+
+func probeOptions(r *http.Request) (probe.Options, error) {
+	opts := probe.Options{}
+	if s := r.FormValue("insecure_skip_verify"); s != "" {
+		skip, err := strconv.ParseBool(s)
+		if err != nil {
+			return opts, err
+		}
+		opts.InsecureSkipVerify = skip
+	}
+	return opts, nil
+}
+
+// literal rejects secret references in credentials taken from the request.
+func literal(values ...string) error {
+	for _, v := range values {
+		if secrets.IsReference(v) && !strings.HasPrefix(v, secrets.SchemePlain+":") {
+			return errors.New("secret references are only resolved for the stored configuration")
+		}
+	}
+	return nil
+}
+
+func writeDiagnostic(rw http.ResponseWriter, d *probe.Diagnostic) {
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(d)
+}
+
+func handleProbeConfigError(err error, rw http.ResponseWriter) {
+	if err == sql.ErrNoRows {
+		handleError(errors.New("no configuration given or stored"), http.StatusNotFound, rw)
+	} else {
+		handleError(err, http.StatusInternalServerError, rw)
+	}
+}
+
+// TestRabbitMQConfig godoc
+//
+//	@summary    Tests the connection to the RabbitMQ broker
+//	@description Performs an AMQP handshake including authentication and opening the virtual host.
+//	@tags       RabbitMQ
+//	@accept     mpfd
+//	@produce    json
+//	@param      conn_url                formData    string          false   "Connection URL, the stored configuration is tested if omitted"
+//	@param      username                formData    string          false   "Username"
+//	@param      password                formData    string          false   "Password"
+//	@param      insecure_skip_verify    formData    bool            false   "Do not verify the TLS certificate"
+//	@success    200         {object}    probe.Diagnostic   "Diagnostic"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /rabbitmq_config/test [post]
+func (api *Service) TestRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	opts, err := probeOptions(r)
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	conf := sqlcdb.RabbitMqConfig{
+		ConnUrl:  r.FormValue("conn_url"),
+		Username: r.FormValue("username"),
+		Password: r.FormValue("password"),
+	}
+	if conf.ConnUrl == "" {
+		if conf, err = api.r.GetRabbitMQConfig(r.Context()); err != nil {
+			handleProbeConfigError(err, rw)
+			return
+		}
+	} else if err := literal(conf.ConnUrl, conf.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	if conf.ConnUrl, err = secrets.ResolveAllowed(r.Context(), conf.ConnUrl); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+	if conf.Password, err = secrets.ResolveAllowed(r.Context(), conf.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	writeDiagnostic(rw, probe.AMQP(r.Context(), conf.ConnUrl, conf.Username, conf.Password, opts))
+}
+
+// influxProbeTarget derives the server URL and credentials from conf. Host
+// may be given with or without scheme.
+func influxProbeTarget(conf sqlcdb.InfluxdbConfiguration) probe.InfluxDBTarget {
+	target := probe.InfluxDBTarget{Org: conf.Organization, Bucket: conf.DatabaseName, Token: conf.Password}
+	if conf.User != "" {
+		target.Token = conf.User + ":" + conf.Password
+	}
+
+	if strings.Contains(conf.Host, "://") {
+		target.URL = conf.Host
+		return target
+	}
+
+	scheme := "http"
+	if conf.SslEnabled {
+		scheme = "https"
+	}
+	host := conf.Host
+	if conf.Port != 0 {
+		host = net.JoinHostPort(conf.Host, strconv.Itoa(int(conf.Port)))
+	}
+	target.URL = fmt.Sprintf("%s://%s", scheme, host)
+	return target
+}
+
+// TestInfluxDBConfig godoc
+//
+//	@summary    Tests the connection to InfluxDB
+//	@description Pings the server, then writes a point to the measurement cc_backend_probe of the bucket (database_name), reads it back and deletes it again.
+//	@tags       InfluxDB
+//	@accept     mpfd
+//	@produce    json
+//	@param      host                    formData    string          false   "Host, the stored configuration is tested if omitted"
+//	@param      port                    formData    int             false   "Port"
+//	@param      ssl_enabled             formData    bool            false   "SSL Enabled"
+//	@param      user                    formData    string          false   "User"
+//	@param      password                formData    string          false   "Password or API token"
+//	@param      organization            formData    string          false   "Organization"
+//	@param      database_name           formData    string          false   "Bucket"
+//	@param      insecure_skip_verify    formData    bool            false   "Do not verify the TLS certificate"
+//	@success    200         {object}    probe.Diagnostic   "Diagnostic"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /influxdb_config/test [post]
+func (api *Service) TestInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	opts, err := probeOptions(r)
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	conf := sqlcdb.InfluxdbConfiguration{
+		Host:         r.FormValue("host"),
+		User:         r.FormValue("user"),
+		Password:     r.FormValue("password"),
+		Organization: r.FormValue("organization"),
+		DatabaseName: r.FormValue("database_name"),
+	}
+	if conf.Host == "" {
+		if conf, err = api.r.GetInfluxDBConfiguration(r.Context()); err != nil {
+			handleProbeConfigError(err, rw)
+			return
+		}
+	} else {
+		if err := literal(conf.Password); err != nil {
+			handleError(err, http.StatusBadRequest, rw)
+			return
+		}
+		if s := r.FormValue("port"); s != "" {
+			port, err := strconv.Atoi(s)
+			if err != nil {
+				handleError(err, http.StatusBadRequest, rw)
+				return
+			}
+			conf.Port = int32(port)
+		}
+		if s := r.FormValue("ssl_enabled"); s != "" {
+			if conf.SslEnabled, err = strconv.ParseBool(s); err != nil {
+				handleError(err, http.StatusBadRequest, rw)
+				return
+			}
+		}
+	}
+
+	if conf.Password, err = secrets.ResolveAllowed(r.Context(), conf.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	writeDiagnostic(rw, probe.InfluxDB(r.Context(), influxProbeTarget(conf), opts))
+}
+
+// TestFileStashURL godoc
+//
+//	@summary    Tests the File Stash URL
+//	@description Sends a HEAD request to the URL and retrieves its listing.
+//	@tags       FileStash
+//	@accept     mpfd
+//	@produce    json
+//	@param      url                     formData    string          false   "File Stash URL, the stored URL is tested if omitted"
+//	@param      insecure_skip_verify    formData    bool            false   "Do not verify the TLS certificate"
+//	@success    200         {object}    probe.Diagnostic   "Diagnostic"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /file_stash_url/test [post]
+func (api *Service) TestFileStashURL(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	opts, err := probeOptions(r)
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	url := r.FormValue("url")
+	if url == "" {
+		stash, err := api.r.GetFileStashURL(r.Context())
+		if err != nil {
+			handleProbeConfigError(err, rw)
+			return
+		}
+		url = stash.Url
+	}
+
+	writeDiagnostic(rw, probe.FileStash(r.Context(), url, opts))
+}
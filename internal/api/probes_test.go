@@ -0,0 +1,62 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/probe"
+)
+
+func TestProbeFileStashURL(t *testing.T) {
+	stash := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet {
+			rw.Write([]byte(`["a.tar", "b.tar"]`))
+		}
+	}))
+	defer stash.Close()
+
+	template := setupAuthzTemplate(t)
+	r := setupAuthzRouter(t, template)
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/file_stash_url/test", nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d without configuration, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	db, err := sql.Open("sqlite3", template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO file_stash_url (id, url) VALUES (1, ?)`, stash.URL); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+	r = setupAuthzRouter(t, template)
+
+	rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/file_stash_url/test", nil)
+	var d probe.Diagnostic
+	if err := json.Unmarshal(rw.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if rw.Code != http.StatusOK || !d.Ok || d.Target != stash.URL || len(d.Steps) != 2 {
+		t.Errorf("unexpected diagnostic for stored URL: %d %s", rw.Code, rw.Body.String())
+	}
+
+	// A URL in the request is tested instead of the stored one
+	rw = doAuthz(t, r, authzUsers["admin"], "POST", "/api/file_stash_url/test", url.Values{"url": {stash.URL + "/x"}})
+	d = probe.Diagnostic{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Target != stash.URL+"/x" {
+		t.Errorf("unexpected target %s", d.Target)
+	}
+}
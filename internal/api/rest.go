@@ -5,13 +5,15 @@
 package api
 
 import (
-	// "database/sql"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -47,12 +49,14 @@ import (
 
 // Service define a service
 type Service struct {
-	r *sqlcdb.Queries
+	db *sql.DB
+	r  *sqlcdb.Queries
 }
 
-func NewService(r *sqlcdb.Queries) *Service {
+func NewService(db *sql.DB) *Service {
 	return &Service{
-		r: r,
+		db: db,
+		r:  sqlcdb.New(db),
 	}
 }
 
@@ -69,8 +73,13 @@ type RestApi struct {
 }
 
 func (api *RestApi) MountRoutes(r *mux.Router) {
+	if api.Authentication != nil {
+		api.mountSCIMRoutes(r)
+	}
+
 	r = r.PathPrefix("/api").Subrouter()
 	r.StrictSlash(true)
+	r.Use(checkScope)
 
 	// r.HandleFunc("/jobs/start_job/", api.startJob).Methods(http.MethodPost, http.MethodPut)
 	// r.HandleFunc("/jobs/stop_job/", api.stopJobByRequest).Methods(http.MethodPost, http.MethodPut)
@@ -92,47 +101,91 @@ func (api *RestApi) MountRoutes(r *mux.Router) {
 
 	if api.Authentication != nil {
 		r.HandleFunc("/jwt/", api.getJWT).Methods(http.MethodGet)
+		r.HandleFunc("/tokens", api.createToken).Methods(http.MethodPost)
+		r.HandleFunc("/tokens", api.getTokens).Methods(http.MethodGet)
+		r.HandleFunc("/tokens/{id}", api.revokeToken).Methods(http.MethodDelete)
+		r.HandleFunc("/totp", api.getTOTP).Methods(http.MethodGet)
+		r.HandleFunc("/totp", api.enrollTOTP).Methods(http.MethodPost)
+		r.HandleFunc("/totp", api.disableTOTP).Methods(http.MethodDelete)
+		r.HandleFunc("/totp/confirm", api.confirmTOTP).Methods(http.MethodPost)
+		r.HandleFunc("/totp/recovery-codes", api.regenerateRecoveryCodes).Methods(http.MethodPost)
+		r.HandleFunc("/sessions", api.getSessions).Methods(http.MethodGet)
+		r.HandleFunc("/sessions", api.deleteSessions).Methods(http.MethodDelete)
+		r.HandleFunc("/sessions/{id}", api.deleteSession).Methods(http.MethodDelete)
+		r.HandleFunc("/password", api.changePassword).Methods(http.MethodPost)
+		r.HandleFunc("/password-resets", api.createPasswordReset).Methods(http.MethodPost)
 		r.HandleFunc("/roles/", api.getRoles).Methods(http.MethodGet)
 		r.HandleFunc("/users/", api.createUser).Methods(http.MethodPost, http.MethodPut)
 		r.HandleFunc("/users/", api.getUsers).Methods(http.MethodGet)
 		r.HandleFunc("/users/", api.deleteUser).Methods(http.MethodDelete)
 		r.HandleFunc("/user/{id}", api.updateUser).Methods(http.MethodPost)
+		r.HandleFunc("/user/{id}", api.patchUser).Methods(http.MethodPatch)
 		r.HandleFunc("/configuration/", api.updateConfiguration).Methods(http.MethodPost)
+		r.HandleFunc("/configuration/", api.resetConfiguration).Methods(http.MethodDelete)
+		r.HandleFunc("/configuration/keys", api.getConfigurationKeys).Methods(http.MethodGet)
+		r.HandleFunc("/configuration/export", api.exportConfiguration).Methods(http.MethodGet)
+		r.HandleFunc("/configuration/import", api.importConfiguration).Methods(http.MethodPost)
+		r.HandleFunc("/configuration/profile", api.pushConfigurationProfile).Methods(http.MethodPut)
+		r.HandleFunc("/audit", api.Service.GetAuditLog).Methods(http.MethodGet)
+		r.HandleFunc("/lockouts", api.Service.GetLoginLockouts).Methods(http.MethodGet)
+		r.HandleFunc("/lockouts/{kind}/{name}", api.Service.UnlockLogin).Methods(http.MethodDelete)
 		// Machine Configuration
 		r.HandleFunc("/machine_conf", api.Service.CreateMachineConf).Methods(http.MethodPost)
 		r.HandleFunc("/machine_conf/{machine_id}", api.Service.GetMachineConf).Methods(http.MethodGet)
+		r.HandleFunc("/machine_conf/{machine_id}/resolved", api.Service.GetResolvedMachineConf).Methods(http.MethodGet)
 		r.HandleFunc("/machine_conf/{id}", api.Service.UpdateMachineConf).Methods(http.MethodPut)
 		r.HandleFunc("/machine_conf/{id}", api.Service.DeleteMachineConf).Methods(http.MethodDelete)
 		// RabbitMQ Configuration
 		r.HandleFunc("/rabbitmq_config", api.Service.CreateRabbitMQConfig).Methods("POST")
 		r.HandleFunc("/rabbitmq_config", api.Service.GetRabbitMQConfig).Methods("GET")
+		r.HandleFunc("/rabbitmq_config/resolved", api.Service.GetResolvedRabbitMQConfig).Methods("GET")
 		r.HandleFunc("/rabbitmq_config", api.Service.UpdateRabbitMQConfig).Methods("PUT")
 		r.HandleFunc("/rabbitmq_config", api.Service.DeleteRabbitMQConfig).Methods("DELETE")
+		r.HandleFunc("/rabbitmq_config/test", api.Service.TestRabbitMQConfig).Methods("POST")
 		// InfluxDB Configuration
 		r.HandleFunc("/influxdb_config", api.Service.CreateInfluxDBConfig).Methods("POST")
 		r.HandleFunc("/influxdb_config", api.Service.GetInfluxDBConfig).Methods("GET")
+		r.HandleFunc("/influxdb_config/resolved", api.Service.GetResolvedInfluxDBConfig).Methods("GET")
 		r.HandleFunc("/influxdb_config", api.Service.UpdateInfluxDBConfig).Methods("PUT")
 		r.HandleFunc("/influxdb_config", api.Service.DeleteInfluxDBConfig).Methods("DELETE")
+		r.HandleFunc("/influxdb_config/test", api.Service.TestInfluxDBConfig).Methods("POST")
 		// File stash Configuration
 		r.HandleFunc("/file_stash_url", api.Service.CreateFileStashURL).Methods("POST")
 		r.HandleFunc("/file_stash_url", api.Service.GetFileStashURL).Methods("GET")
 		r.HandleFunc("/file_stash_url", api.Service.UpdateFileStashURL).Methods("PUT")
 		r.HandleFunc("/file_stash_url", api.Service.DeleteFileStashURL).Methods("DELETE")
+		r.HandleFunc("/file_stash_url/test", api.Service.TestFileStashURL).Methods("POST")
+		// Version history of the configurations above
+		r.HandleFunc("/config/{type}/versions", api.Service.GetConfigVersions).Methods("GET")
+		r.HandleFunc("/config/{type}/versions/{version}", api.Service.GetConfigVersion).Methods("GET")
+		r.HandleFunc("/config/{type}/diff", api.Service.DiffConfigVersions).Methods("GET")
+		r.HandleFunc("/config/{type}/rollback/{version}", api.Service.RollbackConfig).Methods("POST")
+		// Secrets for db: references
+		r.HandleFunc("/secrets", api.Service.GetSecrets).Methods("GET")
+		r.HandleFunc("/secrets/{name}", api.Service.PutSecret).Methods("PUT")
+		r.HandleFunc("/secrets/{name}", api.Service.DeleteSecret).Methods("DELETE")
 		// Machine Configuration
 		r.HandleFunc("/machine", api.Service.CreateMachine).Methods("POST")
 		r.HandleFunc("/machine/{machine_id}", api.Service.GetMachine).Methods("GET")
 		r.HandleFunc("/machine/{machine_id}", api.Service.UpdateMachine).Methods("PUT")
 		r.HandleFunc("/machine/{machine_id}", api.Service.DeleteMachine).Methods("DELETE")
 		r.HandleFunc("/machines", api.Service.ListMachines).Methods("GET")
+		r.HandleFunc("/machine/{machine_id}/groups", api.Service.GetMachineGroups).Methods("GET")
+		r.HandleFunc("/machine/{machine_id}/groups", api.Service.CreateMachineGroup).Methods("POST")
+		r.HandleFunc("/machine/{machine_id}/groups/{group}", api.Service.DeleteMachineGroup).Methods("DELETE")
 		// LV Storage Issuer routes
-		r.HandleFunc("lv_storage_issuer", api.Service.CreateLVStorageIssuer).Methods("POST")
-		r.HandleFunc("lv_storage_issuers", api.Service.GetLVStorageIssuers).Methods("GET")
-		r.HandleFunc("lv_storage_issuer/{id}", api.Service.UpdateLVStorageIssuer).Methods("PUT")
-		r.HandleFunc("lv_storage_issuer/{id}", api.Service.DeleteLVStorageIssuer).Methods("DELETE")
+		r.HandleFunc("/lv_storage_issuer", api.Service.CreateLVStorageIssuer).Methods("POST")
+		r.HandleFunc("/lv_storage_issuers", api.Service.GetLVStorageIssuers).Methods("GET")
+		r.HandleFunc("/lv_storage_issuer/{id}", api.Service.UpdateLVStorageIssuer).Methods("PUT")
+		r.HandleFunc("/lv_storage_issuer/{id}", api.Service.DeleteLVStorageIssuer).Methods("DELETE")
+		// LV requests, polled by the agents
+		r.HandleFunc("/lv_requests/{machine_id}", api.Service.GetLVRequests).Methods("GET")
+		r.HandleFunc("/lv_requests/{id}", api.Service.UpdateLVRequest).Methods("PUT")
 		// Physical Volume routes
-		r.HandleFunc("physical_volumes", api.Service.CreatePhysicalVolume).Methods("POST")
-		r.HandleFunc("physical_volumes/{pv_id}", api.Service.UpdatePhysicalVolume).Methods("PUT")
-		r.HandleFunc("physical_volumes/{pv_id}", api.Service.DeletePhysicalVolume).Methods("DELETE")
+		r.HandleFunc("/physical_volumes", api.Service.CreatePhysicalVolume).Methods("POST")
+		r.HandleFunc("/physical_volumes", api.Service.GetPhysicalVolumes).Methods("GET")
+		r.HandleFunc("/physical_volumes/{pv_id}", api.Service.UpdatePhysicalVolume).Methods("PUT")
+		r.HandleFunc("/physical_volumes/{pv_id}", api.Service.DeletePhysicalVolume).Methods("DELETE")
 
 		// Notification routes
 		r.HandleFunc("/notifications", api.Service.CreateNotification).Methods("POST")
@@ -147,8 +200,8 @@ func (api *RestApi) MountRoutes(r *mux.Router) {
 		// Volume Group routes
 		r.HandleFunc("/volume_groups", api.Service.CreateVolumeGroup).Methods("POST")
 		r.HandleFunc("/volume_groups", api.Service.GetVolumeGroups).Methods("GET")
-		r.HandleFunc("/volume_groups/{id}", api.Service.UpdateVolumeGroup).Methods("PUT")
-		r.HandleFunc("/volume_groups/{id}", api.Service.DeleteVolumeGroup).Methods("DELETE")
+		r.HandleFunc("/volume_groups/{vg_id}", api.Service.UpdateVolumeGroup).Methods("PUT")
+		r.HandleFunc("/volume_groups/{vg_id}", api.Service.DeleteVolumeGroup).Methods("DELETE")
 
 		// Logical Volume routes
 		r.HandleFunc("/logical_volume", api.Service.CreateLogicalVolume).Methods("POST")
@@ -269,17 +322,7 @@ func securedCheck(r *http.Request) error {
 		}
 
 		// extract IP address
-		IPAddress := r.Header.Get("X-Real-Ip")
-		if IPAddress == "" {
-			IPAddress = r.Header.Get("X-Forwarded-For")
-		}
-		if IPAddress == "" {
-			IPAddress = r.RemoteAddr
-		}
-
-		if strings.Contains(IPAddress, ":") {
-			IPAddress = strings.Split(IPAddress, ":")[0]
-		}
+		IPAddress := util.ClientIP(r)
 
 		// check if IP is allowed
 		if !util.Contains(config.Keys.ApiAllowedIPs, IPAddress) {
@@ -308,7 +351,7 @@ func securedCheck(r *http.Request) error {
 //	@failure		400			{string}	string	"Bad Request"
 //	@failure		401			{string}	string	"Unauthorized"
 //	@failure		403			{string}	string	"Forbidden"
-//	@failure		422			{string}	string	"Unprocessable Entity: creating user failed"
+//	@failure		422			{string}	string	"Unprocessable Entity: password rejected by the policy or creating user failed"
 //	@failure		500			{string}	string	"Internal Server Error"
 //	@security		ApiKeyAuth
 //	@router			/users/ [post]
@@ -335,6 +378,13 @@ func (api *RestApi) createUser(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(password) != 0 && api.Authentication.PasswordPolicy != nil {
+		if err := api.Authentication.PasswordPolicy.Check(username, password); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
 	if len(project) != 0 && role != schema.GetRoleString(schema.RoleManager) {
 		http.Error(rw, "only managers require a project (can be changed later)",
 			http.StatusBadRequest)
@@ -402,11 +452,21 @@ func (api *RestApi) deleteUser(rw http.ResponseWriter, r *http.Request) {
 //
 //	@summary		Returns a list of users
 //	@tags			User
-//	@description	Returns a JSON-encoded list of users.
-//	@description	Required query-parameter defines if all users or only users with additional special roles are returned.
+//	@description	Returns a JSON-encoded list of users matching all given filters, the number of all matching users is
+//	@description	returned in the X-Total-Count header. Use format=csv (or Accept: text/csv) to export the users as CSV.
 //	@description	Only accessible from IPs registered with apiAllowedIPs configuration option.
-//	@produce		json
-//	@param			not-just-user	query		bool				true	"If returned list should contain all users or only users with additional special roles"
+//	@produce		json,text/csv
+//	@param			not-just-user	query		bool				false	"Only users with additional special roles"
+//	@param			via-ldap		query		bool				false	"Only users from LDAP (true) or only users not from LDAP (false)"
+//	@param			auth-source		query		string				false	"Comma separated auth sources"	Enums(local, ldap, token, oidc)
+//	@param			role			query		string				false	"Role"	Enums(admin, support, manager, user, api)
+//	@param			project			query		string				false	"Managed project"
+//	@param			search			query		string				false	"Case-insensitive part of username, name or email"
+//	@param			sort			query		string				false	"Sort field, default username"	Enums(username, name, email, auth-source)
+//	@param			order			query		string				false	"Sort order, default asc"	Enums(asc, desc)
+//	@param			limit			query		int					false	"Maximum number of users"
+//	@param			offset			query		int					false	"Number of users to skip"
+//	@param			format			query		string				false	"Output format"	Enums(json, csv)
 //	@success		200				{array}		api.ApiReturnedUser	"List of users returned successfully"
 //	@failure		400				{string}	string				"Bad Request"
 //	@failure		401				{string}	string				"Unauthorized"
@@ -426,12 +486,38 @@ func (api *RestApi) getUsers(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	users, err := repository.GetUserRepository().ListUsers(r.URL.Query().Get("not-just-user") == "true")
+	filter, err := parseUserFilter(r.URL.Query())
 	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	users, err := repository.QueryUsers(r.Context(), api.Service.db, filter)
+	if errors.Is(err, repository.ErrInvalidUserFilter) {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	total := len(users)
+	if filter.Limit != 0 || filter.Offset != 0 {
+		if total, err = repository.CountUsers(r.Context(), api.Service.db, filter); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	rw.Header().Set("X-Total-Count", strconv.Itoa(total))
 
+	if r.URL.Query().Get("format") == "csv" || strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		rw.Header().Set("Content-Disposition", "attachment; filename=\"users.csv\"")
+		if err := writeUsersCSV(rw, users); err != nil {
+			log.Warnf("writing user CSV export failed: %v", err)
+		}
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(rw).Encode(users)
 }
 
@@ -441,6 +527,7 @@ func (api *RestApi) getUsers(rw http.ResponseWriter, r *http.Request) {
 //	@tags			User
 //	@description	Modifies user defined by username (id) in one of four possible ways.
 //	@description	If more than one formValue is set then only the highest priority field is used.
+//	@description	Use PATCH to change several fields, including name and email, at once.
 //	@description	Only accessible from IPs registered with apiAllowedIPs configuration option.
 //	@accept			mpfd
 //	@produce		plain
@@ -475,27 +562,26 @@ func (api *RestApi) updateUser(rw http.ResponseWriter, r *http.Request) {
 	newproj := r.FormValue("add-project")
 	delproj := r.FormValue("remove-project")
 
-	// TODO: Handle anything but roles...
 	if newrole != "" {
-		if err := repository.GetUserRepository().AddRole(r.Context(), mux.Vars(r)["id"], newrole); err != nil {
+		if err := repository.GetUserRepository().AddRole(withAuditActor(r), mux.Vars(r)["id"], newrole); err != nil {
 			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
 			return
 		}
 		rw.Write([]byte("Add Role Success"))
 	} else if delrole != "" {
-		if err := repository.GetUserRepository().RemoveRole(r.Context(), mux.Vars(r)["id"], delrole); err != nil {
+		if err := repository.GetUserRepository().RemoveRole(withAuditActor(r), mux.Vars(r)["id"], delrole); err != nil {
 			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
 			return
 		}
 		rw.Write([]byte("Remove Role Success"))
 	} else if newproj != "" {
-		if err := repository.GetUserRepository().AddProject(r.Context(), mux.Vars(r)["id"], newproj); err != nil {
+		if err := repository.GetUserRepository().AddProject(withAuditActor(r), mux.Vars(r)["id"], newproj); err != nil {
 			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
 			return
 		}
 		rw.Write([]byte("Add Project Success"))
 	} else if delproj != "" {
-		if err := repository.GetUserRepository().RemoveProject(r.Context(), mux.Vars(r)["id"], delproj); err != nil {
+		if err := repository.GetUserRepository().RemoveProject(withAuditActor(r), mux.Vars(r)["id"], delproj); err != nil {
 			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
 			return
 		}
@@ -523,13 +609,28 @@ func (api *RestApi) getJWT(rw http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	user, err := repository.GetUserRepository().GetUser(username)
+	scopes, err := scopesFromForm(r)
 	if err != nil {
-		http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+		http.Error(rw, err.Error(), http.StatusBadRequest)
 		return
 	}
+	opts := auth.TokenOptions{Name: r.FormValue("name"), Scopes: scopes}
+	if opts.Name == "" {
+		opts.Name = "default"
+	}
 
-	jwt, err := api.Authentication.JwtAuth.ProvideJWT(user)
+	// Tokens for themselves get the roles the request was authenticated with
+	user := me
+	if username != me.Username {
+		if user, err = repository.GetUserRepository().GetUser(username); err != nil {
+			http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	} else if err := selfIssued(me, &opts); err != nil {
+		http.Error(rw, err.Error(), http.StatusForbidden)
+		return
+	}
+	jwt, _, err := api.Authentication.JwtAuth.IssueToken(r.Context(), api.Service.db, user, opts)
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
 		return
@@ -561,15 +662,30 @@ func (api *RestApi) getRoles(rw http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(rw).Encode(roles)
 }
 
+// updateConfiguration godoc
+//
+//	@summary		Changes a setting of the web UI for the requesting user
+//	@tags			UIConfig
+//	@description	The value has to match the schema of the key, see /configuration/keys.
+//	@accept			mpfd
+//	@produce		plain
+//	@param			key		formData	string	true	"Key, for per cluster keys optionally suffixed by ':<cluster>'"
+//	@param			value	formData	string	true	"JSON encoded value"
+//	@success		200		{string}	string	"success"
+//	@failure		422		{string}	string	"Unknown key or invalid value"
+//	@failure		500		{string}	string	"Internal Server Error"
+//	@security		ApiKeyAuth
+//	@router			/configuration/ [post]
 func (api *RestApi) updateConfiguration(rw http.ResponseWriter, r *http.Request) {
 	rw.Header().Set("Content-Type", "text/plain")
 	key, value := r.FormValue("key"), r.FormValue("value")
 
-	fmt.Printf("REST > KEY: %#v\nVALUE: %#v\n", key, value)
-
-	if err := repository.GetUserCfgRepo().UpdateConfig(key, value, repository.GetUserFromContext(r.Context())); err != nil {
+	if err := repository.GetUserCfgRepo().UpdateConfig(key, value, repository.GetUserFromContext(r.Context())); errors.Is(err, schema.ErrInvalidUIConfig) {
 		http.Error(rw, err.Error(), http.StatusUnprocessableEntity)
 		return
+	} else if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	rw.Write([]byte("success"))
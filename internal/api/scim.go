@@ -0,0 +1,993 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+// SCIM 2.0 (RFC 7643, RFC 7644) lets identity providers create, update and
+// deprovision users. The id of a user is its username. The groups are the
+// fixed roles, changing the members of a group changes the roles of users.
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+const (
+	scimSchemaUser         = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimSchemaGroup        = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	scimSchemaListResponse = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimSchemaError        = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimSchemaSPConfig     = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+
+	scimContentType = "application/scim+json"
+)
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+}
+
+// SCIMMultiValue is an entry of a multi-valued attribute like emails,
+// groups or members.
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+type SCIMMeta struct {
+	ResourceType string `json:"resourceType"`
+	Location     string `json:"location"`
+}
+
+type SCIMUser struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName"`
+	Name        *SCIMName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []SCIMMultiValue `json:"emails,omitempty"`
+	Active      *bool            `json:"active,omitempty"`
+	Password    string           `json:"password,omitempty"`
+	Groups      []SCIMMultiValue `json:"groups,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
+type SCIMGroup struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	DisplayName string           `json:"displayName"`
+	Members     []SCIMMultiValue `json:"members,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
+type SCIMListResponse struct {
+	Schemas      []string    `json:"schemas"`
+	TotalResults int         `json:"totalResults"`
+	StartIndex   int         `json:"startIndex"`
+	ItemsPerPage int         `json:"itemsPerPage"`
+	Resources    interface{} `json:"Resources"`
+}
+
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type SCIMPatchOp struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail"`
+}
+
+// scimStatusError is an error with the status and SCIM error type of the
+// response it results in.
+type scimStatusError struct {
+	status   int
+	scimType string
+	detail   string
+}
+
+func (e *scimStatusError) Error() string {
+	return e.detail
+}
+
+func scimErrorf(status int, scimType, format string, args ...interface{}) error {
+	return &scimStatusError{status: status, scimType: scimType, detail: fmt.Sprintf(format, args...)}
+}
+
+func writeSCIM(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", scimContentType)
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Warnf("writing SCIM response failed: %v", err)
+	}
+}
+
+func writeSCIMError(rw http.ResponseWriter, err error) {
+	status, scimType := http.StatusInternalServerError, ""
+	var se *scimStatusError
+	switch {
+	case errors.As(err, &se):
+		status, scimType = se.status, se.scimType
+	case errors.Is(err, sql.ErrNoRows):
+		status = http.StatusNotFound
+	case errors.Is(err, repository.ErrInvalidUser), errors.Is(err, auth.ErrWeakPassword):
+		status, scimType = http.StatusBadRequest, "invalidValue"
+	}
+	if status >= http.StatusInternalServerError {
+		log.Warnf("SCIM request failed: %v", err)
+	}
+	writeSCIM(rw, status, SCIMError{
+		Schemas:  []string{scimSchemaError},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   err.Error(),
+	})
+}
+
+// mountSCIMRoutes mounts the SCIM endpoints below /scim/v2. Like all routes
+// not listed in auth.routeScopes, they need the admin scope for scoped tokens.
+func (api *RestApi) mountSCIMRoutes(r *mux.Router) {
+	r = r.PathPrefix("/scim/v2").Subrouter()
+	r.Use(checkScope)
+
+	r.HandleFunc("/ServiceProviderConfig", api.scimServiceProviderConfig).Methods(http.MethodGet)
+	r.HandleFunc("/Users", api.scimListUsers).Methods(http.MethodGet)
+	r.HandleFunc("/Users", api.scimCreateUser).Methods(http.MethodPost)
+	r.HandleFunc("/Users/{id}", api.scimGetUser).Methods(http.MethodGet)
+	r.HandleFunc("/Users/{id}", api.scimReplaceUser).Methods(http.MethodPut)
+	r.HandleFunc("/Users/{id}", api.scimPatchUser).Methods(http.MethodPatch)
+	r.HandleFunc("/Users/{id}", api.scimDeleteUser).Methods(http.MethodDelete)
+	r.HandleFunc("/Groups", api.scimListGroups).Methods(http.MethodGet)
+	r.HandleFunc("/Groups/{id}", api.scimGetGroup).Methods(http.MethodGet)
+	r.HandleFunc("/Groups/{id}", api.scimReplaceGroup).Methods(http.MethodPut)
+	r.HandleFunc("/Groups/{id}", api.scimPatchGroup).Methods(http.MethodPatch)
+}
+
+// checkSCIMRequest does the checks shared by all SCIM handlers, only admins
+// may provision users.
+func checkSCIMRequest(rw http.ResponseWriter, r *http.Request) bool {
+	if err := securedCheck(r); err != nil {
+		writeSCIMError(rw, scimErrorf(http.StatusForbidden, "", "%s", err.Error()))
+		return false
+	}
+	if user := repository.GetUserFromContext(r.Context()); !user.HasRole(schema.RoleAdmin) {
+		writeSCIMError(rw, scimErrorf(http.StatusForbidden, "", "only admins are allowed to provision users"))
+		return false
+	}
+	return true
+}
+
+func decodeSCIM(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return scimErrorf(http.StatusBadRequest, "invalidSyntax", "parsing request body failed: %s", err.Error())
+	}
+	return nil
+}
+
+var scimFilterRe = regexp.MustCompile(`(?i)^\s*([a-z][a-z0-9.]*)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// parseSCIMFilter parses filters of the form `attribute eq "value"`, the
+// only ones supported. The attribute is returned in lower case.
+func parseSCIMFilter(filter string) (string, string, error) {
+	m := scimFilterRe.FindStringSubmatch(filter)
+	if m == nil {
+		return "", "", scimErrorf(http.StatusBadRequest, "invalidFilter", "unsupported filter '%s', only 'attribute eq \"value\"' is supported", filter)
+	}
+	value, err := strconv.Unquote(`"` + m[2] + `"`)
+	if err != nil {
+		return "", "", scimErrorf(http.StatusBadRequest, "invalidFilter", "invalid filter value in '%s'", filter)
+	}
+	return strings.ToLower(m[1]), value, nil
+}
+
+// scimPage returns the bounds of the page of n resources requested by the
+// startIndex and count query parameters, and the 1-based start index.
+func scimPage(r *http.Request, n int) (int, int, int) {
+	startIndex, err := strconv.Atoi(r.URL.Query().Get("startIndex"))
+	if err != nil || startIndex < 1 {
+		startIndex = 1
+	}
+	start := min(startIndex-1, n)
+	end := n
+	if count, err := strconv.Atoi(r.URL.Query().Get("count")); err == nil {
+		end = min(start+max(count, 0), n)
+	}
+	return start, end, startIndex
+}
+
+func scimListResponse(resources interface{}, total, items, startIndex int) *SCIMListResponse {
+	return &SCIMListResponse{
+		Schemas:      []string{scimSchemaListResponse},
+		TotalResults: total,
+		StartIndex:   startIndex,
+		ItemsPerPage: items,
+		Resources:    resources,
+	}
+}
+
+func (api *RestApi) scimServiceProviderConfig(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	writeSCIM(rw, http.StatusOK, map[string]interface{}{
+		"schemas":        []string{scimSchemaSPConfig},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": 0},
+		"changePassword": map[string]bool{"supported": true},
+		"sort":           map[string]bool{"supported": false},
+		"etag":           map[string]bool{"supported": false},
+		"authenticationSchemes": []map[string]string{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "API token of an admin",
+		}},
+	})
+}
+
+func scimUserFromUser(base string, user *schema.User) *SCIMUser {
+	active := !user.Disabled
+	s := &SCIMUser{
+		Schemas:     []string{scimSchemaUser},
+		ID:          user.Username,
+		UserName:    user.Username,
+		DisplayName: user.Name,
+		Active:      &active,
+		Meta: &SCIMMeta{
+			ResourceType: "User",
+			Location:     base + "/scim/v2/Users/" + url.PathEscape(user.Username),
+		},
+	}
+	if user.Name != "" {
+		s.Name = &SCIMName{Formatted: user.Name}
+	}
+	if user.Email != "" {
+		s.Emails = []SCIMMultiValue{{Value: user.Email, Type: "work", Primary: true}}
+	}
+	for _, role := range user.Roles {
+		s.Groups = append(s.Groups, SCIMMultiValue{
+			Value:   role,
+			Display: role,
+			Ref:     base + "/scim/v2/Groups/" + role,
+		})
+	}
+	return s
+}
+
+// fullName returns the name to store for s.
+func (s *SCIMUser) fullName() string {
+	if s.Name != nil {
+		if name := s.Name.fullName(); name != "" {
+			return name
+		}
+	}
+	return s.DisplayName
+}
+
+func (n *SCIMName) fullName() string {
+	if n.Formatted != "" {
+		return n.Formatted
+	}
+	return strings.TrimSpace(n.GivenName + " " + n.FamilyName)
+}
+
+// scimEmail returns the primary or else the first email of emails.
+func scimEmail(emails []SCIMMultiValue) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+	if len(emails) != 0 {
+		return emails[0].Value
+	}
+	return ""
+}
+
+func (api *RestApi) checkSCIMPassword(username, password string) error {
+	if password == "" || api.Authentication.PasswordPolicy == nil {
+		return nil
+	}
+	return api.Authentication.PasswordPolicy.Check(username, password)
+}
+
+func (api *RestApi) scimListUsers(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+
+	match := func(*schema.User) bool { return true }
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attr, value, err := parseSCIMFilter(filter)
+		if err != nil {
+			writeSCIMError(rw, err)
+			return
+		}
+		switch attr {
+		case "username", "id":
+			match = func(u *schema.User) bool { return strings.EqualFold(u.Username, value) }
+		case "emails", "emails.value":
+			match = func(u *schema.User) bool { return strings.EqualFold(u.Email, value) }
+		default:
+			writeSCIMError(rw, scimErrorf(http.StatusBadRequest, "invalidFilter", "filtering by '%s' is not supported", attr))
+			return
+		}
+	}
+
+	users, err := repository.LoadUsers(r.Context(), api.Service.db, false)
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	base := requestBaseURL(r)
+	matching := make([]*SCIMUser, 0, len(users))
+	for _, user := range users {
+		if match(user) {
+			matching = append(matching, scimUserFromUser(base, user))
+		}
+	}
+	start, end, startIndex := scimPage(r, len(matching))
+	writeSCIM(rw, http.StatusOK, scimListResponse(matching[start:end], len(matching), end-start, startIndex))
+}
+
+func (api *RestApi) scimGetUser(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	user, err := repository.LoadUser(r.Context(), api.Service.db, mux.Vars(r)["id"])
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	writeSCIM(rw, http.StatusOK, scimUserFromUser(requestBaseURL(r), user))
+}
+
+// scimCreateUser creates a user with the role user. Users with a password
+// log in locally, all others via OpenID Connect.
+func (api *RestApi) scimCreateUser(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	var s SCIMUser
+	if err := decodeSCIM(r, &s); err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	if s.UserName == "" {
+		writeSCIMError(rw, scimErrorf(http.StatusBadRequest, "invalidValue", "userName is required"))
+		return
+	}
+	if err := api.checkSCIMPassword(s.UserName, s.Password); err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+
+	user := &schema.User{
+		Username:   s.UserName,
+		Name:       s.fullName(),
+		Email:      scimEmail(s.Emails),
+		Password:   s.Password,
+		Roles:      []string{schema.GetRoleString(schema.RoleUser)},
+		Projects:   []string{},
+		AuthSource: schema.AuthViaOIDC,
+		Disabled:   s.Active != nil && !*s.Active,
+	}
+	if s.Password != "" {
+		user.AuthSource = schema.AuthViaLocalPassword
+	}
+
+	var created *schema.User
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		if _, err := repository.LoadUser(ctx, tx, user.Username); err == nil {
+			return nil, scimErrorf(http.StatusConflict, "uniqueness", "user '%s' already exists", user.Username)
+		} else if err != sql.ErrNoRows {
+			return nil, err
+		}
+		if err := repository.InsertUser(ctx, tx, user); err != nil {
+			return nil, err
+		}
+		var err error
+		if created, err = repository.LoadUser(ctx, tx, user.Username); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditCreate, resource: "user", resourceID: user.Username,
+			after: auditUser(created)}, nil
+	})
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+
+	res := scimUserFromUser(requestBaseURL(r), created)
+	rw.Header().Set("Location", res.Meta.Location)
+	writeSCIM(rw, http.StatusCreated, res)
+}
+
+// scimUpdateUser applies the update returned by change for the current
+// state of the user and responds with the updated user.
+func (api *RestApi) scimUpdateUser(rw http.ResponseWriter, r *http.Request,
+	change func(user *schema.User) (*repository.UserUpdate, error),
+) {
+	username := mux.Vars(r)["id"]
+	var updated *schema.User
+	var revoked []string
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		current, err := repository.LoadUser(ctx, tx, username)
+		if err != nil {
+			return nil, err
+		}
+		update, err := change(current)
+		if err != nil {
+			return nil, err
+		}
+		if update.Password != nil {
+			if err := api.checkSCIMPassword(username, *update.Password); err != nil {
+				return nil, err
+			}
+		}
+		before, after, ids, err := updateUserTx(ctx, tx, username, update)
+		if err != nil {
+			return nil, err
+		}
+		updated, revoked = after, ids
+		return &auditRecord{action: repository.AuditUpdate, resource: "user", resourceID: username,
+			before: auditUser(before), after: auditUser(after)}, nil
+	})
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	api.tokensRevoked(revoked)
+	writeSCIM(rw, http.StatusOK, scimUserFromUser(requestBaseURL(r), updated))
+}
+
+// scimReplaceUser replaces name, email, active and, if given, the password
+// of a user. Its groups are read-only here.
+func (api *RestApi) scimReplaceUser(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	var s SCIMUser
+	if err := decodeSCIM(r, &s); err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+
+	api.scimUpdateUser(rw, r, func(user *schema.User) (*repository.UserUpdate, error) {
+		if s.UserName != "" && s.UserName != user.Username {
+			return nil, scimErrorf(http.StatusBadRequest, "mutability", "userName cannot be changed")
+		}
+		name, email := s.fullName(), scimEmail(s.Emails)
+		update := &repository.UserUpdate{Name: &name, Email: &email}
+		if s.Active != nil {
+			disabled := !*s.Active
+			update.Disabled = &disabled
+		}
+		if s.Password != "" {
+			update.Password = &s.Password
+		}
+		return update, nil
+	})
+}
+
+func (api *RestApi) scimPatchUser(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	var patch SCIMPatchOp
+	if err := decodeSCIM(r, &patch); err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+
+	api.scimUpdateUser(rw, r, func(user *schema.User) (*repository.UserUpdate, error) {
+		return scimUserPatch(user, patch.Operations)
+	})
+}
+
+func scimString(value json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(value, &s); err != nil {
+		return "", scimErrorf(http.StatusBadRequest, "invalidValue", "expected a string, got %s", value)
+	}
+	return s, nil
+}
+
+// scimBool accepts booleans and, as sent by some identity providers,
+// strings like "True".
+func scimBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(strings.ToLower(s)); err == nil {
+			return b, nil
+		}
+	}
+	return false, scimErrorf(http.StatusBadRequest, "invalidValue", "expected a boolean, got %s", value)
+}
+
+// splitName splits name at its first space into given and family name.
+func splitName(name string) (string, string) {
+	given, family, _ := strings.Cut(name, " ")
+	return given, family
+}
+
+// scimUserPatch translates the PATCH operations for user into an update.
+func scimUserPatch(user *schema.User, ops []SCIMPatchOperation) (*repository.UserUpdate, error) {
+	name, email, disabled := user.Name, user.Email, user.Disabled
+	var password *string
+
+	var apply func(path string, value json.RawMessage, remove bool) error
+	apply = func(path string, value json.RawMessage, remove bool) error {
+		var err error
+		lower := strings.ToLower(path)
+		switch {
+		case lower == "username":
+			var s string
+			if !remove {
+				s, err = scimString(value)
+			}
+			if err == nil && s != user.Username {
+				err = scimErrorf(http.StatusBadRequest, "mutability", "userName cannot be changed")
+			}
+		case lower == "displayname", lower == "name.formatted":
+			name = ""
+			if !remove {
+				name, err = scimString(value)
+			}
+		case lower == "name":
+			name = ""
+			if !remove {
+				var n SCIMName
+				if err = json.Unmarshal(value, &n); err != nil {
+					return scimErrorf(http.StatusBadRequest, "invalidValue", "invalid name %s", value)
+				}
+				name = n.fullName()
+			}
+		case lower == "name.givenname", lower == "name.familyname":
+			var s string
+			if !remove {
+				s, err = scimString(value)
+			}
+			given, family := splitName(name)
+			if lower == "name.givenname" {
+				given = s
+			} else {
+				family = s
+			}
+			name = strings.TrimSpace(given + " " + family)
+		case lower == "emails":
+			email = ""
+			if !remove {
+				var emails []SCIMMultiValue
+				if err = json.Unmarshal(value, &emails); err != nil {
+					return scimErrorf(http.StatusBadRequest, "invalidValue", "invalid emails %s", value)
+				}
+				email = scimEmail(emails)
+			}
+		case lower == "emails.value", strings.HasPrefix(lower, "emails[") && strings.HasSuffix(lower, "].value"):
+			email = ""
+			if !remove {
+				email, err = scimString(value)
+			}
+		case lower == "active":
+			if remove {
+				return scimErrorf(http.StatusBadRequest, "mutability", "active cannot be removed")
+			}
+			var active bool
+			active, err = scimBool(value)
+			disabled = !active
+		case lower == "password":
+			s := ""
+			if !remove {
+				s, err = scimString(value)
+			}
+			password = &s
+		case lower == "externalid", lower == "schemas", lower == "id", lower == "meta":
+			// Not stored
+		case lower == "":
+			var attrs map[string]json.RawMessage
+			if err := json.Unmarshal(value, &attrs); err != nil {
+				return scimErrorf(http.StatusBadRequest, "invalidValue", "expected an object, got %s", value)
+			}
+			for attr, value := range attrs {
+				if attr == "" {
+					continue
+				}
+				if err := apply(attr, value, false); err != nil {
+					return err
+				}
+			}
+		default:
+			return scimErrorf(http.StatusBadRequest, "invalidPath", "unsupported attribute '%s'", path)
+		}
+		return err
+	}
+
+	for _, op := range ops {
+		switch strings.ToLower(op.Op) {
+		case "add", "replace":
+			if err := apply(op.Path, op.Value, false); err != nil {
+				return nil, err
+			}
+		case "remove":
+			if op.Path == "" {
+				return nil, scimErrorf(http.StatusBadRequest, "noTarget", "remove needs a path")
+			}
+			if err := apply(op.Path, nil, true); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, scimErrorf(http.StatusBadRequest, "invalidSyntax", "unsupported operation '%s'", op.Op)
+		}
+	}
+	return &repository.UserUpdate{Name: &name, Email: &email, Disabled: &disabled, Password: password}, nil
+}
+
+// scimDeleteUser deletes a user, its sessions and API tokens.
+func (api *RestApi) scimDeleteUser(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	username := mux.Vars(r)["id"]
+	var revoked []string
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.LoadUser(ctx, tx, username)
+		if err != nil {
+			return nil, err
+		}
+		if revoked, err = repository.DeleteUser(ctx, tx, username); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditDelete, resource: "user", resourceID: username,
+			before: auditUser(before)}, nil
+	})
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	api.tokensRevoked(revoked)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// scimGroupIDs are the roles that can be assigned as groups.
+var scimGroupIDs = []string{
+	schema.GetRoleString(schema.RoleAdmin),
+	schema.GetRoleString(schema.RoleSupport),
+	schema.GetRoleString(schema.RoleManager),
+	schema.GetRoleString(schema.RoleUser),
+	schema.GetRoleString(schema.RoleApi),
+}
+
+func isSCIMGroup(id string) bool {
+	for _, group := range scimGroupIDs {
+		if group == id {
+			return true
+		}
+	}
+	return false
+}
+
+// scimGroupMembers returns the sorted usernames of the users with role.
+func scimGroupMembers(users []*schema.User, role string) []string {
+	members := []string{}
+	for _, user := range users {
+		for _, r := range user.Roles {
+			if r == role {
+				members = append(members, user.Username)
+				break
+			}
+		}
+	}
+	sort.Strings(members)
+	return members
+}
+
+func scimGroup(base, role string, members []string) *SCIMGroup {
+	g := &SCIMGroup{
+		Schemas:     []string{scimSchemaGroup},
+		ID:          role,
+		DisplayName: role,
+		Meta:        &SCIMMeta{ResourceType: "Group", Location: base + "/scim/v2/Groups/" + role},
+	}
+	for _, member := range members {
+		g.Members = append(g.Members, SCIMMultiValue{
+			Value:   member,
+			Display: member,
+			Ref:     base + "/scim/v2/Users/" + url.PathEscape(member),
+		})
+	}
+	return g
+}
+
+func (api *RestApi) scimListGroups(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+
+	groups := scimGroupIDs
+	if filter := r.URL.Query().Get("filter"); filter != "" {
+		attr, value, err := parseSCIMFilter(filter)
+		if err != nil {
+			writeSCIMError(rw, err)
+			return
+		}
+		if attr != "displayname" && attr != "id" {
+			writeSCIMError(rw, scimErrorf(http.StatusBadRequest, "invalidFilter", "filtering by '%s' is not supported", attr))
+			return
+		}
+		groups = nil
+		for _, group := range scimGroupIDs {
+			if strings.EqualFold(group, value) {
+				groups = append(groups, group)
+			}
+		}
+	}
+	withMembers := !strings.Contains(strings.ToLower(r.URL.Query().Get("excludedAttributes")), "members")
+
+	users, err := repository.LoadUsers(r.Context(), api.Service.db, false)
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	base := requestBaseURL(r)
+	start, end, startIndex := scimPage(r, len(groups))
+	page := make([]*SCIMGroup, 0, end-start)
+	for _, group := range groups[start:end] {
+		var members []string
+		if withMembers {
+			members = scimGroupMembers(users, group)
+		}
+		page = append(page, scimGroup(base, group, members))
+	}
+	writeSCIM(rw, http.StatusOK, scimListResponse(page, len(groups), len(page), startIndex))
+}
+
+func (api *RestApi) scimGetGroup(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	role := mux.Vars(r)["id"]
+	if !isSCIMGroup(role) {
+		writeSCIMError(rw, scimErrorf(http.StatusNotFound, "", "no group '%s'", role))
+		return
+	}
+	users, err := repository.LoadUsers(r.Context(), api.Service.db, false)
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	writeSCIM(rw, http.StatusOK, scimGroup(requestBaseURL(r), role, scimGroupMembers(users, role)))
+}
+
+// scimSetMembers changes the members of the group of a role to those
+// returned by change for the current members. Each user whose roles change
+// is audited as well as the group.
+func (api *RestApi) scimSetMembers(rw http.ResponseWriter, r *http.Request,
+	change func(members map[string]bool) (map[string]bool, error),
+) {
+	role := mux.Vars(r)["id"]
+	if !isSCIMGroup(role) {
+		writeSCIMError(rw, scimErrorf(http.StatusNotFound, "", "no group '%s'", role))
+		return
+	}
+
+	var after []string
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		users, err := repository.LoadUsers(ctx, tx, false)
+		if err != nil {
+			return nil, err
+		}
+		before := scimGroupMembers(users, role)
+		current := make(map[string]bool, len(before))
+		for _, member := range before {
+			current[member] = true
+		}
+		members := make(map[string]bool, len(current))
+		for member := range current {
+			members[member] = true
+		}
+		target, err := change(members)
+		if err != nil {
+			return nil, err
+		}
+
+		byName := make(map[string]*schema.User, len(users))
+		for _, user := range users {
+			byName[user.Username] = user
+		}
+		for member := range target {
+			if byName[member] == nil {
+				return nil, scimErrorf(http.StatusBadRequest, "invalidValue", "no user '%s'", member)
+			}
+		}
+
+		after = []string{}
+		for _, user := range users {
+			if target[user.Username] {
+				after = append(after, user.Username)
+			}
+			if target[user.Username] == current[user.Username] {
+				continue
+			}
+			roles := []string{}
+			for _, r := range user.Roles {
+				if r != role {
+					roles = append(roles, r)
+				}
+			}
+			if target[user.Username] {
+				roles = append(roles, role)
+			}
+			prev, next, err := repository.UpdateUser(ctx, tx, user.Username, &repository.UserUpdate{Roles: &roles})
+			if err != nil {
+				return nil, err
+			}
+			if err := repository.RecordAuditFromContext(ctx, tx, repository.AuditUpdate, "user", user.Username,
+				auditUser(prev), auditUser(next)); err != nil {
+				return nil, err
+			}
+		}
+		sort.Strings(after)
+		return &auditRecord{action: repository.AuditUpdate, resource: "group", resourceID: role,
+			before: map[string]interface{}{"members": before},
+			after:  map[string]interface{}{"members": after}}, nil
+	})
+	if err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+	writeSCIM(rw, http.StatusOK, scimGroup(requestBaseURL(r), role, after))
+}
+
+// scimMemberValues returns the usernames of a members value.
+func scimMemberValues(value json.RawMessage) ([]string, error) {
+	var members []SCIMMultiValue
+	if err := json.Unmarshal(value, &members); err != nil {
+		return nil, scimErrorf(http.StatusBadRequest, "invalidValue", "invalid members %s", value)
+	}
+	usernames := make([]string, 0, len(members))
+	for _, member := range members {
+		usernames = append(usernames, member.Value)
+	}
+	return usernames, nil
+}
+
+var scimMemberFilterRe = regexp.MustCompile(`(?i)^members\[(.*)\]$`)
+
+func (api *RestApi) scimReplaceGroup(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	var g SCIMGroup
+	if err := decodeSCIM(r, &g); err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+
+	api.scimSetMembers(rw, r, func(map[string]bool) (map[string]bool, error) {
+		if g.DisplayName != "" && g.DisplayName != mux.Vars(r)["id"] {
+			return nil, scimErrorf(http.StatusBadRequest, "mutability", "groups cannot be renamed")
+		}
+		target := make(map[string]bool, len(g.Members))
+		for _, member := range g.Members {
+			target[member.Value] = true
+		}
+		return target, nil
+	})
+}
+
+// scimPatchGroup adds, removes or replaces members of a group, also with
+// paths like `members[value eq "username"]`.
+func (api *RestApi) scimPatchGroup(rw http.ResponseWriter, r *http.Request) {
+	if !checkSCIMRequest(rw, r) {
+		return
+	}
+	var patch SCIMPatchOp
+	if err := decodeSCIM(r, &patch); err != nil {
+		writeSCIMError(rw, err)
+		return
+	}
+
+	api.scimSetMembers(rw, r, func(members map[string]bool) (map[string]bool, error) {
+		for _, op := range patch.Operations {
+			kind := strings.ToLower(op.Op)
+			if kind != "add" && kind != "replace" && kind != "remove" {
+				return nil, scimErrorf(http.StatusBadRequest, "invalidSyntax", "unsupported operation '%s'", op.Op)
+			}
+
+			path, value := strings.ToLower(op.Path), op.Value
+			if path == "" && kind != "remove" {
+				var attrs map[string]json.RawMessage
+				if err := json.Unmarshal(value, &attrs); err != nil {
+					return nil, scimErrorf(http.StatusBadRequest, "invalidValue", "expected an object, got %s", value)
+				}
+				if name, ok := attrs["displayName"]; ok {
+					if s, err := scimString(name); err != nil || s != mux.Vars(r)["id"] {
+						return nil, scimErrorf(http.StatusBadRequest, "mutability", "groups cannot be renamed")
+					}
+				}
+				v, ok := attrs["members"]
+				if !ok {
+					continue
+				}
+				path, value = "members", v
+			}
+
+			var usernames []string
+			switch {
+			case path == "members" && kind == "remove" && len(value) == 0:
+				for member := range members {
+					usernames = append(usernames, member)
+				}
+			case path == "members":
+				var err error
+				if usernames, err = scimMemberValues(value); err != nil {
+					return nil, err
+				}
+			case scimMemberFilterRe.MatchString(path) && kind == "remove":
+				attr, username, err := parseSCIMFilter(scimMemberFilterRe.FindStringSubmatch(op.Path)[1])
+				if err != nil {
+					return nil, err
+				}
+				if attr != "value" {
+					return nil, scimErrorf(http.StatusBadRequest, "invalidFilter", "members can only be selected by value")
+				}
+				usernames = []string{username}
+			case path == "displayname":
+				if s, err := scimString(value); err != nil || s != mux.Vars(r)["id"] {
+					return nil, scimErrorf(http.StatusBadRequest, "mutability", "groups cannot be renamed")
+				}
+				continue
+			default:
+				return nil, scimErrorf(http.StatusBadRequest, "invalidPath", "unsupported path '%s'", op.Path)
+			}
+
+			if kind == "replace" {
+				members = make(map[string]bool, len(usernames))
+			}
+			for _, username := range usernames {
+				if kind == "remove" {
+					delete(members, username)
+				} else {
+					members[username] = true
+				}
+			}
+		}
+		return members, nil
+	})
+}
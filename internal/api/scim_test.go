@@ -0,0 +1,181 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/api"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+func TestSCIMUsers(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, roles, ldap) VALUES ('admin', '["admin"]', 0)`); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	admin := authzUsers["admin"]
+
+	if rw := doJSON(t, r, authzUsers["user"], "GET", "/scim/v2/Users", ""); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for non-admin, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	rw := doJSON(t, r, admin, "POST", "/scim/v2/Users", `{
+		"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+		"userName": "alice",
+		"name": {"givenName": "Alice", "familyName": "Smith"},
+		"emails": [{"value": "alice@example.com", "type": "work", "primary": true}],
+		"active": true}`)
+	var created api.SCIMUser
+	if err := json.Unmarshal(rw.Body.Bytes(), &created); err != nil || rw.Code != http.StatusCreated {
+		t.Fatalf("creating user failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if created.ID != "alice" || rw.Header().Get("Location") != created.Meta.Location ||
+		rw.Header().Get("Content-Type") != "application/scim+json" {
+		t.Errorf("unexpected response: %+v %v", created, rw.Header())
+	}
+	user, err := repository.LoadUser(ctx, db, "alice")
+	if err != nil || user.Name != "Alice Smith" || user.Email != "alice@example.com" ||
+		user.AuthSource != schema.AuthViaOIDC || !user.HasRole(schema.RoleUser) {
+		t.Errorf("unexpected user: %+v %v", user, err)
+	}
+	if rw := doJSON(t, r, admin, "POST", "/scim/v2/Users", `{"userName": "alice"}`); rw.Code != http.StatusConflict ||
+		!strings.Contains(rw.Body.String(), `"scimType":"uniqueness"`) {
+		t.Errorf("expected %d for existing user, got %d %s", http.StatusConflict, rw.Code, rw.Body.String())
+	}
+
+	var list api.SCIMListResponse
+	rw = doJSON(t, r, admin, "GET", `/scim/v2/Users?filter=userName+eq+%22ALICE%22`, "")
+	if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil || list.TotalResults != 1 {
+		t.Errorf("filter by userName failed: %s", rw.Body.String())
+	}
+	rw = doJSON(t, r, admin, "GET", "/scim/v2/Users?startIndex=2&count=5", "")
+	if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil || list.TotalResults != 2 || list.ItemsPerPage != 1 ||
+		!strings.Contains(rw.Body.String(), `"userName":"alice"`) {
+		t.Errorf("pagination failed: %s", rw.Body.String())
+	}
+	if rw := doJSON(t, r, admin, "GET", `/scim/v2/Users?filter=userName+sw+%22a%22`, ""); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for unsupported filter, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	// Deprovisioning as sent by identity providers ends sessions and revokes tokens
+	now := time.Now().Unix()
+	if _, err := db.Exec(`INSERT INTO sessions (id, token_hash, username, created_at, last_activity) VALUES ('s1', 'h1', 'alice', ?, ?)`, now, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.CreateApiToken(ctx, db, &repository.ApiToken{ID: "t1", Username: "alice", Name: "ci", Scopes: []string{}, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+	rw = doJSON(t, r, admin, "PATCH", "/scim/v2/Users/alice", `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "a.smith@example.com"},
+			{"op": "replace", "path": "name.givenName", "value": "Alicia"}]}`)
+	if rw.Code != http.StatusOK {
+		t.Fatalf("patching user failed: %d %s", rw.Code, rw.Body.String())
+	}
+	user, _ = repository.LoadUser(ctx, db, "alice")
+	if !user.Disabled || user.Email != "a.smith@example.com" || user.Name != "Alicia Smith" {
+		t.Errorf("patch not applied: %+v", user)
+	}
+	if sessions, _ := repository.ListSessions(ctx, db, "alice"); len(sessions) != 0 {
+		t.Errorf("sessions of deprovisioned user not ended: %v", sessions)
+	}
+	if token, _ := repository.GetApiToken(ctx, db, "t1"); token.RevokedAt == 0 {
+		t.Errorf("token of deprovisioned user not revoked")
+	}
+	if rw := doJSON(t, r, admin, "PATCH", "/scim/v2/Users/alice", `{"Operations": [{"op": "replace", "path": "userName", "value": "bob"}]}`); rw.Code != http.StatusBadRequest ||
+		!strings.Contains(rw.Body.String(), `"scimType":"mutability"`) {
+		t.Errorf("expected %d renaming user, got %d %s", http.StatusBadRequest, rw.Code, rw.Body.String())
+	}
+
+	rw = doJSON(t, r, admin, "PUT", "/scim/v2/Users/alice", `{"userName": "alice", "displayName": "Alice", "active": true}`)
+	if user, _ = repository.LoadUser(ctx, db, "alice"); rw.Code != http.StatusOK || user.Disabled || user.Name != "Alice" || user.Email != "" {
+		t.Errorf("replacing user failed: %d %+v", rw.Code, user)
+	}
+
+	if rw := doJSON(t, r, admin, "DELETE", "/scim/v2/Users/alice", ""); rw.Code != http.StatusNoContent {
+		t.Errorf("expected %d deleting user, got %d", http.StatusNoContent, rw.Code)
+	}
+	if rw := doJSON(t, r, admin, "GET", "/scim/v2/Users/alice", ""); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d for deleted user, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	rw = doAuthz(t, r, admin, "GET", "/api/audit?resource_id=alice", nil)
+	for _, action := range []string{`"action":"create"`, `"action":"update"`, `"action":"delete"`} {
+		if !strings.Contains(rw.Body.String(), action) {
+			t.Errorf("audit entry %s missing: %s", action, rw.Body.String())
+		}
+	}
+}
+
+func TestSCIMGroups(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, roles, projects, ldap) VALUES
+		('admin', '["admin"]', '[]', 0), ('alice', '["user"]', '[]', 3), ('bob', '["user", "manager"]', '["p1"]', 3), ('carol', '["user"]', '[]', 3)`); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	admin := authzUsers["admin"]
+	hasRole := func(username string, role schema.Role) bool {
+		user, err := repository.LoadUser(ctx, db, username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user.HasRole(role)
+	}
+
+	var list api.SCIMListResponse
+	rw := doJSON(t, r, admin, "GET", "/scim/v2/Groups?excludedAttributes=members", "")
+	if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil || list.TotalResults != 5 || strings.Contains(rw.Body.String(), "members") {
+		t.Errorf("unexpected groups: %s", rw.Body.String())
+	}
+	var group api.SCIMGroup
+	rw = doJSON(t, r, admin, "GET", "/scim/v2/Groups/manager", "")
+	if err := json.Unmarshal(rw.Body.Bytes(), &group); err != nil || len(group.Members) != 1 || group.Members[0].Value != "bob" {
+		t.Errorf("unexpected group: %s", rw.Body.String())
+	}
+	if rw := doJSON(t, r, admin, "GET", "/scim/v2/Groups/wheel", ""); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d for unknown group, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	rw = doJSON(t, r, admin, "PATCH", "/scim/v2/Groups/support", `{"Operations": [
+		{"op": "add", "path": "members", "value": [{"value": "alice"}, {"value": "carol"}]},
+		{"op": "remove", "path": "members[value eq \"carol\"]"}]}`)
+	if rw.Code != http.StatusOK || !hasRole("alice", schema.RoleSupport) || hasRole("carol", schema.RoleSupport) {
+		t.Errorf("patching group members failed: %d %s", rw.Code, rw.Body.String())
+	}
+
+	// Changes are all or nothing
+	rw = doJSON(t, r, admin, "PATCH", "/scim/v2/Groups/support", `{"Operations": [{"op": "add", "value": {"members": [{"value": "carol"}, {"value": "nobody"}]}}]}`)
+	if rw.Code != http.StatusBadRequest || hasRole("carol", schema.RoleSupport) {
+		t.Errorf("expected %d adding unknown user, got %d", http.StatusBadRequest, rw.Code)
+	}
+	rw = doJSON(t, r, admin, "PUT", "/scim/v2/Groups/manager", `{"displayName": "manager", "members": []}`)
+	if rw.Code != http.StatusBadRequest || !hasRole("bob", schema.RoleManager) {
+		t.Errorf("expected %d removing manager with projects, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	rw = doJSON(t, r, admin, "PUT", "/scim/v2/Groups/support", `{"displayName": "support", "members": [{"value": "carol"}]}`)
+	if rw.Code != http.StatusOK || hasRole("alice", schema.RoleSupport) || !hasRole("carol", schema.RoleSupport) {
+		t.Errorf("replacing group members failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if rw := doJSON(t, r, admin, "PUT", "/scim/v2/Groups/support", `{"displayName": "helpdesk"}`); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d renaming group, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	rw = doAuthz(t, r, admin, "GET", "/api/audit?resource_id=support", nil)
+	if !strings.Contains(rw.Body.String(), `"resource":"group"`) {
+		t.Errorf("group change not audited: %s", rw.Body.String())
+	}
+}
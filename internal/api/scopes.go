@@ -0,0 +1,45 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"net/http"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// requiredScope returns the scope needed for the route r was matched to.
+func requiredScope(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return schema.ScopeAdmin
+	}
+	tmpl, err := route.GetPathTemplate()
+	if err != nil {
+		return schema.ScopeAdmin
+	}
+	return auth.RouteScope(r.Method + " " + tmpl)
+}
+
+// checkScope rejects requests made with a scoped token if the route needs a
+// scope the token does not carry.
+func checkScope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		user := repository.GetUserFromContext(r.Context())
+		if user != nil && user.AuthType == schema.AuthToken {
+			if scope := requiredScope(r); !user.HasScope(scope) {
+				log.Warnf("access denied: token of user '%s' (scopes %v) lacks scope '%s' for %s %s",
+					user.Username, user.Scopes, scope, r.Method, r.URL.Path)
+				http.Error(rw, "token lacks scope '"+scope+"'", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(rw, r)
+	})
+}
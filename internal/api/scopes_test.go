@@ -0,0 +1,65 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+func TestTokenScopes(t *testing.T) {
+	template := setupAuthzTemplate(t)
+
+	allowedIPs := config.Keys.ApiAllowedIPs
+	config.Keys.ApiAllowedIPs = []string{"*"}
+	t.Cleanup(func() { config.Keys.ApiAllowedIPs = allowedIPs })
+
+	serveToken := func(scopes []string, method, target string, form url.Values) int {
+		user := &schema.User{Username: "admin", Roles: []string{"admin"}, Scopes: scopes, AuthType: schema.AuthToken}
+		req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(context.WithValue(req.Context(), repository.ContextUserKey, user))
+		rw := httptest.NewRecorder()
+		setupAuthzRouter(t, template).ServeHTTP(rw, req)
+		return rw.Code
+	}
+
+	logForm := url.Values{"machine_id": {"m1"}, "log_message": {"hi"}}
+	pvForm := url.Values{"machine_id": {"m1"}, "pv_name": {"/dev/sdb"}, "vg_name": {"vg0"}, "pv_fmt": {"lvm2"}, "pv_attr": {"a"}, "pv_size": {"1G"}, "pv_free": {"0"}}
+	userForm := url.Values{"username": {"new"}, "password": {"pw"}, "role": {"user"}}
+
+	for _, tc := range []struct {
+		scopes   []string
+		method   string
+		target   string
+		form     url.Values
+		expected int
+	}{
+		// Tokens without scope are only limited by the roles of the user
+		{nil, "GET", "/api/machines", nil, http.StatusOK},
+		{[]string{"inventory:write"}, "POST", "/api/physical_volumes", pvForm, http.StatusCreated},
+		{[]string{"inventory:write"}, "POST", "/api/realtime_logs", logForm, http.StatusForbidden},
+		{[]string{"inventory:write"}, "GET", "/api/machines", nil, http.StatusForbidden},
+		{[]string{"inventory:write"}, "POST", "/api/users/", userForm, http.StatusForbidden},
+		{[]string{"inventory:write"}, "POST", "/api/tokens", url.Values{"name": {"x"}}, http.StatusForbidden},
+		{[]string{"logs:write", "machines:read"}, "POST", "/api/realtime_logs", logForm, http.StatusCreated},
+		{[]string{"logs:write", "machines:read"}, "GET", "/api/machine/m1", nil, http.StatusOK},
+		{[]string{"machines:read"}, "DELETE", "/api/machine/m1", nil, http.StatusForbidden},
+		{[]string{"machines:read"}, "GET", "/api/rabbitmq_config", nil, http.StatusForbidden},
+		{[]string{"admin"}, "DELETE", "/api/machine/m1", nil, http.StatusNoContent},
+	} {
+		if code := serveToken(tc.scopes, tc.method, tc.target, tc.form); code != tc.expected {
+			t.Errorf("%v %s %s: expected %d, got %d", tc.scopes, tc.method, tc.target, tc.expected, code)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+	"github.com/gorilla/mux"
+)
+
+var secretNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,255}$`)
+
+// checkSecretRefs rejects credentials that are secret references which the
+// policy does not allow or which cannot be resolved, so that broken
+// references are noticed when a config is saved and not when it is used.
+func checkSecretRefs(ctx context.Context, values ...string) error {
+	for _, v := range values {
+		if err := secrets.Allowed(v); err != nil {
+			return err
+		}
+		if err := secrets.Check(ctx, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSecrets godoc
+//
+//	@summary    Lists the secrets stored in the database
+//	@description Only names and change metadata are returned, never the values.
+//	@tags       Secrets
+//	@produce    json
+//	@success    200         {array}     repository.StoredSecret   "Secrets"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /secrets [get]
+func (api *Service) GetSecrets(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
+		return
+	}
+
+	list, err := repository.ListStoredSecrets(r.Context(), api.db)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(list)
+}
+
+// PutSecret godoc
+//
+//	@summary    Stores a secret encrypted in the database
+//	@description The secret can be used as db:<name> reference in place of passwords and tokens.
+//	@tags       Secrets
+//	@accept     mpfd
+//	@produce    json
+//	@param      name        path        string          true    "Name"
+//	@param      value       formData    string          true    "Secret value"
+//	@success    200         {object}    repository.StoredSecret   "Stored secret"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@failure    503         {object}    ErrorResponse   "No secrets-key configured"
+//	@router     /secrets/{name} [put]
+func (api *Service) PutSecret(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	provider := secrets.DB()
+	if provider == nil {
+		handleError(errors.New("no secrets-key configured"), http.StatusServiceUnavailable, rw)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	if !secretNamePattern.MatchString(name) {
+		handleError(fmt.Errorf("invalid secret name '%s'", name), http.StatusBadRequest, rw)
+		return
+	}
+	value := r.FormValue("value")
+	if value == "" {
+		handleError(errors.New("value is required"), http.StatusBadRequest, rw)
+		return
+	}
+
+	var stored *repository.StoredSecret
+	err := api.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := auditState(repository.GetStoredSecret(ctx, tx, name))
+		if err != nil {
+			return nil, err
+		}
+		if err := provider.Store(ctx, tx, name, value); err != nil {
+			return nil, err
+		}
+		if stored, err = repository.GetStoredSecret(ctx, tx, name); err != nil {
+			return nil, err
+		}
+
+		action := repository.AuditUpdate
+		if before == nil {
+			action = repository.AuditCreate
+		}
+		return &auditRecord{action: action, resource: "secret", resourceID: name, before: before, after: stored}, nil
+	})
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(stored)
+}
+
+// DeleteSecret godoc
+//
+//	@summary    Deletes a secret stored in the database
+//	@tags       Secrets
+//	@param      name        path        string          true    "Name"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /secrets/{name} [delete]
+func (api *Service) DeleteSecret(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	err := api.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.GetStoredSecret(ctx, tx, name)
+		if err != nil {
+			return nil, err
+		}
+		err = repository.DeleteStoredSecret(ctx, tx, name)
+		return &auditRecord{action: repository.AuditDelete, resource: "secret", resourceID: name, before: before}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// resolveSecretRefs replaces the secret references among values by the
+// secrets they refer to. Only references that could be saved via the API
+// are resolved.
+func resolveSecretRefs(ctx context.Context, values ...*string) error {
+	for _, v := range values {
+		s, err := secrets.ResolveAllowed(ctx, *v)
+		if err != nil {
+			return err
+		}
+		*v = s
+	}
+	return nil
+}
+
+func resolveNullSecretRefs(ctx context.Context, values ...*sql.NullString) error {
+	for _, v := range values {
+		if !v.Valid {
+			continue
+		}
+		if err := resolveSecretRefs(ctx, &v.String); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func handleConfigReadError(err error, rw http.ResponseWriter) {
+	if err == sql.ErrNoRows {
+		handleError(err, http.StatusNotFound, rw)
+	} else {
+		handleError(err, http.StatusInternalServerError, rw)
+	}
+}
+
+// GetResolvedRabbitMQConfig godoc
+//
+//	@summary    Retrieves the RabbitMQ configuration for its consumers
+//	@description Secret references in the connection URL and the password are replaced by the secrets they refer to.
+//	@tags       RabbitMQ
+//	@produce    json
+//	@success    200         {object}    RabbitMqConfig  "RabbitMQ configuration with resolved credentials"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /rabbitmq_config/resolved [get]
+func (api *Service) GetResolvedRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigResolve, nil) {
+		return
+	}
+
+	config, err := api.r.GetRabbitMQConfig(r.Context())
+	if err == nil {
+		err = resolveSecretRefs(r.Context(), &config.ConnUrl, &config.Password)
+	}
+	if err != nil {
+		handleConfigReadError(err, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(config)
+}
+
+// GetResolvedInfluxDBConfig godoc
+//
+//	@summary    Retrieves the InfluxDB configuration for its consumers
+//	@description Secret references in the password are replaced by the secrets they refer to.
+//	@tags       InfluxDB
+//	@produce    json
+//	@success    200         {object}    InfluxdbConfiguration  "InfluxDB configuration with resolved credentials"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /influxdb_config/resolved [get]
+func (api *Service) GetResolvedInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigResolve, nil) {
+		return
+	}
+
+	config, err := api.r.GetInfluxDBConfiguration(r.Context())
+	if err == nil {
+		err = resolveSecretRefs(r.Context(), &config.Password)
+	}
+	if err != nil {
+		handleConfigReadError(err, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(config)
+}
+
+// GetResolvedMachineConf godoc
+//
+//	@summary    Retrieves a machine configuration for its consumers
+//	@description Secret references in the password and the passphrase are replaced by the secrets they refer to.
+//	@tags       MachineConf
+//	@produce    json
+//	@param      machine_id   path        string          true    "Machine ID"
+//	@success    200          {object}    MachineConf     "Machine configuration with resolved credentials"
+//	@failure    403          {object}    ErrorResponse   "Forbidden"
+//	@failure    404          {object}    ErrorResponse   "Not Found"
+//	@failure    500          {object}    ErrorResponse   "Internal Server Error"
+//	@router     /machine_conf/{machine_id}/resolved [get]
+func (api *Service) GetResolvedMachineConf(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyMachineResolve, machineFromPath) {
+		return
+	}
+
+	config, err := api.r.GetMachineConf(r.Context(), mux.Vars(r)["machine_id"])
+	if err == nil {
+		err = resolveNullSecretRefs(r.Context(), &config.Password, &config.Passphrase)
+	}
+	if err != nil {
+		handleConfigReadError(err, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(config)
+}
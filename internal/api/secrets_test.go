@@ -0,0 +1,105 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+)
+
+func TestSecrets(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+
+	key, _ := secrets.ParseKey(strings.Repeat("ab", 32))
+	provider, err := secrets.NewDBProvider(db, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets.UseDB(provider)
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/secrets/rabbit-pw", url.Values{"value": {"s3cret"}}); rw.Code != http.StatusOK {
+		t.Fatalf("storing secret failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/secrets/bad%20name", url.Values{"value": {"x"}}); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for invalid name, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	rw := doAuthz(t, r, authzUsers["support"], "GET", "/api/secrets", nil)
+	var list []repository.StoredSecret
+	if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "rabbit-pw" || list[0].UpdatedBy != "admin" {
+		t.Errorf("unexpected secrets: %s", rw.Body.String())
+	}
+
+	// References are checked when a config is saved
+	form := url.Values{"conn_url": {"amqp://mq/"}, "username": {"cc"}, "password": {"db:missing"}}
+	if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/rabbitmq_config", form); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for unresolvable reference, got %d", http.StatusBadRequest, rw.Code)
+	}
+	// Other credentials of cc-backend cannot be referenced
+	t.Setenv("JWT_PRIVATE_KEY", "private")
+	for _, ref := range []string{"env:JWT_PRIVATE_KEY", "file:/etc/hostname"} {
+		form.Set("password", ref)
+		if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/rabbitmq_config", form); rw.Code != http.StatusBadRequest {
+			t.Errorf("expected %d for %s, got %d", http.StatusBadRequest, ref, rw.Code)
+		}
+	}
+	form.Set("password", "db:rabbit-pw")
+	if rw := doAuthz(t, r, authzUsers["admin"], "PUT", "/api/rabbitmq_config", form); rw.Code != http.StatusOK {
+		t.Errorf("saving reference failed: %d %s", rw.Code, rw.Body.String())
+	}
+
+	// Consumers get the resolved credentials, the stored form keeps the reference
+	if _, err := db.Exec(`INSERT INTO rabbit_mq_config (conn_url, username, password) VALUES ('amqp://mq/', 'cc', 'db:rabbit-pw')`); err != nil {
+		t.Fatal(err)
+	}
+	rw = doAuthz(t, r, authzUsers["api"], "GET", "/api/rabbitmq_config/resolved", nil)
+	if rw.Code != http.StatusOK || !strings.Contains(rw.Body.String(), `"Password":"s3cret"`) {
+		t.Errorf("expected resolved password, got %d %s", rw.Code, rw.Body.String())
+	}
+	if rw := doAuthz(t, r, authzUsers["support"], "GET", "/api/rabbitmq_config/resolved", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for support, got %d", http.StatusForbidden, rw.Code)
+	}
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/rabbitmq_config", nil)
+	if !strings.Contains(rw.Body.String(), `"Password":"db:rabbit-pw"`) {
+		t.Errorf("expected stored reference, got %s", rw.Body.String())
+	}
+
+	// Probes resolve the references of the stored config only, not those
+	// sent to a host of the caller's choosing
+	probe := url.Values{"conn_url": {"amqp://127.0.0.1:1/"}, "username": {"cc"}, "password": {"db:rabbit-pw"}}
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/rabbitmq_config/test", probe); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for a reference in the probe request, got %d", http.StatusBadRequest, rw.Code)
+	}
+	probe.Set("password", "s3cret")
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/rabbitmq_config/test", probe); rw.Code != http.StatusOK {
+		t.Errorf("expected a diagnostic for plain credentials, got %d", rw.Code)
+	}
+	influx := url.Values{"host": {"127.0.0.1"}, "port": {"1"}, "password": {"env:JWT_PRIVATE_KEY"}}
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/influxdb_config/test", influx); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for a reference in the probe request, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=secret", nil)
+	if !strings.Contains(rw.Body.String(), "rabbit-pw") || strings.Contains(rw.Body.String(), "s3cret") {
+		t.Errorf("unexpected audit log: %s", rw.Body.String())
+	}
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "DELETE", "/api/secrets/rabbit-pw", nil); rw.Code != http.StatusNoContent {
+		t.Errorf("deleting secret failed: %d", rw.Code)
+	}
+	if _, err := secrets.Resolve(context.Background(), "db:rabbit-pw"); err == nil {
+		t.Errorf("deleted secret still resolves")
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// getSessions godoc
+//
+//	@summary    Lists the login sessions of a user
+//	@description The session of the request is marked as current. Admins may list the sessions of other users.
+//	@tags       Sessions
+//	@produce    json
+//	@param      username    query       string          false   "Owner of the sessions (defaults to the requesting user)"
+//	@success    200         {array}     repository.Session  "Sessions"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /sessions [get]
+func (api *RestApi) getSessions(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	username, err := resourceOwner(r, "sessions")
+	if err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	sessions, err := repository.ListSessions(r.Context(), api.Service.db, username)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+	current := repository.GetSessionIDFromContext(r.Context())
+	for _, s := range sessions {
+		s.Current = s.ID == current
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(sessions)
+}
+
+// deleteSession godoc
+//
+//	@summary    Ends a login session
+//	@description The session is logged out with its next request. Admins may end sessions of other users.
+//	@tags       Sessions
+//	@param      id          path        string          true    "Session ID"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /sessions/{id} [delete]
+func (api *RestApi) deleteSession(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	me := repository.GetUserFromContext(r.Context())
+	id := mux.Vars(r)["id"]
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.GetSession(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		// Do not tell others which session IDs exist
+		if before.Username != me.Username && !me.HasRole(schema.RoleAdmin) {
+			return nil, sql.ErrNoRows
+		}
+		if err := repository.DeleteSession(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditDelete, resource: "session", resourceID: id, before: before}, nil
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(fmt.Errorf("no session with ID '%s'", id), http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// deleteSessions godoc
+//
+//	@summary    Ends all login sessions of a user
+//	@description Ends all sessions of the requesting user except the current one, e.g. after a password change.
+//	@description Admins may end all sessions of other users.
+//	@tags       Sessions
+//	@param      username    query       string          false   "Owner of the sessions (defaults to the requesting user)"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /sessions [delete]
+func (api *RestApi) deleteSessions(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	username, err := resourceOwner(r, "sessions")
+	if err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+	keep := ""
+	if username == repository.GetUserFromContext(r.Context()).Username {
+		keep = repository.GetSessionIDFromContext(r.Context())
+	}
+
+	err = api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.ListSessions(ctx, tx, username)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := repository.DeleteUserSessions(ctx, tx, username, keep); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditDelete, resource: "session", resourceID: "user:" + username,
+			before: map[string]interface{}{"sessions": before}}, nil
+	})
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
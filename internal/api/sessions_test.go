@@ -0,0 +1,102 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+func TestSessionEndpoints(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+
+	now := time.Now().Unix()
+	for _, s := range []*repository.Session{
+		{ID: "u1", TokenHash: "h-u1", Username: "user", CreatedAt: now, LastActivity: now},
+		{ID: "u2", TokenHash: "h-u2", Username: "user", CreatedAt: now, LastActivity: now - 60},
+		{ID: "u3", TokenHash: "h-u3", Username: "user", CreatedAt: now, LastActivity: now - 120},
+		{ID: "a1", TokenHash: "h-a1", Username: "admin", CreatedAt: now, LastActivity: now},
+	} {
+		if err := repository.CreateSession(context.Background(), db, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Requests of user authenticated by session u1
+	doSession := func(method, target string) *httptest.ResponseRecorder {
+		u := *authzUsers["user"]
+		u.AuthType = schema.AuthSession
+		req := httptest.NewRequest(method, target, nil)
+		ctx := context.WithValue(req.Context(), repository.ContextUserKey, &u)
+		req = req.WithContext(context.WithValue(ctx, repository.ContextSessionKey, "u1"))
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		return rw
+	}
+	list := func(rw *httptest.ResponseRecorder) []*repository.Session {
+		t.Helper()
+		var sessions []*repository.Session
+		if err := json.Unmarshal(rw.Body.Bytes(), &sessions); err != nil {
+			t.Fatalf("%d %s", rw.Code, rw.Body.String())
+		}
+		return sessions
+	}
+
+	sessions := list(doSession("GET", "/api/sessions"))
+	if len(sessions) != 3 || sessions[0].ID != "u1" || !sessions[0].Current || sessions[1].Current {
+		t.Errorf("unexpected sessions: %s", doSession("GET", "/api/sessions").Body.String())
+	}
+	if strings.Contains(doSession("GET", "/api/sessions").Body.String(), "h-u1") {
+		t.Errorf("token hash exposed")
+	}
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/sessions?username=admin", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d listing sessions of others, got %d", http.StatusForbidden, rw.Code)
+	}
+	if sessions := list(doAuthz(t, r, authzUsers["admin"], "GET", "/api/sessions?username=user", nil)); len(sessions) != 3 {
+		t.Errorf("expected 3 sessions of user, got %d", len(sessions))
+	}
+
+	// Sessions of others look like non-existing ones
+	if rw := doSession("DELETE", "/api/sessions/a1"); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d ending session of others, got %d", http.StatusNotFound, rw.Code)
+	}
+	if rw := doSession("DELETE", "/api/sessions/u2"); rw.Code != http.StatusNoContent {
+		t.Errorf("expected %d ending own session, got %d: %s", http.StatusNoContent, rw.Code, rw.Body.String())
+	}
+	if rw := doSession("DELETE", "/api/sessions/u2"); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d ending session twice, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	// Logging out everywhere else keeps the current session
+	if rw := doSession("DELETE", "/api/sessions"); rw.Code != http.StatusNoContent {
+		t.Errorf("expected %d ending other sessions, got %d", http.StatusNoContent, rw.Code)
+	}
+	if sessions := list(doSession("GET", "/api/sessions")); len(sessions) != 1 || sessions[0].ID != "u1" {
+		t.Errorf("expected only current session left, got %v", sessions)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["admin"], "DELETE", "/api/sessions?username=user", nil); rw.Code != http.StatusNoContent {
+		t.Errorf("expected %d ending sessions of others as admin, got %d", http.StatusNoContent, rw.Code)
+	}
+	if sessions := list(doSession("GET", "/api/sessions")); len(sessions) != 0 {
+		t.Errorf("expected no sessions left, got %v", sessions)
+	}
+	if sessions := list(doAuthz(t, r, authzUsers["admin"], "GET", "/api/sessions", nil)); len(sessions) != 1 {
+		t.Errorf("sessions of admin affected: %v", sessions)
+	}
+
+	rw := doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=session", nil)
+	if n := strings.Count(rw.Body.String(), `"action":"delete"`); n != 3 {
+		t.Errorf("expected 3 audit entries, got %d: %s", n, rw.Body.String())
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// IssuedApiToken is returned once when a token is created. The token itself
+// is not stored and cannot be retrieved later.
+type IssuedApiToken struct {
+	Token string               `json:"token"`
+	Info  *repository.ApiToken `json:"info"`
+}
+
+// resourceOwner returns the user whose tokens or sessions a request is
+// about. Users may only manage their own, admins those of everybody.
+func resourceOwner(r *http.Request, what string) (string, error) {
+	me := repository.GetUserFromContext(r.Context())
+	username := r.FormValue("username")
+	if username == "" || username == me.Username {
+		return me.Username, nil
+	}
+	if !me.HasRole(schema.RoleAdmin) {
+		return "", fmt.Errorf("only admins are allowed to manage %s of other users", what)
+	}
+	return username, nil
+}
+
+// selfIssued restricts a token me issues for themselves to what the
+// credentials of the request allow. The token gets the roles of me (see
+// IssueToken), so only the scopes and machine of the token me authenticated
+// with are left to check. Depending on the jwts config, non-admins may not
+// issue tokens for themselves at all or only for a limited time.
+func selfIssued(me *schema.User, opts *auth.TokenOptions) error {
+	if conf := config.Keys.JwtConfig; conf != nil && !me.HasRole(schema.RoleAdmin) {
+		if conf.DisableSelfIssue {
+			return errors.New("issuing tokens for yourself is disabled, ask an admin")
+		}
+		if conf.SelfIssueMaxAge != "" {
+			maxAge, err := time.ParseDuration(conf.SelfIssueMaxAge)
+			if err != nil {
+				return errors.New("cannot parse selfIssueMaxAge config key")
+			}
+			if opts.ValidFor <= 0 || opts.ValidFor > maxAge {
+				opts.ValidFor = maxAge
+			}
+		}
+	}
+
+	if len(me.Scopes) > 0 {
+		if len(opts.Scopes) == 0 {
+			opts.Scopes = slices.Clone(me.Scopes)
+		}
+		for _, scope := range opts.Scopes {
+			if !me.HasScope(scope) {
+				return fmt.Errorf("scope '%s' exceeds the scopes of your token", scope)
+			}
+		}
+	}
+	if me.Machine != "" {
+		if opts.Machine == "" {
+			opts.Machine = me.Machine
+		} else if opts.Machine != me.Machine {
+			return errors.New("machine_id differs from the machine of your token")
+		}
+	}
+	return nil
+}
+
+func scopesFromForm(r *http.Request) ([]string, error) {
+	scopes := auth.ParseScopes(r.FormValue("scopes"))
+	for _, scope := range scopes {
+		if !schema.IsValidScope(scope) {
+			return nil, fmt.Errorf("invalid scope '%s'", scope)
+		}
+	}
+	return scopes, nil
+}
+
+// createToken godoc
+//
+//	@summary    Issues a named API token
+//	@description The token is only returned in this response. Its ID can be used to revoke it.
+//	@description Admins may issue tokens for other users.
+//	@tags       Tokens
+//	@accept     mpfd
+//	@produce    json
+//	@param      name        formData    string          true    "Name of the token, e.g. the host of an agent"
+//	@param      scopes      formData    string          false   "Comma separated list of scopes (machines:read, machines:write, inventory:write, logs:write, secrets:read, admin)"
+//	@param      expires_in  formData    string          false   "Lifetime, e.g. 720h (capped by max-age)"
+//	@param      machine_id  formData    string          false   "Machine the token is bound to, agents with the api role need it to push data"
+//	@param      username    formData    string          false   "Owner of the token (defaults to the requesting user)"
+//	@success    200         {object}    api.IssuedApiToken  "Issued token"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    422         {object}    ErrorResponse   "Unprocessable Entity"
+//	@failure    503         {object}    ErrorResponse   "Token authentication not configured"
+//	@security   ApiKeyAuth
+//	@router     /tokens [post]
+func (api *RestApi) createToken(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+	if api.Authentication.JwtAuth == nil {
+		handleError(errors.New("token authentication not configured"), http.StatusServiceUnavailable, rw)
+		return
+	}
+
+	username, err := resourceOwner(r, "tokens")
+	if err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	scopes, err := scopesFromForm(r)
+	if err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+	opts := auth.TokenOptions{Name: r.FormValue("name"), Scopes: scopes, Machine: r.FormValue("machine_id")}
+	if opts.Name == "" {
+		handleError(errors.New("name is required"), http.StatusBadRequest, rw)
+		return
+	}
+	if opts.Machine != "" {
+		if _, err := api.Service.r.GetMachine(r.Context(), opts.Machine); errors.Is(err, sql.ErrNoRows) {
+			handleError(fmt.Errorf("no machine '%s'", opts.Machine), http.StatusUnprocessableEntity, rw)
+			return
+		} else if err != nil {
+			handleError(err, http.StatusInternalServerError, rw)
+			return
+		}
+	}
+	if s := r.FormValue("expires_in"); s != "" {
+		if opts.ValidFor, err = time.ParseDuration(s); err != nil || opts.ValidFor <= 0 {
+			handleError(fmt.Errorf("invalid expires_in '%s'", s), http.StatusBadRequest, rw)
+			return
+		}
+	}
+
+	user := repository.GetUserFromContext(r.Context())
+	if username != user.Username {
+		if user, err = repository.GetUserRepository().GetUser(username); err != nil {
+			handleError(err, http.StatusUnprocessableEntity, rw)
+			return
+		}
+	} else if err := selfIssued(user, &opts); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	issued := IssuedApiToken{}
+	err = api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		token, info, err := api.Authentication.JwtAuth.IssueToken(ctx, tx, user, opts)
+		if err != nil {
+			return nil, err
+		}
+		issued.Token, issued.Info = token, info
+		return &auditRecord{action: repository.AuditCreate, resource: "api_token", resourceID: issued.Info.ID, after: issued.Info}, nil
+	})
+	if err != nil {
+		handleError(err, http.StatusUnprocessableEntity, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(issued)
+}
+
+// getTokens godoc
+//
+//	@summary    Lists the API tokens of a user
+//	@description Revoked and expired tokens are included. Admins may list the tokens of other users.
+//	@tags       Tokens
+//	@produce    json
+//	@param      username    query       string          false   "Owner of the tokens (defaults to the requesting user)"
+//	@success    200         {array}     repository.ApiToken   "Tokens"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /tokens [get]
+func (api *RestApi) getTokens(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	username, err := resourceOwner(r, "tokens")
+	if err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	tokens, err := repository.ListApiTokens(r.Context(), api.Service.db, username)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(tokens)
+}
+
+// revokeToken godoc
+//
+//	@summary    Revokes an API token
+//	@description The token is rejected from then on. Admins may revoke tokens of other users.
+//	@tags       Tokens
+//	@param      id          path        string          true    "Token ID (jti claim)"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /tokens/{id} [delete]
+func (api *RestApi) revokeToken(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+
+	me := repository.GetUserFromContext(r.Context())
+	id := mux.Vars(r)["id"]
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.GetApiToken(ctx, tx, id)
+		if err != nil {
+			return nil, err
+		}
+		// Do not tell others which token IDs exist
+		if before.Username != me.Username && !me.HasRole(schema.RoleAdmin) {
+			return nil, sql.ErrNoRows
+		}
+		if err := repository.RevokeApiToken(ctx, tx, id); err != nil {
+			return nil, err
+		}
+		after, err := repository.GetApiToken(ctx, tx, id)
+		return &auditRecord{action: repository.AuditUpdate, resource: "api_token", resourceID: id, before: before, after: after}, err
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(fmt.Errorf("no token with ID '%s'", id), http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+
+	if api.Authentication.JwtAuth != nil {
+		api.Authentication.JwtAuth.Revoked(id)
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
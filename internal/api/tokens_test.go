@@ -0,0 +1,195 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/api"
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+func TestApiTokens(t *testing.T) {
+	_, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+
+	jwtConfig := config.Keys.JwtConfig
+	config.Keys.JwtConfig = &schema.JWTAuthConfig{MaxAge: "2000h"}
+	t.Cleanup(func() { config.Keys.JwtConfig = jwtConfig })
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtAuth := auth.NewJWTAuthenticator(pub, priv, repository.NewTokenDenylist(db))
+	restapi := &api.RestApi{
+		Service:        api.NewService(db),
+		Authentication: &auth.Authentication{JwtAuth: jwtAuth},
+	}
+	r := mux.NewRouter()
+	restapi.MountRoutes(r)
+
+	rw := doAuthz(t, r, authzUsers["api"], "POST", "/api/tokens", url.Values{"name": {"agent-host1"}, "scopes": {"logs:write, inventory:write"}, "machine_id": {"m1"}})
+	if rw.Code != http.StatusOK {
+		t.Fatalf("issuing token failed: %d %s", rw.Code, rw.Body.String())
+	}
+	var issued api.IssuedApiToken
+	if err := json.Unmarshal(rw.Body.Bytes(), &issued); err != nil {
+		t.Fatal(err)
+	}
+	if issued.Info.Username != "api" || len(issued.Info.Scopes) != 2 || issued.Info.MachineID != "m1" {
+		t.Errorf("unexpected token: %s", rw.Body.String())
+	}
+
+	authenticate := func() (*schema.User, error) {
+		req := httptest.NewRequest("GET", "/api/machines", nil)
+		req.Header.Set("X-Auth-Token", issued.Token)
+		return jwtAuth.AuthViaJWT(httptest.NewRecorder(), req)
+	}
+	user, err := authenticate()
+	if err != nil {
+		t.Fatalf("issued token rejected: %v", err)
+	}
+	if !user.HasScope("logs:write") || user.HasScope("admin") {
+		t.Errorf("unexpected scopes of token user: %v", user.Scopes)
+	}
+	if user.Machine != "m1" {
+		t.Errorf("expected token bound to m1, got '%s'", user.Machine)
+	}
+	if rw := doAuthz(t, r, authzUsers["api"], "POST", "/api/tokens", url.Values{"name": {"x"}, "machine_id": {"m9"}}); rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for unknown machine, got %d", http.StatusUnprocessableEntity, rw.Code)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["api"], "POST", "/api/tokens", url.Values{"name": {"x"}, "scopes": {"everything"}}); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for invalid scope, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	// Others can neither see nor revoke the token
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/tokens?username=api", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d listing tokens of others, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["user"], "DELETE", "/api/tokens/"+issued.Info.ID, nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d revoking token of others, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/tokens?username=api", nil)
+	var list []repository.ApiToken
+	if err := json.Unmarshal(rw.Body.Bytes(), &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != issued.Info.ID || list[0].LastUsedAt == 0 {
+		t.Errorf("unexpected tokens: %s", rw.Body.String())
+	}
+
+	if rw := doAuthz(t, r, authzUsers["api"], "DELETE", "/api/tokens/"+issued.Info.ID, nil); rw.Code != http.StatusNoContent {
+		t.Fatalf("revoking token failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if _, err := authenticate(); err == nil {
+		t.Errorf("revoked token accepted")
+	}
+
+	// Other instances read the revocation from the database
+	other := auth.NewJWTAuthenticator(pub, priv, repository.NewTokenDenylist(db))
+	req := httptest.NewRequest("GET", "/api/machines", nil)
+	req.Header.Set("X-Auth-Token", issued.Token)
+	if _, err := other.AuthViaJWT(httptest.NewRecorder(), req); err == nil {
+		t.Errorf("revoked token accepted by other instance")
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=api_token", nil)
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &entries); err != nil || len(entries) != 2 {
+		t.Errorf("unexpected audit log: %s", rw.Body.String())
+	}
+}
+
+func TestSelfIssuedTokens(t *testing.T) {
+	_, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec("INSERT INTO machines (machine_id, hostname, os_version, ip_address) VALUES ('m2', 'host2', 'linux', '10.0.0.2')"); err != nil {
+		t.Fatal(err)
+	}
+
+	jwtConfig := config.Keys.JwtConfig
+	config.Keys.JwtConfig = &schema.JWTAuthConfig{MaxAge: "2000h", SelfIssueMaxAge: "24h"}
+	t.Cleanup(func() { config.Keys.JwtConfig = jwtConfig })
+
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtAuth := auth.NewJWTAuthenticator(pub, priv, repository.NewTokenDenylist(db))
+	restapi := &api.RestApi{
+		Service:        api.NewService(db),
+		Authentication: &auth.Authentication{JwtAuth: jwtAuth},
+	}
+	r := mux.NewRouter()
+	restapi.MountRoutes(r)
+
+	issue := func(user *schema.User, form url.Values) *repository.ApiToken {
+		t.Helper()
+		rw := doAuthz(t, r, user, "POST", "/api/tokens", form)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("issuing token failed: %d %s", rw.Code, rw.Body.String())
+		}
+		var issued api.IssuedApiToken
+		if err := json.Unmarshal(rw.Body.Bytes(), &issued); err != nil {
+			t.Fatal(err)
+		}
+		return issued.Info
+	}
+	lifetime := func(info *repository.ApiToken) time.Duration {
+		return time.Duration(info.ExpiresAt-info.CreatedAt) * time.Second
+	}
+
+	// The lifetime of tokens non-admins issue for themselves is capped
+	if info := issue(authzUsers["user"], url.Values{"name": {"u"}, "expires_in": {"720h"}}); lifetime(info) != 24*time.Hour {
+		t.Errorf("expected self-issued token to expire after 24h, got %s", lifetime(info))
+	}
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/jwt/?username=user", nil); rw.Code != http.StatusOK {
+		t.Errorf("issuing JWT failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if tokens, err := repository.ListApiTokens(context.Background(), db, "user"); err != nil || len(tokens) != 2 || lifetime(tokens[0]) != 24*time.Hour || lifetime(tokens[1]) != 24*time.Hour {
+		t.Errorf("unexpected tokens of user: %v %v", tokens, err)
+	}
+	if info := issue(authzUsers["admin"], url.Values{"name": {"a"}, "expires_in": {"720h"}}); lifetime(info) != 720*time.Hour {
+		t.Errorf("expected admin token to expire after 720h, got %s", lifetime(info))
+	}
+
+	// Tokens issued with a scoped and machine bound token keep its scopes
+	// and machine
+	scoped := *authzUsers["api"]
+	scoped.Scopes = []string{"logs:write", "inventory:write"}
+	if info := issue(&scoped, url.Values{"name": {"s"}}); len(info.Scopes) != 2 || info.MachineID != "m1" {
+		t.Errorf("expected scopes and machine of the request, got %+v", info)
+	}
+	for _, form := range []url.Values{
+		{"name": {"s"}, "scopes": {"admin"}},
+		{"name": {"s"}, "machine_id": {"m2"}},
+	} {
+		if rw := doAuthz(t, r, &scoped, "POST", "/api/tokens", form); rw.Code != http.StatusForbidden {
+			t.Errorf("%v: expected %d, got %d", form, http.StatusForbidden, rw.Code)
+		}
+	}
+
+	// With disableSelfIssue only admins issue tokens
+	config.Keys.JwtConfig.DisableSelfIssue = true
+	if rw := doAuthz(t, r, authzUsers["user"], "POST", "/api/tokens", url.Values{"name": {"u"}}); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/jwt/?username=user", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d, got %d", http.StatusForbidden, rw.Code)
+	}
+	issue(authzUsers["admin"], url.Values{"name": {"a"}})
+}
@@ -0,0 +1,336 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// TOTPStatus describes the second factor of a user.
+type TOTPStatus struct {
+	Username          string `json:"username"`
+	Enabled           bool   `json:"enabled"`
+	Required          bool   `json:"required"`            // required for one of the roles of the user
+	EnabledAt         int64  `json:"enabledAt,omitempty"` // 0 if not enabled
+	RecoveryCodesLeft int    `json:"recoveryCodesLeft"`
+}
+
+// RecoveryCodes are returned once when created, only their hashes are
+// stored.
+type RecoveryCodes struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// totpUser returns the local or LDAP account a second factor request is
+// about and whether it is the requesting user. Users may only manage their
+// own second factor, admins may look at and reset those of everybody.
+func totpUser(r *http.Request, db *sql.DB) (*schema.User, bool, error) {
+	me := repository.GetUserFromContext(r.Context())
+	username := r.FormValue("username")
+	self := username == "" || username == me.Username
+	if self {
+		username = me.Username
+	} else if !me.HasRole(schema.RoleAdmin) {
+		return nil, false, errors.New("only admins are allowed to manage the second factor of other users")
+	}
+
+	user, err := repository.LoadUser(r.Context(), db, username)
+	if err != nil {
+		return nil, self, err
+	}
+	if !auth.SupportsTOTP(user) {
+		return nil, self, errors.New("two-factor authentication is only supported for local and LDAP accounts")
+	}
+	return user, self, nil
+}
+
+func totpErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, auth.ErrTOTPNotEnrolled), errors.Is(err, sql.ErrNoRows):
+		return http.StatusNotFound
+	case errors.Is(err, auth.ErrTOTPEnabled):
+		return http.StatusConflict
+	case errors.Is(err, auth.ErrTOTPInvalid), errors.Is(err, auth.ErrLoginThrottled):
+		return http.StatusForbidden
+	default:
+		return http.StatusUnprocessableEntity
+	}
+}
+
+// checkTOTPRequest does the checks shared by all second factor handlers.
+func (api *RestApi) checkTOTPRequest(rw http.ResponseWriter, r *http.Request) (*schema.User, bool, bool) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return nil, false, false
+	}
+	if api.Authentication == nil || api.Authentication.TOTP == nil {
+		handleError(errors.New("two-factor authentication not available"), http.StatusServiceUnavailable, rw)
+		return nil, false, false
+	}
+
+	user, self, err := totpUser(r, api.Service.db)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			handleError(errors.New("no such user"), http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusForbidden, rw)
+		}
+		return nil, false, false
+	}
+	return user, self, true
+}
+
+// verifyTOTP checks the code given by a user before changing their second
+// factor. Wrong codes count as failed logins.
+func (api *RestApi) verifyTOTP(r *http.Request, username string) error {
+	ctx := r.Context()
+	throttle := api.Authentication.LoginThrottle
+	if throttle != nil {
+		if wait, err := throttle.Check(ctx, username, ""); err != nil {
+			log.Errorf("checking failed logins of user '%s' failed: %v", username, err)
+		} else if wait > 0 {
+			return fmt.Errorf("%w, try again in %s", auth.ErrLoginThrottled, wait.Round(time.Second))
+		}
+	}
+
+	err := api.Authentication.TOTP.Verify(ctx, api.Service.db, username, r.FormValue("code"))
+	if errors.Is(err, auth.ErrTOTPInvalid) && throttle != nil {
+		if err := throttle.Failed(ctx, username, util.ClientIP(r)); err != nil {
+			log.Errorf("recording failed code of user '%s' failed: %v", username, err)
+		}
+	}
+	return err
+}
+
+// getTOTP godoc
+//
+//	@summary    Shows whether two-factor authentication is enabled
+//	@description Admins may look at the second factor of other users.
+//	@tags       TOTP
+//	@produce    json
+//	@param      username    query       string          false   "User (defaults to the requesting user)"
+//	@success    200         {object}    api.TOTPStatus  "Status"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    503         {object}    ErrorResponse   "Two-factor authentication not available"
+//	@security   ApiKeyAuth
+//	@router     /totp [get]
+func (api *RestApi) getTOTP(rw http.ResponseWriter, r *http.Request) {
+	user, _, ok := api.checkTOTPRequest(rw, r)
+	if !ok {
+		return
+	}
+
+	status := TOTPStatus{Username: user.Username, Required: api.Authentication.TOTP.Required(user)}
+	tt, err := api.Authentication.TOTP.Get(r.Context(), api.Service.db, user.Username)
+	if err != nil && err != sql.ErrNoRows {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+	if err == nil && tt.Enabled {
+		status.Enabled, status.EnabledAt = true, tt.EnabledAt
+		status.RecoveryCodesLeft = len(tt.RecoveryCodes)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(status)
+}
+
+// enrollTOTP godoc
+//
+//	@summary    Starts setting up two-factor authentication
+//	@description Returns a new secret and its otpauth:// provisioning URI, which can be shown as QR code.
+//	@description The second factor is enabled once a code is confirmed with /totp/confirm.
+//	@tags       TOTP
+//	@produce    json
+//	@success    200         {object}    auth.TOTPEnrollment "Secret and provisioning URI"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    409         {object}    ErrorResponse   "Already enabled"
+//	@failure    503         {object}    ErrorResponse   "Two-factor authentication not available"
+//	@security   ApiKeyAuth
+//	@router     /totp [post]
+func (api *RestApi) enrollTOTP(rw http.ResponseWriter, r *http.Request) {
+	user, self, ok := api.checkTOTPRequest(rw, r)
+	if !ok {
+		return
+	}
+	if !self {
+		handleError(errors.New("users have to set up their second factor themselves"), http.StatusForbidden, rw)
+		return
+	}
+
+	var enrollment *auth.TOTPEnrollment
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		var err error
+		if enrollment, err = api.Authentication.TOTP.Enroll(ctx, tx, user.Username); err != nil {
+			return nil, err
+		}
+		after, err := api.Authentication.TOTP.Get(ctx, tx, user.Username)
+		return &auditRecord{action: repository.AuditCreate, resource: "user_totp", resourceID: user.Username, after: after}, err
+	})
+	if err != nil {
+		handleError(err, totpErrorStatus(err), rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(enrollment)
+}
+
+// confirmTOTP godoc
+//
+//	@summary    Enables two-factor authentication
+//	@description Confirms the secret returned by POST /totp with a code of the authenticator app.
+//	@description Returns recovery codes, which are only shown once.
+//	@tags       TOTP
+//	@accept     mpfd
+//	@produce    json
+//	@param      code        formData    string          true    "Current code of the authenticator app"
+//	@success    200         {object}    api.RecoveryCodes   "Recovery codes"
+//	@failure    403         {object}    ErrorResponse   "Invalid code"
+//	@failure    404         {object}    ErrorResponse   "Not set up"
+//	@failure    409         {object}    ErrorResponse   "Already enabled"
+//	@failure    503         {object}    ErrorResponse   "Two-factor authentication not available"
+//	@security   ApiKeyAuth
+//	@router     /totp/confirm [post]
+func (api *RestApi) confirmTOTP(rw http.ResponseWriter, r *http.Request) {
+	user, self, ok := api.checkTOTPRequest(rw, r)
+	if !ok {
+		return
+	}
+	if !self {
+		handleError(errors.New("users have to set up their second factor themselves"), http.StatusForbidden, rw)
+		return
+	}
+
+	codes := RecoveryCodes{}
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := api.Authentication.TOTP.Get(ctx, tx, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		if codes.RecoveryCodes, err = api.Authentication.TOTP.Confirm(ctx, tx, user.Username, r.FormValue("code")); err != nil {
+			return nil, err
+		}
+		after, err := api.Authentication.TOTP.Get(ctx, tx, user.Username)
+		return &auditRecord{action: repository.AuditUpdate, resource: "user_totp", resourceID: user.Username, before: before, after: after}, err
+	})
+	if err != nil {
+		handleError(err, totpErrorStatus(err), rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(codes)
+}
+
+// regenerateRecoveryCodes godoc
+//
+//	@summary    Replaces the recovery codes
+//	@description The old recovery codes become invalid. The new ones are only shown once.
+//	@tags       TOTP
+//	@accept     mpfd
+//	@produce    json
+//	@param      code        formData    string          true    "Current code of the authenticator app or a recovery code"
+//	@success    200         {object}    api.RecoveryCodes   "Recovery codes"
+//	@failure    403         {object}    ErrorResponse   "Invalid code"
+//	@failure    404         {object}    ErrorResponse   "Not enabled"
+//	@failure    503         {object}    ErrorResponse   "Two-factor authentication not available"
+//	@security   ApiKeyAuth
+//	@router     /totp/recovery-codes [post]
+func (api *RestApi) regenerateRecoveryCodes(rw http.ResponseWriter, r *http.Request) {
+	user, self, ok := api.checkTOTPRequest(rw, r)
+	if !ok {
+		return
+	}
+	if !self {
+		handleError(errors.New("users have to renew their recovery codes themselves"), http.StatusForbidden, rw)
+		return
+	}
+	if err := api.verifyTOTP(r, user.Username); err != nil {
+		handleError(err, totpErrorStatus(err), rw)
+		return
+	}
+
+	codes := RecoveryCodes{}
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		var err error
+		if codes.RecoveryCodes, err = api.Authentication.TOTP.RegenerateRecoveryCodes(ctx, tx, user.Username); err != nil {
+			return nil, err
+		}
+		after, err := api.Authentication.TOTP.Get(ctx, tx, user.Username)
+		return &auditRecord{action: repository.AuditUpdate, resource: "user_totp", resourceID: user.Username, after: after}, err
+	})
+	if err != nil {
+		handleError(err, totpErrorStatus(err), rw)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(codes)
+}
+
+// disableTOTP godoc
+//
+//	@summary    Disables two-factor authentication
+//	@description Users have to give a current code. Admins may reset the second factor of other users
+//	@description without code, e.g. if they lost their device and recovery codes.
+//	@tags       TOTP
+//	@param      code        query       string          false   "Current code or recovery code, required for the own second factor"
+//	@param      username    query       string          false   "User (defaults to the requesting user)"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden or invalid code"
+//	@failure    404         {object}    ErrorResponse   "Not set up"
+//	@failure    503         {object}    ErrorResponse   "Two-factor authentication not available"
+//	@security   ApiKeyAuth
+//	@router     /totp [delete]
+func (api *RestApi) disableTOTP(rw http.ResponseWriter, r *http.Request) {
+	user, self, ok := api.checkTOTPRequest(rw, r)
+	if !ok {
+		return
+	}
+	if self {
+		tt, err := api.Authentication.TOTP.Get(r.Context(), api.Service.db, user.Username)
+		if err != nil && err != sql.ErrNoRows {
+			handleError(err, http.StatusInternalServerError, rw)
+			return
+		}
+		// A pending enrollment can be dropped without code
+		if err == nil && tt.Enabled {
+			if err := api.verifyTOTP(r, user.Username); err != nil {
+				handleError(err, totpErrorStatus(err), rw)
+				return
+			}
+		}
+	}
+
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := api.Authentication.TOTP.Get(ctx, tx, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		if err := api.Authentication.TOTP.Disable(ctx, tx, user.Username); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditDelete, resource: "user_totp", resourceID: user.Username, before: before}, nil
+	})
+	if err != nil {
+		handleError(err, totpErrorStatus(err), rw)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
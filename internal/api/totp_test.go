@@ -0,0 +1,130 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/api"
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// currentTOTP computes the code an authenticator app shows for secret.
+func currentTOTP(t *testing.T, secret string) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, time.Now().Unix()/30)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:])&0x7fffffff)%1000000)
+}
+
+func TestTOTPEndpoints(t *testing.T) {
+	_, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, roles, ldap) VALUES ('admin', '["admin"]', 0), ('user', '["user"]', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	totp, err := auth.NewTOTP(db, &schema.TOTPConfig{RequiredRoles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	restapi := &api.RestApi{
+		Service:        api.NewService(db),
+		Authentication: &auth.Authentication{TOTP: totp},
+	}
+	r := mux.NewRouter()
+	restapi.MountRoutes(r)
+
+	status := func(user, target string) api.TOTPStatus {
+		t.Helper()
+		var status api.TOTPStatus
+		rw := doAuthz(t, r, authzUsers[user], "GET", target, nil)
+		if err := json.Unmarshal(rw.Body.Bytes(), &status); err != nil {
+			t.Fatalf("%d %s", rw.Code, rw.Body.String())
+		}
+		return status
+	}
+	if s := status("user", "/api/totp"); s.Enabled || s.Required {
+		t.Errorf("unexpected status: %+v", s)
+	}
+	if s := status("admin", "/api/totp"); s.Enabled || !s.Required {
+		t.Errorf("unexpected status: %+v", s)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["user"], "GET", "/api/totp?username=admin", nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for second factor of others, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw := doAuthz(t, r, authzUsers["admin"], "POST", "/api/totp", url.Values{"username": {"user"}}); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d enrolling others, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	rw := doAuthz(t, r, authzUsers["user"], "POST", "/api/totp", nil)
+	var enrollment auth.TOTPEnrollment
+	if err := json.Unmarshal(rw.Body.Bytes(), &enrollment); err != nil || enrollment.Secret == "" {
+		t.Fatalf("enrollment failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if !strings.HasPrefix(enrollment.URI, "otpauth://totp/ClusterCockpit:user?") {
+		t.Errorf("unexpected provisioning URI: %s", enrollment.URI)
+	}
+
+	if rw := doAuthz(t, r, authzUsers["user"], "POST", "/api/totp/confirm", url.Values{"code": {"abc"}}); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for invalid code, got %d", http.StatusForbidden, rw.Code)
+	}
+	rw = doAuthz(t, r, authzUsers["user"], "POST", "/api/totp/confirm", url.Values{"code": {currentTOTP(t, enrollment.Secret)}})
+	var codes api.RecoveryCodes
+	if err := json.Unmarshal(rw.Body.Bytes(), &codes); err != nil || len(codes.RecoveryCodes) != 10 {
+		t.Fatalf("confirmation failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if s := status("admin", "/api/totp?username=user"); !s.Enabled || s.RecoveryCodesLeft != 10 {
+		t.Errorf("unexpected status: %+v", s)
+	}
+	if rw := doAuthz(t, r, authzUsers["user"], "POST", "/api/totp", nil); rw.Code != http.StatusConflict {
+		t.Errorf("expected %d enrolling twice, got %d", http.StatusConflict, rw.Code)
+	}
+
+	rw = doAuthz(t, r, authzUsers["user"], "POST", "/api/totp/recovery-codes", url.Values{"code": {codes.RecoveryCodes[0]}})
+	var renewed api.RecoveryCodes
+	if err := json.Unmarshal(rw.Body.Bytes(), &renewed); err != nil || len(renewed.RecoveryCodes) != 10 {
+		t.Fatalf("renewing recovery codes failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if rw := doAuthz(t, r, authzUsers["user"], "DELETE", "/api/totp?code="+codes.RecoveryCodes[1], nil); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d with replaced recovery code, got %d", http.StatusForbidden, rw.Code)
+	}
+
+	// Admins reset the second factor of others without code
+	if rw := doAuthz(t, r, authzUsers["admin"], "DELETE", "/api/totp?username=user", nil); rw.Code != http.StatusNoContent {
+		t.Errorf("expected %d resetting second factor, got %d: %s", http.StatusNoContent, rw.Code, rw.Body.String())
+	}
+	if s := status("user", "/api/totp"); s.Enabled {
+		t.Errorf("second factor not disabled: %+v", s)
+	}
+	if rw := doAuthz(t, r, authzUsers["admin"], "DELETE", "/api/totp?username=user", nil); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d, got %d", http.StatusNotFound, rw.Code)
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=user_totp", nil)
+	if n := strings.Count(rw.Body.String(), `"resourceId":"user"`); n != 4 {
+		t.Errorf("expected 4 audit entries, got %d: %s", n, rw.Body.String())
+	}
+	if strings.Contains(rw.Body.String(), enrollment.Secret) {
+		t.Errorf("secret written to audit log")
+	}
+}
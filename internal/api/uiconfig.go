@@ -0,0 +1,242 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// UIConfigKeyInfo is a key of the UI configuration with its default.
+type UIConfigKeyInfo struct {
+	schema.UIConfigKey
+	Default interface{} `json:"default,omitempty" swaggertype:"object"`
+}
+
+// UIConfigProfileResult tells how many users a pushed profile was applied to.
+type UIConfigProfileResult struct {
+	Users int `json:"users"`
+}
+
+func uiConfigErrorStatus(err error) int {
+	if errors.Is(err, schema.ErrInvalidUIConfig) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// uiConfigUser returns the requesting user, UI settings are stored per user.
+func uiConfigUser(rw http.ResponseWriter, r *http.Request) *schema.User {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return nil
+	}
+	user := repository.GetUserFromContext(r.Context())
+	if user == nil {
+		handleError(errors.New("UI settings need an authenticated user"), http.StatusUnauthorized, rw)
+	}
+	return user
+}
+
+func decodeUIConfig(r *http.Request) (map[string]interface{}, error) {
+	var settings map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		return nil, fmt.Errorf("%w: parsing request body failed: %s", schema.ErrInvalidUIConfig, err.Error())
+	}
+	if settings == nil {
+		return nil, fmt.Errorf("%w: expected an object", schema.ErrInvalidUIConfig)
+	}
+	return settings, nil
+}
+
+// getConfigurationKeys godoc
+//
+//	@summary    Lists the settings of the web UI
+//	@description Returns all keys with description, JSON schema of their values and default.
+//	@tags       UIConfig
+//	@produce    json
+//	@success    200         {array}     api.UIConfigKeyInfo "UI configuration keys"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /configuration/keys [get]
+func (api *RestApi) getConfigurationKeys(rw http.ResponseWriter, r *http.Request) {
+	if uiConfigUser(rw, r) == nil {
+		return
+	}
+	keys, err := schema.UIConfigKeys()
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	infos := make([]UIConfigKeyInfo, 0, len(keys))
+	for _, key := range keys {
+		infos = append(infos, UIConfigKeyInfo{UIConfigKey: key, Default: config.Keys.UiDefaults[key.Name]})
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(infos)
+}
+
+// resetConfiguration godoc
+//
+//	@summary    Resets settings of the web UI to their defaults
+//	@description Resets the given keys of the requesting user, all keys if none are given.
+//	@tags       UIConfig
+//	@param      key         query       []string        false   "Keys to reset"  collectionFormat(multi)
+//	@success    204         "No Content"
+//	@failure    401         {object}    ErrorResponse   "Unauthorized"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /configuration/ [delete]
+func (api *RestApi) resetConfiguration(rw http.ResponseWriter, r *http.Request) {
+	user := uiConfigUser(rw, r)
+	if user == nil {
+		return
+	}
+	if err := repository.DeleteUIConfig(r.Context(), api.Service.db, user.Username, r.URL.Query()["key"]...); err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+	repository.InvalidateUIConfig(user.Username)
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// exportConfiguration godoc
+//
+//	@summary    Exports the settings of the web UI
+//	@description Returns the settings the requesting user changed as JSON object, for /configuration/import.
+//	@tags       UIConfig
+//	@produce    json
+//	@success    200         {object}    map[string]interface{}  "Settings by key"
+//	@failure    401         {object}    ErrorResponse   "Unauthorized"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /configuration/export [get]
+func (api *RestApi) exportConfiguration(rw http.ResponseWriter, r *http.Request) {
+	user := uiConfigUser(rw, r)
+	if user == nil {
+		return
+	}
+	settings, err := repository.LoadUIConfig(r.Context(), api.Service.db, user.Username)
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Content-Disposition", "attachment; filename=\"ui-config.json\"")
+	enc := json.NewEncoder(rw)
+	enc.SetIndent("", "  ")
+	enc.Encode(settings)
+}
+
+// importConfiguration godoc
+//
+//	@summary    Imports settings of the web UI
+//	@description Replaces all settings of the requesting user by the exported ones, unless merge is set.
+//	@description Nothing is changed if any key or value is invalid.
+//	@tags       UIConfig
+//	@accept     json
+//	@param      request     body        map[string]interface{}  true    "Settings by key"
+//	@param      merge       query       bool            false   "Keep settings not in the request"
+//	@success    204         "No Content"
+//	@failure    401         {object}    ErrorResponse   "Unauthorized"
+//	@failure    422         {object}    ErrorResponse   "Unknown keys or invalid values"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /configuration/import [post]
+func (api *RestApi) importConfiguration(rw http.ResponseWriter, r *http.Request) {
+	user := uiConfigUser(rw, r)
+	if user == nil {
+		return
+	}
+	settings, err := decodeUIConfig(r)
+	if err != nil {
+		handleError(err, uiConfigErrorStatus(err), rw)
+		return
+	}
+
+	err = api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, err := repository.LoadUIConfig(ctx, tx, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		if r.URL.Query().Get("merge") != "true" {
+			if err := repository.DeleteUIConfig(ctx, tx, user.Username); err != nil {
+				return nil, err
+			}
+		}
+		if err := repository.SetUIConfig(ctx, tx, user.Username, settings); err != nil {
+			return nil, err
+		}
+		after, err := repository.LoadUIConfig(ctx, tx, user.Username)
+		if err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditUpdate, resource: "ui_config", resourceID: user.Username,
+			before: before, after: after}, nil
+	})
+	repository.InvalidateUIConfig(user.Username)
+	if err != nil {
+		handleError(err, uiConfigErrorStatus(err), rw)
+		return
+	}
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// pushConfigurationProfile godoc
+//
+//	@summary    Applies settings of the web UI to all users
+//	@description Sets the given settings for every user, replacing their own values of these keys.
+//	@description Other settings of the users are kept. Only admins may push profiles.
+//	@tags       UIConfig
+//	@accept     json
+//	@produce    json
+//	@param      request     body        map[string]interface{}  true    "Settings by key"
+//	@success    200         {object}    api.UIConfigProfileResult   "Number of users"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    422         {object}    ErrorResponse   "Unknown keys or invalid values"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@security   ApiKeyAuth
+//	@router     /configuration/profile [put]
+func (api *RestApi) pushConfigurationProfile(rw http.ResponseWriter, r *http.Request) {
+	user := uiConfigUser(rw, r)
+	if user == nil {
+		return
+	}
+	if !user.HasRole(schema.RoleAdmin) {
+		handleError(errors.New("only admins are allowed to push UI settings to all users"), http.StatusForbidden, rw)
+		return
+	}
+	settings, err := decodeUIConfig(r)
+	if err != nil {
+		handleError(err, uiConfigErrorStatus(err), rw)
+		return
+	}
+
+	var usernames []string
+	err = api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		var err error
+		if usernames, err = repository.PushUIConfig(ctx, tx, settings); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditUpdate, resource: "ui_config", resourceID: "*",
+			after: map[string]interface{}{"settings": settings, "users": usernames}}, nil
+	})
+	repository.InvalidateUIConfig(usernames...)
+	if err != nil {
+		handleError(err, uiConfigErrorStatus(err), rw)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(UIConfigProfileResult{Users: len(usernames)})
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/api"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+)
+
+func TestUIConfigEndpoints(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, roles, ldap) VALUES ('admin', '["admin"]', 0), ('user', '["user"]', 0), ('support', '["support"]', 0)`); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	user := authzUsers["user"]
+	settings := func(username string) map[string]interface{} {
+		settings, err := repository.LoadUIConfig(ctx, db, username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return settings
+	}
+
+	var keys []api.UIConfigKeyInfo
+	rw := doAuthz(t, r, user, "GET", "/api/configuration/keys", nil)
+	if err := json.Unmarshal(rw.Body.Bytes(), &keys); err != nil || len(keys) == 0 {
+		t.Fatalf("listing keys failed: %d %s", rw.Code, rw.Body.String())
+	}
+
+	if rw := doJSON(t, r, user, "POST", "/api/configuration/import", `{"plot_general_lineWidth": 2, "no_such_key": 1}`); rw.Code != http.StatusUnprocessableEntity ||
+		!strings.Contains(rw.Body.String(), "no_such_key") {
+		t.Errorf("expected %d importing unknown key, got %d %s", http.StatusUnprocessableEntity, rw.Code, rw.Body.String())
+	}
+	if rw := doJSON(t, r, user, "POST", "/api/configuration/import", `{"plot_general_lineWidth": "wide"}`); rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d importing invalid value, got %d", http.StatusUnprocessableEntity, rw.Code)
+	}
+	if s := settings("user"); len(s) != 0 {
+		t.Errorf("invalid import partially applied: %v", s)
+	}
+
+	if rw := doJSON(t, r, user, "POST", "/api/configuration/import",
+		`{"plot_general_lineWidth": 2, "job_view_selectedMetrics:fritz": ["flops_any"], "plot_view_showRoofline": false}`); rw.Code != http.StatusNoContent {
+		t.Fatalf("import failed: %d %s", rw.Code, rw.Body.String())
+	}
+	rw = doAuthz(t, r, user, "GET", "/api/configuration/export", nil)
+	var exported map[string]interface{}
+	if err := json.Unmarshal(rw.Body.Bytes(), &exported); err != nil || !reflect.DeepEqual(exported, settings("user")) || len(exported) != 3 {
+		t.Errorf("unexpected export: %s", rw.Body.String())
+	}
+
+	// Without merge the import replaces all settings
+	if rw := doJSON(t, r, user, "POST", "/api/configuration/import?merge=true", `{"plot_list_jobsPerPage": 20}`); rw.Code != http.StatusNoContent || len(settings("user")) != 4 {
+		t.Errorf("merging import failed: %d %v", rw.Code, settings("user"))
+	}
+	if rw := doJSON(t, r, user, "POST", "/api/configuration/import", `{"plot_list_jobsPerPage": 25}`); rw.Code != http.StatusNoContent || len(settings("user")) != 1 {
+		t.Errorf("replacing import failed: %d %v", rw.Code, settings("user"))
+	}
+
+	if rw := doJSON(t, r, user, "PUT", "/api/configuration/profile", `{"plot_general_lineWidth": 4}`); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d pushing profile as user, got %d", http.StatusForbidden, rw.Code)
+	}
+	rw = doJSON(t, r, authzUsers["admin"], "PUT", "/api/configuration/profile", `{"plot_general_lineWidth": 4}`)
+	var result api.UIConfigProfileResult
+	if err := json.Unmarshal(rw.Body.Bytes(), &result); err != nil || result.Users != 3 {
+		t.Fatalf("pushing profile failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if s := settings("support"); s["plot_general_lineWidth"] != float64(4) {
+		t.Errorf("profile not applied: %v", s)
+	}
+	if s := settings("user"); len(s) != 2 {
+		t.Errorf("profile replaced other settings: %v", s)
+	}
+
+	if rw := doAuthz(t, r, user, "DELETE", "/api/configuration/?key=plot_general_lineWidth", nil); rw.Code != http.StatusNoContent || len(settings("user")) != 1 {
+		t.Errorf("resetting key failed: %d %v", rw.Code, settings("user"))
+	}
+	if rw := doAuthz(t, r, user, "DELETE", "/api/configuration/", nil); rw.Code != http.StatusNoContent || len(settings("user")) != 0 {
+		t.Errorf("resetting all keys failed: %d %v", rw.Code, settings("user"))
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource=ui_config", nil)
+	if !strings.Contains(rw.Body.String(), `"resourceId":"*"`) || !strings.Contains(rw.Body.String(), `"resourceId":"user"`) {
+		t.Errorf("UI config changes not audited: %s", rw.Body.String())
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// auditUser returns the audit log state of user. Unlike its JSON encoding,
+// it includes the password hash, so that password changes show up
+// (redacted) in the diff.
+func auditUser(user *schema.User) map[string]interface{} {
+	if user == nil {
+		return nil
+	}
+	state := make(map[string]interface{})
+	data, _ := json.Marshal(user)
+	json.Unmarshal(data, &state)
+	delete(state, "authType")
+	state["password"] = user.Password
+	return state
+}
+
+// requestBaseURL returns scheme and host of the server as seen by the client
+// of r.
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// updateUserTx applies update to username within tx. If the user is
+// disabled or gets a new password, its sessions end and, if disabled, its
+// API tokens are revoked. The IDs of revoked tokens are returned for the
+// denylist, see tokensRevoked.
+func updateUserTx(ctx context.Context, tx *sql.Tx, username string, update *repository.UserUpdate) (*schema.User, *schema.User, []string, error) {
+	before, after, err := repository.UpdateUser(ctx, tx, username, update)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var revoked []string
+	if after.Disabled || update.Password != nil {
+		if _, err := repository.DeleteUserSessions(ctx, tx, username, ""); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if after.Disabled && !before.Disabled {
+		if revoked, err = repository.RevokeUserApiTokens(ctx, tx, username); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return before, after, revoked, nil
+}
+
+// tokensRevoked makes this instance reject the revoked tokens immediately.
+func (api *RestApi) tokensRevoked(ids []string) {
+	if api.Authentication.JwtAuth == nil {
+		return
+	}
+	for _, id := range ids {
+		api.Authentication.JwtAuth.Revoked(id)
+	}
+}
+
+// parseUserFilter returns the user filter given by the query parameters of
+// getUsers.
+func parseUserFilter(query url.Values) (*repository.UserFilter, error) {
+	filter := &repository.UserFilter{
+		SpecialsOnly: query.Get("not-just-user") == "true",
+		Role:         query.Get("role"),
+		Project:      query.Get("project"),
+		Search:       query.Get("search"),
+		SortBy:       query.Get("sort"),
+	}
+
+	if s := query.Get("via-ldap"); s != "" {
+		viaLDAP, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid via-ldap: %w", err)
+		}
+		if viaLDAP {
+			filter.AuthSources = []schema.AuthSource{schema.AuthViaLDAP}
+		} else {
+			filter.NotAuthSources = []schema.AuthSource{schema.AuthViaLDAP}
+		}
+	}
+	for _, param := range query["auth-source"] {
+		for _, name := range strings.Split(param, ",") {
+			source, err := schema.ParseAuthSource(strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			filter.AuthSources = append(filter.AuthSources, source)
+		}
+	}
+
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		filter.Desc = true
+	default:
+		return nil, fmt.Errorf("invalid order '%s'", query.Get("order"))
+	}
+
+	var err error
+	if s := query.Get("limit"); s != "" {
+		if filter.Limit, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+	if s := query.Get("offset"); s != "" {
+		if filter.Offset, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid offset: %w", err)
+		}
+	}
+	return filter, nil
+}
+
+// csvCell keeps spreadsheets from evaluating s as formula.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// writeUsersCSV writes users as CSV with a header line. Roles and projects
+// are separated by semicolons.
+func writeUsersCSV(w io.Writer, users []*schema.User) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"username", "name", "email", "roles", "projects", "auth_source", "disabled"})
+	for _, user := range users {
+		cw.Write([]string{
+			csvCell(user.Username),
+			csvCell(user.Name),
+			csvCell(user.Email),
+			strings.Join(user.Roles, ";"),
+			csvCell(strings.Join(user.Projects, ";")),
+			user.AuthSource.String(),
+			strconv.FormatBool(user.Disabled),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// patchUser godoc
+//
+//	@summary    Updates several fields of a user at once
+//	@description Fields missing in the body are kept. Roles and projects replace the current ones. Disabled users
+//	@description cannot log in, their sessions end and their API tokens are revoked. Only admins may update users.
+//	@tags       User
+//	@accept     json
+//	@produce    json
+//	@param      id          path        string                  true    "Username"
+//	@param      request     body        repository.UserUpdate   true    "Fields to change"
+//	@success    200         {object}    schema.User     "Updated user"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    404         {object}    ErrorResponse   "Not Found"
+//	@failure    422         {object}    ErrorResponse   "Invalid roles, projects or password"
+//	@security   ApiKeyAuth
+//	@router     /user/{id} [patch]
+func (api *RestApi) patchUser(rw http.ResponseWriter, r *http.Request) {
+	if err := securedCheck(r); err != nil {
+		handleError(err, http.StatusForbidden, rw)
+		return
+	}
+	if user := repository.GetUserFromContext(r.Context()); !user.HasRole(schema.RoleAdmin) {
+		handleError(errors.New("only admins are allowed to update a user"), http.StatusForbidden, rw)
+		return
+	}
+
+	username := mux.Vars(r)["id"]
+	var update repository.UserUpdate
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&update); err != nil {
+		handleError(fmt.Errorf("parsing request body failed: %w", err), http.StatusBadRequest, rw)
+		return
+	}
+	if update.Password != nil && *update.Password != "" && api.Authentication.PasswordPolicy != nil {
+		if err := api.Authentication.PasswordPolicy.Check(username, *update.Password); err != nil {
+			handleError(err, http.StatusUnprocessableEntity, rw)
+			return
+		}
+	}
+
+	var updated *schema.User
+	var revoked []string
+	err := api.Service.auditedTx(r, func(ctx context.Context, tx *sql.Tx) (*auditRecord, error) {
+		before, after, ids, err := updateUserTx(ctx, tx, username, &update)
+		if err != nil {
+			return nil, err
+		}
+		updated, revoked = after, ids
+		return &auditRecord{action: repository.AuditUpdate, resource: "user", resourceID: username,
+			before: auditUser(before), after: auditUser(after)}, nil
+	})
+	if err != nil {
+		switch {
+		case err == sql.ErrNoRows:
+			handleError(fmt.Errorf("no user '%s'", username), http.StatusNotFound, rw)
+		case errors.Is(err, repository.ErrInvalidUser):
+			handleError(err, http.StatusUnprocessableEntity, rw)
+		default:
+			handleError(err, http.StatusInternalServerError, rw)
+		}
+		return
+	}
+	api.tokensRevoked(revoked)
+
+	rw.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(rw).Encode(updated)
+}
@@ -0,0 +1,149 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+)
+
+// doJSON is like doAuthz with a JSON body.
+func doJSON(t *testing.T, r *mux.Router, user *schema.User, method, target, body string) *httptest.ResponseRecorder {
+	u := *user
+	u.AuthType = schema.AuthSession
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req = req.WithContext(context.WithValue(req.Context(), repository.ContextUserKey, &u))
+
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestPatchUser(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, roles, ldap) VALUES ('admin', '["admin"]', 0), ('alice', '["user"]', 0)`); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now().Unix()
+	if _, err := db.Exec(`INSERT INTO sessions (id, token_hash, username, created_at, last_activity) VALUES ('s1', 'h1', 'alice', ?, ?)`, now, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := repository.CreateApiToken(context.Background(), db, &repository.ApiToken{ID: "t1", Username: "alice", Name: "ci", Scopes: []string{}, CreatedAt: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	if rw := doJSON(t, r, authzUsers["user"], "PATCH", "/api/user/alice", `{"name": "Alice"}`); rw.Code != http.StatusForbidden {
+		t.Errorf("expected %d for non-admin, got %d", http.StatusForbidden, rw.Code)
+	}
+	if rw := doJSON(t, r, authzUsers["admin"], "PATCH", "/api/user/nobody", `{"name": "Nobody"}`); rw.Code != http.StatusNotFound {
+		t.Errorf("expected %d for unknown user, got %d", http.StatusNotFound, rw.Code)
+	}
+	if rw := doJSON(t, r, authzUsers["admin"], "PATCH", "/api/user/alice", `{"nickname": "Al"}`); rw.Code != http.StatusBadRequest {
+		t.Errorf("expected %d for unknown field, got %d", http.StatusBadRequest, rw.Code)
+	}
+
+	// Nothing is changed if one of the fields is invalid
+	if rw := doJSON(t, r, authzUsers["admin"], "PATCH", "/api/user/alice", `{"name": "Alice", "projects": ["p1"]}`); rw.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected %d for projects of non-manager, got %d", http.StatusUnprocessableEntity, rw.Code)
+	}
+	if user, _ := repository.LoadUser(context.Background(), db, "alice"); user.Name != "" {
+		t.Errorf("invalid update partially applied: %+v", user)
+	}
+
+	rw := doJSON(t, r, authzUsers["admin"], "PATCH", "/api/user/alice",
+		`{"name": "Alice", "email": "alice@example.com", "roles": ["user", "Manager"], "projects": ["p1"], "disabled": true}`)
+	var updated schema.User
+	if err := json.Unmarshal(rw.Body.Bytes(), &updated); err != nil || rw.Code != http.StatusOK {
+		t.Fatalf("update failed: %d %s", rw.Code, rw.Body.String())
+	}
+	if updated.Name != "Alice" || updated.Email != "alice@example.com" || !updated.HasRole(schema.RoleManager) ||
+		len(updated.Projects) != 1 || !updated.Disabled {
+		t.Errorf("unexpected user after update: %+v", updated)
+	}
+	if sessions, _ := repository.ListSessions(context.Background(), db, "alice"); len(sessions) != 0 {
+		t.Errorf("sessions of disabled user not ended: %v", sessions)
+	}
+	if token, _ := repository.GetApiToken(context.Background(), db, "t1"); token.RevokedAt == 0 {
+		t.Errorf("token of disabled user not revoked")
+	}
+
+	rw = doAuthz(t, r, authzUsers["admin"], "GET", "/api/audit?resource_id=alice", nil)
+	if !strings.Contains(rw.Body.String(), `"resource":"user"`) || !strings.Contains(rw.Body.String(), "alice@example.com") {
+		t.Errorf("update not audited: %s", rw.Body.String())
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	r, db := setupAuthzRouterDB(t, setupAuthzTemplate(t))
+	if _, err := db.Exec(`INSERT INTO user (username, name, email, roles, projects, ldap) VALUES
+		('admin', 'Admin', NULL, '["admin"]', '[]', 0),
+		('alice', 'Alice Smith', 'alice@example.com', '["user"]', '[]', 1),
+		('bob', 'Bob Jones', 'bob@example.com', '["user", "manager"]', '["p_1"]', 1),
+		('carol', '=cmd()', 'carol@example.org', '["user"]', '[]', 3),
+		('dave', 'Dave', NULL, '["user", "manager"]', '["px1"]', 0)`); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(query string) ([]string, string) {
+		t.Helper()
+		rw := doAuthz(t, r, authzUsers["admin"], "GET", "/api/users/?"+query, nil)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("listing users with %q failed: %d %s", query, rw.Code, rw.Body.String())
+		}
+		var users []schema.User
+		if err := json.Unmarshal(rw.Body.Bytes(), &users); err != nil {
+			t.Fatal(err)
+		}
+		usernames := []string{}
+		for _, user := range users {
+			usernames = append(usernames, user.Username)
+		}
+		return usernames, rw.Header().Get("X-Total-Count")
+	}
+
+	for _, tc := range []struct {
+		query, want, total string
+	}{
+		{"", "admin,alice,bob,carol,dave", "5"},
+		{"via-ldap=false", "admin,carol,dave", "3"},
+		{"via-ldap=true", "alice,bob", "2"},
+		{"auth-source=local,oidc", "admin,carol,dave", "3"},
+		{"role=manager", "bob,dave", "2"},
+		{"project=p_1", "bob", "1"},
+		{"search=EXAMPLE.COM", "alice,bob", "2"},
+		{"search=jones", "bob", "1"},
+		{"not-just-user=true&via-ldap=false", "admin,dave", "2"},
+		{"sort=email&order=desc", "carol,bob,alice,dave,admin", "5"},
+		{"limit=2&offset=1", "alice,bob", "5"},
+		{"offset=4", "dave", "5"},
+	} {
+		usernames, total := list(tc.query)
+		if got := strings.Join(usernames, ","); got != tc.want || total != tc.total {
+			t.Errorf("%q: expected %s (%s), got %s (%s)", tc.query, tc.want, tc.total, got, total)
+		}
+	}
+
+	for _, query := range []string{"auth-source=kerberos", "role=wheel", "sort=password", "order=up", "limit=-1"} {
+		if rw := doAuthz(t, r, authzUsers["admin"], "GET", "/api/users/?"+query, nil); rw.Code != http.StatusBadRequest {
+			t.Errorf("expected %d for %q, got %d", http.StatusBadRequest, query, rw.Code)
+		}
+	}
+
+	rw := doAuthz(t, r, authzUsers["admin"], "GET", "/api/users/?format=csv&auth-source=oidc", nil)
+	if want := "username,name,email,roles,projects,auth_source,disabled\ncarol,'=cmd(),carol@example.org,user,,oidc,false\n"; rw.Body.String() != want ||
+		!strings.HasPrefix(rw.Header().Get("Content-Type"), "text/csv") {
+		t.Errorf("unexpected CSV export: %q", rw.Body.String())
+	}
+}
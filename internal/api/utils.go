@@ -9,7 +9,10 @@ import (
 	"net/http"
 	"strconv"
 
-	// "github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/pubsub"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
 	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
 	// "github.com/Deepbinder-main/cc-backend/internal/repository"
 
@@ -34,9 +37,7 @@ import (
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine [post]
 func (api *Service) CreateMachine(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -53,13 +54,20 @@ func (api *Service) CreateMachine(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = api.r.CreateMachine(r.Context(), params)
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		if err := q.CreateMachine(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := q.GetMachine(r.Context(), params.MachineID)
+		return &auditRecord{action: repository.AuditCreate, resource: "machine", resourceID: params.MachineID, after: after}, err
+	})
 	if err != nil {
 		log.Printf("error creating machine: %v", err)
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(params.MachineID, model.MachineChangeCreated)
 	rw.WriteHeader(http.StatusCreated)
 	json.NewEncoder(rw).Encode(params)
 }
@@ -75,9 +83,7 @@ func (api *Service) CreateMachine(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine/{machine_id} [get]
 func (api *Service) GetMachine(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromPath) {
 		return
 	}
 
@@ -112,9 +118,7 @@ func (api *Service) GetMachine(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine/{machine_id} [put]
 func (api *Service) UpdateMachine(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineFromPath) {
 		return
 	}
 
@@ -132,7 +136,17 @@ func (api *Service) UpdateMachine(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = api.r.UpdateMachine(r.Context(), params)
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := q.GetMachine(r.Context(), machineID)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateMachine(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := q.GetMachine(r.Context(), machineID)
+		return &auditRecord{action: repository.AuditUpdate, resource: "machine", resourceID: machineID, before: before, after: after}, err
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			handleError(err, http.StatusNotFound, rw)
@@ -142,6 +156,7 @@ func (api *Service) UpdateMachine(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishMachineChange(machineID, model.MachineChangeUpdated)
 	json.NewEncoder(rw).Encode(params)
 }
 
@@ -155,20 +170,26 @@ func (api *Service) UpdateMachine(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine/{machine_id} [delete]
 func (api *Service) DeleteMachine(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
 	machineID := mux.Vars(r)["machine_id"]
 
-	err = api.r.DeleteMachine(r.Context(), machineID)
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetMachine(r.Context(), machineID))
+		if err != nil {
+			return nil, err
+		}
+		err = q.DeleteMachine(r.Context(), machineID)
+		return &auditRecord{action: repository.AuditDelete, resource: "machine", resourceID: machineID, before: before}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(machineID, model.MachineChangeDeleted)
 	rw.WriteHeader(http.StatusNoContent)
 }
 
@@ -183,9 +204,7 @@ func (api *Service) DeleteMachine(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machines [get]
 func (api *Service) ListMachines(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
 		return
 	}
 
@@ -196,6 +215,110 @@ func (api *Service) ListMachines(rw http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(rw).Encode("still development")
 }
 
+// Machine Groups
+
+// GetMachineGroups godoc
+//
+//	@summary    Lists the groups a machine belongs to
+//	@description Managers may access a machine if one of its groups matches one of their projects.
+//	@tags       MachineGroup
+//	@produce    json
+//	@param      machine_id  path        string          true    "Machine ID"
+//	@success    200         {array}     string          "Group names"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /machine/{machine_id}/groups [get]
+func (api *Service) GetMachineGroups(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromPath) {
+		return
+	}
+
+	groups, err := api.r.GetMachineGroups(r.Context(), mux.Vars(r)["machine_id"])
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+	if groups == nil {
+		groups = []string{}
+	}
+
+	json.NewEncoder(rw).Encode(groups)
+}
+
+// CreateMachineGroup godoc
+//
+//	@summary    Adds a machine to a group
+//	@tags       MachineGroup
+//	@accept     mpfd
+//	@produce    json
+//	@param      machine_id  path        string          true    "Machine ID"
+//	@param      group_name  formData    string          true    "Group (project) name"
+//	@success    201         "Created"
+//	@failure    400         {object}    ErrorResponse   "Bad Request"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /machine/{machine_id}/groups [post]
+func (api *Service) CreateMachineGroup(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	params := sqlcdb.CreateMachineGroupParams{
+		MachineID: mux.Vars(r)["machine_id"],
+		GroupName: r.FormValue("group_name"),
+	}
+
+	if params.GroupName == "" {
+		handleError(errors.New("group_name is required"), http.StatusBadRequest, rw)
+		return
+	}
+
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		err := q.CreateMachineGroup(r.Context(), params)
+		return &auditRecord{action: repository.AuditCreate, resource: "machine_group", resourceID: params.MachineID, after: params}, err
+	})
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(params)
+}
+
+// DeleteMachineGroup godoc
+//
+//	@summary    Removes a machine from a group
+//	@tags       MachineGroup
+//	@produce    json
+//	@param      machine_id  path        string          true    "Machine ID"
+//	@param      group       path        string          true    "Group (project) name"
+//	@success    204         "No Content"
+//	@failure    403         {object}    ErrorResponse   "Forbidden"
+//	@failure    500         {object}    ErrorResponse   "Internal Server Error"
+//	@router     /machine/{machine_id}/groups/{group} [delete]
+func (api *Service) DeleteMachineGroup(rw http.ResponseWriter, r *http.Request) {
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
+		return
+	}
+
+	vars := mux.Vars(r)
+	params := sqlcdb.DeleteMachineGroupParams{
+		MachineID: vars["machine_id"],
+		GroupName: vars["group"],
+	}
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		err := q.DeleteMachineGroup(r.Context(), params)
+		return &auditRecord{action: repository.AuditDelete, resource: "machine_group", resourceID: params.MachineID, before: params}, err
+	})
+	if err != nil {
+		handleError(err, http.StatusInternalServerError, rw)
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
 // Add these methods to the Service struct
 
 // CreateMachineConf godoc
@@ -207,9 +330,9 @@ func (api *Service) ListMachines(rw http.ResponseWriter, r *http.Request) {
 //	@param      machine_id   formData    string          true    "Machine ID"
 //	@param      hostname     formData    string          true    "Hostname"
 //	@param      username     formData    string          true    "Username"
-//	@param      passphrase   formData    string          false   "Passphrase"
+//	@param      passphrase   formData    string          false   "Passphrase or secret reference (env:, file:, db:)"
 //	@param      port_number  formData    int             true    "Port Number"
-//	@param      password     formData    string          false   "Password"
+//	@param      password     formData    string          false   "Password or secret reference (env:, file:, db:)"
 //	@param      host_key     formData    string          false   "Host Key"
 //	@param      folder_path  formData    string          false   "Folder Path"
 //	@success    201          {object}    MachineConf     "Created machine configuration"
@@ -217,9 +340,7 @@ func (api *Service) ListMachines(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500          {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine_conf [post]
 func (api *Service) CreateMachineConf(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineFromForm) {
 		return
 	}
 
@@ -240,7 +361,15 @@ func (api *Service) CreateMachineConf(rw http.ResponseWriter, r *http.Request) {
 		FolderPath: sql.NullString{String: r.FormValue("folder_path"), Valid: r.FormValue("folder_path") != ""},
 	}
 
-	err = api.r.CreateMachineConf(r.Context(), params)
+	if err := checkSecretRefs(r.Context(), params.Password.String, params.Passphrase.String); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		err := q.CreateMachineConf(r.Context(), params)
+		return &auditRecord{action: repository.AuditCreate, resource: "machine_conf", resourceID: params.MachineID, after: params}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -253,6 +382,7 @@ func (api *Service) CreateMachineConf(rw http.ResponseWriter, r *http.Request) {
 // GetMachineConf godoc
 //
 //	@summary    Retrieves a machine configuration
+//	@description Credentials are only returned to admins.
 //	@tags       MachineConf
 //	@produce    json
 //	@param      machine_id   path        string          true    "Machine ID"
@@ -261,9 +391,7 @@ func (api *Service) CreateMachineConf(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500          {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine_conf/{machine_id} [get]
 func (api *Service) GetMachineConf(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromPath) {
 		return
 	}
 
@@ -279,6 +407,12 @@ func (api *Service) GetMachineConf(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !showCredentials(r) {
+		machineConf.Password = sql.NullString{}
+		machineConf.Passphrase = sql.NullString{}
+		machineConf.HostKey = sql.NullString{}
+	}
+
 	json.NewEncoder(rw).Encode(machineConf)
 }
 
@@ -291,9 +425,9 @@ func (api *Service) GetMachineConf(rw http.ResponseWriter, r *http.Request) {
 //	@param      id           path        int             true    "Machine Configuration ID"
 //	@param      hostname     formData    string          true    "Hostname"
 //	@param      username     formData    string          true    "Username"
-//	@param      passphrase   formData    string          false   "Passphrase"
+//	@param      passphrase   formData    string          false   "Passphrase or secret reference (env:, file:, db:)"
 //	@param      port_number  formData    int             true    "Port Number"
-//	@param      password     formData    string          false   "Password"
+//	@param      password     formData    string          false   "Password or secret reference (env:, file:, db:)"
 //	@param      host_key     formData    string          false   "Host Key"
 //	@param      folder_path  formData    string          false   "Folder Path"
 //	@success    200          {object}    MachineConf     "Updated machine configuration"
@@ -302,9 +436,7 @@ func (api *Service) GetMachineConf(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500          {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine_conf/{id} [put]
 func (api *Service) UpdateMachineConf(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("id", api.r.GetMachineConfOwner)) {
 		return
 	}
 
@@ -331,7 +463,22 @@ func (api *Service) UpdateMachineConf(rw http.ResponseWriter, r *http.Request) {
 		FolderPath: sql.NullString{String: r.FormValue("folder_path"), Valid: r.FormValue("folder_path") != ""},
 	}
 
-	err = api.r.UpdateMachineConf(r.Context(), params)
+	if err := checkSecretRefs(r.Context(), params.Password.String, params.Passphrase.String); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := q.GetMachineConfByID(r.Context(), params.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateMachineConf(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := q.GetMachineConfByID(r.Context(), params.ID)
+		return &auditRecord{action: repository.AuditUpdate, resource: "machine_conf", resourceID: strconv.Itoa(id), before: before, after: after}, err
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			handleError(err, http.StatusNotFound, rw)
@@ -355,9 +502,7 @@ func (api *Service) UpdateMachineConf(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500          {object}    ErrorResponse   "Internal Server Error"
 //	@router     /machine_conf/{id} [delete]
 func (api *Service) DeleteMachineConf(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("id", api.r.GetMachineConfOwner)) {
 		return
 	}
 
@@ -367,7 +512,14 @@ func (api *Service) DeleteMachineConf(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = api.r.DeleteMachineConf(r.Context(), int32(id))
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetMachineConfByID(r.Context(), int32(id)))
+		if err != nil {
+			return nil, err
+		}
+		err = q.DeleteMachineConf(r.Context(), int32(id))
+		return &auditRecord{action: repository.AuditDelete, resource: "machine_conf", resourceID: strconv.Itoa(id), before: before}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -384,17 +536,15 @@ func (api *Service) DeleteMachineConf(rw http.ResponseWriter, r *http.Request) {
 //	@tags       RabbitMQ
 //	@accept     mpfd
 //	@produce    json
-//	@param      conn_url    formData    string          true    "Connection URL"
+//	@param      conn_url    formData    string          true    "Connection URL or secret reference (env:, file:, db:)"
 //	@param      username    formData    string          true    "Username"
-//	@param      password    formData    string          true    "Password"
+//	@param      password    formData    string          true    "Password or secret reference (env:, file:, db:)"
 //	@success    200         {object}    RabbitMqConfig  "Created/Updated RabbitMQ configuration"
 //	@failure    400         {object}    ErrorResponse   "Bad Request"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /rabbitmq_config [post]
 func (api *Service) CreateRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -404,7 +554,22 @@ func (api *Service) CreateRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 		Password: r.FormValue("password"),
 	}
 
-	err = api.r.CreateRabbitMQConfig(r.Context(), params)
+	if err := checkSecretRefs(r.Context(), params.ConnUrl, params.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetRabbitMQConfig(r.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.CreateRabbitMQConfig(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := auditState(q.GetRabbitMQConfig(r.Context()))
+		return &auditRecord{action: repository.AuditCreate, resource: repository.ConfigRabbitMQ, configType: repository.ConfigRabbitMQ, before: before, after: after}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -417,6 +582,7 @@ func (api *Service) CreateRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 // GetRabbitMQConfig godoc
 //
 //	@summary    Retrieves the RabbitMQ configuration
+//	@description Credentials are only returned to admins.
 //	@tags       RabbitMQ
 //	@produce    json
 //	@success    200         {object}    RabbitMqConfig  "Retrieved RabbitMQ configuration"
@@ -424,9 +590,7 @@ func (api *Service) CreateRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /rabbitmq_config [get]
 func (api *Service) GetRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
 		return
 	}
 
@@ -440,6 +604,11 @@ func (api *Service) GetRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !showCredentials(r) {
+		config.ConnUrl = repository.RedactURL(config.ConnUrl)
+		config.Password = ""
+	}
+
 	json.NewEncoder(rw).Encode(config)
 }
 
@@ -449,17 +618,15 @@ func (api *Service) GetRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
 //	@tags       RabbitMQ
 //	@accept     mpfd
 //	@produce    json
-//	@param      conn_url    formData    string          true    "Connection URL"
+//	@param      conn_url    formData    string          true    "Connection URL or secret reference (env:, file:, db:)"
 //	@param      username    formData    string          true    "Username"
-//	@param      password    formData    string          true    "Password"
+//	@param      password    formData    string          true    "Password or secret reference (env:, file:, db:)"
 //	@success    200         {object}    RabbitMqConfig  "Updated RabbitMQ configuration"
 //	@failure    400         {object}    ErrorResponse   "Bad Request"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /rabbitmq_config [put]
 func (api *Service) UpdateRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -469,7 +636,22 @@ func (api *Service) UpdateRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 		Password: r.FormValue("password"),
 	}
 
-	err = api.r.UpdateRabbitMQConfig(r.Context(), params)
+	if err := checkSecretRefs(r.Context(), params.ConnUrl, params.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetRabbitMQConfig(r.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateRabbitMQConfig(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := auditState(q.GetRabbitMQConfig(r.Context()))
+		return &auditRecord{action: repository.AuditUpdate, resource: repository.ConfigRabbitMQ, configType: repository.ConfigRabbitMQ, before: before, after: after}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -488,15 +670,24 @@ func (api *Service) UpdateRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /rabbitmq_config [delete]
 func (api *Service) DeleteRabbitMQConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
-	err = api.r.DeleteRabbitMQConfig(r.Context())
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := q.GetRabbitMQConfig(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		err = q.DeleteRabbitMQConfig(r.Context())
+		return &auditRecord{action: repository.AuditDelete, resource: repository.ConfigRabbitMQ, configType: repository.ConfigRabbitMQ, before: before}, err
+	})
 	if err != nil {
-		handleError(err, http.StatusInternalServerError, rw)
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
 		return
 	}
 
@@ -516,7 +707,7 @@ func (api *Service) DeleteRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 //	@param      host                   formData    string          true    "Host"
 //	@param      port                   formData    int             true    "Port"
 //	@param      user                   formData    string          true    "User"
-//	@param      password               formData    string          true    "Password"
+//	@param      password               formData    string          true    "Password or secret reference (env:, file:, db:)"
 //	@param      organization           formData    string          true    "Organization"
 //	@param      ssl_enabled            formData    bool            true    "SSL Enabled"
 //	@param      batch_size             formData    int             true    "Batch Size"
@@ -530,9 +721,7 @@ func (api *Service) DeleteRabbitMQConfig(rw http.ResponseWriter, r *http.Request
 //	@failure    500                    {object}    ErrorResponse   "Internal Server Error"
 //	@router     /influxdb_config [post]
 func (api *Service) CreateInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -583,7 +772,22 @@ func (api *Service) CreateInfluxDBConfig(rw http.ResponseWriter, r *http.Request
 		MetaAsTags:           sql.NullString{String: r.FormValue("meta_as_tags"), Valid: r.FormValue("meta_as_tags") != ""},
 	}
 
-	err = api.r.CreateInfluxDBConfiguration(r.Context(), params)
+	if err := checkSecretRefs(r.Context(), params.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetInfluxDBConfiguration(r.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.CreateInfluxDBConfiguration(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := auditState(q.GetInfluxDBConfiguration(r.Context()))
+		return &auditRecord{action: repository.AuditCreate, resource: repository.ConfigInfluxDB, configType: repository.ConfigInfluxDB, before: before, after: after}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -596,6 +800,7 @@ func (api *Service) CreateInfluxDBConfig(rw http.ResponseWriter, r *http.Request
 // GetInfluxDBConfig godoc
 //
 //	@summary    Retrieves the InfluxDB configuration
+//	@description Credentials are only returned to admins.
 //	@tags       InfluxDB
 //	@produce    json
 //	@success    200         {object}    InfluxdbConfiguration  "Retrieved InfluxDB configuration"
@@ -603,9 +808,7 @@ func (api *Service) CreateInfluxDBConfig(rw http.ResponseWriter, r *http.Request
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /influxdb_config [get]
 func (api *Service) GetInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
 		return
 	}
 
@@ -619,6 +822,10 @@ func (api *Service) GetInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !showCredentials(r) {
+		config.Password = ""
+	}
+
 	json.NewEncoder(rw).Encode(config)
 }
 
@@ -633,7 +840,7 @@ func (api *Service) GetInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
 //	@param      host                   formData    string          true    "Host"
 //	@param      port                   formData    int             true    "Port"
 //	@param      user                   formData    string          true    "User"
-//	@param      password               formData    string          true    "Password"
+//	@param      password               formData    string          true    "Password or secret reference (env:, file:, db:)"
 //	@param      organization           formData    string          true    "Organization"
 //	@param      ssl_enabled            formData    bool            true    "SSL Enabled"
 //	@param      batch_size             formData    int             true    "Batch Size"
@@ -647,9 +854,7 @@ func (api *Service) GetInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500                    {object}    ErrorResponse   "Internal Server Error"
 //	@router     /influxdb_config [put]
 func (api *Service) UpdateInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -700,7 +905,22 @@ func (api *Service) UpdateInfluxDBConfig(rw http.ResponseWriter, r *http.Request
 		MetaAsTags:           sql.NullString{String: r.FormValue("meta_as_tags"), Valid: r.FormValue("meta_as_tags") != ""},
 	}
 
-	err = api.r.UpdateInfluxDBConfiguration(r.Context(), params)
+	if err := checkSecretRefs(r.Context(), params.Password); err != nil {
+		handleError(err, http.StatusBadRequest, rw)
+		return
+	}
+
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetInfluxDBConfiguration(r.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateInfluxDBConfiguration(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := auditState(q.GetInfluxDBConfiguration(r.Context()))
+		return &auditRecord{action: repository.AuditUpdate, resource: repository.ConfigInfluxDB, configType: repository.ConfigInfluxDB, before: before, after: after}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -719,15 +939,24 @@ func (api *Service) UpdateInfluxDBConfig(rw http.ResponseWriter, r *http.Request
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /influxdb_config [delete]
 func (api *Service) DeleteInfluxDBConfig(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
-	err = api.r.DeleteInfluxDBConfiguration(r.Context(), 1) // Assuming the ID is always 1 for the single row
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := q.GetInfluxDBConfiguration(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		err = q.DeleteInfluxDBConfiguration(r.Context(), before.ID)
+		return &auditRecord{action: repository.AuditDelete, resource: repository.ConfigInfluxDB, configType: repository.ConfigInfluxDB, before: before}, err
+	})
 	if err != nil {
-		handleError(err, http.StatusInternalServerError, rw)
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
 		return
 	}
 
@@ -748,9 +977,7 @@ func (api *Service) DeleteInfluxDBConfig(rw http.ResponseWriter, r *http.Request
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /file_stash_url [post]
 func (api *Service) CreateFileStashURL(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -760,7 +987,17 @@ func (api *Service) CreateFileStashURL(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = api.r.CreateFileStashURL(r.Context(), url)
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetFileStashURL(r.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.CreateFileStashURL(r.Context(), url); err != nil {
+			return nil, err
+		}
+		after, err := auditState(q.GetFileStashURL(r.Context()))
+		return &auditRecord{action: repository.AuditCreate, resource: repository.ConfigFileStash, configType: repository.ConfigFileStash, before: before, after: after}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -777,6 +1014,7 @@ func (api *Service) CreateFileStashURL(rw http.ResponseWriter, r *http.Request)
 // GetFileStashURL godoc
 //
 //	@summary    Retrieves the File Stash URL
+//	@description Credentials are only returned to admins.
 //	@tags       FileStash
 //	@produce    json
 //	@success    200         {object}    FileStashUrl    "Retrieved File Stash URL"
@@ -784,9 +1022,7 @@ func (api *Service) CreateFileStashURL(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /file_stash_url [get]
 func (api *Service) GetFileStashURL(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
 		return
 	}
 
@@ -800,6 +1036,10 @@ func (api *Service) GetFileStashURL(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !showCredentials(r) {
+		fileStashURL.Url = repository.RedactURL(fileStashURL.Url)
+	}
+
 	json.NewEncoder(rw).Encode(fileStashURL)
 }
 
@@ -815,9 +1055,7 @@ func (api *Service) GetFileStashURL(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /file_stash_url [put]
 func (api *Service) UpdateFileStashURL(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -827,7 +1065,17 @@ func (api *Service) UpdateFileStashURL(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = api.r.UpdateFileStashURL(r.Context(), url)
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetFileStashURL(r.Context()))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateFileStashURL(r.Context(), url); err != nil {
+			return nil, err
+		}
+		after, err := auditState(q.GetFileStashURL(r.Context()))
+		return &auditRecord{action: repository.AuditUpdate, resource: repository.ConfigFileStash, configType: repository.ConfigFileStash, before: before, after: after}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -850,15 +1098,24 @@ func (api *Service) UpdateFileStashURL(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /file_stash_url [delete]
 func (api *Service) DeleteFileStashURL(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
-	err = api.r.DeleteFileStashURL(r.Context(), 1) // Assuming the ID is always 1 for the single row
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := q.GetFileStashURL(r.Context())
+		if err != nil {
+			return nil, err
+		}
+		err = q.DeleteFileStashURL(r.Context(), before.ID)
+		return &auditRecord{action: repository.AuditDelete, resource: repository.ConfigFileStash, configType: repository.ConfigFileStash, before: before}, err
+	})
 	if err != nil {
-		handleError(err, http.StatusInternalServerError, rw)
+		if err == sql.ErrNoRows {
+			handleError(err, http.StatusNotFound, rw)
+		} else {
+			handleError(err, http.StatusInternalServerError, rw)
+		}
 		return
 	}
 
@@ -883,9 +1140,7 @@ func (api *Service) DeleteFileStashURL(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}  ErrorResponse       "Internal Server Error"
 //	@router     /lv_storage_issuer [post]
 func (api *Service) CreateLVStorageIssuer(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineFromForm) {
 		return
 	}
 
@@ -904,7 +1159,10 @@ func (api *Service) CreateLVStorageIssuer(rw http.ResponseWriter, r *http.Reques
 		Maxavailablespacegb: maxAvailableSpaceGB,
 	}
 
-	err = api.r.CreateLVStorageIssuer(r.Context(), params)
+	err := api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		err := q.CreateLVStorageIssuer(r.Context(), params)
+		return &auditRecord{action: repository.AuditCreate, resource: "lv_storage_issuer", resourceID: params.MachineID, after: params}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -923,9 +1181,7 @@ func (api *Service) CreateLVStorageIssuer(rw http.ResponseWriter, r *http.Reques
 //	@failure    500         {object}  ErrorResponse       "Internal Server Error"
 //	@router     /lv_storage_issuers [get]
 func (api *Service) GetLVStorageIssuers(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigRead, nil) {
 		return
 	}
 
@@ -957,9 +1213,7 @@ func (api *Service) GetLVStorageIssuers(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}  ErrorResponse       "Internal Server Error"
 //	@router     /lv_storage_issuer/{id} [put]
 func (api *Service) UpdateLVStorageIssuer(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("id", api.r.GetLVStorageIssuerOwner)) {
 		return
 	}
 
@@ -984,7 +1238,17 @@ func (api *Service) UpdateLVStorageIssuer(rw http.ResponseWriter, r *http.Reques
 		Maxavailablespacegb: maxAvailableSpaceGB,
 	}
 
-	err = api.r.UpdateLVStorageIssuer(r.Context(), params)
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := q.GetLVStorageIssuer(r.Context(), params.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateLVStorageIssuer(r.Context(), params); err != nil {
+			return nil, err
+		}
+		after, err := q.GetLVStorageIssuer(r.Context(), params.ID)
+		return &auditRecord{action: repository.AuditUpdate, resource: "lv_storage_issuer", resourceID: strconv.Itoa(id), before: before, after: after}, err
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			handleError(err, http.StatusNotFound, rw)
@@ -1008,9 +1272,7 @@ func (api *Service) UpdateLVStorageIssuer(rw http.ResponseWriter, r *http.Reques
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /lv_storage_issuer/{id} [delete]
 func (api *Service) DeleteLVStorageIssuer(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("id", api.r.GetLVStorageIssuerOwner)) {
 		return
 	}
 
@@ -1020,7 +1282,14 @@ func (api *Service) DeleteLVStorageIssuer(rw http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = api.r.DeleteLVStorageIssuer(r.Context(), int32(id))
+	err = api.audited(r, func(q *sqlcdb.Queries) (*auditRecord, error) {
+		before, err := auditState(q.GetLVStorageIssuer(r.Context(), int32(id)))
+		if err != nil {
+			return nil, err
+		}
+		err = q.DeleteLVStorageIssuer(r.Context(), int32(id))
+		return &auditRecord{action: repository.AuditDelete, resource: "lv_storage_issuer", resourceID: strconv.Itoa(id), before: before}, err
+	})
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
@@ -1043,9 +1312,7 @@ func (api *Service) DeleteLVStorageIssuer(rw http.ResponseWriter, r *http.Reques
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /notifications [post]
 func (api *Service) CreateNotification(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyNotificationCreate, nil) {
 		return
 	}
 
@@ -1055,12 +1322,13 @@ func (api *Service) CreateNotification(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = api.r.CreateNotification(r.Context(), message)
+	notification, err := repository.CreateNotification(r.Context(), api.db, message)
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	pubsub.Notifications.Publish(notification)
 	rw.WriteHeader(http.StatusCreated)
 	json.NewEncoder(rw).Encode(map[string]string{"message": message})
 }
@@ -1075,9 +1343,7 @@ func (api *Service) CreateNotification(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /notifications [get]
 func (api *Service) GetNotifications(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyNotificationRead, nil) {
 		return
 	}
 
@@ -1106,9 +1372,7 @@ func (api *Service) GetNotifications(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /notifications/{id} [delete]
 func (api *Service) DeleteNotification(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyConfigWrite, nil) {
 		return
 	}
 
@@ -1142,9 +1406,7 @@ func (api *Service) DeleteNotification(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /realtime_logs [post]
 func (api *Service) CreateRealtimeLog(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineFromForm) {
 		return
 	}
 
@@ -1158,12 +1420,14 @@ func (api *Service) CreateRealtimeLog(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = api.r.CreateRealtimeLog(r.Context(), params)
+	logEntry, err := repository.CreateRealtimeLog(r.Context(), api.db, params.MachineID, params.LogMessage)
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	pubsub.RealtimeLogs.Publish(logEntry)
+
 	rw.WriteHeader(http.StatusCreated)
 	json.NewEncoder(rw).Encode(params)
 }
@@ -1173,20 +1437,18 @@ func (api *Service) CreateRealtimeLog(rw http.ResponseWriter, r *http.Request) {
 //	@summary    Retrieves realtime logs for a machine
 //	@tags       RealtimeLogs
 //	@produce    json
-//	@param      machine_id  path        string          true    "Machine ID"
+//	@param      machine_id  query       string          true    "Machine ID"
 //	@param      limit       query       int             false   "Limit the number of logs"
 //	@success    200         {array}     RealtimeLog     "Retrieved realtime logs"
 //	@failure    400         {object}    ErrorResponse   "Bad Request"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
-//	@router     /realtime_logs/{machine_id} [get]
+//	@router     /realtime_logs [get]
 func (api *Service) GetRealtimeLogs(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromForm) {
 		return
 	}
 
-	machineID := mux.Vars(r)["machine_id"]
+	machineID := r.FormValue("machine_id")
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit <= 0 {
 		limit = 10 // Default limit
@@ -1215,9 +1477,7 @@ func (api *Service) GetRealtimeLogs(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /realtime_logs/{id} [delete]
 func (api *Service) DeleteRealtimeLog(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("id", api.r.GetRealtimeLogOwner)) {
 		return
 	}
 
@@ -1257,9 +1517,7 @@ func (api *Service) DeleteRealtimeLog(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /volume_groups [post]
 func (api *Service) CreateVolumeGroup(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineFromForm) {
 		return
 	}
 
@@ -1274,12 +1532,13 @@ func (api *Service) CreateVolumeGroup(rw http.ResponseWriter, r *http.Request) {
 		VgFree:    r.FormValue("vg_free"),
 	}
 
-	err = api.r.CreateVolumeGroup(r.Context(), params)
+	err := api.r.CreateVolumeGroup(r.Context(), params)
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(params.MachineID, model.MachineChangeInventory)
 	rw.WriteHeader(http.StatusCreated)
 	json.NewEncoder(rw).Encode(params)
 }
@@ -1289,18 +1548,16 @@ func (api *Service) CreateVolumeGroup(rw http.ResponseWriter, r *http.Request) {
 //	@summary    Retrieves volume groups for a machine
 //	@tags       VolumeGroups
 //	@produce    json
-//	@param      machine_id  path        string          true    "Machine ID"
+//	@param      machine_id  query       string          true    "Machine ID"
 //	@success    200         {array}     VolumeGroup     "Retrieved volume groups"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
-//	@router     /volume_groups/{machine_id} [get]
+//	@router     /volume_groups [get]
 func (api *Service) GetVolumeGroups(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromForm) {
 		return
 	}
 
-	machineID := mux.Vars(r)["machine_id"]
+	machineID := r.FormValue("machine_id")
 
 	volumeGroups, err := api.r.GetVolumeGroups(r.Context(), machineID)
 	if err != nil {
@@ -1330,9 +1587,7 @@ func (api *Service) GetVolumeGroups(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /volume_groups/{vg_id} [put]
 func (api *Service) UpdateVolumeGroup(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineOwnedBy("vg_id", api.r.GetVolumeGroupOwner)) {
 		return
 	}
 
@@ -1359,6 +1614,7 @@ func (api *Service) UpdateVolumeGroup(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishMachineChange(machineOf(r, machineOwnedBy("vg_id", api.r.GetVolumeGroupOwner)), model.MachineChangeInventory)
 	json.NewEncoder(rw).Encode(params)
 }
 
@@ -1367,24 +1623,26 @@ func (api *Service) UpdateVolumeGroup(rw http.ResponseWriter, r *http.Request) {
 //	@summary    Deletes a volume group
 //	@tags       VolumeGroup
 //	@produce    json
-//	@param      group_id    path        string          true    "Volume Group ID"
+//	@param      vg_id       path        int             true    "Volume Group ID"
 //	@success    204         "No Content"
 //	@failure    400         {object}    ErrorResponse   "Bad Request"
 //	@failure    404         {object}    ErrorResponse   "Not Found"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
-//	@router     /volume_group/{group_id} [delete]
+//	@router     /volume_groups/{vg_id} [delete]
 func (api *Service) DeleteVolumeGroup(rw http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	groupIDStr := vars["group_id"]
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("vg_id", api.r.GetVolumeGroupOwner)) {
+		return
+	}
 
-	// Convert group_id to int32
-	groupIDInt, err := strconv.Atoi(groupIDStr)
+	// Convert vg_id to int32
+	groupIDInt, err := strconv.Atoi(mux.Vars(r)["vg_id"])
 	if err != nil {
 		http.Error(rw, "Invalid group ID", http.StatusBadRequest)
 		return
 	}
 	groupID := int32(groupIDInt)
 
+	machineID := machineOf(r, machineOwnedBy("vg_id", api.r.GetVolumeGroupOwner))
 	err = api.r.DeleteVolumeGroup(r.Context(), groupID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -1395,6 +1653,7 @@ func (api *Service) DeleteVolumeGroup(rw http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	publishMachineChange(machineID, model.MachineChangeInventory)
 	rw.WriteHeader(http.StatusNoContent)
 }
 
@@ -1414,11 +1673,9 @@ func (api *Service) DeleteVolumeGroup(rw http.ResponseWriter, r *http.Request) {
 //	@success    201         {object}    PhysicalVolume  "Created physical volume"
 //	@failure    400         {object}    ErrorResponse   "Bad Request"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
-//	@router     /physical_volume [post]
+//	@router     /physical_volumes [post]
 func (api *Service) CreatePhysicalVolume(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineFromForm) {
 		return
 	}
 
@@ -1437,12 +1694,13 @@ func (api *Service) CreatePhysicalVolume(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = api.r.CreatePhysicalVolume(r.Context(), params)
+	err := api.r.CreatePhysicalVolume(r.Context(), params)
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(params.MachineID, model.MachineChangeInventory)
 	rw.WriteHeader(http.StatusCreated)
 	json.NewEncoder(rw).Encode(params)
 }
@@ -1452,19 +1710,17 @@ func (api *Service) CreatePhysicalVolume(rw http.ResponseWriter, r *http.Request
 //	@summary    Retrieves physical volume records for a machine
 //	@tags       PhysicalVolume
 //	@produce    json
-//	@param      machine_id  path        string          true    "Machine ID"
+//	@param      machine_id  query       string          true    "Machine ID"
 //	@success    200         {array}     PhysicalVolume  "Retrieved physical volumes"
 //	@failure    404         {object}    ErrorResponse   "Not Found"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
-//	@router     /physical_volumes/{machine_id} [get]
+//	@router     /physical_volumes [get]
 func (api *Service) GetPhysicalVolumes(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromForm) {
 		return
 	}
 
-	machineID := mux.Vars(r)["machine_id"]
+	machineID := r.FormValue("machine_id")
 
 	physicalVolumes, err := api.r.GetPhysicalVolumes(r.Context(), machineID)
 	if err != nil {
@@ -1496,11 +1752,9 @@ func (api *Service) GetPhysicalVolumes(rw http.ResponseWriter, r *http.Request)
 //	@failure    400         {object}    ErrorResponse   "Bad Request"
 //	@failure    404         {object}    ErrorResponse   "Not Found"
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
-//	@router     /physical_volume/{pv_id} [put]
+//	@router     /physical_volumes/{pv_id} [put]
 func (api *Service) UpdatePhysicalVolume(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineOwnedBy("pv_id", api.r.GetPhysicalVolumeOwner)) {
 		return
 	}
 
@@ -1536,6 +1790,7 @@ func (api *Service) UpdatePhysicalVolume(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
+	publishMachineChange(machineOf(r, machineOwnedBy("pv_id", api.r.GetPhysicalVolumeOwner)), model.MachineChangeInventory)
 	json.NewEncoder(rw).Encode(params)
 }
 
@@ -1548,11 +1803,9 @@ func (api *Service) UpdatePhysicalVolume(rw http.ResponseWriter, r *http.Request
 //	@success    204     "No Content"
 //	@failure    400     {object}    ErrorResponse   "Bad Request"
 //	@failure    500     {object}    ErrorResponse   "Internal Server Error"
-//	@router     /physical_volume/{pv_id} [delete]
+//	@router     /physical_volumes/{pv_id} [delete]
 func (api *Service) DeletePhysicalVolume(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("pv_id", api.r.GetPhysicalVolumeOwner)) {
 		return
 	}
 
@@ -1563,12 +1816,14 @@ func (api *Service) DeletePhysicalVolume(rw http.ResponseWriter, r *http.Request
 		return
 	}
 
+	machineID := machineOf(r, machineOwnedBy("pv_id", api.r.GetPhysicalVolumeOwner))
 	err = api.r.DeletePhysicalVolume(r.Context(), int32(pvID))
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(machineID, model.MachineChangeInventory)
 	rw.WriteHeader(http.StatusNoContent)
 }
 
@@ -1590,9 +1845,7 @@ func (api *Service) DeletePhysicalVolume(rw http.ResponseWriter, r *http.Request
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /logical_volume [post]
 func (api *Service) CreateLogicalVolume(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineFromForm) {
 		return
 	}
 
@@ -1609,12 +1862,13 @@ func (api *Service) CreateLogicalVolume(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	err = api.r.CreateLogicalVolume(r.Context(), params)
+	err := api.r.CreateLogicalVolume(r.Context(), params)
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(params.MachineID, model.MachineChangeInventory)
 	rw.WriteHeader(http.StatusCreated)
 	json.NewEncoder(rw).Encode(params)
 }
@@ -1630,9 +1884,7 @@ func (api *Service) CreateLogicalVolume(rw http.ResponseWriter, r *http.Request)
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /logical_volumes/{machine_id} [get]
 func (api *Service) GetLogicalVolumes(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineRead, machineFromPath) {
 		return
 	}
 
@@ -1668,9 +1920,7 @@ func (api *Service) GetLogicalVolumes(rw http.ResponseWriter, r *http.Request) {
 //	@failure    500         {object}    ErrorResponse   "Internal Server Error"
 //	@router     /logical_volume/{lv_id} [put]
 func (api *Service) UpdateLogicalVolume(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachinePush, machineOwnedBy("lv_id", api.r.GetLogicalVolumeOwner)) {
 		return
 	}
 
@@ -1704,6 +1954,7 @@ func (api *Service) UpdateLogicalVolume(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	publishMachineChange(machineOf(r, machineOwnedBy("lv_id", api.r.GetLogicalVolumeOwner)), model.MachineChangeInventory)
 	json.NewEncoder(rw).Encode(params)
 }
 
@@ -1718,9 +1969,7 @@ func (api *Service) UpdateLogicalVolume(rw http.ResponseWriter, r *http.Request)
 //	@failure    500     {object}    ErrorResponse   "Internal Server Error"
 //	@router     /logical_volume/{lv_id} [delete]
 func (api *Service) DeleteLogicalVolume(rw http.ResponseWriter, r *http.Request) {
-	err := securedCheck(r)
-	if err != nil {
-		http.Error(rw, err.Error(), http.StatusForbidden)
+	if !api.authorize(rw, r, auth.PolicyMachineWrite, machineOwnedBy("lv_id", api.r.GetLogicalVolumeOwner)) {
 		return
 	}
 
@@ -1731,12 +1980,14 @@ func (api *Service) DeleteLogicalVolume(rw http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	machineID := machineOf(r, machineOwnedBy("lv_id", api.r.GetLogicalVolumeOwner))
 	err = api.r.DeleteLogicalVolume(r.Context(), int32(lvID))
 	if err != nil {
 		handleError(err, http.StatusInternalServerError, rw)
 		return
 	}
 
+	publishMachineChange(machineID, model.MachineChangeInventory)
 	rw.WriteHeader(http.StatusNoContent)
 }
     
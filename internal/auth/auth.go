@@ -10,15 +10,21 @@ import (
 	"database/sql"
 	"encoding/base64"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
 	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
 	"github.com/gorilla/sessions"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type Authenticator interface {
@@ -32,34 +38,109 @@ type Authentication struct {
 	JwtAuth        *JWTAuthenticator
 	LocalAuth      *LocalAuthenticator
 	authenticators []Authenticator
+	Sessions       *SessionStore
 	SessionMaxAge  time.Duration
+	// Sessions inactive for longer end, 0 disables the timeout
+	SessionIdleTimeout time.Duration
+	LoginThrottle      *LoginThrottle
+	TOTP               *TOTP
+	PasswordPolicy     *PasswordPolicy
+}
+
+const (
+	// Cookie remembering a login waiting for the second factor
+	pendingLoginName   = "login"
+	pendingLoginMaxAge = 5 * time.Minute
+)
+
+// ErrUserDisabled is returned for logins of disabled users.
+var ErrUserDisabled = errors.New("account disabled")
+
+var (
+	loginAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cc",
+		Subsystem: "auth",
+		Name:      "logins_total",
+		Help:      "Login attempts by result (success, failure, throttled or disabled). Prompts for the second factor do not count.",
+	}, []string{"result"})
+
+	authentications = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cc",
+		Subsystem: "auth",
+		Name:      "requests_total",
+		Help:      "Authentication of requests to secured routes by method (jwt, session or none) and result.",
+	}, []string{"method", "result"})
+)
+
+type recoveryCodesKey struct{}
+
+// RecoveryCodesFromContext returns the recovery codes created when a user
+// set up the second factor during login. They have to be shown to the user
+// once.
+func RecoveryCodesFromContext(ctx context.Context) []string {
+	codes, _ := ctx.Value(recoveryCodesKey{}).([]string)
+	return codes
 }
 
 func (auth *Authentication) AuthViaSession(
 	rw http.ResponseWriter,
 	r *http.Request,
 ) (*schema.User, error) {
+	user, _, err := auth.sessionUser(r)
+	return user, err
+}
+
+// sessionUser returns the user of the session cookie of r and the session.
+// Roles and projects are read from the user table, so that changes apply
+// immediately.
+func (auth *Authentication) sessionUser(r *http.Request) (*schema.User, *repository.Session, error) {
 	session, err := auth.sessionStore.Get(r, "session")
 	if err != nil {
 		log.Error("Error while getting session store")
-		return nil, err
+		return nil, nil, err
 	}
 
 	if session.IsNew {
-		return nil, nil
+		return nil, nil, nil
+	}
+
+	// Cookies without token were issued before sessions were stored
+	token, _ := session.Values["token"].(string)
+	if token == "" {
+		return nil, nil, nil
+	}
+	s, err := auth.Sessions.Lookup(r.Context(), token, auth.SessionIdleTimeout)
+	if err != nil {
+		if err == ErrSessionEnded {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	// Users not stored keep the roles and projects they logged in with
+	user, err := repository.GetUserRepository().GetUser(s.Username)
+	if err == sql.ErrNoRows {
+		user = &schema.User{Username: s.Username, Roles: s.Roles, Projects: s.Projects}
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if user.Disabled {
+		log.Warnf("ending session of disabled user '%s'", user.Username)
+		if err := repository.DeleteSession(r.Context(), auth.Sessions.db, s.ID); err != nil && err != sql.ErrNoRows {
+			log.Warnf("ending session failed: %v", err)
+		}
+		return nil, nil, nil
 	}
 
-	// TODO: Check if session keys exist
-	username, _ := session.Values["username"].(string)
-	projects, _ := session.Values["projects"].([]string)
-	roles, _ := session.Values["roles"].([]string)
 	return &schema.User{
-		Username:   username,
-		Projects:   projects,
-		Roles:      roles,
+		Username:   user.Username,
+		Name:       user.Name,
+		Email:      user.Email,
+		Projects:   user.Projects,
+		Roles:      user.Roles,
 		AuthType:   schema.AuthSession,
 		AuthSource: -1,
-	}, nil
+	}, s, nil
 }
 
 func Init() (*Authentication, error) {
@@ -119,6 +200,28 @@ func Init() (*Authentication, error) {
 		log.Info("Missing JWT configuration: No JWT token support!")
 	}
 
+	throttle, err := NewLoginThrottle(repository.GetConnection().DB.DB, repository.GetConnection().Driver, config.Keys.LoginLockout)
+	if err != nil {
+		log.Error("Error while initializing authentication -> login throttle init failed")
+		return nil, err
+	}
+	auth.LoginThrottle = throttle
+	auth.Sessions = NewSessionStore(repository.GetConnection().DB.DB)
+
+	totp, err := NewTOTP(repository.GetConnection().DB.DB, config.Keys.TOTP)
+	if err != nil {
+		log.Error("Error while initializing authentication -> TOTP init failed")
+		return nil, err
+	}
+	auth.TOTP = totp
+
+	policy, err := NewPasswordPolicy(repository.GetConnection().DB.DB, config.Keys.PasswordPolicy)
+	if err != nil {
+		log.Error("Error while initializing authentication -> password policy init failed")
+		return nil, err
+	}
+	auth.PasswordPolicy = policy
+
 	auth.LocalAuth = &LocalAuthenticator{}
 	if err := auth.LocalAuth.Init(); err != nil {
 		log.Error("Error while initializing authentication -> localAuth init failed")
@@ -129,6 +232,31 @@ func Init() (*Authentication, error) {
 	return auth, nil
 }
 
+// mapGroups derives roles and projects from the groups of a user. Users get
+// the user role if no group maps to a role.
+func mapGroups(groups []string, roleMapping, projectMapping map[string][]string) (roles, projects []string) {
+	roles, projects = make([]string, 0), make([]string, 0)
+	for _, group := range groups {
+		for _, role := range roleMapping[group] {
+			if !util.Contains(roles, role) {
+				roles = append(roles, role)
+			}
+		}
+		for _, project := range projectMapping[group] {
+			if !util.Contains(projects, project) {
+				projects = append(projects, project)
+			}
+		}
+	}
+	sort.Strings(roles)
+	sort.Strings(projects)
+
+	if len(roles) == 0 {
+		roles = append(roles, schema.GetRoleString(schema.RoleUser))
+	}
+	return roles, projects
+}
+
 func persistUser(user *schema.User) {
 	r := repository.GetUserRepository()
 	_, err := r.GetUser(user.Username)
@@ -150,12 +278,18 @@ func (auth *Authentication) SaveSession(rw http.ResponseWriter, r *http.Request,
 		return err
 	}
 
+	token, _, err := auth.Sessions.Create(r.Context(), user, util.ClientIP(r), r.UserAgent(),
+		auth.SessionMaxAge, auth.SessionIdleTimeout)
+	if err != nil {
+		log.Errorf("session creation failed: %s", err.Error())
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return err
+	}
+
 	if auth.SessionMaxAge != 0 {
 		session.Options.MaxAge = int(auth.SessionMaxAge.Seconds())
 	}
-	session.Values["username"] = user.Username
-	session.Values["projects"] = user.Projects
-	session.Values["roles"] = user.Roles
+	session.Values["token"] = token
 	if err := auth.sessionStore.Save(r, rw, session); err != nil {
 		log.Warnf("session save failed: %s", err.Error())
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -171,8 +305,53 @@ func (auth *Authentication) Login(
 ) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		username := r.FormValue("username")
+		pending := ""
+		if username == "" {
+			pending = auth.pendingLogin(r)
+			username = pending
+		}
+		ip := util.ClientIP(r)
+		if auth.LoginThrottle != nil {
+			wait, err := auth.LoginThrottle.Check(r.Context(), username, ip)
+			if err != nil {
+				log.Errorf("checking failed logins of user '%s' failed: %v", username, err)
+			} else if wait > 0 {
+				wait = wait.Round(time.Second) + time.Second
+				log.Warnf("login of user '%s' from %s throttled for %s", username, ip, wait)
+				rw.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())))
+				loginAttempts.WithLabelValues("throttled").Inc()
+				onfailure(rw, r, fmt.Errorf("%w, try again in %s", ErrLoginThrottled, wait))
+				return
+			}
+		}
+		failed := func(err error) {
+			loginAttempts.WithLabelValues("failure").Inc()
+			if auth.LoginThrottle != nil {
+				if err := auth.LoginThrottle.Failed(r.Context(), username, ip); err != nil {
+					log.Errorf("recording failed login of user '%s' failed: %v", username, err)
+				}
+			}
+			onfailure(rw, r, err)
+		}
+
 		var dbUser *schema.User
 
+		if pending != "" {
+			user, err := repository.GetUserRepository().GetUser(pending)
+			if err != nil {
+				log.Warnf("loading user '%s' of pending login failed: %v", pending, err)
+				failed(errors.New("login expired, please log in again"))
+				return
+			}
+			if user.Disabled {
+				loginAttempts.WithLabelValues("disabled").Inc()
+				onfailure(rw, r, ErrUserDisabled)
+				return
+			}
+			auth.loginSecondStep(rw, r, user, onsuccess, onfailure, failed)
+			return
+		}
+
 		if username != "" {
 			var err error
 			dbUser, err = repository.GetUserRepository().GetUser(username)
@@ -193,57 +372,263 @@ func (auth *Authentication) Login(
 			user, err := authenticator.Login(user, rw, r)
 			if err != nil {
 				log.Warnf("user login failed: %s", err.Error())
-				onfailure(rw, r, err)
+				failed(err)
 				return
 			}
-
-			if err := auth.SaveSession(rw, r, user); err != nil {
+			// Only tell who knows the password
+			if dbUser != nil && dbUser.Disabled {
+				log.Warnf("login of disabled user '%s' rejected", username)
+				loginAttempts.WithLabelValues("disabled").Inc()
+				onfailure(rw, r, ErrUserDisabled)
 				return
 			}
 
-			log.Infof("login successfull: user: %#v (roles: %v, projects: %v)", user.Username, user.Roles, user.Projects)
-			ctx := context.WithValue(r.Context(), repository.ContextUserKey, user)
-			onsuccess.ServeHTTP(rw, r.WithContext(ctx))
+			if auth.TOTP != nil {
+				switch authenticator.(type) {
+				case *LocalAuthenticator, *LdapAuthenticator:
+					auth.loginSecondStep(rw, r, user, onsuccess, onfailure, failed)
+					return
+				default:
+					if err := auth.TOTP.CheckExternalLogin(r.Context(), user); err != nil {
+						log.Warnf("login of user '%s' rejected: %v", user.Username, err)
+						failed(err)
+						return
+					}
+				}
+			}
+			auth.loginSucceeded(rw, r, user, nil, onsuccess)
 			return
 		}
 
 		log.Debugf("login failed: no authenticator applied")
-		onfailure(rw, r, errors.New("no authenticator applied"))
+		failed(errors.New("no authenticator applied"))
 	})
 }
 
+// loginSecondStep asks user for the second factor if enabled or required,
+// and checks it if given in the form field "otp". Until then, the login is
+// remembered in a separate cookie, so that only the code has to be posted.
+func (auth *Authentication) loginSecondStep(
+	rw http.ResponseWriter,
+	r *http.Request,
+	user *schema.User,
+	onsuccess http.Handler,
+	onfailure func(rw http.ResponseWriter, r *http.Request, loginErr error),
+	failed func(err error),
+) {
+	if !SupportsTOTP(user) {
+		failed(errors.New("second factor is only supported for local and LDAP accounts"))
+		return
+	}
+
+	ctx := r.Context()
+	tt, err := auth.TOTP.Get(ctx, auth.TOTP.db, user.Username)
+	if err != nil && err != sql.ErrNoRows {
+		log.Errorf("loading second factor of user '%s' failed: %v", user.Username, err)
+		onfailure(rw, r, err)
+		return
+	}
+	enabled := err == nil && tt.Enabled
+	if !enabled && !auth.TOTP.Required(user) {
+		auth.loginSucceeded(rw, r, user, nil, onsuccess)
+		return
+	}
+
+	code := r.FormValue("otp")
+	if code == "" {
+		if err := auth.savePendingLogin(rw, r, user.Username); err != nil {
+			onfailure(rw, r, err)
+			return
+		}
+		if enabled {
+			onfailure(rw, r, ErrTOTPRequired)
+			return
+		}
+		enrollment, err := auth.TOTP.pendingEnrollment(ctx, auth.TOTP.db, user.Username)
+		if err != nil {
+			onfailure(rw, r, err)
+			return
+		}
+		onfailure(rw, r, &TOTPEnrollmentError{Enrollment: enrollment})
+		return
+	}
+
+	if enabled {
+		if err := auth.TOTP.Verify(ctx, auth.TOTP.db, user.Username, code); err != nil {
+			log.Warnf("second factor of user '%s' rejected: %v", user.Username, err)
+			failed(err)
+			return
+		}
+		auth.loginSucceeded(rw, r, user, nil, onsuccess)
+		return
+	}
+
+	codes, err := auth.TOTP.Confirm(ctx, auth.TOTP.db, user.Username, code)
+	if err != nil {
+		log.Warnf("second factor enrollment of user '%s' rejected: %v", user.Username, err)
+		enrollment, eerr := auth.TOTP.pendingEnrollment(ctx, auth.TOTP.db, user.Username)
+		if eerr != nil {
+			onfailure(rw, r, eerr)
+			return
+		}
+		failed(&TOTPEnrollmentError{Enrollment: enrollment, Err: err})
+		return
+	}
+	auth.loginSucceeded(rw, r, user, codes, onsuccess)
+}
+
+func (auth *Authentication) loginSucceeded(
+	rw http.ResponseWriter,
+	r *http.Request,
+	user *schema.User,
+	recoveryCodes []string,
+	onsuccess http.Handler,
+) {
+	auth.clearPendingLogin(rw, r)
+	if err := auth.SaveSession(rw, r, user); err != nil {
+		return
+	}
+	loginAttempts.WithLabelValues("success").Inc()
+	if auth.LoginThrottle != nil {
+		if err := auth.LoginThrottle.Succeeded(r.Context(), user.Username); err != nil {
+			log.Errorf("resetting failed logins of user '%s' failed: %v", user.Username, err)
+		}
+	}
+
+	log.Infof("login successfull: user: %#v (roles: %v, projects: %v)", user.Username, user.Roles, user.Projects)
+	ctx := context.WithValue(r.Context(), repository.ContextUserKey, user)
+	if len(recoveryCodes) > 0 {
+		ctx = context.WithValue(ctx, recoveryCodesKey{}, recoveryCodes)
+	}
+	onsuccess.ServeHTTP(rw, r.WithContext(ctx))
+}
+
+func (auth *Authentication) savePendingLogin(rw http.ResponseWriter, r *http.Request, username string) error {
+	session, err := auth.sessionStore.New(r, pendingLoginName)
+	if err != nil && session == nil {
+		return err
+	}
+	session.Options.MaxAge = int(pendingLoginMaxAge.Seconds())
+	session.Values["username"] = username
+	session.Values["expires"] = time.Now().Add(pendingLoginMaxAge).Unix()
+	return auth.sessionStore.Save(r, rw, session)
+}
+
+// pendingLogin returns the user whose password was accepted but who still
+// has to give the second factor, or an empty string.
+func (auth *Authentication) pendingLogin(r *http.Request) string {
+	if auth.TOTP == nil {
+		return ""
+	}
+	session, err := auth.sessionStore.Get(r, pendingLoginName)
+	if err != nil || session.IsNew {
+		return ""
+	}
+	username, _ := session.Values["username"].(string)
+	expires, _ := session.Values["expires"].(int64)
+	if time.Now().Unix() > expires {
+		return ""
+	}
+	return username
+}
+
+func (auth *Authentication) clearPendingLogin(rw http.ResponseWriter, r *http.Request) {
+	if _, err := r.Cookie(pendingLoginName); err != nil {
+		return
+	}
+	session, err := auth.sessionStore.Get(r, pendingLoginName)
+	if session == nil {
+		log.Warnf("clearing pending login failed: %v", err)
+		return
+	}
+	session.Options.MaxAge = -1
+	if err := auth.sessionStore.Save(r, rw, session); err != nil {
+		log.Warnf("clearing pending login failed: %v", err)
+	}
+}
+
 func (auth *Authentication) Auth(
 	onsuccess http.Handler,
 	onfailure func(rw http.ResponseWriter, r *http.Request, authErr error),
 ) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		method := "jwt"
 		user, err := auth.JwtAuth.AuthViaJWT(rw, r)
 		if err != nil {
 			log.Infof("authentication failed: %s", err.Error())
+			authentications.WithLabelValues(method, "failure").Inc()
 			http.Error(rw, err.Error(), http.StatusUnauthorized)
 			return
 		}
 
+		ctx := r.Context()
 		if user == nil {
-			user, err = auth.AuthViaSession(rw, r)
+			var session *repository.Session
+			method = "session"
+			user, session, err = auth.sessionUser(r)
 			if err != nil {
 				log.Infof("authentication failed: %s", err.Error())
+				authentications.WithLabelValues(method, "failure").Inc()
 				http.Error(rw, err.Error(), http.StatusUnauthorized)
 				return
 			}
+			if session != nil {
+				ctx = context.WithValue(ctx, repository.ContextSessionKey, session.ID)
+			}
 		}
 
 		if user != nil {
-			ctx := context.WithValue(r.Context(), repository.ContextUserKey, user)
+			authentications.WithLabelValues(method, "success").Inc()
+			ctx = context.WithValue(ctx, repository.ContextUserKey, user)
 			onsuccess.ServeHTTP(rw, r.WithContext(ctx))
 			return
 		}
 
 		log.Debug("authentication failed")
+		authentications.WithLabelValues("none", "failure").Inc()
 		onfailure(rw, r, errors.New("unauthorized (please login first)"))
 	})
 }
 
+type upgradeHeaderKey struct{}
+
+// WebsocketUpgrade keeps the headers of the upgrade request of a websocket
+// connection, so that WebsocketUser can authenticate the connection by its
+// session cookie or JWT header for as long as it is open. Requests without
+// credentials are not rejected, the connection may still authenticate with
+// the init payload of the GraphQL transport.
+func (auth *Authentication) WebsocketUpgrade(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), upgradeHeaderKey{}, r.Header.Clone())
+		next.ServeHTTP(rw, r.WithContext(ctx))
+	})
+}
+
+// WebsocketUser returns the user of the credentials of the upgrade request
+// kept by WebsocketUpgrade. It returns nil if there were none or they are
+// not valid anymore, because the session ended, the token was revoked or
+// the user was disabled.
+func (auth *Authentication) WebsocketUser(ctx context.Context) (*schema.User, error) {
+	header, _ := ctx.Value(upgradeHeaderKey{}).(http.Header)
+	if header == nil {
+		return nil, nil
+	}
+	// A new request each time, the session store caches sessions per request
+	r := (&http.Request{Header: header}).WithContext(ctx)
+
+	if auth.JwtAuth != nil {
+		user, err := auth.JwtAuth.AuthViaJWT(nil, r)
+		if user != nil || err != nil {
+			return user, err
+		}
+	}
+	if auth.sessionStore == nil {
+		return nil, nil
+	}
+	user, _, err := auth.sessionUser(r)
+	return user, err
+}
+
 func (auth *Authentication) Logout(onsuccess http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		session, err := auth.sessionStore.Get(r, "session")
@@ -253,6 +638,11 @@ func (auth *Authentication) Logout(onsuccess http.Handler) http.Handler {
 		}
 
 		if !session.IsNew {
+			if token, _ := session.Values["token"].(string); token != "" {
+				if err := auth.Sessions.End(r.Context(), token); err != nil {
+					log.Warnf("ending session failed: %v", err)
+				}
+			}
 			session.Options.MaxAge = -1
 			if err := auth.sessionStore.Save(r, rw, session); err != nil {
 				http.Error(rw, err.Error(), http.StatusInternalServerError)
@@ -5,48 +5,230 @@
 package auth
 
 import (
+	"context"
 	"crypto/ed25519"
-	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
 	"github.com/Deepbinder-main/cc-backend/internal/repository"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	sq "github.com/Masterminds/squirrel"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/oklog/ulid/v2"
 )
 
 type JWTAuthenticator struct {
-	publicKey  ed25519.PublicKey
-	privateKey ed25519.PrivateKey
+	// Key set file (jwts.keysFile), empty if only the key pair from the
+	// environment is used
+	keysFile string
+	envKey   *JWTKey
+
+	keysLock    sync.RWMutex
+	keys        map[string]*JWTKey // Verification keys by ID
+	signingKey  *JWTKey
+	keysModTime time.Time // Modification time of keysFile when it was loaded
+	keysChecked time.Time
+
+	// Revocation state of tokens with an ID ('jti' claim), nil to accept all
+	denylist *repository.TokenDenylist
+}
+
+// How often the key set file is checked for changes, so that rotated keys
+// are picked up without a restart
+const keysReloadInterval = 10 * time.Second
+
+// TokenOptions describe a token issued by IssueToken.
+type TokenOptions struct {
+	// Name under which the token is listed, e.g. the host of an agent
+	Name   string
+	Scopes []string
+	// Machine the token is bound to, required for api users pushing data
+	// of a machine
+	Machine string
+	// Lifetime of the token, capped by the max-age config key. Zero means
+	// max-age (or no expiry if max-age is not set).
+	ValidFor time.Duration
+}
+
+// NewJWTAuthenticator returns an authenticator using the given keys. Token
+// IDs are checked against denylist unless it is nil.
+func NewJWTAuthenticator(
+	publicKey ed25519.PublicKey,
+	privateKey ed25519.PrivateKey,
+	denylist *repository.TokenDenylist,
+) *JWTAuthenticator {
+	key := newJWTKey(publicKey, privateKey, time.Now())
+	ja := &JWTAuthenticator{
+		envKey:   key,
+		keys:     map[string]*JWTKey{key.Kid: key},
+		denylist: denylist,
+	}
+	if privateKey != nil {
+		ja.signingKey = key
+	}
+	return ja
 }
 
 func (ja *JWTAuthenticator) Init() error {
-	pubKey, privKey := os.Getenv("JWT_PUBLIC_KEY"), os.Getenv("JWT_PRIVATE_KEY")
-	if pubKey == "" || privKey == "" {
+	if err := ja.initKeys(); err != nil {
+		return err
+	}
+
+	ja.denylist = repository.GetTokenDenylist()
+	return nil
+}
+
+func (ja *JWTAuthenticator) initKeys() error {
+	var err error
+	if ja.envKey, err = envJWTKey(); err != nil {
+		log.Warn(err.Error())
+		return err
+	}
+	if config.Keys.JwtConfig != nil {
+		ja.keysFile = config.Keys.JwtConfig.KeysFile
+	}
+	if err := ja.loadKeys(); err != nil {
+		return err
+	}
+	if ja.signingKey == nil {
 		log.Warn("environment variables 'JWT_PUBLIC_KEY' or 'JWT_PRIVATE_KEY' not set (token based authentication will not work)")
-	} else {
-		bytes, err := base64.StdEncoding.DecodeString(pubKey)
-		if err != nil {
-			log.Warn("Could not decode JWT public key")
-			return err
+	}
+	return nil
+}
+
+// loadKeys (re)reads the key set file. The key pair from the environment is
+// always accepted for verification and signs tokens if the key set has no
+// current key.
+func (ja *JWTAuthenticator) loadKeys() error {
+	ks := &JWTKeySet{}
+	var modTime time.Time
+	if ja.keysFile != "" {
+		if fi, err := os.Stat(ja.keysFile); err == nil {
+			modTime = fi.ModTime()
 		}
-		ja.publicKey = ed25519.PublicKey(bytes)
-		bytes, err = base64.StdEncoding.DecodeString(privKey)
-		if err != nil {
-			log.Warn("Could not decode JWT private key")
+		var err error
+		if ks, err = LoadJWTKeySet(ja.keysFile); err != nil {
 			return err
 		}
-		ja.privateKey = ed25519.PrivateKey(bytes)
 	}
 
+	keys := make(map[string]*JWTKey, len(ks.Keys)+1)
+	for _, k := range ks.Keys {
+		keys[k.Kid] = k
+	}
+	var signingKey *JWTKey
+	if ks.Current != "" {
+		signingKey = keys[ks.Current]
+	}
+	if ja.envKey != nil {
+		if _, ok := keys[ja.envKey.Kid]; !ok {
+			keys[ja.envKey.Kid] = ja.envKey
+		}
+		if signingKey == nil {
+			signingKey = ja.envKey
+		}
+	}
+
+	ja.keysLock.Lock()
+	defer ja.keysLock.Unlock()
+	ja.keys, ja.signingKey = keys, signingKey
+	ja.keysModTime, ja.keysChecked = modTime, time.Now()
 	return nil
 }
 
+// reloadKeys reloads the key set file if it was changed, e.g. by a key
+// rotation. On errors, the previous keys stay in use.
+func (ja *JWTAuthenticator) reloadKeys() {
+	if ja.keysFile == "" {
+		return
+	}
+
+	ja.keysLock.RLock()
+	due := time.Since(ja.keysChecked) >= keysReloadInterval
+	modTime := ja.keysModTime
+	ja.keysLock.RUnlock()
+	if !due {
+		return
+	}
+
+	fi, err := os.Stat(ja.keysFile)
+	if err == nil && fi.ModTime().Equal(modTime) {
+		ja.keysLock.Lock()
+		ja.keysChecked = time.Now()
+		ja.keysLock.Unlock()
+		return
+	}
+
+	if err := ja.loadKeys(); err != nil {
+		log.Errorf("reloading JWT keys failed: %v", err)
+		ja.keysLock.Lock()
+		ja.keysChecked = time.Now()
+		ja.keysLock.Unlock()
+	}
+}
+
+// verificationKey selects the key by the 'kid' header of t. Tokens issued
+// before keys had IDs are checked against all keys.
+func (ja *JWTAuthenticator) verificationKey(t *jwt.Token) (interface{}, error) {
+	if t.Method != jwt.SigningMethodEdDSA {
+		return nil, errors.New("only Ed25519/EdDSA supported")
+	}
+
+	ja.reloadKeys()
+	ja.keysLock.RLock()
+	defer ja.keysLock.RUnlock()
+
+	if kid, ok := t.Header["kid"].(string); ok {
+		key, ok := ja.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID '%s'", kid)
+		}
+		return key.public, nil
+	}
+
+	set := jwt.VerificationKeySet{}
+	for _, key := range ja.keys {
+		set.Keys = append(set.Keys, key.public)
+	}
+	return set, nil
+}
+
+// Keys returns the public keys that verify tokens, the signing key first.
+func (ja *JWTAuthenticator) Keys() []JWK {
+	ja.reloadKeys()
+	ja.keysLock.RLock()
+	defer ja.keysLock.RUnlock()
+
+	jwks := make([]JWK, 0, len(ja.keys))
+	if ja.signingKey != nil {
+		jwks = append(jwks, ja.signingKey.JWK())
+	}
+	for _, key := range ja.keys {
+		if key != ja.signingKey {
+			jwks = append(jwks, key.JWK())
+		}
+	}
+	return jwks
+}
+
+// ServeJWKS publishes the verification keys as JSON Web Key Set, so that
+// other services can verify tokens issued by cc-backend.
+func (ja *JWTAuthenticator) ServeJWKS(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(keysReloadInterval.Seconds())))
+	json.NewEncoder(rw).Encode(struct {
+		Keys []JWK `json:"keys"`
+	}{Keys: ja.Keys()})
+}
+
 func (ja *JWTAuthenticator) AuthViaJWT(
 	rw http.ResponseWriter,
 	r *http.Request,
@@ -62,13 +244,13 @@ func (ja *JWTAuthenticator) AuthViaJWT(
 		return nil, nil
 	}
 
-	token, err := jwt.Parse(rawtoken, func(t *jwt.Token) (interface{}, error) {
-		if t.Method != jwt.SigningMethodEdDSA {
-			return nil, errors.New("only Ed25519/EdDSA supported")
-		}
+	return ja.AuthViaToken(rawtoken)
+}
 
-		return ja.publicKey, nil
-	})
+// AuthViaToken verifies a JWT passed outside of the request headers, such
+// as in the init payload of a GraphQL websocket connection.
+func (ja *JWTAuthenticator) AuthViaToken(rawtoken string) (*schema.User, error) {
+	token, err := jwt.Parse(rawtoken, ja.verificationKey)
 	if err != nil {
 		log.Warn("Error while parsing JWT token")
 		return nil, err
@@ -82,6 +264,11 @@ func (ja *JWTAuthenticator) AuthViaJWT(
 	claims := token.Claims.(jwt.MapClaims)
 	sub, _ := claims["sub"].(string)
 
+	if err := ja.checkRevocation(claims); err != nil {
+		log.Warnf("rejected JWT of '%s': %s", sub, err.Error())
+		return nil, err
+	}
+
 	var roles []string
 
 	// Validate user + roles from JWT against database?
@@ -93,6 +280,9 @@ func (ja *JWTAuthenticator) AuthViaJWT(
 			log.Warn("Could not find user from JWT in internal database.")
 			return nil, errors.New("unknown user")
 		}
+		if user.Disabled {
+			return nil, ErrUserDisabled
+		}
 		// Take user roles from database instead of trusting the JWT
 		roles = user.Roles
 	} else {
@@ -106,33 +296,147 @@ func (ja *JWTAuthenticator) AuthViaJWT(
 		}
 	}
 
+	var scopes []string
+	if scope, ok := claims["scope"].(string); ok {
+		scopes = strings.Fields(scope)
+	}
+	machine, _ := claims["machine"].(string)
+
 	return &schema.User{
 		Username:   sub,
 		Roles:      roles,
+		Scopes:     scopes,
+		Machine:    machine,
 		AuthType:   schema.AuthToken,
 		AuthSource: -1,
 	}, nil
 }
 
-// Generate a new JWT that can be used for authentication
-func (ja *JWTAuthenticator) ProvideJWT(user *schema.User) (string, error) {
-	if ja.privateKey == nil {
-		return "", errors.New("environment variable 'JWT_PRIVATE_KEY' not set")
+// checkRevocation rejects tokens whose ID was revoked. Tokens without ID
+// were issued before tokens were stored and can only be rejected as a whole
+// with the requireTokenId config key.
+func (ja *JWTAuthenticator) checkRevocation(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		if config.Keys.JwtConfig != nil && config.Keys.JwtConfig.RequireTokenID {
+			return errors.New("token without ID is not accepted, please request a new token")
+		}
+		return nil
+	}
+
+	if ja.denylist == nil {
+		return nil
+	}
+
+	revoked, err := ja.denylist.IsRevoked(jti)
+	if err != nil {
+		log.Errorf("checking revocation of token %s failed: %v", jti, err)
+		return errors.New("token could not be validated")
+	}
+	if revoked {
+		return errors.New("token has been revoked")
+	}
+
+	ja.denylist.Touch(jti)
+	return nil
+}
+
+// Generate a new JWT that can be used for authentication. Without scopes,
+// the token may be used for everything the roles of the user allow.
+func (ja *JWTAuthenticator) ProvideJWT(user *schema.User, scopes ...string) (string, error) {
+	token, _, err := ja.IssueToken(context.Background(), repository.GetConnection().DB, user,
+		TokenOptions{Name: "default", Scopes: scopes})
+	return token, err
+}
+
+// ParseScopes splits a comma separated list of scopes.
+func ParseScopes(s string) []string {
+	scopes := make([]string, 0)
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// IssueToken signs a new JWT for user and stores its ID using runner, so
+// that it can be listed and revoked.
+func (ja *JWTAuthenticator) IssueToken(
+	ctx context.Context,
+	runner sq.BaseRunner,
+	user *schema.User,
+	opts TokenOptions,
+) (string, *repository.ApiToken, error) {
+	ja.reloadKeys()
+	ja.keysLock.RLock()
+	signingKey := ja.signingKey
+	ja.keysLock.RUnlock()
+	if signingKey == nil {
+		return "", nil, errors.New("environment variable 'JWT_PRIVATE_KEY' not set")
+	}
+	for _, scope := range opts.Scopes {
+		if !schema.IsValidScope(scope) {
+			return "", nil, fmt.Errorf("invalid scope '%s'", scope)
+		}
+	}
+
+	validFor := opts.ValidFor
+	if config.Keys.JwtConfig != nil && config.Keys.JwtConfig.MaxAge != "" {
+		maxAge, err := time.ParseDuration(config.Keys.JwtConfig.MaxAge)
+		if err != nil {
+			return "", nil, errors.New("cannot parse max-age config key")
+		}
+		if validFor <= 0 || validFor > maxAge {
+			validFor = maxAge
+		}
 	}
 
 	now := time.Now()
+	info := &repository.ApiToken{
+		ID:        ulid.Make().String(),
+		Username:  user.Username,
+		Name:      opts.Name,
+		Scopes:    opts.Scopes,
+		MachineID: opts.Machine,
+		CreatedAt: now.Unix(),
+	}
+
 	claims := jwt.MapClaims{
+		"jti":   info.ID,
 		"sub":   user.Username,
 		"roles": user.Roles,
 		"iat":   now.Unix(),
 	}
-	if config.Keys.JwtConfig.MaxAge != "" {
-		d, err := time.ParseDuration(config.Keys.JwtConfig.MaxAge)
-		if err != nil {
-			return "", errors.New("cannot parse max-age config key")
-		}
-		claims["exp"] = now.Add(d).Unix()
+	if len(opts.Scopes) > 0 {
+		claims["scope"] = strings.Join(opts.Scopes, " ")
+	}
+	if opts.Machine != "" {
+		claims["machine"] = opts.Machine
+	}
+	if validFor > 0 {
+		info.ExpiresAt = now.Add(validFor).Unix()
+		claims["exp"] = info.ExpiresAt
 	}
 
-	return jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(ja.privateKey)
+	t := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	t.Header["kid"] = signingKey.Kid
+	token, err := t.SignedString(signingKey.private)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := repository.CreateApiToken(ctx, runner, info); err != nil {
+		return "", nil, err
+	}
+
+	return token, info, nil
+}
+
+// Revoked makes this instance reject the token immediately. Call it after
+// repository.RevokeApiToken was committed.
+func (ja *JWTAuthenticator) Revoked(id string) {
+	if ja.denylist != nil {
+		ja.denylist.Revoked(id)
+	}
 }
@@ -0,0 +1,238 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// JWTKey is an Ed25519 key pair in a key set. Retired keys no longer sign
+// tokens but still verify the tokens they signed.
+type JWTKey struct {
+	Kid        string `json:"kid"`
+	PublicKey  string `json:"publicKey"`            // base64
+	PrivateKey string `json:"privateKey,omitempty"` // base64, empty for keys that only verify
+	CreatedAt  int64  `json:"createdAt"`
+	RetiredAt  int64  `json:"retiredAt,omitempty"`
+
+	public  ed25519.PublicKey
+	private ed25519.PrivateKey
+}
+
+// JWTKeySet is the content of the file configured as jwts.keysFile.
+type JWTKeySet struct {
+	// Kid of the key that signs new tokens
+	Current string    `json:"current"`
+	Keys    []*JWTKey `json:"keys"`
+}
+
+// JWK is the JSON Web Key (RFC 8037) representation of a public key.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+}
+
+// KeyID derives the ID of a public key as its JWK thumbprint (RFC 7638), so
+// that the same key always gets the same ID, also if it is configured by
+// environment variables.
+func KeyID(pub ed25519.PublicKey) string {
+	x := base64.RawURLEncoding.EncodeToString(pub)
+	sum := sha256.Sum256([]byte(`{"crv":"Ed25519","kty":"OKP","x":"` + x + `"}`))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func newJWTKey(pub ed25519.PublicKey, priv ed25519.PrivateKey, created time.Time) *JWTKey {
+	k := &JWTKey{
+		Kid:       KeyID(pub),
+		PublicKey: base64.StdEncoding.EncodeToString(pub),
+		CreatedAt: created.Unix(),
+		public:    pub,
+		private:   priv,
+	}
+	if priv != nil {
+		k.PrivateKey = base64.StdEncoding.EncodeToString(priv)
+	}
+	return k
+}
+
+func (k *JWTKey) decode() error {
+	pub, err := base64.StdEncoding.DecodeString(k.PublicKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key of key '%s'", k.Kid)
+	}
+	k.public = ed25519.PublicKey(pub)
+	if k.Kid != KeyID(k.public) {
+		return fmt.Errorf("key ID '%s' does not match its public key", k.Kid)
+	}
+
+	if k.PrivateKey != "" {
+		priv, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+		if err != nil || len(priv) != ed25519.PrivateKeySize {
+			return fmt.Errorf("invalid private key of key '%s'", k.Kid)
+		}
+		k.private = ed25519.PrivateKey(priv)
+	}
+	return nil
+}
+
+// JWK returns the public key as JSON Web Key.
+func (k *JWTKey) JWK() JWK {
+	return JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(k.public),
+		Kid: k.Kid,
+		Alg: "EdDSA",
+		Use: "sig",
+	}
+}
+
+// LoadJWTKeySet reads the key set at path. A missing file is an empty set.
+func LoadJWTKeySet(path string) (*JWTKeySet, error) {
+	ks := &JWTKeySet{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ks, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, ks); err != nil {
+		return nil, fmt.Errorf("parsing JWT key set '%s' failed: %w", path, err)
+	}
+
+	for _, k := range ks.Keys {
+		if err := k.decode(); err != nil {
+			return nil, err
+		}
+	}
+	if ks.Current != "" {
+		if k := ks.Key(ks.Current); k == nil || k.private == nil {
+			return nil, fmt.Errorf("current key '%s' of JWT key set has no private key", ks.Current)
+		}
+	}
+	return ks, nil
+}
+
+// Save writes the key set to path, replacing the file atomically.
+func (ks *JWTKeySet) Save(path string) error {
+	data, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".jwt-keys-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (ks *JWTKeySet) Key(kid string) *JWTKey {
+	for _, k := range ks.Keys {
+		if k.Kid == kid {
+			return k
+		}
+	}
+	return nil
+}
+
+// Add adds k if no key with the same ID is in the set yet.
+func (ks *JWTKeySet) Add(k *JWTKey) {
+	if ks.Key(k.Kid) == nil {
+		ks.Keys = append(ks.Keys, k)
+	}
+}
+
+// Rotate generates a new signing key and retires the current one. Keys
+// retired longer than keep ago are removed, as the tokens they signed have
+// expired. With keep zero, retired keys are kept.
+func (ks *JWTKeySet) Rotate(keep time.Duration) (*JWTKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	keys := make([]*JWTKey, 0, len(ks.Keys)+1)
+	for _, k := range ks.Keys {
+		if k.RetiredAt == 0 {
+			k.RetiredAt = now.Unix()
+		}
+		if keep > 0 && now.Sub(time.Unix(k.RetiredAt, 0)) > keep {
+			continue
+		}
+		keys = append(keys, k)
+	}
+
+	key := newJWTKey(pub, priv, now)
+	ks.Keys = append(keys, key)
+	ks.Current = key.Kid
+	return key, nil
+}
+
+// envJWTKey returns the key pair configured by the environment variables
+// 'JWT_PUBLIC_KEY' and 'JWT_PRIVATE_KEY', or nil if they are not set.
+func envJWTKey() (*JWTKey, error) {
+	pubKey, privKey := os.Getenv("JWT_PUBLIC_KEY"), os.Getenv("JWT_PRIVATE_KEY")
+	if pubKey == "" || privKey == "" {
+		return nil, nil
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(pubKey)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return nil, errors.New("could not decode JWT public key")
+	}
+	priv, err := base64.StdEncoding.DecodeString(privKey)
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		return nil, errors.New("could not decode JWT private key")
+	}
+	return newJWTKey(ed25519.PublicKey(pub), ed25519.PrivateKey(priv), time.Now()), nil
+}
+
+// RotateJWTKeys adds a new signing key to the key set at path. The key pair
+// from the environment is taken over on the first rotation, so that tokens
+// signed with it stay valid. Retired keys are removed once keep has passed.
+func RotateJWTKeys(path string, keep time.Duration) (*JWTKey, error) {
+	ks, err := LoadJWTKeySet(path)
+	if err != nil {
+		return nil, err
+	}
+
+	envKey, err := envJWTKey()
+	if err != nil {
+		return nil, err
+	}
+	if envKey != nil && len(ks.Keys) == 0 {
+		ks.Add(envKey)
+	}
+
+	key, err := ks.Rotate(keep)
+	if err != nil {
+		return nil, err
+	}
+	return key, ks.Save(path)
+}
@@ -0,0 +1,129 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/golang-jwt/jwt/v5"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestJWTKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbfilepath := filepath.Join(dir, "test.db")
+	if err := repository.MigrateDB("sqlite3", dbfilepath); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", dbfilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	keysFile := filepath.Join(dir, "jwt-keys.json")
+	jwtConfig := config.Keys.JwtConfig
+	config.Keys.JwtConfig = &schema.JWTAuthConfig{MaxAge: "1h", KeysFile: keysFile}
+	t.Cleanup(func() { config.Keys.JwtConfig = jwtConfig })
+
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	t.Setenv("JWT_PUBLIC_KEY", base64.StdEncoding.EncodeToString(pub))
+	t.Setenv("JWT_PRIVATE_KEY", base64.StdEncoding.EncodeToString(priv))
+
+	user := &schema.User{Username: "api", Roles: []string{"api"}}
+	authenticate := func(ja *JWTAuthenticator, token string) error {
+		req := httptest.NewRequest("GET", "/api/machines", nil)
+		req.Header.Set("X-Auth-Token", token)
+		_, err := ja.AuthViaJWT(httptest.NewRecorder(), req)
+		return err
+	}
+
+	before := &JWTAuthenticator{}
+	if err := before.initKeys(); err != nil {
+		t.Fatal(err)
+	}
+	oldToken, _, err := before.IssueToken(ctx, db, user, TokenOptions{Name: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tokens issued before keys had IDs
+	legacyToken, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.MapClaims{"sub": "api"}).SignedString(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := RotateJWTKeys(keysFile, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := &JWTAuthenticator{}
+	if err := after.initKeys(); err != nil {
+		t.Fatal(err)
+	}
+	newToken, _, err := after.IssueToken(ctx, db, user, TokenOptions{Name: "new"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if err != nil || parsed.Header["kid"] != key.Kid {
+		t.Errorf("new token not signed with rotated key: %v %v", parsed.Header, err)
+	}
+	for _, token := range []string{oldToken, legacyToken, newToken} {
+		if err := authenticate(after, token); err != nil {
+			t.Errorf("token rejected after rotation: %v", err)
+		}
+	}
+	// The running instance picks up the new key as well
+	before.keysChecked = time.Time{}
+	if err := authenticate(before, newToken); err != nil {
+		t.Errorf("token of rotated key rejected by running instance: %v", err)
+	}
+
+	rw := httptest.NewRecorder()
+	after.ServeJWKS(rw, httptest.NewRequest("GET", "/.well-known/jwks.json", nil))
+	var jwks struct {
+		Keys []JWK `json:"keys"`
+	}
+	if err := json.Unmarshal(rw.Body.Bytes(), &jwks); err != nil {
+		t.Fatal(err)
+	}
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != key.Kid || jwks.Keys[1].Kid != KeyID(pub) {
+		t.Errorf("unexpected JWKS: %s", rw.Body.String())
+	}
+	x, _ := base64.RawURLEncoding.DecodeString(jwks.Keys[1].X)
+	if !ed25519.PublicKey(x).Equal(pub) {
+		t.Errorf("JWKS does not contain the previous public key")
+	}
+
+	// Retired keys are removed once tokens signed by them have expired
+	ks, err := LoadJWTKeySet(keysFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ks.Key(KeyID(pub)).RetiredAt = time.Now().Add(-2 * time.Hour).Unix()
+	if err := ks.Save(keysFile); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RotateJWTKeys(keysFile, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if ks, err = LoadJWTKeySet(keysFile); err != nil || len(ks.Keys) != 2 || ks.Key(KeyID(pub)) != nil {
+		t.Errorf("expired key not removed: %v %v", ks, err)
+	}
+}
@@ -5,23 +5,46 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
 	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
 	"github.com/go-ldap/ldap/v3"
 )
 
+// defaultGroupFilter matches the common object classes of groups.
+const defaultGroupFilter = "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames)(objectClass=posixGroup))"
+
 type LdapAuthenticator struct {
 	syncPassword string
 	UserAttr     string
+	pool         *ldapPool
+
+	// Role and project mappings keyed by normalized group DN
+	roleMapping    map[string][]string
+	projectMapping map[string][]string
+}
+
+// LdapSyncReport lists the usernames changed by a sync.
+type LdapSyncReport struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Updated []string `json:"updated"`
+}
+
+func (r *LdapSyncReport) String() string {
+	return fmt.Sprintf("%d added %v, %d removed %v, %d updated %v",
+		len(r.Added), r.Added, len(r.Removed), r.Removed, len(r.Updated), r.Updated)
 }
 
 var _ Authenticator = (*LdapAuthenticator)(nil)
@@ -34,6 +57,28 @@ func (la *LdapAuthenticator) Init() error {
 
 	lc := config.Keys.LdapConfig
 
+	pool, err := newLdapPool(lc, la.syncPassword)
+	if err != nil {
+		return err
+	}
+	la.pool = pool
+
+	if lc.UserAttr != "" {
+		la.UserAttr = lc.UserAttr
+	} else {
+		la.UserAttr = "gecos"
+	}
+
+	for group, roles := range lc.RoleMapping {
+		for _, role := range roles {
+			if !schema.IsValidRole(role) {
+				return fmt.Errorf("invalid role '%s' mapped to LDAP group '%s'", role, group)
+			}
+		}
+	}
+	la.roleMapping = normalizeMapping(lc.RoleMapping)
+	la.projectMapping = normalizeMapping(lc.ProjectMapping)
+
 	if lc.SyncInterval != "" {
 		interval, err := time.ParseDuration(lc.SyncInterval)
 		if err != nil {
@@ -51,23 +96,150 @@ func (la *LdapAuthenticator) Init() error {
 			ticker := time.NewTicker(interval)
 			for t := range ticker.C {
 				log.Printf("sync started at %s", t.Format(time.RFC3339))
-				if err := la.Sync(); err != nil {
+				report, err := la.Sync()
+				if err != nil {
 					log.Errorf("sync failed: %s", err.Error())
+					continue
 				}
-				log.Print("sync done")
+				log.Printf("sync done: %s", report)
 			}
 		}()
 	} else {
 		log.Info("LDAP configuration key sync_interval invalid")
 	}
 
-	if lc.UserAttr != "" {
-		la.UserAttr = lc.UserAttr
-	} else {
-		la.UserAttr = "gecos"
+	return nil
+}
+
+// hasGroupMapping reports whether roles and projects of users are derived
+// from their LDAP groups. Otherwise they are managed in cc-backend only.
+func (la *LdapAuthenticator) hasGroupMapping() bool {
+	return len(la.roleMapping) > 0 || len(la.projectMapping) > 0
+}
+
+// normalizeDN brings a DN into a canonical form so that DNs differing only
+// in case or spacing compare equal.
+func normalizeDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	return strings.ToLower(parsed.String())
+}
+
+func normalizeMapping(mapping map[string][]string) map[string][]string {
+	normalized := make(map[string][]string, len(mapping))
+	for dn, values := range mapping {
+		key := normalizeDN(dn)
+		normalized[key] = append(normalized[key], values...)
 	}
+	return normalized
+}
 
-	return nil
+// ldapGroups indexes the groups below the configured group bases by their
+// members.
+type ldapGroups struct {
+	byDN  map[string][]string // normalized member DN -> group DNs
+	byUid map[string][]string // memberUid of posix groups -> group DNs
+}
+
+// searchGroups reads the groups below the group bases matching filter, in
+// addition to the configured group filter.
+func (la *LdapAuthenticator) searchGroups(l *ldap.Conn, filter string) (*ldapGroups, error) {
+	lc := config.Keys.LdapConfig
+	groups := &ldapGroups{byDN: map[string][]string{}, byUid: map[string][]string{}}
+
+	groupFilter := lc.GroupFilter
+	if groupFilter == "" {
+		groupFilter = defaultGroupFilter
+	}
+	for _, base := range lc.GroupBases {
+		sr, err := l.Search(ldap.NewSearchRequest(
+			base,
+			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf("(&%s%s)", groupFilter, filter),
+			[]string{"dn", "member", "uniqueMember", "memberUid"}, nil))
+		if err != nil {
+			return nil, fmt.Errorf("LDAP group search in '%s' failed: %w", base, err)
+		}
+
+		for _, entry := range sr.Entries {
+			group := normalizeDN(entry.DN)
+			for _, member := range append(entry.GetAttributeValues("member"), entry.GetAttributeValues("uniqueMember")...) {
+				member = normalizeDN(member)
+				groups.byDN[member] = append(groups.byDN[member], group)
+			}
+			for _, uid := range entry.GetAttributeValues("memberUid") {
+				groups.byUid[uid] = append(groups.byUid[uid], group)
+			}
+		}
+	}
+	return groups, nil
+}
+
+// userGroups searches the groups the user of entry is a member of.
+func (la *LdapAuthenticator) userGroups(l *ldap.Conn, entry *ldap.Entry) (*ldapGroups, error) {
+	dn, uid := ldap.EscapeFilter(entry.DN), ldap.EscapeFilter(entry.GetAttributeValue("uid"))
+	return la.searchGroups(l, fmt.Sprintf("(|(member=%s)(uniqueMember=%s)(memberUid=%s))", dn, dn, uid))
+}
+
+// rolesAndProjects maps the groups of the user of entry, both from its
+// memberOf attribute and from the group entries, to roles and projects.
+func (la *LdapAuthenticator) rolesAndProjects(entry *ldap.Entry, groups *ldapGroups) (roles, projects []string) {
+	dns := make([]string, 0)
+	add := func(group string) {
+		if !util.Contains(dns, group) {
+			dns = append(dns, group)
+		}
+	}
+	for _, group := range entry.GetAttributeValues("memberOf") {
+		add(normalizeDN(group))
+	}
+	if groups != nil {
+		for _, group := range groups.byDN[normalizeDN(entry.DN)] {
+			add(group)
+		}
+		for _, group := range groups.byUid[entry.GetAttributeValue("uid")] {
+			add(group)
+		}
+	}
+	return mapGroups(dns, la.roleMapping, la.projectMapping)
+}
+
+// userAttributes are the attributes read from user entries.
+func (la *LdapAuthenticator) userAttributes() []string {
+	return []string{"dn", "uid", la.UserAttr, "memberOf"}
+}
+
+// searchUser returns the entry of username.
+func (la *LdapAuthenticator) searchUser(l *ldap.Conn, username string) (*ldap.Entry, error) {
+	lc := config.Keys.LdapConfig
+	sr, err := l.Search(ldap.NewSearchRequest(
+		lc.UserBase,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&%s(uid=%s))", lc.UserFilter, ldap.EscapeFilter(username)),
+		la.userAttributes(), nil))
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.Entries) != 1 {
+		return nil, errors.New("user does not exist or too many entries returned")
+	}
+	return sr.Entries[0], nil
+}
+
+// mapUser derives the roles and projects of the user of entry if a group
+// mapping is configured. Otherwise new users only get the user role.
+func (la *LdapAuthenticator) mapUser(l *ldap.Conn, entry *ldap.Entry) (roles, projects []string, err error) {
+	if !la.hasGroupMapping() {
+		return []string{schema.GetRoleString(schema.RoleUser)}, make([]string, 0), nil
+	}
+	groups, err := la.userGroups(l, entry)
+	if err != nil {
+		return nil, nil, err
+	}
+	roles, projects = la.rolesAndProjects(entry, groups)
+	return roles, projects, nil
 }
 
 func (la *LdapAuthenticator) CanLogin(
@@ -84,36 +256,26 @@ func (la *LdapAuthenticator) CanLogin(
 		}
 	} else {
 		if lc.SyncUserOnLogin {
-			l, err := la.getLdapConnection(true)
+			l, err := la.pool.get(true)
 			if err != nil {
-				log.Error("LDAP connection error")
+				log.Errorf("LDAP connection error: %v", err)
+				return nil, false
 			}
-			defer l.Close()
+			defer la.pool.put(l)
 
-			// Search for the given username
-			searchRequest := ldap.NewSearchRequest(
-				lc.UserBase,
-				ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-				fmt.Sprintf("(&%s(uid=%s))", lc.UserFilter, username),
-				[]string{"dn", "uid", la.UserAttr}, nil)
-
-			sr, err := l.Search(searchRequest)
+			entry, err := la.searchUser(l.Conn, username)
 			if err != nil {
-				log.Warn(err)
+				log.Warnf("LDAP: %v", err)
 				return nil, false
 			}
 
-			if len(sr.Entries) != 1 {
-				log.Warn("LDAP: User does not exist or too many entries returned")
+			name := entry.GetAttributeValue(la.UserAttr)
+			roles, projects, err := la.mapUser(l.Conn, entry)
+			if err != nil {
+				log.Warnf("LDAP: mapping groups of user '%s' failed: %v", username, err)
 				return nil, false
 			}
 
-			entry := sr.Entries[0]
-			name := entry.GetAttributeValue(la.UserAttr)
-			var roles []string
-			roles = append(roles, schema.GetRoleString(schema.RoleUser))
-			projects := make([]string, 0)
-
 			user = &schema.User{
 				Username:   username,
 				Name:       name,
@@ -140,12 +302,12 @@ func (la *LdapAuthenticator) Login(
 	rw http.ResponseWriter,
 	r *http.Request,
 ) (*schema.User, error) {
-	l, err := la.getLdapConnection(false)
+	l, err := la.pool.get(false)
 	if err != nil {
 		log.Warn("Error while getting ldap connection")
 		return nil, err
 	}
-	defer l.Close()
+	defer la.pool.put(l)
 
 	userDn := strings.Replace(config.Keys.LdapConfig.UserBind, "{username}", user.Username, -1)
 	if err := l.Bind(userDn, r.FormValue("password")); err != nil {
@@ -154,54 +316,101 @@ func (la *LdapAuthenticator) Login(
 		return nil, fmt.Errorf("Authentication failed")
 	}
 
+	if la.hasGroupMapping() {
+		la.updateUser(r.Context(), user)
+	}
+
 	return user, nil
 }
 
-func (la *LdapAuthenticator) Sync() error {
+// updateUser applies the group mapping to a user logging in. Failures are
+// logged only, the user keeps the roles and projects from the last sync.
+func (la *LdapAuthenticator) updateUser(ctx context.Context, user *schema.User) {
+	l, err := la.pool.get(true)
+	if err != nil {
+		log.Warnf("LDAP: cannot update roles of user '%s': %v", user.Username, err)
+		return
+	}
+	defer la.pool.put(l)
+
+	entry, err := la.searchUser(l.Conn, user.Username)
+	if err != nil {
+		log.Warnf("LDAP: cannot update roles of user '%s': %v", user.Username, err)
+		return
+	}
+	roles, projects, err := la.mapUser(l.Conn, entry)
+	if err != nil {
+		log.Warnf("LDAP: cannot update roles of user '%s': %v", user.Username, err)
+		return
+	}
+
+	changed, err := repository.GetUserRepository().SetRolesAndProjects(ctx, user.Username, roles, projects)
+	if err != nil {
+		log.Errorf("LDAP: updating roles of user '%s' failed: %v", user.Username, err)
+		return
+	}
+	if changed {
+		log.Infof("LDAP: user '%s' now has roles %v and projects %v", user.Username, roles, projects)
+	}
+	user.Roles, user.Projects = roles, projects
+}
+
+// Sync adds the users of the LDAP directory to the database and, if
+// configured, removes the users no longer in the directory. With a group
+// mapping, the roles and projects of all LDAP users are updated as well.
+func (la *LdapAuthenticator) Sync() (*LdapSyncReport, error) {
 	const IN_DB int = 1
 	const IN_LDAP int = 2
 	const IN_BOTH int = 3
 	ur := repository.GetUserRepository()
 	lc := config.Keys.LdapConfig
+	report := &LdapSyncReport{Added: []string{}, Removed: []string{}, Updated: []string{}}
 
 	users := map[string]int{}
 	usernames, err := ur.GetLdapUsernames()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	for _, username := range usernames {
 		users[username] = IN_DB
 	}
 
-	l, err := la.getLdapConnection(true)
+	l, err := la.pool.get(true)
 	if err != nil {
 		log.Error("LDAP connection error")
-		return err
+		return nil, err
 	}
-	defer l.Close()
+	defer la.pool.put(l)
 
 	ldapResults, err := l.Search(ldap.NewSearchRequest(
 		lc.UserBase,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
 		lc.UserFilter,
-		[]string{"dn", "uid", la.UserAttr}, nil))
+		la.userAttributes(), nil))
 	if err != nil {
 		log.Warn("LDAP search error")
-		return err
+		return nil, err
 	}
 
-	newnames := map[string]string{}
+	var groups *ldapGroups
+	if la.hasGroupMapping() {
+		if groups, err = la.searchGroups(l.Conn, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	entries := map[string]*ldap.Entry{}
 	for _, entry := range ldapResults.Entries {
 		username := entry.GetAttributeValue("uid")
 		if username == "" {
-			return errors.New("no attribute 'uid'")
+			return nil, errors.New("no attribute 'uid'")
 		}
 
+		entries[username] = entry
 		_, ok := users[username]
 		if !ok {
 			users[username] = IN_LDAP
-			newnames[username] = entry.GetAttributeValue(la.UserAttr)
 		} else {
 			users[username] = IN_BOTH
 		}
@@ -209,14 +418,21 @@ func (la *LdapAuthenticator) Sync() error {
 
 	for username, where := range users {
 		if where == IN_DB && lc.SyncDelOldUsers {
-			ur.DelUser(username)
+			if err := ur.DelUser(username); err != nil {
+				log.Errorf("sync: removing %v failed: %v", username, err)
+				continue
+			}
 			log.Debugf("sync: remove %v (does not show up in LDAP anymore)", username)
+			report.Removed = append(report.Removed, username)
 		} else if where == IN_LDAP {
-			name := newnames[username]
+			entry := entries[username]
+			name := entry.GetAttributeValue(la.UserAttr)
 
-			var roles []string
-			roles = append(roles, schema.GetRoleString(schema.RoleUser))
+			roles := []string{schema.GetRoleString(schema.RoleUser)}
 			projects := make([]string, 0)
+			if groups != nil {
+				roles, projects = la.rolesAndProjects(entry, groups)
+			}
 
 			user := &schema.User{
 				Username:   username,
@@ -226,32 +442,28 @@ func (la *LdapAuthenticator) Sync() error {
 				AuthSource: schema.AuthViaLDAP,
 			}
 
-			log.Debugf("sync: add %v (name: %v, roles: [user], ldap: true)", username, name)
+			log.Debugf("sync: add %v (name: %v, roles: %v, projects: %v, ldap: true)", username, name, roles, projects)
 			if err := ur.AddUser(user); err != nil {
 				log.Errorf("User '%s' LDAP: Insert into DB failed", username)
-				return err
+				return nil, err
+			}
+			report.Added = append(report.Added, username)
+		} else if where == IN_BOTH && groups != nil {
+			roles, projects := la.rolesAndProjects(entries[username], groups)
+			changed, err := ur.SetRolesAndProjects(context.Background(), username, roles, projects)
+			if err != nil {
+				log.Errorf("User '%s' LDAP: Update of roles failed", username)
+				return nil, err
+			}
+			if changed {
+				log.Debugf("sync: update %v (roles: %v, projects: %v)", username, roles, projects)
+				report.Updated = append(report.Updated, username)
 			}
 		}
 	}
 
-	return nil
-}
-
-func (la *LdapAuthenticator) getLdapConnection(admin bool) (*ldap.Conn, error) {
-	lc := config.Keys.LdapConfig
-	conn, err := ldap.DialURL(lc.Url)
-	if err != nil {
-		log.Warn("LDAP URL dial failed")
-		return nil, err
-	}
-
-	if admin {
-		if err := conn.Bind(lc.SearchDN, la.syncPassword); err != nil {
-			conn.Close()
-			log.Warn("LDAP connection bind failed")
-			return nil, err
-		}
-	}
-
-	return conn, nil
+	sort.Strings(report.Added)
+	sort.Strings(report.Removed)
+	sort.Strings(report.Updated)
+	return report, nil
 }
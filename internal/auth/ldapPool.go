@@ -0,0 +1,259 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapDefaultDialTimeout    = 5 * time.Second
+	ldapDefaultRequestTimeout = 10 * time.Second
+	ldapDefaultIdleTimeout    = 5 * time.Minute
+	ldapDefaultPoolSize       = 4
+
+	// Idle connections are checked before reuse after this time
+	ldapHealthCheckInterval = 30 * time.Second
+)
+
+// ldapConn is a connection of the pool.
+type ldapConn struct {
+	*ldap.Conn
+
+	admin bool      // bound as search DN
+	used  time.Time // when it was put back into the pool
+}
+
+// Bind authenticates the connection as dn.
+func (c *ldapConn) Bind(dn, password string) error {
+	c.admin = false
+	return c.Conn.Bind(dn, password)
+}
+
+// healthy reads the root DSE to check whether the server still answers.
+func (c *ldapConn) healthy() bool {
+	_, err := c.Search(ldap.NewSearchRequest(
+		"", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)", []string{"1.1"}, nil))
+	return err == nil
+}
+
+// ldapPool keeps idle connections to the LDAP servers for reuse. New
+// connections are dialed to the configured servers in turn, starting with
+// the one reached last.
+type ldapPool struct {
+	urls           []string
+	startTLS       bool
+	tlsConfig      *tls.Config
+	dialTimeout    time.Duration
+	requestTimeout time.Duration
+	idleTimeout    time.Duration
+	size           int
+
+	searchDN       string
+	searchPassword string
+
+	mu      sync.Mutex
+	idle    []*ldapConn
+	current int
+}
+
+func parseDuration(value string, def time.Duration) (time.Duration, error) {
+	if value == "" {
+		return def, nil
+	}
+	return time.ParseDuration(value)
+}
+
+func newLdapPool(lc *schema.LdapConfig, searchPassword string) (*ldapPool, error) {
+	if len(lc.Url) == 0 {
+		return nil, errors.New("no LDAP server configured")
+	}
+
+	p := &ldapPool{
+		urls:           lc.Url,
+		startTLS:       lc.StartTLS,
+		size:           lc.PoolSize,
+		searchDN:       lc.SearchDN,
+		searchPassword: searchPassword,
+		tlsConfig:      &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	if p.size == 0 {
+		p.size = ldapDefaultPoolSize
+	}
+
+	var err error
+	if p.dialTimeout, err = parseDuration(lc.DialTimeout, ldapDefaultDialTimeout); err != nil {
+		return nil, fmt.Errorf("invalid LDAP dial_timeout: %w", err)
+	}
+	if p.requestTimeout, err = parseDuration(lc.RequestTimeout, ldapDefaultRequestTimeout); err != nil {
+		return nil, fmt.Errorf("invalid LDAP request_timeout: %w", err)
+	}
+	if p.idleTimeout, err = parseDuration(lc.IdleTimeout, ldapDefaultIdleTimeout); err != nil {
+		return nil, fmt.Errorf("invalid LDAP idle_timeout: %w", err)
+	}
+
+	if lc.CaFile != "" {
+		pem, err := os.ReadFile(lc.CaFile)
+		if err != nil {
+			return nil, err
+		}
+		p.tlsConfig.RootCAs = x509.NewCertPool()
+		if !p.tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in LDAP ca_file '%s'", lc.CaFile)
+		}
+	}
+
+	for _, u := range p.urls {
+		if _, err := url.Parse(u); err != nil {
+			return nil, fmt.Errorf("invalid LDAP url '%s': %w", u, err)
+		}
+	}
+	return p, nil
+}
+
+// get returns a connection from the pool or dials a new one. Admin
+// connections are bound as search DN.
+func (p *ldapPool) get(admin bool) (*ldapConn, error) {
+	for c := p.takeIdle(); c != nil; c = p.takeIdle() {
+		if time.Since(c.used) > ldapHealthCheckInterval && !c.healthy() {
+			c.Close()
+			continue
+		}
+		if admin && !c.admin {
+			if err := p.bindAdmin(c); err != nil {
+				c.Close()
+				if ldap.IsErrorWithCode(err, ldap.ErrorNetwork) {
+					continue
+				}
+				return nil, err
+			}
+		}
+		return c, nil
+	}
+
+	c, err := p.dial()
+	if err != nil {
+		return nil, err
+	}
+	if admin {
+		if err := p.bindAdmin(c); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// put returns a connection to the pool, or closes it if it broke or the
+// pool is full.
+func (p *ldapPool) put(c *ldapConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c.IsClosing() || len(p.idle) >= p.size {
+		c.Close()
+		return
+	}
+	c.used = time.Now()
+	p.idle = append(p.idle, c)
+}
+
+// takeIdle removes the most recently used connection from the pool.
+// Connections idle for longer than the idle timeout are closed.
+func (p *ldapPool) takeIdle() *ldapConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	idle := p.idle[:0]
+	for _, c := range p.idle {
+		if c.IsClosing() || time.Since(c.used) > p.idleTimeout {
+			c.Close()
+			continue
+		}
+		idle = append(idle, c)
+	}
+	p.idle = idle
+
+	if len(p.idle) == 0 {
+		return nil
+	}
+	c := p.idle[len(p.idle)-1]
+	p.idle = p.idle[:len(p.idle)-1]
+	return c
+}
+
+func (p *ldapPool) bindAdmin(c *ldapConn) error {
+	if err := c.Bind(p.searchDN, p.searchPassword); err != nil {
+		log.Warn("LDAP connection bind failed")
+		return err
+	}
+	c.admin = true
+	return nil
+}
+
+// dial connects to the first server reachable, trying the one connected
+// last first.
+func (p *ldapPool) dial() (*ldapConn, error) {
+	p.mu.Lock()
+	start := p.current
+	p.mu.Unlock()
+
+	errs := make([]error, 0, len(p.urls))
+	for i := range p.urls {
+		n := (start + i) % len(p.urls)
+		conn, err := p.dialURL(p.urls[n])
+		if err != nil {
+			log.Warnf("LDAP: connecting to %s failed: %v", p.urls[n], err)
+			errs = append(errs, err)
+			continue
+		}
+
+		p.mu.Lock()
+		if p.current != n {
+			log.Infof("LDAP: switched to server %s", p.urls[n])
+			p.current = n
+		}
+		p.mu.Unlock()
+		return &ldapConn{Conn: conn}, nil
+	}
+	return nil, fmt.Errorf("no LDAP server reachable: %w", errors.Join(errs...))
+}
+
+func (p *ldapPool) dialURL(addr string) (*ldap.Conn, error) {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := p.tlsConfig.Clone()
+	tlsConfig.ServerName = u.Hostname()
+
+	conn, err := ldap.DialURL(addr,
+		ldap.DialWithDialer(&net.Dialer{Timeout: p.dialTimeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(p.requestTimeout)
+
+	if p.startTLS && u.Scheme == "ldap" {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS failed: %w", err)
+		}
+	}
+	return conn, nil
+}
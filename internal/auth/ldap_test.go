@@ -0,0 +1,581 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+// mockLdapEntry is an entry of the directory served by mockLdapServer.
+// Binds succeed with the userPassword attribute.
+type mockLdapEntry struct {
+	dn    string
+	attrs map[string][]string
+}
+
+// mockLdapServer is a minimal LDAP server supporting simple binds, StartTLS
+// and searches with and, or, not, equality and presence filters.
+type mockLdapServer struct {
+	listener net.Listener
+	scheme   string
+	// Enables StartTLS if set
+	tlsConfig *tls.Config
+
+	accepted atomic.Int32
+	upgraded atomic.Int32
+
+	mu      sync.Mutex
+	entries []*mockLdapEntry
+	conns   []net.Conn
+}
+
+// newMockLdapServer starts a server, supporting StartTLS if tlsConfig is set.
+func newMockLdapServer(t *testing.T, entries []*mockLdapEntry, tlsConfig *tls.Config) *mockLdapServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startMockLdapServer(t, &mockLdapServer{listener: l, scheme: "ldap", tlsConfig: tlsConfig, entries: entries})
+}
+
+// newMockLdapsServer starts a server accepting TLS connections only.
+func newMockLdapsServer(t *testing.T, entries []*mockLdapEntry, tlsConfig *tls.Config) *mockLdapServer {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return startMockLdapServer(t, &mockLdapServer{listener: l, scheme: "ldaps", entries: entries})
+}
+
+func startMockLdapServer(t *testing.T, s *mockLdapServer) *mockLdapServer {
+	t.Cleanup(func() { s.listener.Close() })
+	go func() {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				return
+			}
+			s.accepted.Add(1)
+			s.mu.Lock()
+			s.conns = append(s.conns, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *mockLdapServer) URL() string {
+	return s.scheme + "://" + s.listener.Addr().String()
+}
+
+func (s *mockLdapServer) setEntries(entries []*mockLdapEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = entries
+}
+
+// dropConnections closes all open connections, as a restarting server does.
+func (s *mockLdapServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *mockLdapServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		startTLS := false
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			responses = append(responses, s.bind(op))
+		case ldap.ApplicationSearchRequest:
+			responses = s.search(op)
+		case ldap.ApplicationExtendedRequest:
+			code := ldap.LDAPResultProtocolError
+			if s.tlsConfig != nil && op.Children[0].Data.String() == "1.3.6.1.4.1.1466.20037" {
+				code, startTLS = ldap.LDAPResultSuccess, true
+			}
+			responses = append(responses, ldapResult(ldap.ApplicationExtendedResponse, code))
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			return
+		}
+
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "MessageID"))
+			envelope.AppendChild(response)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+
+		if startTLS {
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			s.upgraded.Add(1)
+			conn = tlsConn
+		}
+	}
+}
+
+func ldapResult(tag ber.Tag, code int) *ber.Packet {
+	p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	return p
+}
+
+func (s *mockLdapServer) entry(dn string) *mockLdapEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if normalizeDN(e.dn) == normalizeDN(dn) {
+			return e
+		}
+	}
+	return nil
+}
+
+func (s *mockLdapServer) bind(op *ber.Packet) *ber.Packet {
+	dn, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+	if e := s.entry(dn); e == nil || len(e.attrs["userPassword"]) == 0 || e.attrs["userPassword"][0] != password {
+		return ldapResult(ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+	}
+	return ldapResult(ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+}
+
+func (s *mockLdapServer) search(op *ber.Packet) []*ber.Packet {
+	base := normalizeDN(op.Children[0].Data.String())
+	filter := op.Children[6]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	responses := make([]*ber.Packet, 0)
+	for _, e := range s.entries {
+		dn := normalizeDN(e.dn)
+		if (dn != base && !strings.HasSuffix(dn, ","+base)) || !e.matches(filter) {
+			continue
+		}
+
+		p := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		p.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, "objectName"))
+		attrs := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+		for name, values := range e.attrs {
+			if name == "userPassword" {
+				continue
+			}
+			attr := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+			attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+			for _, v := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "value"))
+			}
+			attr.AppendChild(set)
+			attrs.AppendChild(attr)
+		}
+		p.AppendChild(attrs)
+		responses = append(responses, p)
+	}
+	return append(responses, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess))
+}
+
+func (e *mockLdapEntry) values(name string) []string {
+	for attr, values := range e.attrs {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+func (e *mockLdapEntry) matches(filter *ber.Packet) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !e.matches(child) {
+				return false
+			}
+		}
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if e.matches(child) {
+				return true
+			}
+		}
+		return false
+	case ldap.FilterNot:
+		return !e.matches(filter.Children[0])
+	case ldap.FilterPresent:
+		return len(e.values(filter.Data.String())) > 0
+	case ldap.FilterEqualityMatch:
+		name, value := filter.Children[0].Data.String(), filter.Children[1].Data.String()
+		for _, v := range e.values(name) {
+			if strings.EqualFold(v, value) || (strings.Contains(v, "=") && normalizeDN(v) == normalizeDN(value)) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+var testUserRepo struct {
+	once sync.Once
+	err  error
+}
+
+// setupUserRepository connects the repository singletons to a fresh
+// database, once for all tests of the package. Users added by a test are
+// removed after it.
+func setupUserRepository(t *testing.T) *repository.UserRepository {
+	testUserRepo.once.Do(func() {
+		dbfilepath := filepath.Join(os.TempDir(), fmt.Sprintf("cc-backend-auth-test-%d.db", os.Getpid()))
+		os.Remove(dbfilepath)
+		if testUserRepo.err = repository.MigrateDB("sqlite3", dbfilepath); testUserRepo.err == nil {
+			repository.Connect("sqlite3", dbfilepath)
+		}
+	})
+	if testUserRepo.err != nil {
+		t.Fatal(testUserRepo.err)
+	}
+
+	ur := repository.GetUserRepository()
+	t.Cleanup(func() {
+		users, _ := ur.ListUsers(false)
+		for _, user := range users {
+			ur.DelUser(user.Username)
+		}
+		repository.GetConnection().DB.Exec("DELETE FROM sessions")
+	})
+	return ur
+}
+
+const ldapTestBase = "dc=example,dc=com"
+
+func ldapTestEntries() []*mockLdapEntry {
+	person := func(uid string, memberOf ...string) *mockLdapEntry {
+		return &mockLdapEntry{
+			dn: "uid=" + uid + ",ou=people," + ldapTestBase,
+			attrs: map[string][]string{
+				"objectClass":  {"posixAccount"},
+				"uid":          {uid},
+				"gecos":        {strings.ToUpper(uid[:1]) + uid[1:]},
+				"userPassword": {uid + "-pw"},
+				"memberOf":     memberOf,
+			},
+		}
+	}
+	return []*mockLdapEntry{
+		{dn: "cn=admin," + ldapTestBase, attrs: map[string][]string{"userPassword": {"admin-pw"}}},
+		person("alice"),
+		person("bob"),
+		person("carol", "CN=Proj-B,ou=groups,"+ldapTestBase),
+		person("dave"),
+		{dn: "cn=managers,ou=groups," + ldapTestBase, attrs: map[string][]string{
+			"objectClass": {"groupOfNames"},
+			"member":      {"uid=alice,ou=people," + ldapTestBase, "uid=carol, ou=people," + ldapTestBase},
+		}},
+		{dn: "cn=admins,ou=groups," + ldapTestBase, attrs: map[string][]string{
+			"objectClass": {"posixGroup"},
+			"memberUid":   {"bob"},
+		}},
+		// Groups outside of the group bases are ignored
+		{dn: "cn=admins,ou=other," + ldapTestBase, attrs: map[string][]string{
+			"objectClass": {"posixGroup"},
+			"memberUid":   {"dave"},
+		}},
+	}
+}
+
+func TestLdapGroupMapping(t *testing.T) {
+	ur := setupUserRepository(t)
+	server := newMockLdapServer(t, ldapTestEntries(), nil)
+
+	ldapConfig := config.Keys.LdapConfig
+	config.Keys.LdapConfig = &schema.LdapConfig{
+		Url:             schema.LdapURLs{server.URL()},
+		UserBase:        "ou=people," + ldapTestBase,
+		SearchDN:        "cn=admin," + ldapTestBase,
+		UserBind:        "uid={username},ou=people," + ldapTestBase,
+		UserFilter:      "(objectClass=posixAccount)",
+		SyncDelOldUsers: true,
+		SyncUserOnLogin: true,
+		GroupBases:      []string{"ou=groups," + ldapTestBase},
+		RoleMapping: map[string][]string{
+			"cn=managers,ou=groups," + ldapTestBase:     {"manager"},
+			"CN=Admins, OU=groups," + ldapTestBase:      {"admin", "support"},
+			"cn=admins,ou=other," + ldapTestBase:        {"admin"},
+			"cn=not-existing,ou=groups," + ldapTestBase: {"manager"},
+		},
+		ProjectMapping: map[string][]string{
+			"cn=managers,ou=groups," + ldapTestBase: {"projA"},
+			"cn=proj-b,ou=groups," + ldapTestBase:   {"projB"},
+		},
+	}
+	t.Cleanup(func() { config.Keys.LdapConfig = ldapConfig })
+	t.Setenv("LDAP_ADMIN_PASSWORD", "admin-pw")
+
+	la := &LdapAuthenticator{}
+	if err := la.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	expectUser := func(username string, roles, projects []string) {
+		t.Helper()
+		user, err := ur.GetUser(username)
+		if err != nil {
+			t.Fatalf("user %s: %v", username, err)
+		}
+		if !reflect.DeepEqual(user.Roles, roles) || !reflect.DeepEqual(user.Projects, projects) {
+			t.Errorf("user %s: expected roles %v and projects %v, got %v and %v",
+				username, roles, projects, user.Roles, user.Projects)
+		}
+	}
+
+	// Users are created with mapped roles on login
+	user, ok := la.CanLogin(nil, "alice", httptest.NewRecorder(), httptest.NewRequest("POST", "/login", nil))
+	if !ok || !reflect.DeepEqual(user.Roles, []string{"manager"}) || !reflect.DeepEqual(user.Projects, []string{"projA"}) {
+		t.Fatalf("unexpected user on login: %v %v", user, ok)
+	}
+	expectUser("alice", []string{"manager"}, []string{"projA"})
+
+	report, err := la.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &LdapSyncReport{Added: []string{"bob", "carol", "dave"}, Removed: []string{}, Updated: []string{}}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected sync report: %s", report)
+	}
+	expectUser("bob", []string{"admin", "support"}, []string{})
+	expectUser("carol", []string{"manager"}, []string{"projA", "projB"})
+	expectUser("dave", []string{"user"}, []string{})
+
+	// Changed group memberships are applied by the next sync
+	entries := ldapTestEntries()
+	entries[5].attrs["member"] = []string{"uid=dave,ou=people," + ldapTestBase}
+	entries = append(entries[:2], entries[3:]...) // bob left
+	server.setEntries(entries)
+
+	if report, err = la.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	expected = &LdapSyncReport{Added: []string{}, Removed: []string{"bob"}, Updated: []string{"alice", "carol", "dave"}}
+	if !reflect.DeepEqual(report, expected) {
+		t.Errorf("unexpected sync report: %s", report)
+	}
+	expectUser("alice", []string{"user"}, []string{})
+	expectUser("carol", []string{"user"}, []string{"projB"})
+	expectUser("dave", []string{"manager"}, []string{"projA"})
+	if _, err := ur.GetUser("bob"); err == nil {
+		t.Errorf("user bob not removed")
+	}
+
+	// and on login
+	entries[4].attrs["member"] = []string{"uid=alice,ou=people," + ldapTestBase}
+	server.setEntries(entries)
+	alice, _ := ur.GetUser("alice")
+	form := url.Values{"password": {"alice-pw"}}
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if user, err = la.Login(alice, httptest.NewRecorder(), req); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(user.Roles, []string{"manager"}) || !reflect.DeepEqual(user.Projects, []string{"projA"}) {
+		t.Errorf("roles not updated on login: %v %v", user.Roles, user.Projects)
+	}
+	expectUser("alice", []string{"manager"}, []string{"projA"})
+
+	req = httptest.NewRequest("POST", "/login", strings.NewReader(url.Values{"password": {"wrong"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if _, err := la.Login(alice, httptest.NewRecorder(), req); err == nil {
+		t.Errorf("login with wrong password succeeded")
+	}
+
+	// The mappings also apply without periodic sync
+	config.Keys.LdapConfig.SyncInterval = "0"
+	la = &LdapAuthenticator{}
+	if err := la.Init(); err != nil || la.UserAttr != "gecos" || !la.hasGroupMapping() {
+		t.Errorf("mappings not initialized without sync: %v", err)
+	}
+
+	config.Keys.LdapConfig.RoleMapping["cn=x"] = []string{"superuser"}
+	if err := (&LdapAuthenticator{}).Init(); err == nil || !strings.Contains(err.Error(), "superuser") {
+		t.Errorf("expected error for invalid role, got %v", err)
+	}
+}
+
+// testCertificate creates a self-signed certificate for 127.0.0.1 and
+// writes it to a PEM file to be used as CA.
+func testCertificate(t *testing.T) (string, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldap.example.com"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile, &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+}
+
+// unusedLdapURL returns the URL of a server that is down.
+func unusedLdapURL(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	return "ldap://" + l.Addr().String()
+}
+
+func TestLdapConnectionPool(t *testing.T) {
+	setupUserRepository(t)
+	caFile, serverTLS := testCertificate(t)
+	server := newMockLdapServer(t, ldapTestEntries(), serverTLS)
+
+	ldapConfig := config.Keys.LdapConfig
+	t.Cleanup(func() { config.Keys.LdapConfig = ldapConfig })
+	t.Setenv("LDAP_ADMIN_PASSWORD", "admin-pw")
+	newAuthenticator := func(lc schema.LdapConfig) *LdapAuthenticator {
+		t.Helper()
+		lc.UserBase = "ou=people," + ldapTestBase
+		lc.SearchDN = "cn=admin," + ldapTestBase
+		lc.UserBind = "uid={username},ou=people," + ldapTestBase
+		lc.UserFilter = "(objectClass=posixAccount)"
+		config.Keys.LdapConfig = &lc
+		la := &LdapAuthenticator{}
+		if err := la.Init(); err != nil {
+			t.Fatal(err)
+		}
+		return la
+	}
+	login := func(la *LdapAuthenticator, username, password string) error {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		_, err := la.Login(&schema.User{Username: username}, httptest.NewRecorder(), req)
+		return err
+	}
+
+	// The first server is down, StartTLS is verified with the custom CA
+	la := newAuthenticator(schema.LdapConfig{
+		Url:         schema.LdapURLs{unusedLdapURL(t), server.URL()},
+		StartTLS:    true,
+		CaFile:      caFile,
+		DialTimeout: "1s",
+	})
+	for i := 0; i < 5; i++ {
+		if err := login(la, "alice", "alice-pw"); err != nil {
+			t.Fatalf("login %d failed: %v", i, err)
+		}
+	}
+	if err := login(la, "alice", "wrong"); err == nil {
+		t.Errorf("login with wrong password succeeded")
+	}
+	if _, err := la.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if n := server.accepted.Load(); n != 1 {
+		t.Errorf("expected one pooled connection, got %d", n)
+	}
+	if n := server.upgraded.Load(); n != 1 {
+		t.Errorf("expected StartTLS on the connection, got %d", n)
+	}
+
+	// Broken connections are replaced
+	server.dropConnections()
+	la.pool.mu.Lock()
+	for _, c := range la.pool.idle {
+		c.used = time.Now().Add(-time.Minute)
+	}
+	la.pool.mu.Unlock()
+	if err := login(la, "bob", "bob-pw"); err != nil {
+		t.Fatalf("login after server restart failed: %v", err)
+	}
+	if n := server.accepted.Load(); n != 2 {
+		t.Errorf("expected a new connection, got %d", n)
+	}
+
+	// Without the CA the certificate of the server is not trusted
+	la = newAuthenticator(schema.LdapConfig{Url: schema.LdapURLs{server.URL()}, StartTLS: true})
+	if err := login(la, "alice", "alice-pw"); err == nil {
+		t.Errorf("login with untrusted certificate succeeded")
+	}
+
+	ldaps := newMockLdapsServer(t, ldapTestEntries(), serverTLS)
+	la = newAuthenticator(schema.LdapConfig{Url: schema.LdapURLs{ldaps.URL()}, CaFile: caFile})
+	if err := login(la, "carol", "carol-pw"); err != nil {
+		t.Errorf("login via LDAPS failed: %v", err)
+	}
+
+	// No server reachable
+	la = newAuthenticator(schema.LdapConfig{Url: schema.LdapURLs{unusedLdapURL(t)}, SyncUserOnLogin: true})
+	if _, ok := la.CanLogin(nil, "alice", httptest.NewRecorder(), httptest.NewRequest("POST", "/login", nil)); ok {
+		t.Errorf("user can login without LDAP server")
+	}
+	if err := login(la, "alice", "alice-pw"); err == nil {
+		t.Errorf("login without LDAP server succeeded")
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// ErrLoginThrottled is returned for login attempts made while the username
+// or source IP is locked or has to wait after a failure.
+var ErrLoginThrottled = errors.New("too many failed logins")
+
+const (
+	lockoutDefaultMaxFailures   = 5
+	lockoutDefaultMaxIPFailures = 50
+	lockoutDefaultBaseDelay     = time.Second
+	lockoutDefaultMaxDelay      = 30 * time.Second
+	lockoutDefaultDuration      = 15 * time.Minute
+
+	// How often expired counts are removed from the database
+	lockoutPruneInterval = time.Minute
+)
+
+// LoginThrottle tracks failed logins per username and source IP in the
+// database, so that the state is shared by all instances and survives
+// restarts. After a failure, the next attempt for the same username has to
+// wait a delay doubling with every failure. After too many failures the
+// username or IP is locked for a while, or until an admin unlocks it.
+type LoginThrottle struct {
+	db     *sql.DB
+	driver string
+
+	maxFailures     int
+	maxIPFailures   int
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	lockoutDuration time.Duration
+
+	lastPrune atomic.Int64 // unix seconds
+}
+
+func NewLoginThrottle(db *sql.DB, driver string, conf *schema.LoginLockoutConfig) (*LoginThrottle, error) {
+	if conf == nil {
+		conf = &schema.LoginLockoutConfig{}
+	}
+	t := &LoginThrottle{
+		db:            db,
+		driver:        driver,
+		maxFailures:   conf.MaxFailures,
+		maxIPFailures: conf.MaxIPFailures,
+	}
+	if t.maxFailures <= 0 {
+		t.maxFailures = lockoutDefaultMaxFailures
+	}
+	if t.maxIPFailures <= 0 {
+		t.maxIPFailures = lockoutDefaultMaxIPFailures
+	}
+
+	var err error
+	if t.baseDelay, err = parseDuration(conf.BaseDelay, lockoutDefaultBaseDelay); err != nil {
+		return nil, fmt.Errorf("invalid login-lockout.baseDelay: %w", err)
+	}
+	if t.maxDelay, err = parseDuration(conf.MaxDelay, lockoutDefaultMaxDelay); err != nil {
+		return nil, fmt.Errorf("invalid login-lockout.maxDelay: %w", err)
+	}
+	if t.lockoutDuration, err = parseDuration(conf.LockoutDuration, lockoutDefaultDuration); err != nil {
+		return nil, fmt.Errorf("invalid login-lockout.lockoutDuration: %w", err)
+	}
+	return t, nil
+}
+
+// expired reports whether the failures of a count no longer matter at now,
+// because its lockout ended or the last failure is too long ago.
+func (t *LoginThrottle) expired(a *repository.LoginAttempts, now time.Time) bool {
+	if a.LockedUntil != 0 {
+		return now.Unix() >= a.LockedUntil
+	}
+	return now.Sub(time.Unix(a.LastFailure, 0)) > t.lockoutDuration
+}
+
+// wait returns how long the next attempt has to wait at now.
+func (t *LoginThrottle) wait(a *repository.LoginAttempts, now time.Time) time.Duration {
+	if a.Failures == 0 || t.expired(a, now) {
+		return 0
+	}
+	if a.LockedUntil != 0 {
+		return max(time.Unix(a.LockedUntil, 0).Sub(now), 0)
+	}
+	if a.Kind != repository.LoginKeyUser || t.baseDelay == 0 {
+		return 0
+	}
+
+	delay := t.maxDelay
+	if a.Failures < 32 {
+		delay = min(t.baseDelay<<(a.Failures-1), t.maxDelay)
+	}
+	return max(time.Unix(a.LastFailure, 0).Add(delay).Sub(now), 0)
+}
+
+func loginKeys(username, ip string) [][2]string {
+	keys := make([][2]string, 0, 2)
+	if username != "" {
+		keys = append(keys, [2]string{repository.LoginKeyUser, username})
+	}
+	if ip != "" {
+		keys = append(keys, [2]string{repository.LoginKeyIP, ip})
+	}
+	return keys
+}
+
+// Check returns how long a login attempt for username from ip has to wait.
+func (t *LoginThrottle) Check(ctx context.Context, username, ip string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range loginKeys(username, ip) {
+		a, err := repository.GetLoginAttempts(ctx, t.db, key[0], key[1])
+		if err != nil {
+			return 0, err
+		}
+		wait = max(wait, t.wait(a, now))
+	}
+	return wait, nil
+}
+
+// Failed records a failed login for username from ip. Reaching the limit
+// locks the username or IP, which is written to the audit log and posted as
+// notification.
+func (t *LoginThrottle) Failed(ctx context.Context, username, ip string) error {
+	now := time.Now()
+	ctx = repository.WithAuditActor(ctx, &repository.AuditActor{Username: "system", AuthType: "internal", SourceIP: ip})
+	tx, err := t.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The failures are counted in the database, concurrent logins (also of
+	// other instances) must not overwrite each other
+	if err := t.prune(ctx, tx, now); err != nil {
+		return err
+	}
+	for _, key := range loginKeys(username, ip) {
+		a, err := repository.CountLoginFailure(ctx, tx, t.driver, key[0], key[1],
+			now.Unix(), now.Add(-t.lockoutDuration).Unix())
+		if err != nil {
+			return err
+		}
+
+		limit := t.maxFailures
+		if a.Kind == repository.LoginKeyIP {
+			limit = t.maxIPFailures
+		}
+		until := now.Add(t.lockoutDuration).Unix()
+		locked, err := repository.LockLoginAttempts(ctx, tx, a.Kind, a.Name, limit, until)
+		if err != nil {
+			return err
+		}
+		if locked {
+			a.LockedUntil = until
+			message := fmt.Sprintf("Login for %s '%s' locked until %s after %d failed attempts (last from %s)",
+				a.Kind, a.Name, time.Unix(a.LockedUntil, 0).Format(time.RFC3339), a.Failures, ip)
+			log.Warn(message)
+			if err := repository.RecordAuditFromContext(ctx, tx, repository.AuditCreate,
+				"login_lockout", a.Kind+":"+a.Name, nil, a); err != nil {
+				return err
+			}
+			if err := sqlcdb.New(tx).CreateNotification(ctx, message); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// prune removes the expired counts, at most once every
+// lockoutPruneInterval. Every submitted username gets a count, which would
+// otherwise be kept forever for names that never log in successfully.
+func (t *LoginThrottle) prune(ctx context.Context, tx *sql.Tx, now time.Time) error {
+	last := t.lastPrune.Load()
+	if now.Sub(time.Unix(last, 0)) < lockoutPruneInterval || !t.lastPrune.CompareAndSwap(last, now.Unix()) {
+		return nil
+	}
+	n, err := repository.DeleteExpiredLoginAttempts(ctx, tx, now.Unix(), now.Add(-t.lockoutDuration).Unix())
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Debugf("removed %d expired login failure counts", n)
+	}
+	return nil
+}
+
+// Succeeded forgets the failures of username. Those of the source IP are
+// kept, so that logging in with one account does not allow to continue
+// guessing the passwords of others.
+func (t *LoginThrottle) Succeeded(ctx context.Context, username string) error {
+	err := repository.DeleteLoginAttempts(ctx, t.db, repository.LoginKeyUser, username)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
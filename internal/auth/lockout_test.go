@@ -0,0 +1,262 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/sessions"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle, err := NewLoginThrottle(nil, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	for _, tc := range []struct {
+		attempts repository.LoginAttempts
+		expected time.Duration
+	}{
+		{repository.LoginAttempts{Kind: "user"}, 0},
+		{repository.LoginAttempts{Kind: "user", Failures: 1, LastFailure: now.Unix()}, time.Second},
+		{repository.LoginAttempts{Kind: "user", Failures: 3, LastFailure: now.Unix()}, 4 * time.Second},
+		{repository.LoginAttempts{Kind: "user", Failures: 3, LastFailure: now.Unix() - 3}, time.Second},
+		{repository.LoginAttempts{Kind: "user", Failures: 4, LastFailure: now.Unix() - 60}, 0},
+		{repository.LoginAttempts{Kind: "user", Failures: 40, LastFailure: now.Unix()}, 30 * time.Second},
+		// No backoff per IP, only the lockout
+		{repository.LoginAttempts{Kind: "ip", Failures: 10, LastFailure: now.Unix()}, 0},
+		{repository.LoginAttempts{Kind: "ip", Failures: 50, LastFailure: now.Unix(), LockedUntil: now.Unix() + 600}, 600 * time.Second},
+		// Expired lockout
+		{repository.LoginAttempts{Kind: "user", Failures: 5, LastFailure: now.Unix() - 1000, LockedUntil: now.Unix() - 100}, 0},
+	} {
+		wait := throttle.wait(&tc.attempts, time.Unix(now.Unix(), 0))
+		if wait != tc.expected {
+			t.Errorf("%+v: expected wait %s, got %s", tc.attempts, tc.expected, wait)
+		}
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	ctx := context.Background()
+	ur := setupUserRepository(t)
+	db := repository.GetConnection().DB.DB
+	t.Cleanup(func() { db.Exec("DELETE FROM login_attempts") })
+
+	for _, username := range []string{"bob", "carol"} {
+		if err := ur.AddUser(&schema.User{
+			Username: username, Password: username + "-pw",
+			Roles: []string{"user"}, AuthSource: schema.AuthViaLocalPassword,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	throttle, err := NewLoginThrottle(db, "sqlite3", &schema.LoginLockoutConfig{
+		MaxFailures: 3, MaxIPFailures: 5, BaseDelay: "0s", LockoutDuration: "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authentication := &Authentication{
+		sessionStore:   sessions.NewCookieStore([]byte(strings.Repeat("k", 32))),
+		Sessions:       NewSessionStore(db),
+		authenticators: []Authenticator{&LocalAuthenticator{}},
+		LoginThrottle:  throttle,
+	}
+	handler := authentication.Login(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {}),
+		func(rw http.ResponseWriter, r *http.Request, err error) {
+			if errors.Is(err, ErrLoginThrottled) {
+				rw.WriteHeader(http.StatusTooManyRequests)
+			} else {
+				rw.WriteHeader(http.StatusUnauthorized)
+			}
+		})
+	login := func(username, password, ip string) *httptest.ResponseRecorder {
+		form := url.Values{"username": {username}, "password": {password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = ip + ":40000"
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+	expect := func(rw *httptest.ResponseRecorder, code int) {
+		t.Helper()
+		if rw.Code != code {
+			t.Errorf("expected status %d, got %d", code, rw.Code)
+		}
+	}
+
+	counted := func(result string) float64 {
+		return testutil.ToFloat64(loginAttempts.WithLabelValues(result))
+	}
+	failures, successes, throttled := counted("failure"), counted("success"), counted("throttled")
+
+	// Successful logins reset the failures
+	expect(login("bob", "wrong", "10.0.0.1"), http.StatusUnauthorized)
+	expect(login("bob", "wrong", "10.0.0.1"), http.StatusUnauthorized)
+	expect(login("bob", "bob-pw", "10.0.0.1"), http.StatusOK)
+
+	for i := 0; i < 3; i++ {
+		expect(login("bob", "wrong", "10.0.0.2"), http.StatusUnauthorized)
+	}
+	rw := login("bob", "bob-pw", "10.0.0.3")
+	expect(rw, http.StatusTooManyRequests)
+	if retry, _ := strconv.Atoi(rw.Header().Get("Retry-After")); retry < 3500 || retry > 3602 {
+		t.Errorf("unexpected Retry-After: %s", rw.Header().Get("Retry-After"))
+	}
+	if counted("failure")-failures != 5 || counted("success")-successes != 1 || counted("throttled")-throttled != 1 {
+		t.Errorf("unexpected login metrics: %v failures, %v successes, %v throttled",
+			counted("failure")-failures, counted("success")-successes, counted("throttled")-throttled)
+	}
+
+	lockouts, err := repository.ListLoginLockouts(ctx, db, time.Now().Unix())
+	if err != nil || len(lockouts) != 1 || lockouts[0].Kind != "user" || lockouts[0].Name != "bob" {
+		t.Errorf("unexpected lockouts: %v %v", lockouts, err)
+	}
+	notifications, err := sqlcdb.New(db).GetNotifications(ctx, 10)
+	if err != nil || len(notifications) != 1 || !strings.Contains(notifications[0].Message, "user 'bob' locked") {
+		t.Errorf("unexpected notifications: %v %v", notifications, err)
+	}
+	var audited []*repository.AuditEntry
+	if err := repository.QueryAudit(ctx, db, repository.AuditFilter{Resource: "login_lockout"}, func(e *repository.AuditEntry) error {
+		audited = append(audited, e)
+		return nil
+	}); err != nil || len(audited) != 1 || audited[0].ResourceID != "user:bob" || audited[0].SourceIP != "10.0.0.2" {
+		t.Errorf("unexpected audit log: %v %v", audited, err)
+	}
+
+	// Guessing from one address locks the address, also for unknown users
+	for _, username := range []string{"x1", "x2", "x3", "x4", "x5"} {
+		expect(login(username, "wrong", "10.0.0.4"), http.StatusUnauthorized)
+	}
+	expect(login("carol", "carol-pw", "10.0.0.4"), http.StatusTooManyRequests)
+	expect(login("carol", "carol-pw", "10.0.0.5"), http.StatusOK)
+
+	// Unlocking
+	if err := repository.DeleteLoginAttempts(ctx, db, repository.LoginKeyUser, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	expect(login("bob", "bob-pw", "10.0.0.3"), http.StatusOK)
+}
+
+func TestLoginThrottleConcurrent(t *testing.T) {
+	ctx := context.Background()
+	setupUserRepository(t)
+	db := repository.GetConnection().DB.DB
+	t.Cleanup(func() { db.Exec("DELETE FROM login_attempts") })
+
+	throttle, err := NewLoginThrottle(db, "sqlite3", &schema.LoginLockoutConfig{
+		MaxFailures: 5, MaxIPFailures: 100, LockoutDuration: "1h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No failure gets lost and the lockout is reported once
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := throttle.Failed(ctx, "dave", "10.0.0.6"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	a, err := repository.GetLoginAttempts(ctx, db, repository.LoginKeyUser, "dave")
+	if err != nil || a.Failures != 8 || a.LockedUntil == 0 {
+		t.Errorf("unexpected failures: %+v %v", a, err)
+	}
+	notifications, err := sqlcdb.New(db).GetNotifications(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reported := 0
+	for _, n := range notifications {
+		if strings.Contains(n.Message, "user 'dave' locked") {
+			reported++
+		}
+	}
+	if reported != 1 {
+		t.Errorf("expected one notification, got %v", notifications)
+	}
+
+	// Failures after the lockout ended start again
+	if _, err := db.Exec("UPDATE login_attempts SET locked_until = ?", time.Now().Unix()-1); err != nil {
+		t.Fatal(err)
+	}
+	if err := throttle.Failed(ctx, "dave", "10.0.0.6"); err != nil {
+		t.Fatal(err)
+	}
+	a, err = repository.GetLoginAttempts(ctx, db, repository.LoginKeyUser, "dave")
+	if err != nil || a.Failures != 1 || a.LockedUntil != 0 {
+		t.Errorf("expected the failures to be reset: %+v %v", a, err)
+	}
+}
+
+func TestLoginThrottlePrune(t *testing.T) {
+	ctx := context.Background()
+	setupUserRepository(t)
+	db := repository.GetConnection().DB.DB
+	t.Cleanup(func() { db.Exec("DELETE FROM login_attempts") })
+
+	throttle, err := NewLoginThrottle(db, "sqlite3", &schema.LoginLockoutConfig{LockoutDuration: "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().Unix()
+	for _, a := range []repository.LoginAttempts{
+		{Kind: "user", Name: "guessed", Failures: 1, LastFailure: now - 7200},
+		{Kind: "user", Name: "unlocked", Failures: 5, LastFailure: now - 7200, LockedUntil: now - 10},
+		{Kind: "user", Name: "recent", Failures: 1, LastFailure: now - 60},
+		{Kind: "ip", Name: "10.0.0.7", Failures: 50, LastFailure: now - 7200, LockedUntil: now + 600},
+	} {
+		if _, err := db.Exec("INSERT INTO login_attempts (kind, name, failures, last_failure, locked_until) VALUES (?, ?, ?, ?, ?)",
+			a.Kind, a.Name, a.Failures, a.LastFailure, a.LockedUntil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Failed logins remove the expired counts of unknown usernames as well
+	if err := throttle.Failed(ctx, "erin", "10.0.0.8"); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT kind || ':' || name FROM login_attempts ORDER BY kind, name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var kept []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			t.Fatal(err)
+		}
+		kept = append(kept, key)
+	}
+	if strings.Join(kept, ",") != "ip:10.0.0.7,ip:10.0.0.8,user:erin,user:recent" {
+		t.Errorf("unexpected counts kept: %v", kept)
+	}
+}
@@ -7,10 +7,13 @@ package auth
 import (
 	"context"
 	"crypto/rand"
+	"database/sql"
 	"encoding/base64"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
@@ -25,10 +28,20 @@ import (
 type OIDC struct {
 	client         *oauth2.Config
 	provider       *oidc.Provider
+	verifier       *oidc.IDTokenVerifier
 	authentication *Authentication
 	clientID       string
+
+	// Taken from the discovery document, empty if the provider does not
+	// support RP-initiated logout
+	endSessionURL string
 }
 
+const defaultGroupsClaim = "resource_access.clustercockpit.roles"
+
+// Without roleMapping, the client roles 'user' and 'admin' are taken over.
+var defaultRoleMapping = map[string][]string{"user": {"user"}, "admin": {"admin"}}
+
 func randString(nByte int) (string, error) {
 	b := make([]byte, nByte)
 	if _, err := io.ReadFull(rand.Reader, b); err != nil {
@@ -49,7 +62,8 @@ func setCallbackCookie(w http.ResponseWriter, r *http.Request, name, value strin
 }
 
 func NewOIDC(a *Authentication) *OIDC {
-	provider, err := oidc.NewProvider(context.Background(), config.Keys.OpenIDConfig.Provider)
+	conf := config.Keys.OpenIDConfig
+	provider, err := oidc.NewProvider(context.Background(), conf.Provider)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -62,16 +76,43 @@ func NewOIDC(a *Authentication) *OIDC {
 		log.Warn("environment variable 'OID_CLIENT_SECRET' not set (Open ID connect auth will not work)")
 	}
 
+	for group, roles := range conf.RoleMapping {
+		for _, role := range roles {
+			if !schema.IsValidRole(role) {
+				log.Fatalf("invalid role '%s' mapped to OIDC group '%s'", role, group)
+			}
+		}
+	}
+
+	redirectURL := conf.RedirectURL
+	if redirectURL == "" {
+		redirectURL = "http://localhost:8080/oidc-callback"
+		log.Warnf("oidc.redirectUrl not set, using %s", redirectURL)
+	}
+
 	client := &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
 		Endpoint:     provider.Endpoint(),
-		// RedirectURL:  "oidc-callback",
-		RedirectURL: "http://localhost:8080/oidc-callback",
-		Scopes:      []string{oidc.ScopeOpenID, "profile", "email"},
+		RedirectURL:  redirectURL,
+		Scopes:       append([]string{oidc.ScopeOpenID, "profile", "email"}, conf.Scopes...),
 	}
 
-	oa := &OIDC{provider: provider, client: client, clientID: clientID, authentication: a}
+	var discovery struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		log.Warnf("parsing OIDC discovery document failed: %v", err)
+	}
+
+	oa := &OIDC{
+		provider:       provider,
+		verifier:       provider.Verifier(&oidc.Config{ClientID: clientID}),
+		client:         client,
+		clientID:       clientID,
+		authentication: a,
+		endSessionURL:  discovery.EndSessionEndpoint,
+	}
 
 	return oa
 }
@@ -79,6 +120,61 @@ func NewOIDC(a *Authentication) *OIDC {
 func (oa *OIDC) RegisterEndpoints(r *mux.Router) {
 	r.HandleFunc("/oidc-login", oa.OAuth2Login)
 	r.HandleFunc("/oidc-callback", oa.OAuth2Callback)
+	r.Handle("/oidc-logout", oa.authentication.Logout(http.HandlerFunc(oa.OAuth2Logout))).Methods(http.MethodPost)
+}
+
+// claimValues returns the strings found at the dot separated path in claims.
+// The value may be a single string or a list of strings.
+func claimValues(claims map[string]interface{}, path string) []string {
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = m[key]
+	}
+
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, x := range v {
+			if s, ok := x.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// oidcRolesAndProjects maps the groups claim to roles and projects using the
+// configured mappings.
+func oidcRolesAndProjects(conf *schema.OpenIDConfig, claims map[string]interface{}) (roles, projects []string) {
+	groupsClaim, roleMapping := conf.GroupsClaim, conf.RoleMapping
+	if groupsClaim == "" {
+		groupsClaim = defaultGroupsClaim
+	}
+	if roleMapping == nil {
+		roleMapping = defaultRoleMapping
+	}
+	return mapGroups(claimValues(claims, groupsClaim), roleMapping, conf.ProjectMapping)
+}
+
+// syncUser adds the user to the database or updates roles and projects from
+// the current claims.
+func syncUser(ctx context.Context, user *schema.User) error {
+	ur := repository.GetUserRepository()
+	if _, err := ur.GetUser(user.Username); err == sql.ErrNoRows {
+		return ur.AddUser(user)
+	} else if err != nil {
+		return err
+	}
+	_, err := ur.SetRolesAndProjects(ctx, user.Username, user.Roles, user.Projects)
+	return err
 }
 
 func (oa *OIDC) OAuth2Callback(rw http.ResponseWriter, r *http.Request) {
@@ -106,79 +202,105 @@ func (oa *OIDC) OAuth2Callback(rw http.ResponseWriter, r *http.Request) {
 		http.Error(rw, "Code not found", http.StatusBadRequest)
 		return
 	}
-	token, err := oa.client.Exchange(context.Background(), code, oauth2.VerifierOption(codeVerifier))
+	token, err := oa.client.Exchange(r.Context(), code, oauth2.VerifierOption(codeVerifier))
 	if err != nil {
 		http.Error(rw, "Failed to exchange token: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	userInfo, err := oa.provider.UserInfo(context.Background(), oauth2.StaticTokenSource(token))
+	// Extract the ID Token from OAuth2 token.
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(rw, "Cannot access idToken", http.StatusInternalServerError)
+		return
+	}
+	// Parse and verify ID Token payload.
+	idToken, err := oa.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil {
+		http.Error(rw, "Failed to extract idToken: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	userInfo, err := oa.provider.UserInfo(r.Context(), oauth2.StaticTokenSource(token))
 	if err != nil {
 		http.Error(rw, "Failed to get userinfo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// // Extract the ID Token from OAuth2 token.
-	// rawIDToken, ok := token.Extra("id_token").(string)
-	// if !ok {
-	// 	http.Error(rw, "Cannot access idToken", http.StatusInternalServerError)
-	// }
-	//
-	// verifier := oa.provider.Verifier(&oidc.Config{ClientID: oa.clientID})
-	// // Parse and verify ID Token payload.
-	// idToken, err := verifier.Verify(context.Background(), rawIDToken)
-	// if err != nil {
-	// 	http.Error(rw, "Failed to extract idToken: "+err.Error(), http.StatusInternalServerError)
-	// }
-
-	projects := make([]string, 0)
-
-	// Extract custom claims
-	var claims struct {
-		Username string `json:"preferred_username"`
-		Name     string `json:"name"`
-		Profile  struct {
-			Client struct {
-				Roles []string `json:"roles"`
-			} `json:"clustercockpit"`
-		} `json:"resource_access"`
+	// Claims of the userinfo endpoint take precedence over those of the ID token
+	claims := map[string]interface{}{}
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(rw, "Failed to extract Claims: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 	if err := userInfo.Claims(&claims); err != nil {
 		http.Error(rw, "Failed to extract Claims: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	var roles []string
-	for _, r := range claims.Profile.Client.Roles {
-		switch r {
-		case "user":
-			roles = append(roles, schema.GetRoleString(schema.RoleUser))
-		case "admin":
-			roles = append(roles, schema.GetRoleString(schema.RoleAdmin))
-		}
-	}
-
-	if len(roles) == 0 {
-		roles = append(roles, schema.GetRoleString(schema.RoleUser))
+	username, _ := claims["preferred_username"].(string)
+	if username == "" {
+		http.Error(rw, "Claim 'preferred_username' missing", http.StatusInternalServerError)
+		return
 	}
+	name, _ := claims["name"].(string)
+	email, _ := claims["email"].(string)
+	roles, projects := oidcRolesAndProjects(config.Keys.OpenIDConfig, claims)
 
 	user := &schema.User{
-		Username:   claims.Username,
-		Name:       claims.Name,
+		Username:   username,
+		Name:       name,
+		Email:      email,
 		Roles:      roles,
 		Projects:   projects,
 		AuthSource: schema.AuthViaOIDC,
 	}
 
 	if config.Keys.OpenIDConfig.SyncUserOnLogin {
-		persistUser(user)
+		if err := syncUser(r.Context(), user); err != nil {
+			log.Errorf("syncing user '%s' failed: %v", user.Username, err)
+		}
 	}
 
-	oa.authentication.SaveSession(rw, r, user)
+	// The second factor cannot be asked for after a login at the provider
+	if totp := oa.authentication.TOTP; totp != nil {
+		if err := totp.CheckExternalLogin(r.Context(), user); err != nil {
+			log.Warnf("login of user '%s' rejected: %v", user.Username, err)
+			http.Error(rw, err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := oa.authentication.SaveSession(rw, r, user); err != nil {
+		return
+	}
 	log.Infof("login successfull: user: %#v (roles: %v, projects: %v)", user.Username, user.Roles, user.Projects)
 	ctx := context.WithValue(r.Context(), repository.ContextUserKey, user)
 	http.RedirectHandler("/", http.StatusTemporaryRedirect).ServeHTTP(rw, r.WithContext(ctx))
 }
 
+// OAuth2Logout ends the session at the provider after the local session was
+// removed (RP-initiated logout).
+func (oa *OIDC) OAuth2Logout(rw http.ResponseWriter, r *http.Request) {
+	if oa.endSessionURL == "" {
+		http.Redirect(rw, r, "/", http.StatusFound)
+		return
+	}
+
+	u, err := url.Parse(oa.endSessionURL)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	q := u.Query()
+	q.Set("client_id", oa.clientID)
+	if redirect := config.Keys.OpenIDConfig.PostLogoutRedirectURL; redirect != "" {
+		q.Set("post_logout_redirect_uri", redirect)
+	}
+	u.RawQuery = q.Encode()
+	http.Redirect(rw, r, u.String(), http.StatusFound)
+}
+
 func (oa *OIDC) OAuth2Login(rw http.ResponseWriter, r *http.Request) {
 	state, err := randString(16)
 	if err != nil {
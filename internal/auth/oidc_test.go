@@ -0,0 +1,184 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/sessions"
+)
+
+// mockOIDCProvider is a minimal OpenID provider issuing tokens for a single
+// user with the given claims.
+func mockOIDCProvider(t *testing.T, claims map[string]interface{}) *httptest.Server {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(rw http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"issuer":                                srv.URL,
+			"authorization_endpoint":                srv.URL + "/auth",
+			"token_endpoint":                        srv.URL + "/token",
+			"userinfo_endpoint":                     srv.URL + "/userinfo",
+			"jwks_uri":                              srv.URL + "/jwks",
+			"end_session_endpoint":                  srv.URL + "/logout",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(rw http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(rw).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "alg": "RS256", "use": "sig", "kid": "test",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(rw http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "test-code" || r.FormValue("code_verifier") == "" {
+			http.Error(rw, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": srv.URL, "sub": "1234", "aud": "cc-backend",
+			"iat": time.Now().Unix(), "exp": time.Now().Add(time.Hour).Unix(),
+			"preferred_username": claims["preferred_username"],
+		})
+		idToken.Header["kid"] = "test"
+		signed, err := idToken.SignedString(key)
+		if err != nil {
+			t.Error(err)
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(map[string]interface{}{
+			"access_token": "test-access-token", "token_type": "Bearer", "expires_in": 3600, "id_token": signed,
+		})
+	})
+	mux.HandleFunc("/userinfo", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-access-token" {
+			http.Error(rw, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(claims)
+	})
+
+	return srv
+}
+
+func TestOIDCLogin(t *testing.T) {
+	provider := mockOIDCProvider(t, map[string]interface{}{
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"name":               "Alice",
+		"email":              "alice@example.com",
+		"groups":             []string{"hpc-admins", "project-a", "unrelated"},
+	})
+
+	oidcConfig := config.Keys.OpenIDConfig
+	config.Keys.OpenIDConfig = &schema.OpenIDConfig{
+		Provider:              provider.URL,
+		RedirectURL:           "https://cc.example.com/oidc-callback",
+		Scopes:                []string{"groups"},
+		GroupsClaim:           "groups",
+		RoleMapping:           map[string][]string{"hpc-admins": {"admin", "support"}, "project-a": {"manager"}},
+		ProjectMapping:        map[string][]string{"project-a": {"projA"}},
+		PostLogoutRedirectURL: "https://cc.example.com/",
+	}
+	t.Cleanup(func() { config.Keys.OpenIDConfig = oidcConfig })
+	t.Setenv("OID_CLIENT_ID", "cc-backend")
+	t.Setenv("OID_CLIENT_SECRET", "secret")
+
+	setupUserRepository(t)
+	authentication := &Authentication{
+		sessionStore: sessions.NewCookieStore([]byte(strings.Repeat("k", 32))),
+		Sessions:     NewSessionStore(repository.GetConnection().DB.DB),
+	}
+	oa := NewOIDC(authentication)
+
+	// Login redirects to the provider
+	rw := httptest.NewRecorder()
+	oa.OAuth2Login(rw, httptest.NewRequest("GET", "/oidc-login", nil))
+	location, err := url.Parse(rw.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := location.Query()
+	if q.Get("redirect_uri") != "https://cc.example.com/oidc-callback" || !strings.Contains(q.Get("scope"), "groups") {
+		t.Errorf("unexpected authorization request: %s", location)
+	}
+
+	// The provider redirects back with the code
+	req := httptest.NewRequest("GET", "/oidc-callback?code=test-code&state="+q.Get("state"), nil)
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rw = httptest.NewRecorder()
+	oa.OAuth2Callback(rw, req)
+	if rw.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("callback failed: %d %s", rw.Code, rw.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/", nil)
+	for _, c := range rw.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	// Not synced to the database, the roles of the login apply
+	user, err := authentication.AuthViaSession(httptest.NewRecorder(), req)
+	if err != nil || user == nil {
+		t.Fatalf("no session created: %v", err)
+	}
+	if user.Username != "alice" ||
+		!reflect.DeepEqual(user.Roles, []string{"admin", "manager", "support"}) ||
+		!reflect.DeepEqual(user.Projects, []string{"projA"}) {
+		t.Errorf("unexpected session user: %+v", user)
+	}
+
+	// RP-initiated logout
+	rw = httptest.NewRecorder()
+	oa.OAuth2Logout(rw, httptest.NewRequest("POST", "/oidc-logout", nil))
+	location, _ = url.Parse(rw.Header().Get("Location"))
+	if !strings.HasPrefix(location.String(), provider.URL+"/logout") ||
+		location.Query().Get("client_id") != "cc-backend" ||
+		location.Query().Get("post_logout_redirect_uri") != "https://cc.example.com/" {
+		t.Errorf("unexpected logout redirect: %s", location)
+	}
+}
+
+func TestOIDCDefaultRoleMapping(t *testing.T) {
+	claims := map[string]interface{}{
+		"resource_access": map[string]interface{}{
+			"clustercockpit": map[string]interface{}{"roles": []interface{}{"admin", "other"}},
+		},
+	}
+	roles, projects := oidcRolesAndProjects(&schema.OpenIDConfig{}, claims)
+	if !reflect.DeepEqual(roles, []string{"admin"}) || len(projects) != 0 {
+		t.Errorf("unexpected roles %v and projects %v", roles, projects)
+	}
+
+	roles, _ = oidcRolesAndProjects(&schema.OpenIDConfig{}, map[string]interface{}{})
+	if !reflect.DeepEqual(roles, []string{"user"}) {
+		t.Errorf("expected user role without groups, got %v", roles)
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	sq "github.com/Masterminds/squirrel"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrWeakPassword     = errors.New("password does not meet the requirements")
+	ErrPasswordInvalid  = errors.New("current password is wrong")
+	ErrPasswordNotLocal = errors.New("only passwords of local accounts can be changed")
+	ErrResetInvalid     = errors.New("invalid or expired password reset link")
+)
+
+const (
+	passwordDefaultMinLength     = 8
+	passwordDefaultMinClasses    = 1
+	passwordDefaultResetLifetime = 24 * time.Hour
+)
+
+// PasswordPolicy decides which passwords local accounts may have and
+// manages the one-time links admins hand out to reset them.
+type PasswordPolicy struct {
+	db *sql.DB
+
+	minLength     int
+	minClasses    int
+	breached      map[string]struct{} // upper case SHA-1 in hex
+	resetLifetime time.Duration
+}
+
+func NewPasswordPolicy(db *sql.DB, conf *schema.PasswordPolicyConfig) (*PasswordPolicy, error) {
+	if conf == nil {
+		conf = &schema.PasswordPolicyConfig{}
+	}
+	p := &PasswordPolicy{
+		db:         db,
+		minLength:  conf.MinLength,
+		minClasses: conf.MinClasses,
+	}
+	if p.minLength <= 0 {
+		p.minLength = passwordDefaultMinLength
+	}
+	if p.minClasses <= 0 {
+		p.minClasses = passwordDefaultMinClasses
+	}
+	if p.minClasses > 4 {
+		return nil, fmt.Errorf("invalid password-policy.min-classes %d, there are only 4 classes", p.minClasses)
+	}
+
+	var err error
+	if p.resetLifetime, err = parseDuration(conf.ResetLinkLifetime, passwordDefaultResetLifetime); err != nil {
+		return nil, fmt.Errorf("invalid password-policy.reset-link-lifetime: %w", err)
+	}
+	if conf.BreachList != "" {
+		if p.breached, err = loadBreachList(conf.BreachList); err != nil {
+			return nil, fmt.Errorf("loading password-policy.breach-list failed: %w", err)
+		}
+		log.Infof("loaded %d breached passwords", len(p.breached))
+	}
+	return p, nil
+}
+
+func passwordSHA1(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+func isSHA1Hex(s string) bool {
+	if len(s) != 2*sha1.Size {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// loadBreachList reads a file of passwords or SHA-1 hashes, one per line.
+// Counts appended to hashes as in "HASH:count" are ignored.
+func loadBreachList(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	breached := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); isSHA1Hex(hash) {
+			breached[strings.ToUpper(hash)] = struct{}{}
+		} else {
+			breached[passwordSHA1(line)] = struct{}{}
+		}
+	}
+	return breached, scanner.Err()
+}
+
+func passwordClasses(password string) int {
+	var lower, upper, digit, other int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			other = 1
+		}
+	}
+	return lower + upper + digit + other
+}
+
+// Check returns an error wrapping ErrWeakPassword that tells why, if
+// password is not allowed for username.
+func (p *PasswordPolicy) Check(username, password string) error {
+	if n := utf8.RuneCountInString(password); n < p.minLength {
+		return fmt.Errorf("%w: at least %d characters needed", ErrWeakPassword, p.minLength)
+	}
+	if passwordClasses(password) < p.minClasses {
+		return fmt.Errorf("%w: at least %d of lower case letters, upper case letters, digits and other characters needed",
+			ErrWeakPassword, p.minClasses)
+	}
+	if strings.EqualFold(password, username) {
+		return fmt.Errorf("%w: it must not be the username", ErrWeakPassword)
+	}
+	if _, ok := p.breached[passwordSHA1(password)]; ok {
+		return fmt.Errorf("%w: it appeared in a data breach", ErrWeakPassword)
+	}
+	return nil
+}
+
+// Change sets password for user, a local account, after verifying its
+// current password.
+func (p *PasswordPolicy) Change(ctx context.Context, runner sq.BaseRunner, user *schema.User, current, password string) error {
+	if user.AuthSource != schema.AuthViaLocalPassword {
+		return ErrPasswordNotLocal
+	}
+	if user.Password == "" || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(current)) != nil {
+		return ErrPasswordInvalid
+	}
+	if password == current {
+		return fmt.Errorf("%w: it must differ from the current one", ErrWeakPassword)
+	}
+	if err := p.Check(user.Username, password); err != nil {
+		return err
+	}
+	return repository.SetUserPassword(ctx, runner, user.Username, password)
+}
+
+// CreateReset creates a link token with which the local account username
+// can set a new password once. Earlier tokens of the user become invalid.
+func (p *PasswordPolicy) CreateReset(ctx context.Context, runner sq.BaseRunner, username, createdBy string) (string, *repository.PasswordReset, error) {
+	user, err := repository.LoadUser(ctx, runner, username)
+	if err != nil {
+		return "", nil, err
+	}
+	if user.AuthSource != schema.AuthViaLocalPassword {
+		return "", nil, ErrPasswordNotLocal
+	}
+
+	token, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	reset := &repository.PasswordReset{
+		TokenHash: hashSessionToken(token),
+		Username:  username,
+		CreatedBy: createdBy,
+		CreatedAt: now.Unix(),
+		ExpiresAt: now.Add(p.resetLifetime).Unix(),
+	}
+	if err := repository.CreatePasswordReset(ctx, runner, reset); err != nil {
+		return "", nil, err
+	}
+	return token, reset, nil
+}
+
+// Reset sets password for the user of the reset token and ends all its
+// sessions. The token stays valid if the password is rejected. ip is
+// recorded in the audit log.
+func (p *PasswordPolicy) Reset(ctx context.Context, token, password, ip string) (string, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	reset, err := repository.TakePasswordReset(ctx, tx, hashSessionToken(token))
+	if err == sql.ErrNoRows {
+		return "", ErrResetInvalid
+	} else if err != nil {
+		return "", err
+	}
+	if time.Now().Unix() >= reset.ExpiresAt {
+		if err := tx.Commit(); err != nil {
+			return "", err
+		}
+		return "", ErrResetInvalid
+	}
+
+	before, err := repository.LoadUser(ctx, tx, reset.Username)
+	if err == sql.ErrNoRows {
+		return "", ErrResetInvalid
+	} else if err != nil {
+		return "", err
+	}
+	if err := p.Check(reset.Username, password); err != nil {
+		return "", err
+	}
+	if err := repository.SetUserPassword(ctx, tx, reset.Username, password); err != nil {
+		return "", err
+	}
+	if _, err := repository.DeleteUserSessions(ctx, tx, reset.Username, ""); err != nil {
+		return "", err
+	}
+	after, err := repository.LoadUser(ctx, tx, reset.Username)
+	if err != nil {
+		return "", err
+	}
+
+	ctx = repository.WithAuditActor(ctx, &repository.AuditActor{Username: reset.Username, AuthType: "reset-link", SourceIP: ip})
+	if err := repository.RecordAuditFromContext(ctx, tx, repository.AuditUpdate, "user", reset.Username,
+		map[string]interface{}{"password": before.Password}, map[string]interface{}{"password": after.Password}); err != nil {
+		return "", err
+	}
+	return reset.Username, tx.Commit()
+}
+
+// ResetPassword handles the form of a password reset link with the fields
+// token and password.
+func (auth *Authentication) ResetPassword(
+	onsuccess http.Handler,
+	onfailure func(rw http.ResponseWriter, r *http.Request, err error),
+) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if auth.PasswordPolicy == nil {
+			onfailure(rw, r, ErrResetInvalid)
+			return
+		}
+
+		username, err := auth.PasswordPolicy.Reset(r.Context(), r.FormValue("token"), r.FormValue("password"), util.ClientIP(r))
+		if err != nil {
+			log.Warnf("password reset failed: %v", err)
+			onfailure(rw, r, err)
+			return
+		}
+
+		log.Infof("password of user '%s' reset", username)
+		onsuccess.ServeHTTP(rw, r)
+	})
+}
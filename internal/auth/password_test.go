@@ -0,0 +1,143 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	breachList := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(breachList, []byte("Summer2023!\n"+passwordSHA1("Winter2023!")+":42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	policy, err := NewPasswordPolicy(nil, &schema.PasswordPolicyConfig{MinLength: 10, MinClasses: 3, BreachList: breachList})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		username, password string
+		ok                 bool
+	}{
+		{"alice", "Sh0rt!", false},
+		{"alice", "lowercaseonly", false},
+		{"alice", "lowercase42x", false},
+		{"alice", "Lowercase42x", true},
+		{"alice", "Ünïcode-pass", true},
+		{"Alice.Smith1", "alice.smith1", false},
+		{"alice", "Summer2023!", false},
+		{"alice", "Winter2023!", false},
+		{"alice", "Autumn2023!", true},
+	} {
+		err := policy.Check(tc.username, tc.password)
+		if tc.ok && err != nil {
+			t.Errorf("%q rejected: %v", tc.password, err)
+		} else if !tc.ok && !errors.Is(err, ErrWeakPassword) {
+			t.Errorf("%q accepted", tc.password)
+		}
+	}
+
+	if _, err := NewPasswordPolicy(nil, &schema.PasswordPolicyConfig{MinClasses: 5}); err == nil {
+		t.Errorf("more classes than exist accepted")
+	}
+	if _, err := NewPasswordPolicy(nil, &schema.PasswordPolicyConfig{BreachList: breachList + ".missing"}); err == nil {
+		t.Errorf("missing breach list accepted")
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	ur := setupUserRepository(t)
+	for _, user := range []*schema.User{
+		{Username: "bob", Password: "bob-password", Roles: []string{"user"}, AuthSource: schema.AuthViaLocalPassword},
+		{Username: "carol", Roles: []string{"user"}, AuthSource: schema.AuthViaLDAP},
+	} {
+		if err := ur.AddUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	db := repository.GetConnection().DB.DB
+	policy, err := NewPasswordPolicy(db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwordIs := func(password string) bool {
+		user, err := ur.GetUser("bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil
+	}
+
+	if _, _, err := policy.CreateReset(ctx, db, "carol", "admin"); !errors.Is(err, ErrPasswordNotLocal) {
+		t.Errorf("expected reset of LDAP user to fail, got %v", err)
+	}
+
+	token, reset, err := policy.CreateReset(ctx, db, "bob", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reset.TokenHash == token || reset.ExpiresAt-reset.CreatedAt != int64(passwordDefaultResetLifetime.Seconds()) {
+		t.Errorf("unexpected reset: %+v", reset)
+	}
+	if _, err := db.Exec("INSERT INTO sessions (id, token_hash, username, created_at, last_activity) VALUES ('s1', 'h1', 'bob', 0, ?)", time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rejected passwords do not use up the link
+	if _, err := policy.Reset(ctx, token, "short", "192.0.2.1"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("expected weak password, got %v", err)
+	}
+	if username, err := policy.Reset(ctx, token, "new-password", "192.0.2.1"); err != nil || username != "bob" {
+		t.Fatalf("reset failed: %v", err)
+	}
+	if !passwordIs("new-password") {
+		t.Errorf("password not changed")
+	}
+	if sessions, _ := repository.ListSessions(ctx, db, "bob"); len(sessions) != 0 {
+		t.Errorf("sessions not ended: %v", sessions)
+	}
+	if _, err := policy.Reset(ctx, token, "other-password", "192.0.2.1"); !errors.Is(err, ErrResetInvalid) {
+		t.Errorf("link used twice, got %v", err)
+	}
+
+	// Only the latest link is valid and only until it expires
+	first, _, _ := policy.CreateReset(ctx, db, "bob", "admin")
+	second, _, _ := policy.CreateReset(ctx, db, "bob", "admin")
+	if _, err := policy.Reset(ctx, first, "other-password", "192.0.2.1"); !errors.Is(err, ErrResetInvalid) {
+		t.Errorf("replaced link accepted, got %v", err)
+	}
+	if _, err := db.Exec("UPDATE password_resets SET expires_at = ?", time.Now().Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := policy.Reset(ctx, second, "other-password", "192.0.2.1"); !errors.Is(err, ErrResetInvalid) {
+		t.Errorf("expired link accepted, got %v", err)
+	}
+	if !passwordIs("new-password") {
+		t.Errorf("password changed by invalid link")
+	}
+
+	user, _ := ur.GetUser("bob")
+	if err := policy.Change(ctx, db, user, "wrong-password", "changed-password"); !errors.Is(err, ErrPasswordInvalid) {
+		t.Errorf("expected wrong current password, got %v", err)
+	}
+	if err := policy.Change(ctx, db, user, "new-password", "new-password"); !errors.Is(err, ErrWeakPassword) {
+		t.Errorf("expected unchanged password to be rejected, got %v", err)
+	}
+	if err := policy.Change(ctx, db, user, "new-password", "changed-password"); err != nil || !passwordIs("changed-password") {
+		t.Errorf("password change failed: %v", err)
+	}
+}
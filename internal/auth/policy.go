@@ -0,0 +1,93 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// AccessPolicy describes who may access a storage or infrastructure
+// resource. Users holding one of Roles are always admitted. If Managers is
+// set, users with the manager role are admitted as well, but only for
+// machines that belong to a machine group named after one of their projects.
+// If Agents is set, users with the api role are admitted for the machine
+// their token is bound to.
+//
+// The REST and the GraphQL API share these policies.
+type AccessPolicy struct {
+	Roles    []schema.Role
+	Managers bool
+	Agents   bool
+}
+
+var (
+	// Cluster wide configuration (RabbitMQ, InfluxDB, file stash, machine list)
+	PolicyConfigRead  = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin, schema.RoleSupport}}
+	PolicyConfigWrite = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin}}
+
+	// Resources that belong to a single machine
+	PolicyMachineRead  = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin, schema.RoleSupport}, Managers: true}
+	PolicyMachineWrite = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin}, Managers: true}
+
+	// Credentials with secret references resolved, for the agents and
+	// services using the configuration
+	PolicyConfigResolve  = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin, schema.RoleApi}}
+	PolicyMachineResolve = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin}, Agents: true}
+
+	// Inventory and logs pushed by agents running on the machines
+	PolicyMachinePush = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin}, Managers: true, Agents: true}
+
+	// Lockouts after failed logins
+	PolicyLockoutRead  = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin, schema.RoleSupport}}
+	PolicyLockoutWrite = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin}}
+
+	PolicyNotificationRead   = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin, schema.RoleSupport, schema.RoleManager}}
+	PolicyNotificationCreate = AccessPolicy{Roles: []schema.Role{schema.RoleAdmin, schema.RoleApi}}
+)
+
+// Allows reports whether p admits user. For policies admitting managers or
+// agents, machine is called to find the machine the access targets and
+// machineGroups to look up its groups. Both may be nil for resources that
+// are not bound to a machine.
+func (p AccessPolicy) Allows(
+	user *schema.User,
+	machine func() (string, error),
+	machineGroups func(machineID string) ([]string, error),
+) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+	if user.HasAnyRole(p.Roles) {
+		return true, nil
+	}
+
+	agent := p.Agents && user.Machine != "" && user.HasRole(schema.RoleApi)
+	manager := p.Managers && machineGroups != nil && user.HasRole(schema.RoleManager)
+	if machine == nil || (!agent && !manager) {
+		return false, nil
+	}
+
+	machineID, err := machine()
+	if err != nil || machineID == "" {
+		return false, err
+	}
+	if agent && machineID == user.Machine {
+		return true, nil
+	}
+	if !manager {
+		return false, nil
+	}
+
+	groups, err := machineGroups(machineID)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups {
+		if user.HasProject(group) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import "github.com/Deepbinder-main/cc-backend/pkg/schema"
+
+// routeScopes maps method and path template of a REST route to the scope a
+// scoped token needs for it. Routes not listed here require the admin scope.
+// Scopes only restrict tokens further, the roles of the user are checked by
+// the handlers as before. GraphQL fields use the scope of the route they
+// correspond to.
+var routeScopes = map[string]string{
+	"GET /api/machine_state/{cluster}/{host}":         schema.ScopeMachinesRead,
+	"GET /api/machine_conf/{machine_id}":              schema.ScopeMachinesRead,
+	"GET /api/machine/{machine_id}":                   schema.ScopeMachinesRead,
+	"GET /api/machines":                               schema.ScopeMachinesRead,
+	"GET /api/machine/{machine_id}/groups":            schema.ScopeMachinesRead,
+	"GET /api/lv_storage_issuers":                     schema.ScopeMachinesRead,
+	"GET /api/lv_requests/{machine_id}":               schema.ScopeMachinesRead,
+	"GET /api/physical_volumes":                       schema.ScopeMachinesRead,
+	"GET /api/notifications":                          schema.ScopeMachinesRead,
+	"GET /api/realtime_logs":                          schema.ScopeMachinesRead,
+	"GET /api/volume_groups":                          schema.ScopeMachinesRead,
+	"GET /api/logical_volumes/{machine_id}":           schema.ScopeMachinesRead,
+	"POST /api/machine_conf":                          schema.ScopeMachinesWrite,
+	"PUT /api/machine_conf/{id}":                      schema.ScopeMachinesWrite,
+	"DELETE /api/machine_conf/{id}":                   schema.ScopeMachinesWrite,
+	"POST /api/machine":                               schema.ScopeMachinesWrite,
+	"PUT /api/machine/{machine_id}":                   schema.ScopeMachinesWrite,
+	"DELETE /api/machine/{machine_id}":                schema.ScopeMachinesWrite,
+	"POST /api/machine/{machine_id}/groups":           schema.ScopeMachinesWrite,
+	"DELETE /api/machine/{machine_id}/groups/{group}": schema.ScopeMachinesWrite,
+	"POST /api/lv_storage_issuer":                     schema.ScopeMachinesWrite,
+	"PUT /api/lv_storage_issuer/{id}":                 schema.ScopeMachinesWrite,
+	"DELETE /api/lv_storage_issuer/{id}":              schema.ScopeMachinesWrite,
+	"PUT /api/machine_state/{cluster}/{host}":         schema.ScopeInventoryWrite,
+	"POST /api/machine_state/{cluster}/{host}":        schema.ScopeInventoryWrite,
+	"PUT /api/lv_requests/{id}":                       schema.ScopeInventoryWrite,
+	"POST /api/physical_volumes":                      schema.ScopeInventoryWrite,
+	"PUT /api/physical_volumes/{pv_id}":               schema.ScopeInventoryWrite,
+	"DELETE /api/physical_volumes/{pv_id}":            schema.ScopeInventoryWrite,
+	"POST /api/volume_groups":                         schema.ScopeInventoryWrite,
+	"PUT /api/volume_groups/{vg_id}":                  schema.ScopeInventoryWrite,
+	"DELETE /api/volume_groups/{vg_id}":               schema.ScopeInventoryWrite,
+	"POST /api/logical_volume":                        schema.ScopeInventoryWrite,
+	"PUT /api/logical_volume/{lv_id}":                 schema.ScopeInventoryWrite,
+	"DELETE /api/logical_volume/{lv_id}":              schema.ScopeInventoryWrite,
+	"POST /api/realtime_logs":                         schema.ScopeLogsWrite,
+	"DELETE /api/realtime_logs/{id}":                  schema.ScopeLogsWrite,
+	"POST /api/notifications":                         schema.ScopeLogsWrite,
+	"GET /api/rabbitmq_config/resolved":               schema.ScopeSecretsRead,
+	"GET /api/influxdb_config/resolved":               schema.ScopeSecretsRead,
+	"GET /api/machine_conf/{machine_id}/resolved":     schema.ScopeSecretsRead,
+}
+
+// RouteScope returns the scope needed for route, given as method and path
+// template like "GET /api/machines".
+func RouteScope(route string) string {
+	if scope, ok := routeScopes[route]; ok {
+		return scope
+	}
+	return schema.ScopeAdmin
+}
@@ -0,0 +1,143 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// ErrSessionEnded is returned for sessions that expired, timed out or were
+// ended by a logout or an admin.
+var ErrSessionEnded = errors.New("session ended, please login again")
+
+// Minimum interval between updates of the last activity of a session
+const sessionTouchInterval = time.Minute
+
+// SessionStore keeps the login sessions in the database, so that they can
+// be listed and ended, also from other instances. The session cookie only
+// carries a random token, of which the store knows the hash.
+type SessionStore struct {
+	db *sql.DB
+}
+
+func NewSessionStore(db *sql.DB) *SessionStore {
+	return &SessionStore{db: db}
+}
+
+func hashSessionToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomString(n int, encode func([]byte) string) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return encode(b), nil
+}
+
+// Create starts a session for user and returns the token for the cookie.
+// Sessions end after maxAge, if not zero. Expired sessions of all users are
+// removed on the way.
+func (s *SessionStore) Create(ctx context.Context, user *schema.User, ip, userAgent string, maxAge, idleTimeout time.Duration) (string, *repository.Session, error) {
+	token, err := randomString(32, base64.RawURLEncoding.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := randomString(16, hex.EncodeToString)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	if len(userAgent) > 255 {
+		userAgent = userAgent[:255]
+	}
+	session := &repository.Session{
+		ID:           id,
+		TokenHash:    hashSessionToken(token),
+		Username:     user.Username,
+		CreatedAt:    now.Unix(),
+		LastActivity: now.Unix(),
+		SourceIP:     ip,
+		UserAgent:    userAgent,
+		Roles:        user.Roles,
+		Projects:     user.Projects,
+	}
+	if maxAge > 0 {
+		session.ExpiresAt = now.Add(maxAge).Unix()
+	}
+
+	var idleSince int64
+	if idleTimeout > 0 {
+		idleSince = now.Add(-idleTimeout).Unix()
+	}
+	if n, err := repository.DeleteExpiredSessions(ctx, s.db, now.Unix(), idleSince); err != nil {
+		log.Warnf("removing expired sessions failed: %v", err)
+	} else if n > 0 {
+		log.Debugf("removed %d expired sessions", n)
+	}
+
+	if err := repository.CreateSession(ctx, s.db, session); err != nil {
+		return "", nil, err
+	}
+	return token, session, nil
+}
+
+// Lookup returns the session of token and records the activity. It returns
+// ErrSessionEnded if there is no such session anymore or it was inactive
+// for longer than idleTimeout, if not zero.
+func (s *SessionStore) Lookup(ctx context.Context, token string, idleTimeout time.Duration) (*repository.Session, error) {
+	session, err := repository.GetSessionByToken(ctx, s.db, hashSessionToken(token))
+	if err == sql.ErrNoRows {
+		return nil, ErrSessionEnded
+	} else if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if (session.ExpiresAt != 0 && now.Unix() >= session.ExpiresAt) ||
+		(idleTimeout > 0 && now.Sub(time.Unix(session.LastActivity, 0)) > idleTimeout) {
+		if err := repository.DeleteSession(ctx, s.db, session.ID); err != nil && err != sql.ErrNoRows {
+			log.Warnf("removing expired session failed: %v", err)
+		}
+		return nil, ErrSessionEnded
+	}
+
+	if now.Sub(time.Unix(session.LastActivity, 0)) >= sessionTouchInterval {
+		if err := repository.TouchSession(ctx, s.db, session.ID, now.Unix()); err != nil {
+			log.Warnf("updating activity of session failed: %v", err)
+		}
+		session.LastActivity = now.Unix()
+	}
+	return session, nil
+}
+
+// End removes the session of token, if it still exists.
+func (s *SessionStore) End(ctx context.Context, token string) error {
+	session, err := repository.GetSessionByToken(ctx, s.db, hashSessionToken(token))
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	err = repository.DeleteSession(ctx, s.db, session.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,155 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/sessions"
+)
+
+func TestSessions(t *testing.T) {
+	ur := setupUserRepository(t)
+	if err := ur.AddUser(&schema.User{
+		Username: "bob", Password: "bob-pw",
+		Roles: []string{"user"}, AuthSource: schema.AuthViaLocalPassword,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	db := repository.GetConnection().DB.DB
+	authentication := &Authentication{
+		sessionStore:       sessions.NewCookieStore([]byte(strings.Repeat("k", 32))),
+		Sessions:           NewSessionStore(db),
+		SessionMaxAge:      time.Hour,
+		SessionIdleTimeout: time.Hour,
+	}
+
+	login := func() *http.Request {
+		t.Helper()
+		user, err := ur.GetUser("bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		rw := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/login", nil)
+		req.Header.Set("User-Agent", "test-agent")
+		if err := authentication.SaveSession(rw, req, user); err != nil {
+			t.Fatal(err)
+		}
+		req = httptest.NewRequest("GET", "/", nil)
+		for _, c := range rw.Result().Cookies() {
+			req.AddCookie(c)
+		}
+		return req
+	}
+	sessionUser := func(req *http.Request) *schema.User {
+		t.Helper()
+		user, err := authentication.AuthViaSession(httptest.NewRecorder(), req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return user
+	}
+
+	req := login()
+	user := sessionUser(req)
+	if user == nil || user.Username != "bob" || user.AuthType != schema.AuthSession {
+		t.Fatalf("unexpected session user: %+v", user)
+	}
+	list, err := repository.ListSessions(context.Background(), db, "bob")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("expected one stored session, got %v, %v", list, err)
+	}
+	if s := list[0]; s.UserAgent != "test-agent" || s.ExpiresAt == 0 || s.TokenHash == "" {
+		t.Errorf("unexpected stored session: %+v", s)
+	}
+	if c := req.Header.Get("Cookie"); strings.Contains(c, list[0].TokenHash) {
+		t.Errorf("cookie carries the token hash")
+	}
+
+	// Role changes apply without logging in again
+	if err := ur.AddRole(context.Background(), "bob", schema.GetRoleString(schema.RoleSupport)); err != nil {
+		t.Fatal(err)
+	}
+	if user := sessionUser(req); user == nil || !reflect.DeepEqual(user.Roles, []string{"user", "support"}) {
+		t.Errorf("role change not applied: %+v", user)
+	}
+
+	// Sessions inactive for too long end
+	if _, err := db.Exec("UPDATE sessions SET last_activity = ?", time.Now().Add(-2*time.Hour).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if user := sessionUser(req); user != nil {
+		t.Errorf("idle session still valid")
+	}
+
+	// Sessions end after the maximum age, even when active
+	req = login()
+	if _, err := db.Exec("UPDATE sessions SET expires_at = ?", time.Now().Add(-time.Second).Unix()); err != nil {
+		t.Fatal(err)
+	}
+	if user := sessionUser(req); user != nil {
+		t.Errorf("expired session still valid")
+	}
+	if list, _ := repository.ListSessions(context.Background(), db, ""); len(list) != 0 {
+		t.Errorf("ended sessions not removed: %v", list)
+	}
+
+	// Logout ends the session also for copies of the cookie
+	req = login()
+	rw := httptest.NewRecorder()
+	authentication.Logout(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).ServeHTTP(rw, req)
+	if user := sessionUser(req); user != nil {
+		t.Errorf("session still valid after logout")
+	}
+
+	// Websocket connections authenticate with the cookie of the upgrade
+	// request until the session ends
+	req = login()
+	var upgraded context.Context
+	authentication.WebsocketUpgrade(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		upgraded = r.Context()
+	})).ServeHTTP(httptest.NewRecorder(), req)
+	if user, err := authentication.WebsocketUser(upgraded); err != nil || user == nil || user.Username != "bob" {
+		t.Fatalf("unexpected websocket user: %+v, %v", user, err)
+	}
+	authentication.Logout(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {})).ServeHTTP(httptest.NewRecorder(), req)
+	if user, err := authentication.WebsocketUser(upgraded); err != nil || user != nil {
+		t.Errorf("websocket user still valid after logout: %+v, %v", user, err)
+	}
+	if user, err := authentication.WebsocketUser(context.Background()); err != nil || user != nil {
+		t.Errorf("expected no websocket user without upgrade request, got %+v, %v", user, err)
+	}
+
+	// Disabling the user ends its sessions
+	req = login()
+	if _, err := db.Exec("UPDATE user SET disabled = 1 WHERE username = 'bob'"); err != nil {
+		t.Fatal(err)
+	}
+	if user := sessionUser(req); user != nil {
+		t.Errorf("session of disabled user still valid")
+	}
+	if _, err := db.Exec("UPDATE user SET disabled = 0 WHERE username = 'bob'"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Deleting the user ends its sessions
+	req = login()
+	if err := ur.DelUser("bob"); err != nil {
+		t.Fatal(err)
+	}
+	if user := sessionUser(req); user != nil {
+		t.Errorf("session of deleted user still valid")
+	}
+}
@@ -0,0 +1,405 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	sq "github.com/Masterminds/squirrel"
+)
+
+var (
+	ErrTOTPRequired    = errors.New("authentication code required")
+	ErrTOTPInvalid     = errors.New("invalid authentication code")
+	ErrTOTPNotEnrolled = errors.New("two-factor authentication is not set up")
+	ErrTOTPEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrTOTPUnsupported = errors.New("this account requires two-factor authentication, please log in with username and password")
+)
+
+const (
+	totpDigits        = 6
+	totpPeriod        = 30 // seconds
+	totpSkew          = 1  // steps accepted before and after the current one
+	totpSecretSize    = 20
+	totpRecoveryCodes = 10
+	totpDefaultIssuer = "ClusterCockpit"
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment is handed to a user to set up an authenticator app, either
+// by entering the secret or by scanning the URI as QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPEnrollmentError is returned by the login of a user who has to set up
+// a second factor before logging in.
+type TOTPEnrollmentError struct {
+	Enrollment *TOTPEnrollment
+	Err        error // why the last code was rejected, nil if none was given
+}
+
+func (e *TOTPEnrollmentError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return "two-factor authentication has to be set up for this account"
+}
+
+func (e *TOTPEnrollmentError) Unwrap() error {
+	if e.Err != nil {
+		return e.Err
+	}
+	return ErrTOTPRequired
+}
+
+// TOTP manages time-based one-time passwords (RFC 6238) as second factor of
+// local and LDAP accounts. Secrets are encrypted with the secrets key if one is
+// configured. Recovery codes are only stored as hashes.
+type TOTP struct {
+	db            *sql.DB
+	issuer        string
+	requiredRoles []string
+}
+
+func NewTOTP(db *sql.DB, conf *schema.TOTPConfig) (*TOTP, error) {
+	if conf == nil {
+		conf = &schema.TOTPConfig{}
+	}
+	t := &TOTP{db: db, issuer: conf.Issuer, requiredRoles: conf.RequiredRoles}
+	if t.issuer == "" {
+		t.issuer = totpDefaultIssuer
+	}
+	for _, role := range t.requiredRoles {
+		if !schema.IsValidRole(role) {
+			return nil, fmt.Errorf("invalid role '%s' in totp.required-roles", role)
+		}
+	}
+	return t, nil
+}
+
+// Required reports whether user has to use a second factor.
+func (t *TOTP) Required(user *schema.User) bool {
+	for _, role := range t.requiredRoles {
+		if hasRole, _ := user.HasValidRole(role); hasRole {
+			return true
+		}
+	}
+	return false
+}
+
+// SupportsTOTP reports whether user logs in with a password checked by
+// cc-backend, after which the second factor is asked for. Logins via OIDC
+// or JWT are authenticated by another service.
+func SupportsTOTP(user *schema.User) bool {
+	return user.AuthSource == schema.AuthViaLocalPassword || user.AuthSource == schema.AuthViaLDAP
+}
+
+// CheckExternalLogin rejects logins that cannot ask for the second factor
+// (see SupportsTOTP) of users who have it enabled or are required to use it.
+func (t *TOTP) CheckExternalLogin(ctx context.Context, user *schema.User) error {
+	if t.Required(user) {
+		return ErrTOTPUnsupported
+	}
+	tt, err := repository.GetUserTOTP(ctx, t.db, user.Username)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if tt.Enabled {
+		return ErrTOTPUnsupported
+	}
+	return nil
+}
+
+// totpCode computes the HOTP value (RFC 4226) of key for a time step.
+func totpCode(key []byte, step int64) string {
+	mac := hmac.New(sha1.New, key)
+	binary.Write(mac, binary.BigEndian, step)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// matchTOTP returns the time step code is valid for at now, or 0 if it is
+// not valid. Steps up to lastStep were used before and are not accepted.
+func matchTOTP(key []byte, code string, now time.Time, lastStep int64) int64 {
+	code = strings.ReplaceAll(code, " ", "")
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step > lastStep && hmac.Equal([]byte(totpCode(key, step)), []byte(code)) {
+			return step
+		}
+	}
+	return 0
+}
+
+func (t *TOTP) uri(username, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", t.issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	label := url.PathEscape(t.issuer) + ":" + url.PathEscape(username)
+	return "otpauth://totp/" + label + "?" + v.Encode()
+}
+
+func sealName(username string) string {
+	return "totp:" + username
+}
+
+func (t *TOTP) seal(username, secret string) (string, bool, error) {
+	p := secrets.DB()
+	if p == nil {
+		return secret, false, nil
+	}
+	sealed, err := p.Seal(sealName(username), secret)
+	return sealed, err == nil, err
+}
+
+// key returns the decoded secret of tt.
+func (t *TOTP) key(tt *repository.UserTOTP) ([]byte, error) {
+	secret := tt.Secret
+	if tt.Encrypted {
+		p := secrets.DB()
+		if p == nil {
+			return nil, errors.New("TOTP secret is encrypted, but no secrets key is configured")
+		}
+		var err error
+		if secret, err = p.Open(sealName(tt.Username), secret); err != nil {
+			return nil, err
+		}
+	}
+	return totpEncoding.DecodeString(secret)
+}
+
+func hashRecoveryCode(code string) string {
+	code = strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRecoveryCodes returns fresh recovery codes and their hashes.
+func newRecoveryCodes() (codes, hashes []string, err error) {
+	for i := 0; i < totpRecoveryCodes; i++ {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		code := totpEncoding.EncodeToString(b)[:10]
+		codes = append(codes, code[:5]+"-"+code[5:])
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// Get returns sql.ErrNoRows if username has no second factor.
+func (t *TOTP) Get(ctx context.Context, runner sq.BaseRunner, username string) (*repository.UserTOTP, error) {
+	return repository.GetUserTOTP(ctx, runner, username)
+}
+
+// Enroll creates a new secret for username, replacing one not confirmed
+// yet. It is enabled once Confirm was called with a valid code.
+func (t *TOTP) Enroll(ctx context.Context, runner sq.BaseRunner, username string) (*TOTPEnrollment, error) {
+	tt, err := repository.GetUserTOTP(ctx, runner, username)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err == nil && tt.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	b := make([]byte, totpSecretSize)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	secret := totpEncoding.EncodeToString(b)
+	sealed, encrypted, err := t.seal(username, secret)
+	if err != nil {
+		return nil, err
+	}
+	if err := repository.SaveUserTOTP(ctx, runner, &repository.UserTOTP{
+		Username:  username,
+		Secret:    sealed,
+		Encrypted: encrypted,
+		CreatedAt: time.Now().Unix(),
+	}); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{Secret: secret, URI: t.uri(username, secret)}, nil
+}
+
+// pendingEnrollment returns the enrollment of username not confirmed yet,
+// or starts one.
+func (t *TOTP) pendingEnrollment(ctx context.Context, runner sq.BaseRunner, username string) (*TOTPEnrollment, error) {
+	tt, err := repository.GetUserTOTP(ctx, runner, username)
+	if err == sql.ErrNoRows {
+		return t.Enroll(ctx, runner, username)
+	} else if err != nil {
+		return nil, err
+	}
+	if tt.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	key, err := t.key(tt)
+	if err != nil {
+		return nil, err
+	}
+	secret := totpEncoding.EncodeToString(key)
+	return &TOTPEnrollment{Secret: secret, URI: t.uri(username, secret)}, nil
+}
+
+// Confirm enables the second factor of username if code is valid for the
+// enrolled secret. It returns the recovery codes, which are not stored.
+func (t *TOTP) Confirm(ctx context.Context, runner sq.BaseRunner, username, code string) ([]string, error) {
+	tt, err := repository.GetUserTOTP(ctx, runner, username)
+	if err == sql.ErrNoRows {
+		return nil, ErrTOTPNotEnrolled
+	} else if err != nil {
+		return nil, err
+	}
+	if tt.Enabled {
+		return nil, ErrTOTPEnabled
+	}
+
+	key, err := t.key(tt)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	step := matchTOTP(key, code, now, tt.LastStep)
+	if step == 0 {
+		return nil, ErrTOTPInvalid
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tt.Enabled, tt.EnabledAt = true, now.Unix()
+	tt.LastStep, tt.RecoveryCodes = step, hashes
+	if err := repository.SaveUserTOTP(ctx, runner, tt); err != nil {
+		return nil, err
+	}
+	log.Infof("two-factor authentication enabled for user '%s'", username)
+	return codes, nil
+}
+
+// Verify checks code, either a TOTP or an unused recovery code, for the
+// enabled second factor of username. Each code is only accepted once.
+func (t *TOTP) Verify(ctx context.Context, runner sq.BaseRunner, username, code string) error {
+	tt, err := repository.GetUserTOTP(ctx, runner, username)
+	if err == sql.ErrNoRows || (err == nil && !tt.Enabled) {
+		return ErrTOTPNotEnrolled
+	} else if err != nil {
+		return err
+	}
+
+	key, err := t.key(tt)
+	if err != nil {
+		return err
+	}
+	if step := matchTOTP(key, code, time.Now(), tt.LastStep); step != 0 {
+		ok, err := repository.AdvanceUserTOTPStep(ctx, runner, username, step)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrTOTPInvalid
+		}
+		return nil
+	}
+
+	return t.useRecoveryCode(ctx, runner, tt, code)
+}
+
+// useRecoveryCode removes code from the recovery codes of tt. The codes are
+// only replaced if unchanged since they were read, so that a code used by
+// concurrent logins is only accepted for one of them.
+func (t *TOTP) useRecoveryCode(ctx context.Context, runner sq.BaseRunner, tt *repository.UserTOTP, code string) error {
+	hash := hashRecoveryCode(code)
+	for attempt := 0; attempt < totpRecoveryCodes; attempt++ {
+		i := -1
+		for j, h := range tt.RecoveryCodes {
+			if hmac.Equal([]byte(h), []byte(hash)) {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return ErrTOTPInvalid
+		}
+
+		remaining := append(append([]string{}, tt.RecoveryCodes[:i]...), tt.RecoveryCodes[i+1:]...)
+		ok, err := repository.ConsumeUserTOTPRecoveryCodes(ctx, runner, tt.Username, tt.RecoveryCodes, remaining)
+		if err != nil {
+			return err
+		}
+		if ok {
+			log.Warnf("user '%s' used a recovery code, %d left", tt.Username, len(remaining))
+			return nil
+		}
+
+		// Another code was used meanwhile, check against the current ones
+		if tt, err = repository.GetUserTOTP(ctx, runner, tt.Username); err == sql.ErrNoRows || (err == nil && !tt.Enabled) {
+			return ErrTOTPNotEnrolled
+		} else if err != nil {
+			return err
+		}
+	}
+	return ErrTOTPInvalid
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of username.
+func (t *TOTP) RegenerateRecoveryCodes(ctx context.Context, runner sq.BaseRunner, username string) ([]string, error) {
+	tt, err := repository.GetUserTOTP(ctx, runner, username)
+	if err == sql.ErrNoRows || (err == nil && !tt.Enabled) {
+		return nil, ErrTOTPNotEnrolled
+	} else if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	tt.RecoveryCodes = hashes
+	return codes, repository.SaveUserTOTP(ctx, runner, tt)
+}
+
+// Disable removes the second factor of username.
+func (t *TOTP) Disable(ctx context.Context, runner sq.BaseRunner, username string) error {
+	err := repository.DeleteUserTOTP(ctx, runner, username)
+	if err == sql.ErrNoRows {
+		return ErrTOTPNotEnrolled
+	}
+	if err == nil {
+		log.Infof("two-factor authentication disabled for user '%s'", username)
+	}
+	return err
+}
@@ -0,0 +1,260 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/sessions"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// Test vectors of RFC 6238 (SHA1), truncated to 6 digits
+	key := []byte("12345678901234567890")
+	for _, tc := range []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	} {
+		if code := totpCode(key, tc.time/totpPeriod); code != tc.code {
+			t.Errorf("time %d: expected code %s, got %s", tc.time, tc.code, code)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	if step := matchTOTP(key, "005924", now, 0); step != 1234567890/totpPeriod {
+		t.Errorf("current code not accepted")
+	}
+	if step := matchTOTP(key, totpCode(key, 1234567890/totpPeriod-1), now, 0); step == 0 {
+		t.Errorf("code of previous step not accepted")
+	}
+	if step := matchTOTP(key, totpCode(key, 1234567890/totpPeriod-2), now, 0); step != 0 {
+		t.Errorf("outdated code accepted")
+	}
+	if step := matchTOTP(key, "005924", now, 1234567890/totpPeriod); step != 0 {
+		t.Errorf("code accepted twice")
+	}
+}
+
+func TestTOTPLogin(t *testing.T) {
+	ur := setupUserRepository(t)
+	for _, user := range []*schema.User{
+		{Username: "alice", Password: "alice-pw", Roles: []string{"admin"}, AuthSource: schema.AuthViaLocalPassword},
+		{Username: "bob", Password: "bob-pw", Roles: []string{"user"}, AuthSource: schema.AuthViaLocalPassword},
+	} {
+		if err := ur.AddUser(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db := repository.GetConnection().DB.DB
+	totp, err := NewTOTP(db, &schema.TOTPConfig{RequiredRoles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authentication := &Authentication{
+		sessionStore:   sessions.NewCookieStore([]byte(strings.Repeat("k", 32))),
+		Sessions:       NewSessionStore(db),
+		authenticators: []Authenticator{&LocalAuthenticator{}},
+		TOTP:           totp,
+	}
+
+	var loginErr error
+	var recoveryCodes []string
+	handler := authentication.Login(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			loginErr, recoveryCodes = nil, RecoveryCodesFromContext(r.Context())
+		}),
+		func(rw http.ResponseWriter, r *http.Request, err error) {
+			loginErr = err
+			rw.WriteHeader(http.StatusUnauthorized)
+		})
+
+	var cookies []*http.Cookie
+	login := func(form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		for _, c := range rw.Result().Cookies() {
+			if c.Name == pendingLoginName {
+				cookies = []*http.Cookie{c}
+			}
+		}
+		return rw
+	}
+
+	// Users without second factor are not asked for one
+	if rw := login(url.Values{"username": {"bob"}, "password": {"bob-pw"}}); rw.Code != http.StatusOK || loginErr != nil {
+		t.Fatalf("login without second factor failed: %v", loginErr)
+	}
+
+	// Admins have to set it up first
+	login(url.Values{"username": {"alice"}, "password": {"alice-pw"}})
+	var enrollErr *TOTPEnrollmentError
+	if !errors.As(loginErr, &enrollErr) || !errors.Is(loginErr, ErrTOTPRequired) {
+		t.Fatalf("expected enrollment to be required, got %v", loginErr)
+	}
+	if !strings.HasPrefix(enrollErr.Enrollment.URI, "otpauth://totp/ClusterCockpit:alice?") ||
+		!strings.Contains(enrollErr.Enrollment.URI, "secret="+enrollErr.Enrollment.Secret) {
+		t.Errorf("unexpected provisioning URI: %s", enrollErr.Enrollment.URI)
+	}
+	key, err := totpEncoding.DecodeString(enrollErr.Enrollment.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := time.Now().Unix() / totpPeriod
+
+	login(url.Values{"otp": {"000000"}})
+	if !errors.As(loginErr, &enrollErr) || !errors.Is(loginErr, ErrTOTPInvalid) {
+		t.Fatalf("expected invalid code during enrollment, got %v", loginErr)
+	}
+	if secret, _ := totpEncoding.DecodeString(enrollErr.Enrollment.Secret); string(secret) != string(key) {
+		t.Errorf("secret changed after wrong code")
+	}
+
+	if login(url.Values{"otp": {totpCode(key, step)}}); loginErr != nil {
+		t.Fatalf("enrollment failed: %v", loginErr)
+	}
+	if len(recoveryCodes) != totpRecoveryCodes {
+		t.Fatalf("expected %d recovery codes, got %v", totpRecoveryCodes, recoveryCodes)
+	}
+	recovery, codes := recoveryCodes[0], recoveryCodes
+
+	// Enrolled: password, then code
+	cookies = nil
+	if login(url.Values{"username": {"alice"}, "password": {"alice-pw"}}); !errors.Is(loginErr, ErrTOTPRequired) {
+		t.Fatalf("expected code to be required, got %v", loginErr)
+	}
+	if login(url.Values{"otp": {totpCode(key, step)}}); !errors.Is(loginErr, ErrTOTPInvalid) {
+		t.Errorf("code used twice, got %v", loginErr)
+	}
+	if login(url.Values{"otp": {totpCode(key, step+1)}}); loginErr != nil {
+		t.Errorf("login with code failed: %v", loginErr)
+	}
+
+	// A code alone is not enough without pending login
+	cookies = nil
+	if login(url.Values{"otp": {recovery}}); loginErr == nil {
+		t.Errorf("code accepted without password")
+	}
+
+	// Password and recovery code at once, each code only once
+	cookies = nil
+	if login(url.Values{"username": {"alice"}, "password": {"alice-pw"}, "otp": {strings.ToLower(recovery)}}); loginErr != nil {
+		t.Errorf("login with recovery code failed: %v", loginErr)
+	}
+	cookies = nil
+	if login(url.Values{"username": {"alice"}, "password": {"alice-pw"}, "otp": {recovery}}); !errors.Is(loginErr, ErrTOTPInvalid) {
+		t.Errorf("recovery code used twice, got %v", loginErr)
+	}
+
+	tt, err := totp.Get(context.Background(), db, "alice")
+	if err != nil || !tt.Enabled || len(tt.RecoveryCodes) != totpRecoveryCodes-1 || tt.Encrypted {
+		t.Errorf("unexpected stored second factor: %+v, %v", tt, err)
+	}
+
+	// A login that read the codes before a concurrent login used the same
+	// code is rejected, other codes are checked against the current ones
+	if err := totp.Verify(context.Background(), db, "alice", codes[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := totp.useRecoveryCode(context.Background(), db, tt, codes[1]); !errors.Is(err, ErrTOTPInvalid) {
+		t.Errorf("recovery code used twice concurrently, got %v", err)
+	}
+	if err := totp.useRecoveryCode(context.Background(), db, tt, codes[2]); err != nil {
+		t.Errorf("recovery code rejected after concurrent use of another one: %v", err)
+	}
+	if tt, _ := totp.Get(context.Background(), db, "alice"); len(tt.RecoveryCodes) != totpRecoveryCodes-3 {
+		t.Errorf("expected %d recovery codes left, got %d", totpRecoveryCodes-3, len(tt.RecoveryCodes))
+	}
+
+	// Logins that cannot ask for the code are rejected if one is needed
+	authentication.authenticators = []Authenticator{externalAuthenticator{}}
+	cookies = nil
+	if login(url.Values{"username": {"alice"}}); !errors.Is(loginErr, ErrTOTPUnsupported) {
+		t.Errorf("expected external login of alice to be rejected, got %v", loginErr)
+	}
+	if login(url.Values{"username": {"bob"}}); loginErr != nil {
+		t.Errorf("external login of bob failed: %v", loginErr)
+	}
+}
+
+// externalAuthenticator accepts every user, like logins with a token of
+// another service.
+type externalAuthenticator struct{}
+
+func (externalAuthenticator) CanLogin(user *schema.User, username string, rw http.ResponseWriter, r *http.Request) (*schema.User, bool) {
+	return user, user != nil
+}
+
+func (externalAuthenticator) Login(user *schema.User, rw http.ResponseWriter, r *http.Request) (*schema.User, error) {
+	return &schema.User{Username: user.Username, Roles: user.Roles, AuthSource: schema.AuthViaToken}, nil
+}
+
+func TestTOTPLDAPLogin(t *testing.T) {
+	ur := setupUserRepository(t)
+	if err := ur.AddUser(&schema.User{Username: "carol", Roles: []string{"admin"}, AuthSource: schema.AuthViaLDAP}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := newMockLdapServer(t, ldapTestEntries(), nil)
+	ldapConfig := config.Keys.LdapConfig
+	config.Keys.LdapConfig = &schema.LdapConfig{
+		Url:        schema.LdapURLs{server.URL()},
+		UserBase:   "ou=people," + ldapTestBase,
+		SearchDN:   "cn=admin," + ldapTestBase,
+		UserBind:   "uid={username},ou=people," + ldapTestBase,
+		UserFilter: "(objectClass=posixAccount)",
+	}
+	t.Cleanup(func() { config.Keys.LdapConfig = ldapConfig })
+	t.Setenv("LDAP_ADMIN_PASSWORD", "admin-pw")
+	la := &LdapAuthenticator{}
+	if err := la.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	db := repository.GetConnection().DB.DB
+	totp, err := NewTOTP(db, &schema.TOTPConfig{RequiredRoles: []string{"admin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	authentication := &Authentication{
+		sessionStore:   sessions.NewCookieStore([]byte(strings.Repeat("k", 32))),
+		Sessions:       NewSessionStore(db),
+		authenticators: []Authenticator{la},
+		TOTP:           totp,
+	}
+
+	// The second factor is asked for after the LDAP password, like for local users
+	var loginErr error
+	handler := authentication.Login(
+		http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) { loginErr = nil }),
+		func(rw http.ResponseWriter, r *http.Request, err error) { loginErr = err })
+	req := httptest.NewRequest("POST", "/login", strings.NewReader(url.Values{"username": {"carol"}, "password": {"carol-pw"}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	var enrollErr *TOTPEnrollmentError
+	if !errors.As(loginErr, &enrollErr) {
+		t.Errorf("expected LDAP user to set up the second factor, got %v", loginErr)
+	}
+}
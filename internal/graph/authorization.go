@@ -0,0 +1,110 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// fieldRoutes maps the root fields of the schema to the REST route they
+// correspond to. Scoped tokens need the scope of that route (see
+// auth.RouteScope), fields not listed here require the admin scope.
+var fieldRoutes = map[string]string{
+	"Query.machines":                    "GET /api/machines",
+	"Query.machine":                     "GET /api/machine/{machine_id}",
+	"Query.notifications":               "GET /api/notifications",
+	"Mutation.createMachine":            "POST /api/machine",
+	"Mutation.updateMachine":            "PUT /api/machine/{machine_id}",
+	"Mutation.saveLvmConf":              "PUT /api/machine/{machine_id}",
+	"Mutation.saveLvStorageIssuer":      "PUT /api/lv_storage_issuer/{id}",
+	"Mutation.acknowledgeNotification":  "DELETE /api/notifications/{id}",
+	"Mutation.requestLV":                "PUT /api/machine/{machine_id}",
+	"Subscription.realtimeLogs":         "GET /api/realtime_logs",
+	"Subscription.notifications":        "GET /api/notifications",
+	"Subscription.machineStatusChanged": "GET /api/machines",
+}
+
+// checkScope rejects users authenticated with a token that lacks the scope
+// of the root field in ctx, like the REST API does for its routes.
+func checkScope(ctx context.Context) error {
+	user := repository.GetUserFromContext(ctx)
+	if user == nil || user.AuthType != schema.AuthToken {
+		return nil
+	}
+
+	field := ""
+	if fc := graphql.GetFieldContext(ctx); fc != nil {
+		for fc.Parent != nil && fc.Parent.Field.Field != nil {
+			fc = fc.Parent
+		}
+		field = fc.Object + "." + fc.Field.Name
+	}
+	scope := auth.RouteScope(fieldRoutes[field])
+	if !user.HasScope(scope) {
+		log.Warnf("access denied: token of user '%s' (scopes %v) lacks scope '%s' for GraphQL field %s",
+			user.Username, user.Scopes, scope, field)
+		return forbidden(ctx)
+	}
+	return nil
+}
+
+// authorize checks the user in ctx against the same policies and token
+// scopes as the REST API. For policies admitting managers, machineID is the
+// machine the access targets; it may be empty for resources not bound to a
+// machine.
+func (r *Resolver) authorize(ctx context.Context, policy auth.AccessPolicy, machineID string) error {
+	if err := checkScope(ctx); err != nil {
+		return err
+	}
+	allowed, err := r.allows(ctx, policy, machineID)
+	if err != nil {
+		return storageError(ctx, err)
+	}
+	if !allowed {
+		if user := repository.GetUserFromContext(ctx); user != nil {
+			log.Warnf("access denied: user '%s' (roles %v) on GraphQL field %s", user.Username, user.Roles, graphql.GetPath(ctx))
+		}
+		return forbidden(ctx)
+	}
+	return nil
+}
+
+// allows is like authorize, but neither logs denials nor maps errors.
+func (r *Resolver) allows(ctx context.Context, policy auth.AccessPolicy, machineID string) (bool, error) {
+	var machine func() (string, error)
+	if machineID != "" {
+		machine = func() (string, error) { return machineID, nil }
+	}
+	machineGroups := func(machineID string) ([]string, error) {
+		groups, err := repository.LoadMachineGroups(ctx, r.DB, []string{machineID})
+		return groups[machineID], err
+	}
+	return policy.Allows(repository.GetUserFromContext(ctx), machine, machineGroups)
+}
+
+// visibleMachines returns a filter for the machines the user may read
+// according to auth.PolicyMachineRead: all machines for admins and support,
+// and for managers the machines in a machine group named after one of their
+// projects.
+func visibleMachines(ctx context.Context) (repository.MachineFilter, error) {
+	policy := auth.PolicyMachineRead
+	user := repository.GetUserFromContext(ctx)
+	switch {
+	case user == nil:
+		return repository.MachineFilter{}, forbidden(ctx)
+	case user.HasAnyRole(policy.Roles):
+		return repository.MachineFilter{}, nil
+	case policy.Managers && user.HasRole(schema.RoleManager):
+		return repository.MachineFilter{Groups: append([]string{}, user.Projects...)}, nil
+	default:
+		return repository.MachineFilter{}, forbidden(ctx)
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Error codes returned in the "code" extension of GraphQL errors, so that
+// clients can tell them apart without parsing messages. They correspond to
+// the HTTP status codes of the REST API.
+const (
+	CodeForbidden    = "FORBIDDEN"      // 403
+	CodeNotFound     = "NOT_FOUND"      // 404
+	CodeBadUserInput = "BAD_USER_INPUT" // 400
+	CodeInternal     = "INTERNAL_ERROR" // 500
+)
+
+func newError(ctx context.Context, code, format string, args ...interface{}) *gqlerror.Error {
+	return &gqlerror.Error{
+		Path:       graphql.GetPath(ctx),
+		Message:    fmt.Sprintf(format, args...),
+		Extensions: map[string]interface{}{"code": code},
+	}
+}
+
+func forbidden(ctx context.Context) *gqlerror.Error {
+	return newError(ctx, CodeForbidden, "forbidden")
+}
+
+func badUserInput(ctx context.Context, format string, args ...interface{}) *gqlerror.Error {
+	return newError(ctx, CodeBadUserInput, format, args...)
+}
+
+// storageError maps errors of the repository to typed errors.
+func storageError(ctx context.Context, err error) error {
+	var gqlErr *gqlerror.Error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &gqlErr):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return newError(ctx, CodeNotFound, "not found")
+	default:
+		return newError(ctx, CodeInternal, "%s", err.Error())
+	}
+}
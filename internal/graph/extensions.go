@@ -0,0 +1,203 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/complexity"
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Error codes of operations rejected by Limits.
+const (
+	CodeComplexityLimit = "COMPLEXITY_LIMIT_EXCEEDED"
+	CodeDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+)
+
+var (
+	resolverDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cc",
+		Subsystem: "graphql",
+		Name:      "resolver_duration_seconds",
+		Help:      "Time spent in GraphQL resolvers by object and field.",
+		Buckets:   []float64{.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"object", "field"})
+
+	resolverErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cc",
+		Subsystem: "graphql",
+		Name:      "resolver_errors_total",
+		Help:      "GraphQL resolvers that returned an error by object and field.",
+	}, []string{"object", "field"})
+
+	rejectedOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cc",
+		Subsystem: "graphql",
+		Name:      "rejected_operations_total",
+		Help:      "GraphQL operations rejected for exceeding a limit.",
+	}, []string{"limit"})
+)
+
+// Limits rejects operations that are too complex or nested too deeply, so
+// that a single query cannot take down the server. Both are checked after
+// validation and before any resolver runs.
+type Limits struct {
+	MaxComplexity int
+	MaxDepth      int
+
+	es graphql.ExecutableSchema
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = &Limits{}
+
+func (l *Limits) ExtensionName() string {
+	return "Limits"
+}
+
+func (l *Limits) Validate(schema graphql.ExecutableSchema) error {
+	if l.MaxComplexity <= 0 || l.MaxDepth <= 0 {
+		return errors.New("the limits have to be positive")
+	}
+	l.es = schema
+	return nil
+}
+
+func (l *Limits) MutateOperationContext(ctx context.Context, rc *graphql.OperationContext) *gqlerror.Error {
+	op := rc.Doc.Operations.ForName(rc.OperationName)
+	if op == nil {
+		// Reported by the executor
+		return nil
+	}
+
+	if depth := selectionDepth(op.SelectionSet, l.MaxDepth); depth > l.MaxDepth {
+		rejectedOperations.WithLabelValues("depth").Inc()
+		err := gqlerror.Errorf("operation exceeds the depth limit of %d", l.MaxDepth)
+		errcode.Set(err, CodeDepthLimit)
+		return err
+	}
+
+	if c := complexity.Calculate(l.es, op, rc.Variables); c > l.MaxComplexity {
+		rejectedOperations.WithLabelValues("complexity").Inc()
+		err := gqlerror.Errorf("operation has complexity %d, which exceeds the limit of %d", c, l.MaxComplexity)
+		errcode.Set(err, CodeComplexityLimit)
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns the depth of the selection set, fragments do not
+// add to it. Introspection fields are not counted as the schema is nested
+// deeply by nature. Once limit is exceeded the walk stops early.
+func selectionDepth(set ast.SelectionSet, limit int) int {
+	depth := 0
+	for _, sel := range set {
+		var d int
+		switch s := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(s.Name, "__") {
+				continue
+			}
+			d = 1
+			if len(s.SelectionSet) > 0 && limit > 0 {
+				d += selectionDepth(s.SelectionSet, limit-1)
+			}
+		case *ast.InlineFragment:
+			d = selectionDepth(s.SelectionSet, limit)
+		case *ast.FragmentSpread:
+			if s.Definition != nil {
+				d = selectionDepth(s.Definition.SelectionSet, limit)
+			}
+		}
+		depth = max(depth, d)
+		if depth > limit {
+			break
+		}
+	}
+	return depth
+}
+
+// ResolverMetrics times all fields with a resolver and counts their errors.
+// Plain struct fields are not timed.
+type ResolverMetrics struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = ResolverMetrics{}
+
+func (ResolverMetrics) ExtensionName() string {
+	return "ResolverMetrics"
+}
+
+func (ResolverMetrics) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (ResolverMetrics) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	start := time.Now()
+	res, err := next(ctx)
+	resolverDuration.WithLabelValues(fc.Object, fc.Field.Name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		resolverErrors.WithLabelValues(fc.Object, fc.Field.Name).Inc()
+	}
+	return res, err
+}
+
+var tracer = otel.Tracer("github.com/Deepbinder-main/cc-backend/internal/graph")
+
+// ResolverTracing starts a span for all fields with a resolver, so that
+// their SQL queries and metric data requests show up below it.
+type ResolverTracing struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.FieldInterceptor
+} = ResolverTracing{}
+
+func (ResolverTracing) ExtensionName() string {
+	return "ResolverTracing"
+}
+
+func (ResolverTracing) Validate(schema graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (ResolverTracing) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := tracer.Start(ctx, fc.Object+"."+fc.Field.Name, trace.WithAttributes(
+		attribute.String("graphql.field.path", fc.Path().String())))
+	defer span.End()
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"sync"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+type loadersKey struct{}
+
+// loaders batch the nested fields of machines within one GraphQL response.
+// Machines returned by a query are registered with the loaders, and the
+// first time a nested field is resolved for one of them, that field is
+// loaded for all registered machines with a single query. A list of
+// machines with nested volumes thus costs a constant number of queries.
+type loaders struct {
+	db *sqlx.DB
+
+	mu         sync.Mutex
+	machineIDs []string
+	logs       map[int]*batch[[]*model.RealtimeLog]                 // By limit
+	lvRequests map[model.LVRequestStatus]*batch[[]*model.LVRequest] // By status, "" for all
+
+	groups batch[[]string]
+	pvs    batch[[]*model.PhysicalVolume]
+	vgs    batch[[]*model.VolumeGroup]
+	lvs    batch[[]*model.LogicalVolume]
+	confs  batch[*model.MachineConf]
+
+	lvmConfs batch[*model.LvmConf]
+	issuers  batch[[]*model.LvStorageIssuer]
+}
+
+func newLoaders(db *sqlx.DB) *loaders {
+	l := &loaders{
+		db:         db,
+		logs:       make(map[int]*batch[[]*model.RealtimeLog]),
+		lvRequests: make(map[model.LVRequestStatus]*batch[[]*model.LVRequest]),
+	}
+	l.groups.fetch = func(ctx context.Context, ids []string) (map[string][]string, error) {
+		return repository.LoadMachineGroups(ctx, db, ids)
+	}
+	l.pvs.fetch = func(ctx context.Context, ids []string) (map[string][]*model.PhysicalVolume, error) {
+		return repository.LoadPhysicalVolumes(ctx, db, ids)
+	}
+	l.vgs.fetch = func(ctx context.Context, ids []string) (map[string][]*model.VolumeGroup, error) {
+		return repository.LoadVolumeGroups(ctx, db, ids)
+	}
+	l.lvs.fetch = func(ctx context.Context, ids []string) (map[string][]*model.LogicalVolume, error) {
+		return repository.LoadLogicalVolumes(ctx, db, ids)
+	}
+	l.confs.fetch = func(ctx context.Context, ids []string) (map[string]*model.MachineConf, error) {
+		return repository.LoadMachineConfs(ctx, db, ids)
+	}
+	l.lvmConfs.fetch = func(ctx context.Context, ids []string) (map[string]*model.LvmConf, error) {
+		return repository.LoadLvmConfs(ctx, db, ids)
+	}
+	l.issuers.fetch = func(ctx context.Context, ids []string) (map[string][]*model.LvStorageIssuer, error) {
+		return repository.LoadLVStorageIssuers(ctx, db, ids)
+	}
+	return l
+}
+
+// WithLoaders is a gqlgen response middleware that gives every response its
+// own loaders. Register it with AroundResponses. Queries and mutations have
+// a single response, subscriptions one per event, so that every event is
+// resolved with fresh data.
+func (r *Resolver) WithLoaders(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	return next(context.WithValue(ctx, loadersKey{}, newLoaders(r.DB)))
+}
+
+// loaders returns the loaders of the current response. Without
+// WithLoaders, every call gets new loaders and nothing is batched.
+func (r *Resolver) loaders(ctx context.Context) *loaders {
+	if l, ok := ctx.Value(loadersKey{}).(*loaders); ok {
+		return l
+	}
+	return newLoaders(r.DB)
+}
+
+func (l *loaders) register(machines []*model.Machine) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, m := range machines {
+		l.machineIDs = append(l.machineIDs, m.ID)
+	}
+}
+
+func (l *loaders) registered() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.machineIDs
+}
+
+func (l *loaders) latestLogs(limit int) *batch[[]*model.RealtimeLog] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.logs[limit]
+	if !ok {
+		b = &batch[[]*model.RealtimeLog]{fetch: func(ctx context.Context, ids []string) (map[string][]*model.RealtimeLog, error) {
+			return repository.LoadLatestRealtimeLogs(ctx, l.db, ids, limit)
+		}}
+		l.logs[limit] = b
+	}
+	return b
+}
+
+func (l *loaders) lvRequestsByStatus(status model.LVRequestStatus) *batch[[]*model.LVRequest] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.lvRequests[status]
+	if !ok {
+		b = &batch[[]*model.LVRequest]{fetch: func(ctx context.Context, ids []string) (map[string][]*model.LVRequest, error) {
+			return repository.LoadLVRequests(ctx, l.db, ids, status)
+		}}
+		l.lvRequests[status] = b
+	}
+	return b
+}
+
+// batch caches one nested field by machine id.
+type batch[V any] struct {
+	mu      sync.Mutex
+	fetch   func(ctx context.Context, machineIDs []string) (map[string]V, error)
+	results map[string]V
+}
+
+// load returns the value for machineID, fetching it together with the
+// values of all registered machines not loaded yet. Concurrent callers wait
+// for the running fetch instead of starting their own.
+func (b *batch[V]) load(ctx context.Context, l *loaders, machineID string) (V, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if v, ok := b.results[machineID]; ok {
+		return v, nil
+	}
+
+	ids := []string{machineID}
+	for _, id := range l.registered() {
+		if _, ok := b.results[id]; !ok && id != machineID {
+			ids = append(ids, id)
+		}
+	}
+
+	values, err := b.fetch(ctx, ids)
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+
+	if b.results == nil {
+		b.results = make(map[string]V, len(ids))
+	}
+	for _, id := range ids {
+		b.results[id] = values[id]
+	}
+	return b.results[machineID], nil
+}
@@ -0,0 +1,200 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package model
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The storage types are bound in gqlgen.yml. Nested fields such as the
+// volumes of a machine are left to resolvers, which load them in batches.
+
+type Machine struct {
+	ID        string     `json:"id"`
+	Hostname  string     `json:"hostname"`
+	OsVersion string     `json:"osVersion"`
+	IPAddress string     `json:"ipAddress"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type PhysicalVolume struct {
+	ID        int        `json:"id"`
+	MachineID string     `json:"machineId"`
+	Name      string     `json:"name"`
+	VgName    string     `json:"vgName"`
+	Format    string     `json:"format"`
+	Attr      string     `json:"attr"`
+	Size      string     `json:"size"`
+	Free      string     `json:"free"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type VolumeGroup struct {
+	ID        int        `json:"id"`
+	MachineID string     `json:"machineId"`
+	Name      string     `json:"name"`
+	PvCount   string     `json:"pvCount"`
+	LvCount   string     `json:"lvCount"`
+	SnapCount string     `json:"snapCount"`
+	Attr      string     `json:"attr"`
+	Size      string     `json:"size"`
+	Free      string     `json:"free"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type LogicalVolume struct {
+	ID        int        `json:"id"`
+	MachineID string     `json:"machineId"`
+	Name      string     `json:"name"`
+	VgName    string     `json:"vgName"`
+	Attr      string     `json:"attr"`
+	Size      string     `json:"size"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// MachineConf is the SSH configuration of a machine. Credentials are never
+// exposed, only whether they are set.
+type MachineConf struct {
+	ID            int     `json:"id"`
+	MachineID     string  `json:"machineId"`
+	Hostname      string  `json:"hostname"`
+	Username      string  `json:"username"`
+	PortNumber    int     `json:"portNumber"`
+	FolderPath    *string `json:"folderPath,omitempty"`
+	HasPassword   bool    `json:"hasPassword"`
+	HasPassphrase bool    `json:"hasPassphrase"`
+	HasHostKey    bool    `json:"hasHostKey"`
+}
+
+type RealtimeLog struct {
+	ID        int        `json:"id"`
+	MachineID string     `json:"machineId"`
+	Message   string     `json:"message"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type Notification struct {
+	ID             int        `json:"id"`
+	Message        string     `json:"message"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy *string    `json:"acknowledgedBy,omitempty"`
+}
+
+// LvmConf holds the auto-extend thresholds of a machine.
+type LvmConf struct {
+	ID                  int        `json:"id"`
+	MachineID           string     `json:"machineId"`
+	Username            string     `json:"username"`
+	MinAvailableSpaceGb float64    `json:"minAvailableSpaceGB"`
+	MaxAvailableSpaceGb float64    `json:"maxAvailableSpaceGB"`
+	CreatedAt           *time.Time `json:"createdAt,omitempty"`
+}
+
+type LvStorageIssuer struct {
+	ID                  int     `json:"id"`
+	MachineID           string  `json:"machineId"`
+	IncBuffer           *int    `json:"incBuffer,omitempty"`
+	DecBuffer           *int    `json:"decBuffer,omitempty"`
+	Hostname            string  `json:"hostname"`
+	Username            string  `json:"username"`
+	MinAvailableSpaceGb float64 `json:"minAvailableSpaceGB"`
+	MaxAvailableSpaceGb float64 `json:"maxAvailableSpaceGB"`
+}
+
+// LVRequest asks the agent on a machine to create or extend a logical
+// volume.
+type LVRequest struct {
+	ID          int             `json:"id"`
+	MachineID   string          `json:"machineId"`
+	Operation   LVOperation     `json:"operation"`
+	VgName      string          `json:"vgName"`
+	LvName      string          `json:"lvName"`
+	SizeGb      float64         `json:"sizeGB"`
+	Filesystem  string          `json:"filesystem,omitempty"`
+	Status      LVRequestStatus `json:"status"`
+	Message     string          `json:"message,omitempty"`
+	RequestedBy string          `json:"requestedBy"`
+	RequestedAt time.Time       `json:"requestedAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// MachineStatusEvent is published whenever a machine or its inventory
+// changes. The machine itself is loaded when the event is delivered.
+type MachineStatusEvent struct {
+	MachineID string        `json:"machineId"`
+	Change    MachineChange `json:"change"`
+	LvRequest *LVRequest    `json:"lvRequest,omitempty"`
+	At        time.Time     `json:"at"`
+}
+
+// LVOperation and LVRequestStatus are stored in lower case and exposed as
+// GraphQL enums in upper case.
+
+type LVOperation string
+
+const (
+	LVOperationCreate LVOperation = "create"
+	LVOperationExtend LVOperation = "extend"
+)
+
+func (e LVOperation) IsValid() bool {
+	return e == LVOperationCreate || e == LVOperationExtend
+}
+
+func (e LVOperation) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(strings.ToUpper(string(e))))
+}
+
+func (e *LVOperation) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = LVOperation(strings.ToLower(str))
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid LVOperation", str)
+	}
+	return nil
+}
+
+type LVRequestStatus string
+
+const (
+	LVRequestPending LVRequestStatus = "pending"
+	LVRequestRunning LVRequestStatus = "running"
+	LVRequestDone    LVRequestStatus = "done"
+	LVRequestFailed  LVRequestStatus = "failed"
+)
+
+func (e LVRequestStatus) IsValid() bool {
+	switch e {
+	case LVRequestPending, LVRequestRunning, LVRequestDone, LVRequestFailed:
+		return true
+	}
+	return false
+}
+
+func (e LVRequestStatus) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(strings.ToUpper(string(e))))
+}
+
+func (e *LVRequestStatus) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = LVRequestStatus(strings.ToLower(str))
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid LVRequestStatus", str)
+	}
+	return nil
+}
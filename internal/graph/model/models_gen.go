@@ -6,16 +6,98 @@ import (
 	"fmt"
 	"io"
 	"strconv"
+	"time"
 )
 
+// A new version of a singleton config. Clients query the config again to get its data.
+type ConfigChange struct {
+	// rabbitmq_config, influxdb_config or file_stash_url
+	ConfigType string `json:"configType"`
+	Version    int    `json:"version"`
+	// create, update, delete or rollback
+	Action    string    `json:"action"`
+	CreatedBy string    `json:"createdBy"`
+	At        time.Time `json:"at"`
+}
+
+type FileStashURL struct {
+	URL       string     `json:"url"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+type InfluxDBConfig struct {
+	Type                 string  `json:"type"`
+	DatabaseName         string  `json:"databaseName"`
+	Host                 string  `json:"host"`
+	Port                 int     `json:"port"`
+	User                 string  `json:"user"`
+	Organization         string  `json:"organization"`
+	SslEnabled           bool    `json:"sslEnabled"`
+	BatchSize            int     `json:"batchSize"`
+	RetryInterval        string  `json:"retryInterval"`
+	RetryExponentialBase int     `json:"retryExponentialBase"`
+	MaxRetries           int     `json:"maxRetries"`
+	MaxRetryTime         string  `json:"maxRetryTime"`
+	MetaAsTags           *string `json:"metaAsTags,omitempty"`
+}
+
 type IntRange struct {
 	From int `json:"from"`
 	To   int `json:"to"`
 }
 
+type LVRequestInput struct {
+	MachineID string      `json:"machineId"`
+	Operation LVOperation `json:"operation"`
+	VgName    string      `json:"vgName"`
+	LvName    string      `json:"lvName"`
+	// New size of the logical volume
+	SizeGb float64 `json:"sizeGB"`
+	// File system to create, only for CREATE
+	Filesystem *string `json:"filesystem,omitempty"`
+}
+
+// Creates a storage issuer if id is not set.
+type LvStorageIssuerInput struct {
+	ID                  *string `json:"id,omitempty"`
+	MachineID           string  `json:"machineId"`
+	IncBuffer           *int    `json:"incBuffer,omitempty"`
+	DecBuffer           *int    `json:"decBuffer,omitempty"`
+	Hostname            string  `json:"hostname"`
+	Username            string  `json:"username"`
+	MinAvailableSpaceGb float64 `json:"minAvailableSpaceGB"`
+	MaxAvailableSpaceGb float64 `json:"maxAvailableSpaceGB"`
+}
+
+type LvmConfInput struct {
+	Username            string  `json:"username"`
+	MinAvailableSpaceGb float64 `json:"minAvailableSpaceGB"`
+	MaxAvailableSpaceGb float64 `json:"maxAvailableSpaceGB"`
+}
+
+type MachineInput struct {
+	Hostname  string `json:"hostname"`
+	OsVersion string `json:"osVersion"`
+	IPAddress string `json:"ipAddress"`
+}
+
+type Mutation struct {
+}
+
 type Query struct {
 }
 
+type RabbitMQConfig struct {
+	ConnURL   string     `json:"connUrl"`
+	Username  string     `json:"username"`
+	CreatedAt *time.Time `json:"createdAt,omitempty"`
+}
+
+// Subscriptions are served over websockets. Clients authenticate with a JWT
+// passed as authToken in the payload of the connection_init message.
+type Subscription struct {
+}
+
 type User struct {
 	Username string `json:"username"`
 	Name     string `json:"name"`
@@ -64,3 +146,52 @@ func (e *Aggregate) UnmarshalGQL(v interface{}) error {
 func (e Aggregate) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type MachineChange string
+
+const (
+	MachineChangeCreated MachineChange = "CREATED"
+	MachineChangeUpdated MachineChange = "UPDATED"
+	MachineChangeDeleted MachineChange = "DELETED"
+	// Volumes were reported by the agent.
+	MachineChangeInventory MachineChange = "INVENTORY"
+	// The state of an LV request changed.
+	MachineChangeLvRequest MachineChange = "LV_REQUEST"
+)
+
+var AllMachineChange = []MachineChange{
+	MachineChangeCreated,
+	MachineChangeUpdated,
+	MachineChangeDeleted,
+	MachineChangeInventory,
+	MachineChangeLvRequest,
+}
+
+func (e MachineChange) IsValid() bool {
+	switch e {
+	case MachineChangeCreated, MachineChangeUpdated, MachineChangeDeleted, MachineChangeInventory, MachineChangeLvRequest:
+		return true
+	}
+	return false
+}
+
+func (e MachineChange) String() string {
+	return string(e)
+}
+
+func (e *MachineChange) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = MachineChange(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid MachineChange", str)
+	}
+	return nil
+}
+
+func (e MachineChange) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
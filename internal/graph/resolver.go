@@ -1,6 +1,9 @@
 package graph
 
 import (
+	"database/sql"
+	"time"
+
 	"github.com/jmoiron/sqlx"
 )
 
@@ -9,5 +12,12 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	DB      *sqlx.DB
+	DB *sqlx.DB
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
 }
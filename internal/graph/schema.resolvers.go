@@ -6,19 +6,625 @@ package graph
 
 import (
 	"context"
-	// "fmt"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
 
+	"github.com/Deepbinder-main/cc-backend/internal/auth"
 	"github.com/Deepbinder-main/cc-backend/internal/graph/generated"
 	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/pubsub"
 	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sqlcdb "github.com/Deepbinder-main/cc-backend/internal/repository/sqlc/db"
+	"github.com/jmoiron/sqlx"
+	ulid "github.com/oklog/ulid/v2"
 )
 
+// Groups is the resolver for the groups field.
+func (r *machineResolver) Groups(ctx context.Context, obj *model.Machine) ([]string, error) {
+	l := r.loaders(ctx)
+	groups, err := l.groups.load(ctx, l, obj.ID)
+	if groups == nil {
+		groups = []string{}
+	}
+	return groups, err
+}
+
+// PhysicalVolumes is the resolver for the physicalVolumes field.
+func (r *machineResolver) PhysicalVolumes(ctx context.Context, obj *model.Machine) ([]*model.PhysicalVolume, error) {
+	l := r.loaders(ctx)
+	return l.pvs.load(ctx, l, obj.ID)
+}
+
+// VolumeGroups is the resolver for the volumeGroups field.
+func (r *machineResolver) VolumeGroups(ctx context.Context, obj *model.Machine) ([]*model.VolumeGroup, error) {
+	l := r.loaders(ctx)
+	return l.vgs.load(ctx, l, obj.ID)
+}
+
+// LogicalVolumes is the resolver for the logicalVolumes field.
+func (r *machineResolver) LogicalVolumes(ctx context.Context, obj *model.Machine) ([]*model.LogicalVolume, error) {
+	l := r.loaders(ctx)
+	return l.lvs.load(ctx, l, obj.ID)
+}
+
+// Conf is the resolver for the conf field.
+func (r *machineResolver) Conf(ctx context.Context, obj *model.Machine) (*model.MachineConf, error) {
+	l := r.loaders(ctx)
+	return l.confs.load(ctx, l, obj.ID)
+}
+
+// Logs is the resolver for the logs field.
+func (r *machineResolver) Logs(ctx context.Context, obj *model.Machine, limit int) ([]*model.RealtimeLog, error) {
+	if limit < 0 {
+		return nil, badUserInput(ctx, "limit must not be negative")
+	}
+	l := r.loaders(ctx)
+	return l.latestLogs(limit).load(ctx, l, obj.ID)
+}
+
+// LvmConf is the resolver for the lvmConf field.
+func (r *machineResolver) LvmConf(ctx context.Context, obj *model.Machine) (*model.LvmConf, error) {
+	l := r.loaders(ctx)
+	return l.lvmConfs.load(ctx, l, obj.ID)
+}
+
+// StorageIssuers is the resolver for the storageIssuers field.
+func (r *machineResolver) StorageIssuers(ctx context.Context, obj *model.Machine) ([]*model.LvStorageIssuer, error) {
+	l := r.loaders(ctx)
+	issuers, err := l.issuers.load(ctx, l, obj.ID)
+	if issuers == nil {
+		issuers = []*model.LvStorageIssuer{}
+	}
+	return issuers, err
+}
+
+// LvRequests is the resolver for the lvRequests field.
+func (r *machineResolver) LvRequests(ctx context.Context, obj *model.Machine, status *model.LVRequestStatus) ([]*model.LVRequest, error) {
+	var s model.LVRequestStatus
+	if status != nil {
+		s = *status
+	}
+	l := r.loaders(ctx)
+	requests, err := l.lvRequestsByStatus(s).load(ctx, l, obj.ID)
+	if requests == nil {
+		requests = []*model.LVRequest{}
+	}
+	return requests, err
+}
+
+// Machine is the resolver for the machine field.
+func (r *machineStatusEventResolver) Machine(ctx context.Context, obj *model.MachineStatusEvent) (*model.Machine, error) {
+	machines, err := repository.ListMachines(ctx, r.DB, repository.MachineFilter{IDs: []string{obj.MachineID}})
+	if err != nil || len(machines) == 0 {
+		return nil, storageError(ctx, err)
+	}
+	r.loaders(ctx).register(machines)
+	return machines[0], nil
+}
+
+// CreateMachine is the resolver for the createMachine field.
+func (r *mutationResolver) CreateMachine(ctx context.Context, input model.MachineInput) (*model.Machine, error) {
+	if err := r.authorize(ctx, auth.PolicyConfigWrite, ""); err != nil {
+		return nil, err
+	}
+	if input.Hostname == "" || input.OsVersion == "" || input.IPAddress == "" {
+		return nil, badUserInput(ctx, "all fields are required")
+	}
+
+	params := sqlcdb.CreateMachineParams{
+		MachineID: ulid.Make().String(),
+		Hostname:  input.Hostname,
+		OsVersion: input.OsVersion,
+		IpAddress: input.IPAddress,
+	}
+	err := r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+		q := sqlcdb.New(tx)
+		if err := q.CreateMachine(ctx, params); err != nil {
+			return nil, err
+		}
+		after, err := q.GetMachine(ctx, params.MachineID)
+		return &auditRecord{action: repository.AuditCreate, resource: "machine", resourceID: params.MachineID, after: after}, err
+	})
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+
+	pubsub.MachineChanged(params.MachineID, model.MachineChangeCreated, nil)
+	return r.machine(ctx, params.MachineID)
+}
+
+// UpdateMachine is the resolver for the updateMachine field.
+func (r *mutationResolver) UpdateMachine(ctx context.Context, id string, input model.MachineInput) (*model.Machine, error) {
+	if err := r.authorize(ctx, auth.PolicyMachineWrite, id); err != nil {
+		return nil, err
+	}
+	if input.Hostname == "" || input.OsVersion == "" || input.IPAddress == "" {
+		return nil, badUserInput(ctx, "all fields are required")
+	}
+
+	err := r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+		q := sqlcdb.New(tx)
+		before, err := q.GetMachine(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateMachine(ctx, sqlcdb.UpdateMachineParams{
+			MachineID: id,
+			Hostname:  input.Hostname,
+			OsVersion: input.OsVersion,
+			IpAddress: input.IPAddress,
+		}); err != nil {
+			return nil, err
+		}
+		after, err := q.GetMachine(ctx, id)
+		return &auditRecord{action: repository.AuditUpdate, resource: "machine", resourceID: id, before: before, after: after}, err
+	})
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+
+	pubsub.MachineChanged(id, model.MachineChangeUpdated, nil)
+	return r.machine(ctx, id)
+}
+
+// SaveLvmConf is the resolver for the saveLvmConf field.
+func (r *mutationResolver) SaveLvmConf(ctx context.Context, machineID string, input model.LvmConfInput) (*model.LvmConf, error) {
+	if err := r.authorize(ctx, auth.PolicyMachineWrite, machineID); err != nil {
+		return nil, err
+	}
+	if input.MinAvailableSpaceGb < 0 || input.MaxAvailableSpaceGb < input.MinAvailableSpaceGb {
+		return nil, badUserInput(ctx, "minAvailableSpaceGB must not be negative or exceed maxAvailableSpaceGB")
+	}
+	if _, err := r.machine(ctx, machineID); err != nil {
+		return nil, err
+	}
+
+	err := r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+		q := sqlcdb.New(tx)
+		before, err := q.GetLVMConf(ctx, machineID)
+		if errors.Is(err, sql.ErrNoRows) {
+			if err := q.CreateLVMConf(ctx, sqlcdb.CreateLVMConfParams{
+				MachineID:           machineID,
+				Username:            input.Username,
+				Minavailablespacegb: input.MinAvailableSpaceGb,
+				Maxavailablespacegb: input.MaxAvailableSpaceGb,
+			}); err != nil {
+				return nil, err
+			}
+			after, err := q.GetLVMConf(ctx, machineID)
+			return &auditRecord{action: repository.AuditCreate, resource: "lvm_conf", resourceID: machineID, after: after}, err
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := q.UpdateLVMConf(ctx, sqlcdb.UpdateLVMConfParams{
+			ID:                  before.ID,
+			Username:            input.Username,
+			Minavailablespacegb: input.MinAvailableSpaceGb,
+			Maxavailablespacegb: input.MaxAvailableSpaceGb,
+		}); err != nil {
+			return nil, err
+		}
+		after, err := q.GetLVMConf(ctx, machineID)
+		return &auditRecord{action: repository.AuditUpdate, resource: "lvm_conf", resourceID: machineID, before: before, after: after}, err
+	})
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+
+	confs, err := repository.LoadLvmConfs(ctx, r.DB, []string{machineID})
+	return confs[machineID], storageError(ctx, err)
+}
+
+// SaveLvStorageIssuer is the resolver for the saveLvStorageIssuer field.
+func (r *mutationResolver) SaveLvStorageIssuer(ctx context.Context, input model.LvStorageIssuerInput) (*model.LvStorageIssuer, error) {
+	if input.MinAvailableSpaceGb < 0 || input.MaxAvailableSpaceGb < input.MinAvailableSpaceGb {
+		return nil, badUserInput(ctx, "minAvailableSpaceGB must not be negative or exceed maxAvailableSpaceGB")
+	}
+
+	issuer := &model.LvStorageIssuer{
+		MachineID:           input.MachineID,
+		IncBuffer:           input.IncBuffer,
+		DecBuffer:           input.DecBuffer,
+		Hostname:            input.Hostname,
+		Username:            input.Username,
+		MinAvailableSpaceGb: input.MinAvailableSpaceGb,
+		MaxAvailableSpaceGb: input.MaxAvailableSpaceGb,
+	}
+
+	if input.ID == nil {
+		if err := r.authorize(ctx, auth.PolicyMachineWrite, input.MachineID); err != nil {
+			return nil, err
+		}
+		if _, err := r.machine(ctx, input.MachineID); err != nil {
+			return nil, err
+		}
+
+		err := r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+			if err := repository.CreateLVStorageIssuer(ctx, tx, issuer); err != nil {
+				return nil, err
+			}
+			after, err := sqlcdb.New(tx).GetLVStorageIssuer(ctx, int32(issuer.ID))
+			return &auditRecord{action: repository.AuditCreate, resource: "lv_storage_issuer", resourceID: strconv.Itoa(issuer.ID), after: after}, err
+		})
+		return issuer, storageError(ctx, err)
+	}
+
+	id, err := parseID(ctx, *input.ID)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := sqlcdb.New(r.DB).GetLVStorageIssuerOwner(ctx, int32(id))
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+	if err := r.authorize(ctx, auth.PolicyMachineWrite, owner); err != nil {
+		return nil, err
+	}
+	if owner != input.MachineID {
+		return nil, badUserInput(ctx, "storage issuer %d belongs to another machine", id)
+	}
+
+	issuer.ID = id
+	err = r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+		q := sqlcdb.New(tx)
+		before, err := q.GetLVStorageIssuer(ctx, int32(id))
+		if err != nil {
+			return nil, err
+		}
+		if err := q.UpdateLVStorageIssuer(ctx, sqlcdb.UpdateLVStorageIssuerParams{
+			ID:                  int32(id),
+			IncBuffer:           nullInt32(input.IncBuffer),
+			DecBuffer:           nullInt32(input.DecBuffer),
+			Hostname:            input.Hostname,
+			Username:            input.Username,
+			Minavailablespacegb: input.MinAvailableSpaceGb,
+			Maxavailablespacegb: input.MaxAvailableSpaceGb,
+		}); err != nil {
+			return nil, err
+		}
+		after, err := q.GetLVStorageIssuer(ctx, int32(id))
+		return &auditRecord{action: repository.AuditUpdate, resource: "lv_storage_issuer", resourceID: strconv.Itoa(id), before: before, after: after}, err
+	})
+	return issuer, storageError(ctx, err)
+}
+
+// AcknowledgeNotification is the resolver for the acknowledgeNotification field.
+func (r *mutationResolver) AcknowledgeNotification(ctx context.Context, id string) (*model.Notification, error) {
+	if err := r.authorize(ctx, auth.PolicyNotificationRead, ""); err != nil {
+		return nil, err
+	}
+	nid, err := parseID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var notification *model.Notification
+	err = r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+		before, err := repository.GetNotification(ctx, tx, nid)
+		if err != nil {
+			return nil, err
+		}
+		user := repository.GetUserFromContext(ctx)
+		if err := repository.AcknowledgeNotification(ctx, tx, nid, user.Username, time.Now().Unix()); err != nil {
+			return nil, err
+		}
+		notification, err = repository.GetNotification(ctx, tx, nid)
+		return &auditRecord{action: repository.AuditUpdate, resource: "notification", resourceID: id, before: before, after: notification}, err
+	})
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+	return notification, nil
+}
+
+// RequestLv is the resolver for the requestLV field.
+func (r *mutationResolver) RequestLv(ctx context.Context, input model.LVRequestInput) (*model.LVRequest, error) {
+	if err := r.authorize(ctx, auth.PolicyMachineWrite, input.MachineID); err != nil {
+		return nil, err
+	}
+	if _, err := r.machine(ctx, input.MachineID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Truncate(time.Second)
+	req := &model.LVRequest{
+		MachineID:   input.MachineID,
+		Operation:   input.Operation,
+		VgName:      input.VgName,
+		LvName:      input.LvName,
+		SizeGb:      input.SizeGb,
+		RequestedBy: repository.GetUserFromContext(ctx).Username,
+		RequestedAt: now,
+		UpdatedAt:   now,
+	}
+	if input.Filesystem != nil {
+		req.Filesystem = *input.Filesystem
+	}
+
+	err := r.audited(ctx, func(tx *sqlx.Tx) (*auditRecord, error) {
+		if err := validateLVRequest(ctx, tx, &input); err != nil {
+			return nil, err
+		}
+		if err := repository.CreateLVRequest(ctx, tx, req); err != nil {
+			return nil, err
+		}
+		return &auditRecord{action: repository.AuditCreate, resource: "lv_request", resourceID: strconv.Itoa(req.ID), after: req}, nil
+	})
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+
+	pubsub.MachineChanged(req.MachineID, model.MachineChangeLvRequest, req)
+	return req, nil
+}
+
 // User is the resolver for the user field.
 func (r *queryResolver) User(ctx context.Context, username string) (*model.User, error) {
+	if err := checkScope(ctx); err != nil {
+		return nil, err
+	}
 	return repository.GetUserRepository().FetchUserInCtx(ctx, username)
 }
 
+// Machines is the resolver for the machines field.
+func (r *queryResolver) Machines(ctx context.Context) ([]*model.Machine, error) {
+	if err := checkScope(ctx); err != nil {
+		return nil, err
+	}
+	filter, err := visibleMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	machines, err := repository.ListMachines(ctx, r.DB, filter)
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+	r.loaders(ctx).register(machines)
+	return machines, nil
+}
+
+// Machine is the resolver for the machine field.
+func (r *queryResolver) Machine(ctx context.Context, id string) (*model.Machine, error) {
+	if err := checkScope(ctx); err != nil {
+		return nil, err
+	}
+	filter, err := visibleMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	filter.IDs = []string{id}
+	machines, err := repository.ListMachines(ctx, r.DB, filter)
+	if err != nil || len(machines) == 0 {
+		return nil, storageError(ctx, err)
+	}
+	r.loaders(ctx).register(machines)
+	return machines[0], nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *queryResolver) Notifications(ctx context.Context, limit int, offset int, unacknowledged bool) ([]*model.Notification, error) {
+	if err := r.authorize(ctx, auth.PolicyNotificationRead, ""); err != nil {
+		return nil, err
+	}
+	if limit < 0 || offset < 0 {
+		return nil, badUserInput(ctx, "limit and offset must not be negative")
+	}
+
+	notifications, err := repository.ListNotifications(ctx, r.DB, limit, offset, unacknowledged)
+	return notifications, storageError(ctx, err)
+}
+
+// RabbitMQConfig is the resolver for the rabbitMQConfig field.
+func (r *queryResolver) RabbitMQConfig(ctx context.Context) (*model.RabbitMQConfig, error) {
+	if err := r.authorize(ctx, auth.PolicyConfigRead, ""); err != nil {
+		return nil, err
+	}
+
+	config, err := sqlcdb.New(r.DB).GetRabbitMQConfig(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, storageError(ctx, err)
+	}
+
+	return &model.RabbitMQConfig{
+		ConnURL:   repository.RedactURL(config.ConnUrl),
+		Username:  config.Username,
+		CreatedAt: nullTime(config.CreatedAt),
+	}, nil
+}
+
+// InfluxDBConfig is the resolver for the influxDBConfig field.
+func (r *queryResolver) InfluxDBConfig(ctx context.Context) (*model.InfluxDBConfig, error) {
+	if err := r.authorize(ctx, auth.PolicyConfigRead, ""); err != nil {
+		return nil, err
+	}
+
+	config, err := sqlcdb.New(r.DB).GetInfluxDBConfiguration(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, storageError(ctx, err)
+	}
+
+	c := &model.InfluxDBConfig{
+		Type:                 config.Type,
+		DatabaseName:         config.DatabaseName,
+		Host:                 config.Host,
+		Port:                 int(config.Port),
+		User:                 config.User,
+		Organization:         config.Organization,
+		SslEnabled:           config.SslEnabled,
+		BatchSize:            int(config.BatchSize),
+		RetryInterval:        config.RetryInterval,
+		RetryExponentialBase: int(config.RetryExponentialBase),
+		MaxRetries:           int(config.MaxRetries),
+		MaxRetryTime:         config.MaxRetryTime,
+	}
+	if config.MetaAsTags.Valid {
+		c.MetaAsTags = &config.MetaAsTags.String
+	}
+	return c, nil
+}
+
+// FileStashURL is the resolver for the fileStashURL field.
+func (r *queryResolver) FileStashURL(ctx context.Context) (*model.FileStashURL, error) {
+	if err := r.authorize(ctx, auth.PolicyConfigRead, ""); err != nil {
+		return nil, err
+	}
+
+	config, err := sqlcdb.New(r.DB).GetFileStashURL(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, storageError(ctx, err)
+	}
+
+	return &model.FileStashURL{URL: repository.RedactURL(config.Url), CreatedAt: nullTime(config.CreatedAt)}, nil
+}
+
+// RealtimeLogs is the resolver for the realtimeLogs field.
+func (r *subscriptionResolver) RealtimeLogs(ctx context.Context, machineID string) (<-chan *model.RealtimeLog, error) {
+	if err := r.authorize(ctx, auth.PolicyMachineRead, machineID); err != nil {
+		return nil, err
+	}
+
+	return pubsub.RealtimeLogs.Subscribe(ctx, func(l *model.RealtimeLog) bool {
+		return l.MachineID == machineID
+	}), nil
+}
+
+// Notifications is the resolver for the notifications field.
+func (r *subscriptionResolver) Notifications(ctx context.Context) (<-chan *model.Notification, error) {
+	if err := r.authorize(ctx, auth.PolicyNotificationRead, ""); err != nil {
+		return nil, err
+	}
+
+	return pubsub.Notifications.Subscribe(ctx, nil), nil
+}
+
+// MachineStatusChanged is the resolver for the machineStatusChanged field.
+func (r *subscriptionResolver) MachineStatusChanged(ctx context.Context, machineID *string) (<-chan *model.MachineStatusEvent, error) {
+	if machineID != nil {
+		if err := r.authorize(ctx, auth.PolicyMachineRead, *machineID); err != nil {
+			return nil, err
+		}
+		return pubsub.MachineStatus.Subscribe(ctx, func(e *model.MachineStatusEvent) bool {
+			return e.MachineID == *machineID
+		}), nil
+	}
+
+	filter, err := visibleMachines(ctx)
+	if err != nil {
+		return nil, err
+	}
+	events := pubsub.MachineStatus.Subscribe(ctx, nil)
+	if filter.Groups == nil {
+		return events, nil
+	}
+
+	// Managers only get the events of the machines of their projects. The
+	// groups are looked up here and not in the filter, which must not block
+	// publishers.
+	visible := make(chan *model.MachineStatusEvent, pubsub.Buffer)
+	go func() {
+		defer close(visible)
+		for e := range events {
+			if ok, err := r.allows(ctx, auth.PolicyMachineRead, e.MachineID); err != nil || !ok {
+				continue
+			}
+			select {
+			case visible <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return visible, nil
+}
+
+// ConfigChanged is the resolver for the configChanged field.
+func (r *subscriptionResolver) ConfigChanged(ctx context.Context, configType *string) (<-chan *model.ConfigChange, error) {
+	if err := r.authorize(ctx, auth.PolicyConfigRead, ""); err != nil {
+		return nil, err
+	}
+	if configType == nil {
+		return pubsub.ConfigChanges.Subscribe(ctx, nil), nil
+	}
+	if !repository.IsVersionedConfig(*configType) {
+		return nil, badUserInput(ctx, "unknown config type '%s'", *configType)
+	}
+
+	return pubsub.ConfigChanges.Subscribe(ctx, func(c *model.ConfigChange) bool {
+		return c.ConfigType == *configType
+	}), nil
+}
+
+// PhysicalVolumes is the resolver for the physicalVolumes field.
+func (r *volumeGroupResolver) PhysicalVolumes(ctx context.Context, obj *model.VolumeGroup) ([]*model.PhysicalVolume, error) {
+	l := r.loaders(ctx)
+	pvs, err := l.pvs.load(ctx, l, obj.MachineID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*model.PhysicalVolume, 0)
+	for _, pv := range pvs {
+		if pv.VgName == obj.Name {
+			members = append(members, pv)
+		}
+	}
+	return members, nil
+}
+
+// LogicalVolumes is the resolver for the logicalVolumes field.
+func (r *volumeGroupResolver) LogicalVolumes(ctx context.Context, obj *model.VolumeGroup) ([]*model.LogicalVolume, error) {
+	l := r.loaders(ctx)
+	lvs, err := l.lvs.load(ctx, l, obj.MachineID)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*model.LogicalVolume, 0)
+	for _, lv := range lvs {
+		if lv.VgName == obj.Name {
+			members = append(members, lv)
+		}
+	}
+	return members, nil
+}
+
+// Machine returns generated.MachineResolver implementation.
+func (r *Resolver) Machine() generated.MachineResolver { return &machineResolver{r} }
+
+// MachineStatusEvent returns generated.MachineStatusEventResolver implementation.
+func (r *Resolver) MachineStatusEvent() generated.MachineStatusEventResolver {
+	return &machineStatusEventResolver{r}
+}
+
+// Mutation returns generated.MutationResolver implementation.
+func (r *Resolver) Mutation() generated.MutationResolver { return &mutationResolver{r} }
+
 // Query returns generated.QueryResolver implementation.
 func (r *Resolver) Query() generated.QueryResolver { return &queryResolver{r} }
 
+// Subscription returns generated.SubscriptionResolver implementation.
+func (r *Resolver) Subscription() generated.SubscriptionResolver { return &subscriptionResolver{r} }
+
+// VolumeGroup returns generated.VolumeGroupResolver implementation.
+func (r *Resolver) VolumeGroup() generated.VolumeGroupResolver { return &volumeGroupResolver{r} }
+
+type machineResolver struct{ *Resolver }
+type machineStatusEventResolver struct{ *Resolver }
+type mutationResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type volumeGroupResolver struct{ *Resolver }
@@ -0,0 +1,103 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/Deepbinder-main/cc-backend/internal/graph/generated"
+	"github.com/Deepbinder-main/cc-backend/internal/metrics"
+	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+// Defaults of schema.GraphQLConfig.
+const (
+	DefaultMaxComplexity             = 1000
+	DefaultMaxDepth                  = 10
+	DefaultPersistedQueriesCacheSize = 1 << 20
+)
+
+// Persisted queries are dropped after this long at the latest, clients send
+// the full query again if the hash is unknown.
+const persistedQueryTTL = 24 * time.Hour
+
+// NewHandler returns the GraphQL server for the resolver. Operations
+// exceeding the limits in conf are rejected before any resolver runs, if conf
+// is nil the defaults apply. Transports are added by the caller.
+func NewHandler(resolver *Resolver, conf *schema.GraphQLConfig) *handler.Server {
+	limits := schema.GraphQLConfig{
+		MaxComplexity:             DefaultMaxComplexity,
+		MaxDepth:                  DefaultMaxDepth,
+		PersistedQueriesCacheSize: DefaultPersistedQueriesCacheSize,
+	}
+	if conf != nil {
+		if conf.MaxComplexity > 0 {
+			limits.MaxComplexity = conf.MaxComplexity
+		}
+		if conf.MaxDepth > 0 {
+			limits.MaxDepth = conf.MaxDepth
+		}
+		if conf.PersistedQueriesCacheSize > 0 {
+			limits.PersistedQueriesCacheSize = conf.PersistedQueriesCacheSize
+		}
+	}
+
+	config := generated.Config{Resolvers: resolver}
+	config.Complexity.Machine.Logs = func(childComplexity int, limit int) int {
+		return listComplexity(childComplexity, limit)
+	}
+	config.Complexity.Query.Notifications = func(childComplexity int, limit int, offset int, unacknowledged bool) int {
+		return listComplexity(childComplexity, limit)
+	}
+
+	srv := handler.New(generated.NewExecutableSchema(config))
+	srv.SetQueryCache(lru.New(1000))
+	srv.Use(extension.Introspection{})
+	persisted := newPersistedQueries(limits.PersistedQueriesCacheSize)
+	metrics.RegisterCache("graphql-persisted-queries", persisted.cache)
+	srv.Use(extension.AutomaticPersistedQuery{Cache: persisted})
+	srv.Use(&Limits{MaxComplexity: limits.MaxComplexity, MaxDepth: limits.MaxDepth})
+	srv.Use(ResolverMetrics{})
+	srv.Use(ResolverTracing{})
+	srv.AroundResponses(resolver.WithLoaders)
+	return srv
+}
+
+// listComplexity is the complexity of a list field returning up to limit
+// elements.
+func listComplexity(childComplexity, limit int) int {
+	return 1 + max(limit, 1)*childComplexity
+}
+
+// persistedQueries keeps the queries of automatic persisted queries by their
+// hash. The memory used is bounded by the summed length of the queries.
+type persistedQueries struct {
+	cache *lrucache.Cache
+}
+
+var _ graphql.Cache = persistedQueries{}
+
+func newPersistedQueries(maxmemory int) persistedQueries {
+	return persistedQueries{cache: lrucache.New(maxmemory)}
+}
+
+func (pq persistedQueries) Get(ctx context.Context, key string) (interface{}, bool) {
+	query := pq.cache.Get(key, nil)
+	return query, query != nil
+}
+
+func (pq persistedQueries) Add(ctx context.Context, key string, value interface{}) {
+	query, ok := value.(string)
+	if !ok {
+		return
+	}
+	pq.cache.Put(key, query, len(query), persistedQueryTTL)
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/99designs/gqlgen/client"
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestLimits(t *testing.T) {
+	c, _ := setupResolverWithLimits(t, &schema.GraphQLConfig{MaxComplexity: 50, MaxDepth: 3})
+	asAdmin := client.AddHeader("X-Test-User", "admin")
+
+	var resp map[string]interface{}
+	if err := c.Post(`{ machines { hostname volumeGroups { name } } }`, &resp, asAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := testutil.ToFloat64(rejectedOperations.WithLabelValues("depth"))
+	err := c.Post(`{ machines { volumeGroups { logicalVolumes { name } } } }`, &resp, asAdmin)
+	if code := errorCode(t, err); code != CodeDepthLimit {
+		t.Errorf("expected %s, got %s", CodeDepthLimit, code)
+	}
+	if n := testutil.ToFloat64(rejectedOperations.WithLabelValues("depth")); n != rejected+1 {
+		t.Errorf("expected the rejection to be counted, got %v", n-rejected)
+	}
+
+	// Fragments do not hide the depth
+	err = c.Post(`query { machines { ...vgs } }
+		fragment vgs on Machine { volumeGroups { logicalVolumes { name } } }`, &resp, asAdmin)
+	if code := errorCode(t, err); code != CodeDepthLimit {
+		t.Errorf("expected %s, got %s", CodeDepthLimit, code)
+	}
+
+	// Introspection is not limited by depth
+	var schemaResp struct {
+		Schema struct {
+			QueryType struct {
+				Fields []struct {
+					Type struct {
+						OfType struct{ OfType struct{ Name string } }
+					}
+				}
+			}
+		} `json:"__schema"`
+	}
+	if err := c.Post(`{ __schema { queryType { fields { type { ofType { ofType { name } } } } } } }`, &schemaResp, asAdmin); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every log counts, limit 10 is just fine
+	if err := c.Post(`{ machines { logs(limit: 10) { message createdAt } } }`, &resp, asAdmin); err != nil {
+		t.Fatal(err)
+	}
+	err = c.Post(`{ machines { logs(limit: 100) { message createdAt } } }`, &resp, asAdmin)
+	if code := errorCode(t, err); code != CodeComplexityLimit {
+		t.Errorf("expected %s, got %s", CodeComplexityLimit, code)
+	}
+}
+
+func TestPersistedQueries(t *testing.T) {
+	c, _ := setupResolver(t)
+	asAdmin := client.AddHeader("X-Test-User", "admin")
+
+	query := `{ machines { hostname } }`
+	sum := sha256.Sum256([]byte(query))
+	persisted := func(bd *client.Request) {
+		bd.Extensions = map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hex.EncodeToString(sum[:])},
+		}
+	}
+
+	var resp struct {
+		Machines []struct{ Hostname string }
+	}
+	// Unknown hashes have to be sent with the query
+	err := c.Post("", &resp, asAdmin, persisted)
+	if code := errorCode(t, err); code != "PERSISTED_QUERY_NOT_FOUND" {
+		t.Fatalf("expected PERSISTED_QUERY_NOT_FOUND, got %s", code)
+	}
+	if err := c.Post(query, &resp, asAdmin, persisted); err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Machines = nil
+	if err := c.Post("", &resp, asAdmin, persisted); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Machines) == 0 {
+		t.Error("expected the persisted query to return the machines")
+	}
+}
+
+func TestPersistedQueriesCache(t *testing.T) {
+	ctx := context.Background()
+	pq := newPersistedQueries(10)
+	pq.Add(ctx, "a", "{ a }")
+	pq.Add(ctx, "b", "{ b }")
+	if q, ok := pq.Get(ctx, "b"); !ok || q != "{ b }" {
+		t.Errorf("unexpected query: %v", q)
+	}
+
+	// Does not fit along with the others, so they are evicted
+	pq.Add(ctx, "c", "{ cccc }")
+	if _, ok := pq.Get(ctx, "a"); ok {
+		t.Error("expected the least recently used query to be evicted")
+	}
+	if _, ok := pq.Get(ctx, "c"); !ok {
+		t.Error("expected the latest query to be cached")
+	}
+}
+
+func observations(t *testing.T, object, field string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := resolverDuration.WithLabelValues(object, field).(prometheus.Histogram).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestResolverMetrics(t *testing.T) {
+	c, _ := setupResolver(t)
+
+	machines, confs := observations(t, "Query", "machines"), observations(t, "Machine", "conf")
+	var resp struct {
+		Machines []struct {
+			Hostname string
+			Conf     *struct{ Hostname string }
+		}
+	}
+	if err := c.Post(`{ machines { hostname conf { hostname } } }`, &resp, client.AddHeader("X-Test-User", "admin")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := observations(t, "Query", "machines") - machines; n != 1 {
+		t.Errorf("expected Query.machines to be timed once, got %d", n)
+	}
+	if n := observations(t, "Machine", "conf") - confs; n != uint64(len(resp.Machines)) {
+		t.Errorf("expected Machine.conf to be timed for each of the %d machines, got %d", len(resp.Machines), n)
+	}
+	// Not a resolver
+	if n := observations(t, "Machine", "hostname"); n != 0 {
+		t.Errorf("expected Machine.hostname not to be timed, got %d", n)
+	}
+
+	failed := testutil.ToFloat64(resolverErrors.WithLabelValues("Query", "machines"))
+	err := c.Post(`{ machines { hostname } }`, &resp)
+	if code := errorCode(t, err); code != CodeForbidden {
+		t.Fatalf("expected %s, got %s", CodeForbidden, code)
+	}
+	if n := testutil.ToFloat64(resolverErrors.WithLabelValues("Query", "machines")); n != failed+1 {
+		t.Errorf("expected the failed resolver to be counted, got %v", n-failed)
+	}
+}
+
+func TestResolverTracing(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tracing.Start(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	t.Cleanup(func() { tracing.Shutdown(context.Background()) })
+	c, _ := setupResolver(t)
+
+	var resp struct {
+		Machines []struct {
+			Hostname string
+			Conf     *struct{ Hostname string }
+		}
+	}
+	if err := c.Post(`{ machines { hostname conf { hostname } } }`, &resp, client.AddHeader("X-Test-User", "admin")); err != nil {
+		t.Fatal(err)
+	}
+
+	names := map[string]int{}
+	ended := spans.GetSpans()
+	for _, span := range ended {
+		names[span.Name]++
+		if span.SpanContext.TraceID() != ended[0].SpanContext.TraceID() {
+			t.Errorf("expected %s to belong to the trace of the operation", span.Name)
+		}
+	}
+	if names["Query.machines"] != 1 || names["Machine.conf"] != len(resp.Machines) {
+		t.Errorf("expected a span per resolver call, got %v", names)
+	}
+	if names["Machine.hostname"] != 0 {
+		t.Error("expected no spans for fields without a resolver")
+	}
+
+	spans.Reset()
+	if err := c.Post(`{ machines { hostname } }`, &resp); err == nil {
+		t.Fatal("expected anonymous users to be rejected")
+	}
+	if failed := spans.GetSpans(); len(failed) != 1 || failed[0].Status.Code != codes.Error {
+		t.Errorf("expected the failed resolver to be recorded: %+v", failed)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strconv"
+
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/jmoiron/sqlx"
+)
+
+// auditRecord describes a change to be written to the audit log, see
+// internal/api/audit.go.
+type auditRecord struct {
+	action     string
+	resource   string
+	resourceID string
+	before     interface{}
+	after      interface{}
+}
+
+// audited runs change in a transaction and writes the audit record it
+// returns within the same transaction, so that either both or none are
+// persisted.
+func (r *Resolver) audited(ctx context.Context, change func(tx *sqlx.Tx) (*auditRecord, error)) error {
+	tx, err := r.DB.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	rec, err := change(tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := repository.RecordAuditFromContext(ctx, tx, rec.action, rec.resource, rec.resourceID, rec.before, rec.after); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// machine returns the machine with id, registered with the loaders.
+func (r *Resolver) machine(ctx context.Context, id string) (*model.Machine, error) {
+	machines, err := repository.ListMachines(ctx, r.DB, repository.MachineFilter{IDs: []string{id}})
+	if err != nil {
+		return nil, storageError(ctx, err)
+	}
+	if len(machines) == 0 {
+		return nil, newError(ctx, CodeNotFound, "machine '%s' not found", id)
+	}
+
+	r.loaders(ctx).register(machines)
+	return machines[0], nil
+}
+
+func nullInt32(v *int) sql.NullInt32 {
+	if v == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: int32(*v), Valid: true}
+}
+
+func parseID(ctx context.Context, id string) (int, error) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return 0, badUserInput(ctx, "invalid id '%s'", id)
+	}
+	return n, nil
+}
+
+// Names the agents pass to lvcreate, lvextend and mkfs.
+var (
+	lvmNamePattern    = regexp.MustCompile(`^[A-Za-z0-9_.+][A-Za-z0-9_.+-]*$`)
+	filesystemPattern = regexp.MustCompile(`^[a-z0-9]+$`)
+)
+
+// validateLVRequest checks input against the inventory of the machine: the
+// volume group must exist, and the logical volume must exist for EXTEND
+// but not for CREATE.
+func validateLVRequest(ctx context.Context, tx *sqlx.Tx, input *model.LVRequestInput) error {
+	if !lvmNamePattern.MatchString(input.VgName) || !lvmNamePattern.MatchString(input.LvName) {
+		return badUserInput(ctx, "invalid volume group or logical volume name")
+	}
+	if input.SizeGb <= 0 {
+		return badUserInput(ctx, "sizeGB must be positive")
+	}
+	if input.Filesystem != nil && *input.Filesystem != "" {
+		if input.Operation != model.LVOperationCreate {
+			return badUserInput(ctx, "filesystem can only be set when creating a logical volume")
+		}
+		if !filesystemPattern.MatchString(*input.Filesystem) {
+			return badUserInput(ctx, "invalid filesystem '%s'", *input.Filesystem)
+		}
+	}
+
+	vgs, err := repository.LoadVolumeGroups(ctx, tx, []string{input.MachineID})
+	if err != nil {
+		return err
+	}
+	vgExists := false
+	for _, vg := range vgs[input.MachineID] {
+		vgExists = vgExists || vg.Name == input.VgName
+	}
+	if !vgExists {
+		return badUserInput(ctx, "volume group '%s' not found on machine", input.VgName)
+	}
+
+	lvs, err := repository.LoadLogicalVolumes(ctx, tx, []string{input.MachineID})
+	if err != nil {
+		return err
+	}
+	lvExists := false
+	for _, lv := range lvs[input.MachineID] {
+		lvExists = lvExists || (lv.VgName == input.VgName && lv.Name == input.LvName)
+	}
+	switch {
+	case input.Operation == model.LVOperationCreate && lvExists:
+		return badUserInput(ctx, "logical volume '%s/%s' already exists", input.VgName, input.LvName)
+	case input.Operation == model.LVOperationExtend && !lvExists:
+		return badUserInput(ctx, "logical volume '%s/%s' not found on machine", input.VgName, input.LvName)
+	}
+	return nil
+}
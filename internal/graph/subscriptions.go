@@ -0,0 +1,119 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+)
+
+var ErrNoInitToken = errors.New("missing authToken in connection_init payload")
+
+// WebsocketAuthFunc returns the user of a websocket connection. token is the
+// JWT of the connection_init payload, empty if there was none. ctx is derived
+// from the upgrade request, so that the credentials it carried (such as the
+// session cookie) can be checked instead. A nil user without error means
+// there are no valid credentials.
+type WebsocketAuthFunc func(ctx context.Context, token string) (*schema.User, error)
+
+// WebsocketInit returns the InitFunc of the websocket transport. Browsers
+// cannot set headers on websocket connections, so clients either send a JWT
+// as authToken (or Authorization, with or without "Bearer ") in the payload
+// of the connection_init message, or rely on the session cookie sent with
+// the upgrade request. The user returned by authenticate is used for all
+// subscriptions of the connection.
+//
+// The credentials are checked again every recheck interval (if not zero) and
+// the connection is closed once they are no longer valid, so that revoked
+// tokens, ended sessions and disabled users do not keep receiving events.
+func WebsocketInit(authenticate WebsocketAuthFunc, recheck time.Duration) transport.WebsocketInitFunc {
+	return func(ctx context.Context, payload transport.InitPayload) (context.Context, *transport.InitPayload, error) {
+		token := payload.GetString("authToken")
+		if token == "" {
+			token = strings.TrimPrefix(payload.Authorization(), "Bearer ")
+		}
+
+		user, err := authenticate(ctx, token)
+		if err != nil {
+			log.Infof("websocket authentication failed: %s", err.Error())
+			return ctx, nil, err
+		}
+		if user == nil {
+			if token == "" {
+				return ctx, nil, ErrNoInitToken
+			}
+			return ctx, nil, errors.New("unauthorized")
+		}
+
+		ctx = context.WithValue(ctx, repository.ContextUserKey, user)
+		if recheck <= 0 {
+			return ctx, nil, nil
+		}
+		// Cancelling the context closes the connection
+		ctx, cancel := context.WithCancel(ctx)
+		go revalidate(ctx, cancel, authenticate, token, user.Username, recheck)
+		return ctx, nil, nil
+	}
+}
+
+// revalidate calls cancel as soon as the credentials of the connection of
+// ctx are rejected by authenticate.
+func revalidate(ctx context.Context, cancel context.CancelFunc, authenticate WebsocketAuthFunc, token, username string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			user, err := authenticate(ctx, token)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil || user == nil {
+				log.Infof("closing websocket connection of '%s': credentials no longer valid", username)
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+// CheckOrigin returns the CheckOrigin function of the websocket upgrader.
+// Browsers send the session cookie with every upgrade request, so requests
+// from pages of other origins are rejected unless listed in allowed (as
+// scheme://host[:port]). Requests without Origin header do not come from
+// browsers and are accepted.
+func CheckOrigin(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		u, err := url.Parse(origin)
+		if err != nil {
+			return false
+		}
+		if strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		for _, a := range allowed {
+			if strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+				return true
+			}
+		}
+		log.Infof("rejected websocket connection from origin %s", origin)
+		return false
+	}
+}
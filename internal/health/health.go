@@ -0,0 +1,200 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package health serves the liveness (/healthz) and readiness (/readyz)
+// probes. Readiness runs the registered checks of the dependencies of
+// cc-backend and fails once the server is shutting down. The probes are
+// public, so the results are reused for a few seconds and errors are only
+// shown to allowed clients.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/util"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+)
+
+// Upper bound for a single check if the caller does not set one.
+const DefaultTimeout = 5 * time.Second
+
+// How long the readiness probe reuses the results if the caller does not
+// set it.
+const DefaultCacheFor = 5 * time.Second
+
+// Check returns an error if the dependency is not usable.
+type Check func(ctx context.Context) error
+
+// Result of a single check.
+type Result struct {
+	Name      string  `json:"name"`
+	Ok        bool    `json:"ok"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report of the readiness probe. Checks are listed in the order they were
+// added.
+type Report struct {
+	Ok           bool      `json:"ok"`
+	ShuttingDown bool      `json:"shuttingDown,omitempty"`
+	Checks       []*Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks. The zero value is ready to use.
+type Checker struct {
+	// Timeout of each check, DefaultTimeout if zero
+	Timeout time.Duration
+	// How long the readiness probe reuses results, DefaultCacheFor if zero
+	CacheFor time.Duration
+	// Clients that see why checks failed, all if the first entry is "*"
+	AllowedIPs []string
+
+	mu           sync.Mutex
+	checks       []namedCheck
+	shuttingDown atomic.Bool
+
+	// Last report of the readiness probe, runLock makes concurrent probes
+	// wait for a single run
+	runLock sync.Mutex
+	last    *Report
+	lastRun time.Time
+}
+
+// Add registers a check run by the readiness probe.
+func (c *Checker) Add(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// ShuttingDown makes the readiness probe fail from now on, so that load
+// balancers stop sending requests before the server is shut down.
+func (c *Checker) ShuttingDown() {
+	c.shuttingDown.Store(true)
+}
+
+// Run executes all checks concurrently. While shutting down, no checks are
+// run and the report is not ok.
+func (c *Checker) Run(ctx context.Context) *Report {
+	if c.shuttingDown.Load() {
+		return &Report{Ok: false, ShuttingDown: true, Checks: []*Result{}}
+	}
+
+	c.mu.Lock()
+	checks := c.checks
+	c.mu.Unlock()
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	report := &Report{Ok: true, Checks: make([]*Result, len(checks))}
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := nc.check(ctx)
+			res := &Result{Name: nc.name, Ok: err == nil, LatencyMs: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				res.Error = err.Error()
+			}
+			report.Checks[i] = res
+		}(i, nc)
+	}
+	wg.Wait()
+
+	for _, res := range report.Checks {
+		if !res.Ok {
+			report.Ok = false
+			log.Warnf("readiness check '%s' failed: %s", res.Name, res.Error)
+		}
+	}
+	return report
+}
+
+// Live answers the liveness probe. It only shows that the server handles
+// requests, dependencies are not checked.
+func (c *Checker) Live(rw http.ResponseWriter, r *http.Request) {
+	writeJSON(rw, http.StatusOK, map[string]bool{"ok": true})
+}
+
+// Ready answers the readiness probe with the report, the status is 503 if
+// any check failed or the server is shutting down. Only allowed clients get
+// the errors of failed checks.
+func (c *Checker) Ready(rw http.ResponseWriter, r *http.Request) {
+	report := c.cached(r.Context())
+	status := http.StatusOK
+	if !report.Ok {
+		status = http.StatusServiceUnavailable
+	}
+	if !c.allowed(r) {
+		report = report.public()
+	}
+	writeJSON(rw, status, report)
+}
+
+// cached returns the last report if it is recent enough, otherwise it runs
+// the checks.
+func (c *Checker) cached(ctx context.Context) *Report {
+	if c.shuttingDown.Load() {
+		return c.Run(ctx)
+	}
+
+	cacheFor := c.CacheFor
+	if cacheFor == 0 {
+		cacheFor = DefaultCacheFor
+	}
+
+	c.runLock.Lock()
+	defer c.runLock.Unlock()
+	if c.last != nil && time.Since(c.lastRun) < cacheFor {
+		return c.last
+	}
+	c.last = c.Run(ctx)
+	c.lastRun = time.Now()
+	return c.last
+}
+
+func (c *Checker) allowed(r *http.Request) bool {
+	if len(c.AllowedIPs) == 0 {
+		return false
+	}
+	return c.AllowedIPs[0] == "*" || util.Contains(c.AllowedIPs, util.ClientIP(r))
+}
+
+// public is a copy of the report without errors.
+func (r *Report) public() *Report {
+	public := &Report{Ok: r.Ok, ShuttingDown: r.ShuttingDown, Checks: make([]*Result, len(r.Checks))}
+	for i, res := range r.Checks {
+		public.Checks[i] = &Result{Name: res.Name, Ok: res.Ok, LatencyMs: res.LatencyMs}
+	}
+	return public
+}
+
+func writeJSON(rw http.ResponseWriter, status int, v interface{}) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Cache-Control", "no-store")
+	rw.WriteHeader(status)
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Warnf("writing health response failed: %v", err)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func ready(t *testing.T, c *Checker) (int, *Report) {
+	t.Helper()
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	req.RemoteAddr = "192.0.2.1:40000"
+	c.Ready(rw, req)
+	var report Report
+	if err := json.NewDecoder(rw.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	return rw.Code, &report
+}
+
+func TestReadiness(t *testing.T) {
+	var dbErr error
+	c := &Checker{Timeout: 50 * time.Millisecond, AllowedIPs: []string{"*"}}
+	c.Add("database", func(ctx context.Context) error { return dbErr })
+	c.Add("metricdata/emmy", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := ready(t, c)
+	if code != http.StatusServiceUnavailable || report.Ok || len(report.Checks) != 2 {
+		t.Fatalf("unexpected report (%d): %+v", code, report)
+	}
+	if db := report.Checks[0]; db.Name != "database" || !db.Ok || db.Error != "" {
+		t.Errorf("unexpected result: %+v", db)
+	}
+	if md := report.Checks[1]; md.Name != "metricdata/emmy" || md.Ok || md.Error != context.DeadlineExceeded.Error() || md.LatencyMs < 50 {
+		t.Errorf("expected the check to time out: %+v", md)
+	}
+
+	c = &Checker{CacheFor: time.Nanosecond, AllowedIPs: []string{"192.0.2.1"}}
+	c.Add("database", func(ctx context.Context) error { return dbErr })
+	if code, report := ready(t, c); code != http.StatusOK || !report.Ok {
+		t.Errorf("unexpected report (%d): %+v", code, report)
+	}
+	dbErr = errors.New("database is locked")
+	if code, report := ready(t, c); code != http.StatusServiceUnavailable || report.Checks[0].Error != dbErr.Error() {
+		t.Errorf("unexpected report (%d): %+v", code, report)
+	}
+}
+
+func TestReadinessPublic(t *testing.T) {
+	runs := 0
+	c := &Checker{AllowedIPs: []string{"10.0.0.1"}}
+	c.Add("database", func(ctx context.Context) error {
+		runs++
+		return errors.New("dial tcp 10.1.2.3:3306: connection refused")
+	})
+
+	// Other clients neither see the error nor trigger further checks
+	for i := 0; i < 3; i++ {
+		code, report := ready(t, c)
+		if code != http.StatusServiceUnavailable || report.Ok || report.Checks[0].Ok || report.Checks[0].Error != "" {
+			t.Errorf("unexpected report (%d): %+v", code, report.Checks[0])
+		}
+	}
+	if runs != 1 {
+		t.Errorf("expected the checks to run once, got %d", runs)
+	}
+}
+
+func TestShuttingDown(t *testing.T) {
+	c := &Checker{}
+	c.Add("database", func(ctx context.Context) error {
+		t.Error("no checks expected while shutting down")
+		return nil
+	})
+	c.ShuttingDown()
+
+	code, report := ready(t, c)
+	if code != http.StatusServiceUnavailable || report.Ok || !report.ShuttingDown {
+		t.Errorf("unexpected report (%d): %+v", code, report)
+	}
+
+	// Still alive until the server is shut down
+	rw := httptest.NewRecorder()
+	c.Live(rw, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rw.Code != http.StatusOK {
+		t.Errorf("expected the liveness probe to succeed, got %d", rw.Code)
+	}
+}
@@ -15,6 +15,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
 	"github.com/Deepbinder-main/cc-backend/pkg/archive"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
@@ -85,9 +87,15 @@ func (ccms *CCMetricStore) Init(rawConfig json.RawMessage) error {
 
 	ccms.url = config.Url
 	ccms.queryEndpoint = fmt.Sprintf("%s/api/query", config.Url)
-	ccms.jwt = config.Token
+	token, err := secrets.Resolve(context.Background(), config.Token)
+	if err != nil {
+		log.Warn("Error while resolving cc-metric-store token")
+		return err
+	}
+	ccms.jwt = token
 	ccms.client = http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: tracing.Transport(nil),
 	}
 
 	if config.Renamings != nil {
@@ -144,6 +152,7 @@ func (ccms *CCMetricStore) doRequest(
 		log.Error("Error while performing request")
 		return nil, err
 	}
+	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("'%s': HTTP Status: %s", ccms.queryEndpoint, res.Status)
@@ -158,6 +167,14 @@ func (ccms *CCMetricStore) doRequest(
 	return &resBody, nil
 }
 
+// HealthCheck sends a query without metrics, which checks the connection
+// and the token.
+func (ccms *CCMetricStore) HealthCheck(ctx context.Context) error {
+	now := time.Now().Unix()
+	_, err := ccms.doRequest(ctx, &ApiQueryRequest{From: now, To: now, Queries: []ApiQuery{}})
+	return err
+}
+
 func (ccms *CCMetricStore) LoadData(
 	job *schema.Job,
 	metrics []string,
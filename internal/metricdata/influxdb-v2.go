@@ -13,6 +13,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Deepbinder-main/cc-backend/internal/secrets"
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
 	"github.com/Deepbinder-main/cc-backend/pkg/archive"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
@@ -41,13 +43,32 @@ func (idb *InfluxDBv2DataRepository) Init(rawConfig json.RawMessage) error {
 		return err
 	}
 
-	idb.client = influxdb2.NewClientWithOptions(config.Url, config.Token, influxdb2.DefaultOptions().SetTLSConfig(&tls.Config{InsecureSkipVerify: config.SkipTls}))
+	token, err := secrets.Resolve(context.Background(), config.Token)
+	if err != nil {
+		log.Warn("Error while resolving influxdb token")
+		return err
+	}
+
+	opts := influxdb2.DefaultOptions().SetTLSConfig(&tls.Config{InsecureSkipVerify: config.SkipTls})
+	httpClient := opts.HTTPClient()
+	httpClient.Transport = tracing.Transport(httpClient.Transport)
+	idb.client = influxdb2.NewClientWithOptions(config.Url, token, opts)
 	idb.queryClient = idb.client.QueryAPI(config.Org)
 	idb.bucket = config.Bucket
 
 	return nil
 }
 
+func (idb *InfluxDBv2DataRepository) HealthCheck(ctx context.Context) error {
+	if ok, err := idb.client.Ping(ctx); !ok {
+		if err == nil {
+			err = fmt.Errorf("METRICDATA/INFLUXV2 > '%s' is not ready", idb.client.ServerURL())
+		}
+		return err
+	}
+	return nil
+}
+
 func (idb *InfluxDBv2DataRepository) formatTime(t time.Time) string {
 	return t.Format(time.RFC3339) // Like “2006-01-02T15:04:05Z07:00”
 }
@@ -0,0 +1,94 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package metricdata
+
+import (
+	"context"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/Deepbinder-main/cc-backend/internal/metricdata")
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "cc",
+	Subsystem: "metricdata",
+	Name:      "query_duration_seconds",
+	Help:      "Latency of metric data repository queries by cluster, backend, query and status.",
+	Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"cluster", "backend", "query", "status"})
+
+// instrumentedRepository times and traces the queries of a
+// MetricDataRepository. Only the cache misses of LoadData reach the
+// repository. The requests of the backends to their servers become child
+// spans via tracing.Transport.
+type instrumentedRepository struct {
+	MetricDataRepository
+	cluster, backend string
+}
+
+func (r *instrumentedRepository) start(ctx context.Context, query string, metrics []string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "metricdata "+query, trace.WithAttributes(
+		attribute.String("cc.cluster", r.cluster),
+		attribute.String("cc.metricdata.backend", r.backend),
+		attribute.StringSlice("cc.metricdata.metrics", metrics)))
+}
+
+func (r *instrumentedRepository) observe(span trace.Span, query string, start time.Time, err error) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+	queryDuration.WithLabelValues(r.cluster, r.backend, query, status).Observe(time.Since(start).Seconds())
+}
+
+func (r *instrumentedRepository) LoadData(
+	job *schema.Job,
+	metrics []string,
+	scopes []schema.MetricScope,
+	ctx context.Context) (schema.JobData, error) {
+
+	ctx, span := r.start(ctx, "data", metrics)
+	start := time.Now()
+	data, err := r.MetricDataRepository.LoadData(job, metrics, scopes, ctx)
+	r.observe(span, "data", start, err)
+	return data, err
+}
+
+func (r *instrumentedRepository) LoadStats(
+	job *schema.Job,
+	metrics []string,
+	ctx context.Context) (map[string]map[string]schema.MetricStatistics, error) {
+
+	ctx, span := r.start(ctx, "stats", metrics)
+	start := time.Now()
+	stats, err := r.MetricDataRepository.LoadStats(job, metrics, ctx)
+	r.observe(span, "stats", start, err)
+	return stats, err
+}
+
+func (r *instrumentedRepository) LoadNodeData(
+	cluster string,
+	metrics, nodes []string,
+	scopes []schema.MetricScope,
+	from, to time.Time,
+	ctx context.Context) (map[string]map[string][]*schema.JobMetric, error) {
+
+	ctx, span := r.start(ctx, "node_data", metrics)
+	start := time.Now()
+	data, err := r.MetricDataRepository.LoadNodeData(cluster, metrics, nodes, scopes, from, to, ctx)
+	r.observe(span, "node_data", start, err)
+	return data, err
+}
@@ -9,9 +9,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/metrics"
 	"github.com/Deepbinder-main/cc-backend/pkg/archive"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
@@ -31,6 +33,9 @@ type MetricDataRepository interface {
 
 	// Return a map of hosts to a map of metrics at the requested scopes for that node.
 	LoadNodeData(cluster string, metrics, nodes []string, scopes []schema.MetricScope, from, to time.Time, ctx context.Context) (map[string]map[string][]*schema.JobMetric, error)
+
+	// Return an error if the backend cannot be queried. Used by the readiness probe.
+	HealthCheck(ctx context.Context) error
 }
 
 var metricDataRepos map[string]MetricDataRepository = map[string]MetricDataRepository{}
@@ -68,14 +73,41 @@ func Init(disableArchive bool) error {
 				log.Errorf("Error initializing MetricDataRepository %v for cluster %v", kind.Kind, cluster.Name)
 				return err
 			}
-			metricDataRepos[cluster.Name] = mdr
+			metricDataRepos[cluster.Name] = &instrumentedRepository{
+				MetricDataRepository: mdr,
+				cluster:              cluster.Name,
+				backend:              kind.Kind,
+			}
 		}
 	}
 	return nil
 }
 
+// Clusters returns the names of the clusters with a metric data repository.
+func Clusters() []string {
+	clusters := make([]string, 0, len(metricDataRepos))
+	for cluster := range metricDataRepos {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	return clusters
+}
+
+// HealthCheck checks the metric data repository of the cluster.
+func HealthCheck(ctx context.Context, cluster string) error {
+	repo, ok := metricDataRepos[cluster]
+	if !ok {
+		return fmt.Errorf("METRICDATA/METRICDATA > no metric data repository configured for '%s'", cluster)
+	}
+	return repo.HealthCheck(ctx)
+}
+
 var cache *lrucache.Cache = lrucache.New(128 * 1024 * 1024)
 
+func init() {
+	metrics.RegisterCache("metricdata", cache)
+}
+
 // Fetches the metric data for a job.
 func LoadData(job *schema.Job,
 	metrics []string,
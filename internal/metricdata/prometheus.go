@@ -20,6 +20,7 @@ import (
 	"text/template"
 	"time"
 
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
 	"github.com/Deepbinder-main/cc-backend/pkg/archive"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
@@ -155,6 +156,11 @@ func nodeRegex(nodes []string) string {
 	return trieRegex(root, true)
 }
 
+func (pdb *PrometheusDataRepository) HealthCheck(ctx context.Context) error {
+	_, err := pdb.queryClient.Buildinfo(ctx)
+	return err
+}
+
 func (pdb *PrometheusDataRepository) Init(rawConfig json.RawMessage) error {
 	var config PrometheusDataRepositoryConfig
 	// parse config
@@ -163,7 +169,7 @@ func (pdb *PrometheusDataRepository) Init(rawConfig json.RawMessage) error {
 		return err
 	}
 	// support basic authentication
-	var rt http.RoundTripper = nil
+	var rt http.RoundTripper = promapi.DefaultRoundTripper
 	if prom_pw := os.Getenv("PROMETHEUS_PASSWORD"); prom_pw != "" && config.Username != "" {
 		prom_pw := promcfg.Secret(prom_pw)
 		rt = promcfg.NewBasicAuthRoundTripper(config.Username, prom_pw, "", promapi.DefaultRoundTripper)
@@ -175,7 +181,7 @@ func (pdb *PrometheusDataRepository) Init(rawConfig json.RawMessage) error {
 	// init client
 	client, err := promapi.NewClient(promapi.Config{
 		Address:      config.Url,
-		RoundTripper: rt,
+		RoundTripper: tracing.Transport(rt),
 	})
 	if err != nil {
 		log.Error("Error while initializing new prometheus client")
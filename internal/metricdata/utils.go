@@ -39,6 +39,10 @@ func (tmdr *TestMetricDataRepository) LoadStats(
 	panic("TODO")
 }
 
+func (tmdr *TestMetricDataRepository) HealthCheck(ctx context.Context) error {
+	return nil
+}
+
 func (tmdr *TestMetricDataRepository) LoadNodeData(
 	cluster string,
 	metrics, nodes []string,
@@ -0,0 +1,141 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package metrics exposes metrics about cc-backend itself to Prometheus.
+// Packages define their own metrics with promauto, this package adds the
+// HTTP metrics and collects the statistics of connection pools and caches.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Deepbinder-main/cc-backend/internal/util"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
+	"github.com/felixge/httpsnoop"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "cc",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "cc",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests by route, method and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Middleware counts and times requests. The route is the path template of
+// the matched mux route (e.g. /api/machines/{id}), so the number of series
+// does not grow with the IDs in the URLs. Use it with mux.Router.Use.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		route := "unknown"
+		if current := mux.CurrentRoute(r); current != nil {
+			if tmpl, err := current.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		m := httpsnoop.CaptureMetrics(next, rw, r)
+		status := strconv.Itoa(m.Code)
+		httpRequests.WithLabelValues(route, r.Method, status).Inc()
+		httpDuration.WithLabelValues(route, r.Method, status).Observe(m.Duration.Seconds())
+	})
+}
+
+// RegisterDB exposes the statistics of the connection pool as go_sql_*
+// metrics with the label db_name set to name.
+func RegisterDB(name string, db *sql.DB) {
+	if err := prometheus.Register(collectors.NewDBStatsCollector(db, name)); err != nil {
+		log.Warnf("registering the metrics of database '%s' failed: %v", name, err)
+	}
+}
+
+// Handler serves the metrics to clients with an IP in allowedIPs, or to all
+// clients if the first entry is "*".
+func Handler(allowedIPs []string) http.Handler {
+	metrics := promhttp.Handler()
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if len(allowedIPs) == 0 || (allowedIPs[0] != "*" && !util.Contains(allowedIPs, util.ClientIP(r))) {
+			http.Error(rw, "forbidden", http.StatusForbidden)
+			return
+		}
+		metrics.ServeHTTP(rw, r)
+	})
+}
+
+var caches = &cacheCollector{caches: map[string]*lrucache.Cache{}}
+
+func init() {
+	prometheus.MustRegister(caches)
+}
+
+// RegisterCache exposes the statistics of the cache as cc_lrucache_*
+// metrics with the label cache set to name. A cache registered under the
+// same name before is replaced.
+func RegisterCache(name string, c *lrucache.Cache) {
+	caches.mu.Lock()
+	defer caches.mu.Unlock()
+	caches.caches[name] = c
+}
+
+// cacheCollector reads the statistics of the caches on every scrape.
+type cacheCollector struct {
+	mu     sync.Mutex
+	caches map[string]*lrucache.Cache
+}
+
+var (
+	cacheHits      = cacheDesc("hits_total", "Lookups that found a value.")
+	cacheMisses    = cacheDesc("misses_total", "Lookups that had to compute the value or found none.")
+	cacheEvictions = cacheDesc("evictions_total", "Entries removed because they expired or to free memory.")
+	cacheEntries   = cacheDesc("entries", "Number of entries.")
+	cacheMemory    = cacheDesc("memory_bytes", "Estimated size of the entries.")
+	cacheMaxMemory = cacheDesc("max_memory_bytes", "Size the entries are limited to.")
+	cacheDescs     = []*prometheus.Desc{cacheHits, cacheMisses, cacheEvictions, cacheEntries, cacheMemory, cacheMaxMemory}
+)
+
+var _ prometheus.Collector = &cacheCollector{}
+
+func cacheDesc(name, help string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName("cc", "lrucache", name), help, []string{"cache"}, nil)
+}
+
+func (cc *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range cacheDescs {
+		ch <- desc
+	}
+}
+
+func (cc *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	for name, c := range cc.caches {
+		stats := c.Stats()
+		ch <- prometheus.MustNewConstMetric(cacheHits, prometheus.CounterValue, float64(stats.Hits), name)
+		ch <- prometheus.MustNewConstMetric(cacheMisses, prometheus.CounterValue, float64(stats.Misses), name)
+		ch <- prometheus.MustNewConstMetric(cacheEvictions, prometheus.CounterValue, float64(stats.Evictions), name)
+		ch <- prometheus.MustNewConstMetric(cacheEntries, prometheus.GaugeValue, float64(stats.Entries), name)
+		ch <- prometheus.MustNewConstMetric(cacheMemory, prometheus.GaugeValue, float64(stats.UsedMemory), name)
+		ch <- prometheus.MustNewConstMetric(cacheMaxMemory, prometheus.GaugeValue, float64(stats.MaxMemory), name)
+	}
+}
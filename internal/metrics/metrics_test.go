@@ -0,0 +1,84 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := mux.NewRouter()
+	r.HandleFunc("/api/machines/{id}", func(rw http.ResponseWriter, r *http.Request) {
+		if mux.Vars(r)["id"] == "missing" {
+			http.Error(rw, "not found", http.StatusNotFound)
+		}
+	})
+	r.Use(Middleware)
+
+	counted := func(status string) float64 {
+		return testutil.ToFloat64(httpRequests.WithLabelValues("/api/machines/{id}", http.MethodGet, status))
+	}
+	ok, notFound := counted("200"), counted("404")
+	for _, id := range []string{"a", "b", "missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/machines/"+id, nil))
+	}
+
+	if n := counted("200") - ok; n != 2 {
+		t.Errorf("expected 2 successful requests, got %v", n)
+	}
+	if n := counted("404") - notFound; n != 1 {
+		t.Errorf("expected 1 failed request, got %v", n)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	c := lrucache.New(100)
+	RegisterCache("test", c)
+	_ = c.Get("foo", func() (interface{}, time.Duration, int) {
+		return "bar", time.Minute, 3
+	})
+	_ = c.Get("foo", nil)
+
+	scrape := func(allowedIPs []string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		req.RemoteAddr = "10.0.0.1:40000"
+		rw := httptest.NewRecorder()
+		Handler(allowedIPs).ServeHTTP(rw, req)
+		return rw
+	}
+
+	for _, allowed := range [][]string{nil, {"10.0.0.2"}} {
+		if rw := scrape(allowed); rw.Code != http.StatusForbidden {
+			t.Errorf("expected clients not in %v to be rejected, got %d", allowed, rw.Code)
+		}
+	}
+
+	for _, allowed := range [][]string{{"*"}, {"10.0.0.2", "10.0.0.1"}} {
+		rw := scrape(allowed)
+		if rw.Code != http.StatusOK {
+			t.Fatalf("expected clients in %v to be allowed, got %d", allowed, rw.Code)
+		}
+		body, _ := io.ReadAll(rw.Body)
+		for _, line := range []string{
+			`cc_lrucache_hits_total{cache="test"} 1`,
+			`cc_lrucache_misses_total{cache="test"} 1`,
+			`cc_lrucache_memory_bytes{cache="test"} 3`,
+			`cc_lrucache_max_memory_bytes{cache="test"} 100`,
+		} {
+			if !strings.Contains(string(body), line) {
+				t.Errorf("expected %q in the metrics", line)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// AMQP opens a connection to the broker at rawURL (amqp:// or amqps://),
+// authenticates with PLAIN and opens the virtual host given in the URL path.
+// username and password take precedence over credentials in the URL.
+func AMQP(ctx context.Context, rawURL, username, password string, opts Options) *Diagnostic {
+	start := time.Now()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d := newDiagnostic(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "amqp" && u.Scheme != "amqps") || u.Host == "" {
+		return d.fail(start, fmt.Errorf("invalid AMQP URL '%s'", rawURL))
+	}
+	d.Target = u.Redacted()
+
+	if username == "" && u.User != nil {
+		username = u.User.Username()
+	}
+	if password == "" && u.User != nil {
+		password, _ = u.User.Password()
+	}
+	if username == "" {
+		username, password = "guest", "guest"
+	}
+
+	vhost := "/"
+	if len(u.Path) > 1 {
+		vhost, _ = url.PathUnescape(u.Path[1:])
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "amqps" {
+			host = net.JoinHostPort(u.Hostname(), "5671")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "5672")
+		}
+	}
+
+	// Connected here and not by amqp.DialConfig, so that TCP and TLS
+	// failures are reported as step of their own
+	var conn net.Conn
+	if !d.step("connect", func() (string, error) {
+		var dialer net.Dialer
+		c, err := dialer.DialContext(ctx, "tcp", host)
+		if err != nil {
+			return "", err
+		}
+		if deadline, ok := ctx.Deadline(); ok {
+			c.SetDeadline(deadline)
+		}
+
+		if u.Scheme == "amqps" {
+			tc := tls.Client(c, opts.tlsConfig(u.Hostname()))
+			if err := tc.HandshakeContext(ctx); err != nil {
+				c.Close()
+				return "", err
+			}
+			state := tc.ConnectionState()
+			d.TLS = tlsInfo(&state, !opts.InsecureSkipVerify)
+			c = tc
+		}
+
+		conn = c
+		return c.RemoteAddr().String(), nil
+	}) {
+		return d.finish(start)
+	}
+
+	d.step("open", func() (string, error) {
+		c, err := amqp.Open(conn, amqp.Config{
+			SASL:       []amqp.Authentication{&amqp.PlainAuth{Username: username, Password: password}},
+			Vhost:      vhost,
+			Locale:     "en_US",
+			Properties: amqp.Table{"product": "cc-backend", "information": "connectivity probe"},
+		})
+		if err != nil {
+			conn.Close()
+			if errors.Is(err, amqp.ErrCredentials) {
+				return "", fmt.Errorf("authentication as '%s' failed: %w", username, err)
+			}
+			return "", err
+		}
+		defer c.Close()
+
+		return fmt.Sprintf("AMQP %d-%d, %v %v, authenticated as '%s', opened virtual host '%s'",
+			c.Major, c.Minor, c.Properties["product"], c.Properties["version"], username, vhost), nil
+	})
+
+	return d.finish(start)
+}
@@ -0,0 +1,187 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package probe
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+)
+
+type InfluxDBTarget struct {
+	URL    string // e.g. https://influx:8086
+	Token  string // API token, or "user:password" for v1 compatible auth
+	Org    string
+	Bucket string
+}
+
+// Measurement the InfluxDB probe writes its point to.
+const influxProbeMeasurement = "cc_backend_probe"
+
+// InfluxDB pings the server, then writes a point to a dedicated measurement
+// of the bucket, reads it back and deletes it again. The point is deleted
+// even if reading it failed.
+func InfluxDB(ctx context.Context, target InfluxDBTarget, opts Options) *Diagnostic {
+	start := time.Now()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d := newDiagnostic(target.URL)
+	base, err := url.Parse(strings.TrimSuffix(target.URL, "/"))
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return d.fail(start, fmt.Errorf("invalid InfluxDB URL '%s'", target.URL))
+	}
+	d.Target = base.Redacted()
+
+	httpClient := opts.httpClient()
+	httpClient.Transport = &tlsRecorder{next: httpClient.Transport, d: d, verified: !opts.InsecureSkipVerify}
+	client := influxdb2.NewClientWithOptions(base.String(), target.Token,
+		influxdb2.DefaultOptions().SetHTTPClient(httpClient))
+	defer client.Close()
+
+	if !d.step("ping", func() (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, base.String()+"/ping", nil)
+		if err != nil {
+			return "", err
+		}
+		res, body, err := d.roundTrip(httpClient, req, !opts.InsecureSkipVerify, 1024)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK {
+			return "", statusError(res, body)
+		}
+		if v := res.Header.Get("X-Influxdb-Version"); v != "" {
+			return "InfluxDB " + v, nil
+		}
+		return res.Status, nil
+	}) {
+		return d.finish(start)
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return d.fail(start, err)
+	}
+	probeID := hex.EncodeToString(id)
+	ts := time.Now().Truncate(time.Second)
+
+	if !d.step("write", func() (string, error) {
+		point := influxdb2.NewPoint(influxProbeMeasurement,
+			map[string]string{"probe": probeID}, map[string]interface{}{"value": 1}, ts)
+		if err := client.WriteAPIBlocking(target.Org, target.Bucket).WritePoint(ctx, point); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("wrote %s,probe=%s", influxProbeMeasurement, probeID), nil
+	}) {
+		return d.finish(start)
+	}
+
+	readOk := d.step("read", func() (string, error) {
+		query := fmt.Sprintf(`from(bucket: %q)
+	|> range(start: %s, stop: %s)
+	|> filter(fn: (r) => r._measurement == %q and r.probe == %q)`,
+			target.Bucket, ts.Add(-time.Second).Format(time.RFC3339), ts.Add(time.Second).Format(time.RFC3339),
+			influxProbeMeasurement, probeID)
+		result, err := client.QueryAPI(target.Org).Query(ctx, query)
+		if err != nil {
+			return "", err
+		}
+		defer result.Close()
+		for result.Next() {
+			if result.Record().Field() == "value" {
+				return "read back the point", nil
+			}
+		}
+		if err := result.Err(); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("point written to bucket '%s' not found", target.Bucket)
+	})
+
+	// Cleaning up also after a timeout of the previous steps
+	cleanupCtx, cleanupCancel := context.WithTimeout(context.WithoutCancel(ctx), DefaultTimeout/2)
+	defer cleanupCancel()
+	readErr := d.Error
+	d.step("delete", func() (string, error) {
+		predicate := fmt.Sprintf(`_measurement=%q AND probe=%q`, influxProbeMeasurement, probeID)
+		err := client.DeleteAPI().DeleteWithName(cleanupCtx, target.Org, target.Bucket,
+			ts.Add(-time.Second), ts.Add(time.Second), predicate)
+		if err != nil {
+			return "", err
+		}
+		return "deleted the point", nil
+	})
+	if !readOk {
+		d.Error = readErr
+	}
+
+	return d.finish(start)
+}
+
+// FileStash checks that the file stash URL answers a HEAD request and that
+// its listing can be retrieved.
+func FileStash(ctx context.Context, rawURL string, opts Options) *Diagnostic {
+	start := time.Now()
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	d := newDiagnostic(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return d.fail(start, fmt.Errorf("invalid file stash URL '%s'", rawURL))
+	}
+	d.Target = u.Redacted()
+
+	client := opts.httpClient()
+	verified := !opts.InsecureSkipVerify
+	send := func(method string) (*http.Response, []byte, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		return d.roundTrip(client, req, verified, 1024*1024)
+	}
+
+	if !d.step("head", func() (string, error) {
+		res, body, err := send(http.MethodHead)
+		if err != nil {
+			return "", err
+		}
+		// Listing servers commonly do not implement HEAD, the listing decides
+		if res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusNotImplemented {
+			return res.Status + ", HEAD not supported", nil
+		}
+		if res.StatusCode >= 400 {
+			return "", statusError(res, body)
+		}
+		if loc := res.Header.Get("Location"); loc != "" {
+			return res.Status + ", redirects to " + loc, nil
+		}
+		return res.Status, nil
+	}) {
+		return d.finish(start)
+	}
+
+	d.step("list", func() (string, error) {
+		res, body, err := send(http.MethodGet)
+		if err != nil {
+			return "", err
+		}
+		if res.StatusCode < 200 || res.StatusCode >= 300 {
+			return "", statusError(res, body)
+		}
+		return fmt.Sprintf("%s, %s, %d bytes", res.Status, res.Header.Get("Content-Type"), len(body)), nil
+	})
+
+	return d.finish(start)
+}
@@ -0,0 +1,179 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package probe checks whether the external services configured by an admin
+// (message broker, InfluxDB, file stash) are reachable and speak the expected
+// protocol, and reports what it found.
+package probe
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Upper bound for a complete probe if the caller does not set a deadline.
+const DefaultTimeout = 10 * time.Second
+
+// Diagnostic is the outcome of a probe. Steps are listed in the order they
+// were executed; a failed step ends the probe, except for steps cleaning up
+// after earlier ones.
+type Diagnostic struct {
+	Target    string   `json:"target"`
+	Ok        bool     `json:"ok"`
+	LatencyMs float64  `json:"latencyMs"`
+	Steps     []*Step  `json:"steps"`
+	TLS       *TLSInfo `json:"tls,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type Step struct {
+	Name      string  `json:"name"`
+	Ok        bool    `json:"ok"`
+	LatencyMs float64 `json:"latencyMs"`
+	Detail    string  `json:"detail,omitempty"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type TLSInfo struct {
+	Version     string    `json:"version"`
+	CipherSuite string    `json:"cipherSuite"`
+	ServerName  string    `json:"serverName,omitempty"`
+	Subject     string    `json:"subject,omitempty"`
+	Issuer      string    `json:"issuer,omitempty"`
+	NotAfter    time.Time `json:"notAfter,omitempty"`
+	Verified    bool      `json:"verified"`
+}
+
+// Options shared by all probes.
+type Options struct {
+	// Skip certificate verification. The TLS details are reported anyway.
+	InsecureSkipVerify bool
+	// Used for TLS connections, the system pool if nil.
+	RootCAs *x509.CertPool
+}
+
+func (o *Options) tlsConfig(serverName string) *tls.Config {
+	return &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+		RootCAs:            o.RootCAs,
+	}
+}
+
+func newDiagnostic(target string) *Diagnostic {
+	return &Diagnostic{Target: target, Steps: make([]*Step, 0)}
+}
+
+// step runs fn as named step and records its duration and outcome. It
+// returns false if fn failed, in which case the diagnostic is finished.
+func (d *Diagnostic) step(name string, fn func() (string, error)) bool {
+	start := time.Now()
+	detail, err := fn()
+	s := &Step{Name: name, Ok: err == nil, LatencyMs: msSince(start), Detail: detail}
+	if err != nil {
+		s.Error = err.Error()
+		d.Error = name + ": " + err.Error()
+	}
+	d.Steps = append(d.Steps, s)
+	return err == nil
+}
+
+func (d *Diagnostic) finish(start time.Time) *Diagnostic {
+	d.LatencyMs = msSince(start)
+	d.Ok = d.Error == ""
+	return d
+}
+
+func (d *Diagnostic) fail(start time.Time, err error) *Diagnostic {
+	d.Error = err.Error()
+	return d.finish(start)
+}
+
+func msSince(t time.Time) float64 {
+	return float64(time.Since(t).Microseconds()) / 1000
+}
+
+func tlsInfo(state *tls.ConnectionState, verified bool) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+		Verified:    verified && len(state.VerifiedChains) > 0,
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		info.Subject = cert.Subject.String()
+		info.Issuer = cert.Issuer.String()
+		info.NotAfter = cert.NotAfter
+	}
+	return info
+}
+
+// httpClient returns a client for a single probe. Redirects are not followed
+// so that the status of the configured URL itself is reported.
+func (o *Options) httpClient() *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: o.tlsConfig(""), Proxy: http.ProxyFromEnvironment},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// roundTrip executes req and returns the response with a body of at most
+// limit bytes. The TLS details of the first response are recorded in d.
+func (d *Diagnostic) roundTrip(client *http.Client, req *http.Request, verified bool, limit int64) (*http.Response, []byte, error) {
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	if d.TLS == nil && res.TLS != nil {
+		d.TLS = tlsInfo(res.TLS, verified)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, limit))
+	return res, body, err
+}
+
+// tlsRecorder records the TLS details of the first response in d, for
+// clients of libraries that do not return the responses.
+type tlsRecorder struct {
+	next     http.RoundTripper
+	d        *Diagnostic
+	verified bool
+}
+
+func (t *tlsRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := t.next.RoundTrip(req)
+	if err == nil && t.d.TLS == nil && res.TLS != nil {
+		t.d.TLS = tlsInfo(res.TLS, t.verified)
+	}
+	return res, err
+}
+
+func statusError(res *http.Response, body []byte) error {
+	if len(body) > 200 {
+		body = body[:200]
+	}
+	return fmt.Errorf("unexpected status %s: %s", res.Status, body)
+}
+
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, DefaultTimeout)
+}
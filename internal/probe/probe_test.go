@@ -0,0 +1,388 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server side of the AMQP 0-9-1 connection handshake, see
+// https://www.rabbitmq.com/resources/specs/amqp0-9-1.pdf, section 4.2.
+const (
+	amqpFrameMethod       = 1
+	amqpFrameEnd          = 0xCE
+	amqpClassConnection   = 10
+	amqpConnectionStart   = 10
+	amqpConnectionStartOk = 11
+	amqpConnectionTune    = 30
+	amqpConnectionTuneOk  = 31
+	amqpConnectionOpen    = 40
+	amqpConnectionOpenOk  = 41
+	amqpConnectionClose   = 50
+	amqpConnectionCloseOk = 51
+)
+
+var amqpProtocolHeader = []byte{'A', 'M', 'Q', 'P', 0, 0, 9, 1}
+
+type amqpConn struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func (a *amqpConn) send(method uint16, args []byte) error {
+	var frame bytes.Buffer
+	frame.WriteByte(amqpFrameMethod)
+	binary.Write(&frame, binary.BigEndian, uint16(0))
+	binary.Write(&frame, binary.BigEndian, uint32(4+len(args)))
+	binary.Write(&frame, binary.BigEndian, uint16(amqpClassConnection))
+	binary.Write(&frame, binary.BigEndian, method)
+	frame.Write(args)
+	frame.WriteByte(amqpFrameEnd)
+	_, err := a.w.Write(frame.Bytes())
+	return err
+}
+
+// expect reads the next method frame and returns its arguments if it is
+// the given connection method.
+func (a *amqpConn) expect(method uint16) ([]byte, error) {
+	header := make([]byte, 7)
+	if _, err := io.ReadFull(a.r, header); err != nil {
+		return nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header[3:7])+1)
+	if _, err := io.ReadFull(a.r, payload); err != nil {
+		return nil, err
+	}
+	if header[0] != amqpFrameMethod || len(payload) < 5 ||
+		binary.BigEndian.Uint16(payload[0:2]) != amqpClassConnection || binary.BigEndian.Uint16(payload[2:4]) != method {
+		return nil, fmt.Errorf("expected connection method %d, got %v", method, payload)
+	}
+	return payload[4 : len(payload)-1], nil
+}
+
+func amqpWriteShortString(b *bytes.Buffer, s string) {
+	b.WriteByte(byte(len(s)))
+	b.WriteString(s)
+}
+
+func amqpWriteLongString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.BigEndian, uint32(len(s)))
+	b.WriteString(s)
+}
+
+func amqpWriteTable(b *bytes.Buffer, table map[string]string) {
+	var t bytes.Buffer
+	for k, v := range table {
+		amqpWriteShortString(&t, k)
+		t.WriteByte('S')
+		amqpWriteLongString(&t, v)
+	}
+	binary.Write(b, binary.BigEndian, uint32(t.Len()))
+	b.Write(t.Bytes())
+}
+
+func amqpReadShortString(b []byte) (string, []byte) {
+	if len(b) < 1 || len(b) < 1+int(b[0]) {
+		return "", nil
+	}
+	return string(b[1 : 1+b[0]]), b[1+b[0]:]
+}
+
+func amqpReadLongString(b []byte) (string, []byte) {
+	if len(b) < 4 || uint32(len(b)-4) < binary.BigEndian.Uint32(b[0:4]) {
+		return "", nil
+	}
+	n := binary.BigEndian.Uint32(b[0:4])
+	return string(b[4 : 4+n]), b[4+n:]
+}
+
+// fakeBroker accepts AMQP connections and performs the server side of the
+// handshake, accepting only the given credentials and virtual host.
+func fakeBroker(t *testing.T, username, password, vhost string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeBroker(conn, username, password, vhost)
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func serveFakeBroker(conn net.Conn, username, password, vhost string) {
+	defer conn.Close()
+	a := &amqpConn{r: bufio.NewReader(conn), w: conn}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(a.r, header); err != nil || !bytes.Equal(header, amqpProtocolHeader) {
+		conn.Write(amqpProtocolHeader)
+		return
+	}
+
+	var b bytes.Buffer
+	b.Write([]byte{0, 9})
+	amqpWriteTable(&b, map[string]string{"product": "FakeMQ", "version": "1.0"})
+	amqpWriteLongString(&b, "AMQPLAIN PLAIN")
+	amqpWriteLongString(&b, "en_US")
+	a.send(amqpConnectionStart, b.Bytes())
+
+	args, err := a.expect(amqpConnectionStartOk)
+	if err != nil || len(args) < 4 {
+		return
+	}
+	// Skip the client properties
+	rest := args[4+binary.BigEndian.Uint32(args[0:4]):]
+	mechanism, rest := amqpReadShortString(rest)
+	response, _ := amqpReadLongString(rest)
+	if mechanism != "PLAIN" || response != "\x00"+username+"\x00"+password {
+		// RabbitMQ drops the connection on failed authentication
+		return
+	}
+
+	b.Reset()
+	binary.Write(&b, binary.BigEndian, uint16(2047))
+	binary.Write(&b, binary.BigEndian, uint32(131072))
+	binary.Write(&b, binary.BigEndian, uint16(0))
+	a.send(amqpConnectionTune, b.Bytes())
+
+	if _, err := a.expect(amqpConnectionTuneOk); err != nil {
+		return
+	}
+	args, err = a.expect(amqpConnectionOpen)
+	if err != nil {
+		return
+	}
+	if v, _ := amqpReadShortString(args); v != vhost {
+		b.Reset()
+		binary.Write(&b, binary.BigEndian, uint16(530))
+		amqpWriteShortString(&b, "NOT_ALLOWED - vhost "+v+" not found")
+		binary.Write(&b, binary.BigEndian, uint32(0))
+		a.send(amqpConnectionClose, b.Bytes())
+		return
+	}
+	b.Reset()
+	amqpWriteShortString(&b, "")
+	a.send(amqpConnectionOpenOk, b.Bytes())
+
+	if _, err := a.expect(amqpConnectionClose); err == nil {
+		a.send(amqpConnectionCloseOk, nil)
+	}
+}
+
+func TestAMQP(t *testing.T) {
+	addr := fakeBroker(t, "cc", "secret", "cluster")
+
+	d := AMQP(context.Background(), "amqp://"+addr+"/cluster", "cc", "secret", Options{})
+	if !d.Ok || len(d.Steps) != 2 {
+		t.Fatalf("probe failed: %#v", d)
+	}
+	if detail := d.Steps[1].Detail; !strings.HasPrefix(detail, "AMQP 0-9, FakeMQ 1.0") || !strings.Contains(detail, "'cluster'") {
+		t.Errorf("unexpected open detail %q", detail)
+	}
+
+	d = AMQP(context.Background(), "amqp://cc:wrong@"+addr+"/cluster", "", "", Options{})
+	if d.Ok || d.Steps[len(d.Steps)-1].Name != "open" || !strings.Contains(d.Error, "authentication as 'cc' failed") {
+		t.Errorf("expected authentication failure: %#v", d)
+	}
+	if strings.Contains(d.Target, "wrong") {
+		t.Errorf("target leaks password: %s", d.Target)
+	}
+
+	d = AMQP(context.Background(), "amqp://"+addr+"/other", "cc", "secret", Options{})
+	if d.Ok || !strings.Contains(d.Error, "vhost") {
+		t.Errorf("expected vhost failure: %#v", d)
+	}
+
+	d = AMQP(context.Background(), "http://"+addr, "cc", "secret", Options{})
+	if d.Ok || len(d.Steps) != 0 {
+		t.Errorf("expected invalid URL: %#v", d)
+	}
+}
+
+// fakeInfluxDB implements ping, write, query and delete for the bucket
+// "metrics" of the org "hpc". Points written to the bucket "blackhole" are
+// accepted but not stored. The probe IDs of the stored points are returned.
+func fakeInfluxDB(t *testing.T, token string) (*httptest.Server, *sync.Map) {
+	points := &sync.Map{}
+	probeID := regexp.MustCompile(`probe[=\\"\s]+([0-9a-f]{16})`)
+	authorized := func(rw http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Token "+token {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusUnauthorized)
+			io.WriteString(rw, `{"code":"unauthorized","message":"unauthorized access"}`)
+			return false
+		}
+		if r.URL.Query().Get("org") != "hpc" {
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusNotFound)
+			io.WriteString(rw, `{"code":"not found","message":"organization not found"}`)
+			return false
+		}
+		return true
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ping", func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("X-Influxdb-Version", "v2.7.1")
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/v2/write", func(rw http.ResponseWriter, r *http.Request) {
+		if !authorized(rw, r) {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		switch bucket := r.URL.Query().Get("bucket"); bucket {
+		case "metrics":
+			if !strings.HasPrefix(string(body), "cc_backend_probe,") {
+				t.Errorf("unexpected point: %s", body)
+			}
+			if m := probeID.FindSubmatch(body); m != nil {
+				points.Store(string(m[1]), true)
+			}
+		case "blackhole":
+		default:
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(http.StatusNotFound)
+			fmt.Fprintf(rw, `{"code":"not found","message":"bucket \"%s\" not found"}`, bucket)
+			return
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/api/v2/query", func(rw http.ResponseWriter, r *http.Request) {
+		if !authorized(rw, r) {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		rw.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		m := probeID.FindSubmatch(body)
+		if m == nil {
+			return
+		}
+		if _, ok := points.Load(string(m[1])); !ok {
+			return
+		}
+		fmt.Fprintf(rw, "#datatype,string,long,dateTime:RFC3339,dateTime:RFC3339,dateTime:RFC3339,long,string,string,string\r\n"+
+			"#group,false,false,true,true,false,false,true,true,true\r\n"+
+			"#default,_result,,,,,,,,\r\n"+
+			",result,table,_start,_stop,_time,_value,_field,_measurement,probe\r\n"+
+			",,0,2024-01-01T00:00:00Z,2024-01-01T00:00:02Z,2024-01-01T00:00:01Z,1,value,cc_backend_probe,%s\r\n\r\n", m[1])
+	})
+	mux.HandleFunc("/api/v2/delete", func(rw http.ResponseWriter, r *http.Request) {
+		if !authorized(rw, r) {
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `_measurement=\"cc_backend_probe\"`) {
+			t.Errorf("delete not restricted to the probe measurement: %s", body)
+		}
+		if m := probeID.FindSubmatch(body); m != nil {
+			points.Delete(string(m[1]))
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	srv := httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, points
+}
+
+func TestInfluxDB(t *testing.T) {
+	srv, points := fakeInfluxDB(t, "tok")
+	opts := Options{RootCAs: srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs}
+	stored := func() int {
+		n := 0
+		points.Range(func(_, _ any) bool { n++; return true })
+		return n
+	}
+
+	d := InfluxDB(context.Background(), InfluxDBTarget{URL: srv.URL, Token: "tok", Org: "hpc", Bucket: "metrics"}, opts)
+	if !d.Ok || len(d.Steps) != 4 {
+		t.Fatalf("probe failed: %#v", d)
+	}
+	for i, name := range []string{"ping", "write", "read", "delete"} {
+		if d.Steps[i].Name != name || !d.Steps[i].Ok {
+			t.Errorf("unexpected step %d: %#v", i, d.Steps[i])
+		}
+	}
+	if d.Steps[0].Detail != "InfluxDB v2.7.1" {
+		t.Errorf("unexpected ping detail %q", d.Steps[0].Detail)
+	}
+	if d.TLS == nil || !d.TLS.Verified || d.TLS.Version == "" {
+		t.Errorf("unexpected TLS details: %#v", d.TLS)
+	}
+	if n := stored(); n != 0 {
+		t.Errorf("probe left %d points behind", n)
+	}
+
+	d = InfluxDB(context.Background(), InfluxDBTarget{URL: srv.URL, Token: "tok", Org: "hpc", Bucket: "other"}, opts)
+	if d.Ok || d.Steps[len(d.Steps)-1].Name != "write" || !strings.Contains(d.Error, "not found") {
+		t.Errorf("expected bucket failure: %#v", d)
+	}
+	d = InfluxDB(context.Background(), InfluxDBTarget{URL: srv.URL, Token: "wrong", Org: "hpc", Bucket: "metrics"}, opts)
+	if d.Ok || !strings.Contains(d.Error, "unauthorized") {
+		t.Errorf("expected token failure: %#v", d)
+	}
+
+	// Points that cannot be read back are deleted anyway, the read is reported
+	d = InfluxDB(context.Background(), InfluxDBTarget{URL: srv.URL, Token: "tok", Org: "hpc", Bucket: "blackhole"}, opts)
+	if d.Ok || len(d.Steps) != 4 || !strings.HasPrefix(d.Error, "read:") || !d.Steps[3].Ok {
+		t.Errorf("expected read failure: %#v", d)
+	}
+
+	// Credentials in the URL are not reported
+	u := strings.Replace(srv.URL, "https://", "https://admin:pw@", 1)
+	if d = InfluxDB(context.Background(), InfluxDBTarget{URL: u, Token: "tok", Org: "hpc", Bucket: "metrics"}, opts); strings.Contains(d.Target, "pw") {
+		t.Errorf("target leaks password: %s", d.Target)
+	}
+
+	// Self-signed certificate without the test CA
+	d = InfluxDB(context.Background(), InfluxDBTarget{URL: srv.URL, Token: "tok", Org: "hpc", Bucket: "metrics"}, Options{})
+	if d.Ok || d.Steps[0].Name != "ping" || d.Steps[0].Ok {
+		t.Errorf("expected certificate failure: %#v", d)
+	}
+	d = InfluxDB(context.Background(), InfluxDBTarget{URL: srv.URL, Token: "tok", Org: "hpc", Bucket: "metrics"}, Options{InsecureSkipVerify: true})
+	if !d.Ok || d.TLS == nil || d.TLS.Verified {
+		t.Errorf("expected unverified success: %#v", d)
+	}
+}
+
+func TestFileStash(t *testing.T) {
+	srv := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	t.Cleanup(srv.Close)
+
+	d := FileStash(context.Background(), srv.URL+"/", Options{})
+	if !d.Ok || len(d.Steps) != 2 || d.TLS != nil {
+		t.Fatalf("probe failed: %#v", d)
+	}
+	if !strings.Contains(d.Steps[1].Detail, "text/html") {
+		t.Errorf("unexpected list detail %q", d.Steps[1].Detail)
+	}
+
+	d = FileStash(context.Background(), srv.URL+"/missing/", Options{})
+	if d.Ok || d.Steps[0].Name != "head" || !strings.Contains(d.Error, "404") {
+		t.Errorf("expected failure: %#v", d)
+	}
+}
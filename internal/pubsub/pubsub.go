@@ -0,0 +1,110 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package pubsub distributes events within the process. The REST and
+// GraphQL write paths publish into the topics below, GraphQL subscriptions
+// read from them. Nothing is persisted: subscribers only see events
+// published while they are subscribed.
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+)
+
+// Number of events a subscriber may fall behind before it misses events.
+const Buffer = 64
+
+var (
+	RealtimeLogs  = &Topic[*model.RealtimeLog]{}
+	Notifications = &Topic[*model.Notification]{}
+	MachineStatus = &Topic[*model.MachineStatusEvent]{}
+	ConfigChanges = &Topic[*model.ConfigChange]{}
+)
+
+// New versions of the singleton configs are published on ConfigChanges, so
+// that agents subscribed to configChanged can reload them without a restart.
+func init() {
+	for _, configType := range []string{repository.ConfigRabbitMQ, repository.ConfigInfluxDB, repository.ConfigFileStash} {
+		repository.OnConfigChange(configType, ConfigChanged)
+	}
+}
+
+// ConfigChanged publishes a new version of a config on ConfigChanges. The
+// data is left out, it may contain credentials.
+func ConfigChanged(v *repository.ConfigVersion) {
+	ConfigChanges.Publish(&model.ConfigChange{
+		ConfigType: v.ConfigType,
+		Version:    int(v.Version),
+		Action:     v.Action,
+		CreatedBy:  v.CreatedBy,
+		At:         time.Unix(v.CreatedAt, 0),
+	})
+}
+
+// MachineChanged publishes a change of a machine on MachineStatus. req is
+// the LV request that changed, if any.
+func MachineChanged(machineID string, change model.MachineChange, req *model.LVRequest) {
+	MachineStatus.Publish(&model.MachineStatusEvent{MachineID: machineID, Change: change, LvRequest: req, At: time.Now()})
+}
+
+// Topic fans out published values to all current subscribers. The zero
+// value is ready to use.
+type Topic[T any] struct {
+	mu   sync.Mutex
+	subs map[chan T]func(T) bool
+}
+
+// Publish delivers v to all subscribers whose filter accepts it. It never
+// blocks; a subscriber with a full buffer misses v.
+func (t *Topic[T]) Publish(v T) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for ch, filter := range t.subs {
+		if filter != nil && !filter(v) {
+			continue
+		}
+		select {
+		case ch <- v:
+		default:
+			log.Warn("pubsub: subscriber too slow, dropping event")
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the values published until ctx is
+// done, at which point the channel is closed. If filter is not nil, only
+// values it accepts are delivered. filter is called while publishing and
+// must be cheap.
+func (t *Topic[T]) Subscribe(ctx context.Context, filter func(T) bool) <-chan T {
+	ch := make(chan T, Buffer)
+	t.mu.Lock()
+	if t.subs == nil {
+		t.subs = make(map[chan T]func(T) bool)
+	}
+	t.subs[ch] = filter
+	t.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		t.mu.Lock()
+		delete(t.subs, ch)
+		t.mu.Unlock()
+		close(ch)
+	}()
+	return ch
+}
+
+// Subscribers returns the number of current subscribers.
+func (t *Topic[T]) Subscribers() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.subs)
+}
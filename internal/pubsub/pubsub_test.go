@@ -0,0 +1,53 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTopic(t *testing.T) {
+	var topic Topic[int]
+	ctx, cancel := context.WithCancel(context.Background())
+
+	all := topic.Subscribe(ctx, nil)
+	even := topic.Subscribe(ctx, func(v int) bool { return v%2 == 0 })
+	if n := topic.Subscribers(); n != 2 {
+		t.Fatalf("expected 2 subscribers, got %d", n)
+	}
+
+	for i := 1; i <= 4; i++ {
+		topic.Publish(i)
+	}
+	for i := 1; i <= 4; i++ {
+		if v := <-all; v != i {
+			t.Errorf("expected %d, got %d", i, v)
+		}
+	}
+	if v1, v2 := <-even, <-even; v1 != 2 || v2 != 4 {
+		t.Errorf("unexpected filtered values %d, %d", v1, v2)
+	}
+
+	// Slow subscribers miss events instead of blocking the publisher
+	for i := 0; i < Buffer+10; i++ {
+		topic.Publish(i)
+	}
+	if len(all) != Buffer {
+		t.Errorf("expected a full buffer, got %d", len(all))
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for topic.Subscribers() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if n := topic.Subscribers(); n != 0 {
+		t.Errorf("expected no subscribers after cancel, got %d", n)
+	}
+	for range all {
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/metrics"
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// ApiToken describes an issued JWT by its ID (the 'jti' claim). The token
+// itself is not stored.
+type ApiToken struct {
+	ID         string   `json:"id"`
+	Username   string   `json:"username"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	MachineID  string   `json:"machineId,omitempty"` // machine an agent token is bound to
+	CreatedAt  int64    `json:"createdAt"`
+	ExpiresAt  int64    `json:"expiresAt,omitempty"`  // 0 if the token does not expire
+	LastUsedAt int64    `json:"lastUsedAt,omitempty"` // 0 if never used
+	RevokedAt  int64    `json:"revokedAt,omitempty"`  // 0 if not revoked
+}
+
+var apiTokenColumns = []string{"id", "username", "name", "scopes", "machine_id", "created_at", "expires_at", "last_used_at", "revoked_at"}
+
+func scanApiToken(row sq.RowScanner) (*ApiToken, error) {
+	t := &ApiToken{}
+	var scopes string
+	if err := row.Scan(&t.ID, &t.Username, &t.Name, &scopes, &t.MachineID, &t.CreatedAt, &t.ExpiresAt, &t.LastUsedAt, &t.RevokedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &t.Scopes); err != nil {
+		return nil, err
+	}
+	if t.Scopes == nil {
+		t.Scopes = []string{}
+	}
+	return t, nil
+}
+
+func CreateApiToken(ctx context.Context, runner sq.BaseRunner, t *ApiToken) error {
+	if t.Scopes == nil {
+		t.Scopes = []string{}
+	}
+	scopes, err := json.Marshal(t.Scopes)
+	if err != nil {
+		return err
+	}
+
+	_, err = sq.Insert("api_tokens").Columns(apiTokenColumns...).
+		Values(t.ID, t.Username, t.Name, string(scopes), t.MachineID, t.CreatedAt, t.ExpiresAt, t.LastUsedAt, t.RevokedAt).
+		RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// GetApiToken returns sql.ErrNoRows if there is no token with that ID.
+func GetApiToken(ctx context.Context, runner sq.BaseRunner, id string) (*ApiToken, error) {
+	return scanApiToken(sq.Select(apiTokenColumns...).From("api_tokens").
+		Where("id = ?", id).RunWith(runner).QueryRowContext(ctx))
+}
+
+// ListApiTokens returns the tokens of username (of all users if empty),
+// newest first.
+func ListApiTokens(ctx context.Context, runner sq.BaseRunner, username string) ([]*ApiToken, error) {
+	q := sq.Select(apiTokenColumns...).From("api_tokens").OrderBy("created_at DESC", "id")
+	if username != "" {
+		q = q.Where("username = ?", username)
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := make([]*ApiToken, 0)
+	for rows.Next() {
+		t, err := scanApiToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeApiToken marks the token as revoked. Call TokenDenylist.Revoked
+// once the change is committed so that this instance rejects the token
+// immediately; other instances notice within the denylist TTL.
+func RevokeApiToken(ctx context.Context, runner sq.BaseRunner, id string) error {
+	_, err := sq.Update("api_tokens").Set("revoked_at", time.Now().Unix()).
+		Where("id = ? AND revoked_at = 0", id).RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// RevokeUserApiTokens revokes all tokens of username and returns their IDs
+// for TokenDenylist.Revoked.
+func RevokeUserApiTokens(ctx context.Context, runner sq.BaseRunner, username string) ([]string, error) {
+	rows, err := sq.Select("id").From("api_tokens").Where("username = ? AND revoked_at = 0", username).
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		if err := RevokeApiToken(ctx, runner, id); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+const (
+	// How long the revocation state of a token is cached
+	tokenDenylistTTL = 30 * time.Second
+	// Minimum interval between updates of last_used_at of the same token
+	tokenTouchInterval = time.Minute
+)
+
+var (
+	tokenDenylistOnce     sync.Once
+	tokenDenylistInstance *TokenDenylist
+)
+
+// TokenDenylist answers whether a token ID may be used, caching the answer
+// so that authenticating a request does not need a database query.
+type TokenDenylist struct {
+	db    sq.BaseRunner
+	cache *lrucache.Cache
+
+	// Tokens whose use was recorded within the last tokenTouchInterval
+	touched *lrucache.Cache
+}
+
+func GetTokenDenylist() *TokenDenylist {
+	tokenDenylistOnce.Do(func() {
+		tokenDenylistInstance = NewTokenDenylist(GetConnection().DB)
+		metrics.RegisterCache("token-denylist", tokenDenylistInstance.cache)
+	})
+	return tokenDenylistInstance
+}
+
+func NewTokenDenylist(db sq.BaseRunner) *TokenDenylist {
+	return &TokenDenylist{
+		db:      db,
+		cache:   lrucache.New(1024 * 1024),
+		touched: lrucache.New(64 * 1024),
+	}
+}
+
+// IsRevoked reports whether the token with the given ID must be rejected.
+// IDs that are not stored (anymore) count as revoked.
+func (d *TokenDenylist) IsRevoked(id string) (bool, error) {
+	var lookupErr error
+	revoked := d.cache.Get(id, func() (interface{}, time.Duration, int) {
+		t, err := GetApiToken(context.Background(), d.db, id)
+		if err == sql.ErrNoRows {
+			return true, tokenDenylistTTL, len(id)
+		}
+		if err != nil {
+			lookupErr = err
+			return nil, 0, 0
+		}
+		return t.RevokedAt != 0, tokenDenylistTTL, len(id)
+	})
+
+	if lookupErr != nil {
+		d.cache.Del(id)
+		return false, lookupErr
+	}
+	// Callers that waited for a failed lookup of another goroutine get nil
+	isRevoked, ok := revoked.(bool)
+	if !ok {
+		return false, fmt.Errorf("looking up token %s failed", id)
+	}
+	return isRevoked, nil
+}
+
+// Revoked updates the cached state of a token revoked by this instance.
+func (d *TokenDenylist) Revoked(id string) {
+	d.cache.Put(id, true, len(id), tokenDenylistTTL)
+}
+
+// Touch records the use of a token. To keep authentication cheap, the
+// timestamp is written at most once per tokenTouchInterval.
+func (d *TokenDenylist) Touch(id string) {
+	now, due := time.Now(), false
+	d.touched.Get(id, func() (interface{}, time.Duration, int) {
+		due = true
+		return now, tokenTouchInterval, len(id)
+	})
+	if !due {
+		return
+	}
+
+	if _, err := sq.Update("api_tokens").Set("last_used_at", now.Unix()).
+		Where("id = ?", id).RunWith(d.db).Exec(); err != nil {
+		log.Warnf("updating last use of token %s failed: %v", id, err)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+// failingHooks fails all queries once release is closed.
+type failingHooks struct{ release chan struct{} }
+
+func (h *failingHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	<-h.release
+	return ctx, errors.New("database is locked")
+}
+
+func (h *failingHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, nil
+}
+
+func TestTokenDenylistLookupError(t *testing.T) {
+	hooks := &failingHooks{release: make(chan struct{})}
+	sql.Register("sqlite3-failing", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, hooks))
+	db, err := sql.Open("sqlite3-failing", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	// The later lookups wait for the first one, which fails
+	denylist := NewTokenDenylist(db)
+	errs := make([]error, 4)
+	var wg sync.WaitGroup
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = denylist.IsRevoked("t1")
+		}(i)
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(hooks.release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil {
+			t.Errorf("expected lookup %d to fail", i)
+		}
+	}
+}
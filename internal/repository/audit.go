@@ -0,0 +1,312 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	sq "github.com/Masterminds/squirrel"
+)
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// Replacement for values of fields that hold credentials.
+const AuditRedacted = "[REDACTED]"
+
+// Field names (lower case, without separators) containing one of these
+// fragments are never written to the audit log in clear text.
+var auditSecretFields = []string{"password", "passphrase", "hostkey", "secret", "token", "privatekey"}
+
+// AuditActor identifies who triggered a change. It is stored in the request
+// context by the API layer so that repository functions can record it.
+type AuditActor struct {
+	Username string
+	AuthType string
+	SourceIP string
+}
+
+type AuditEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  int64           `json:"createdAt"`
+	Actor      string          `json:"actor"`
+	AuthType   string          `json:"authType"`
+	SourceIP   string          `json:"sourceIp"`
+	Action     string          `json:"action"`
+	Resource   string          `json:"resource"`
+	ResourceID string          `json:"resourceId"`
+	Diff       json.RawMessage `json:"diff,omitempty" swaggertype:"object"`
+}
+
+type AuditFilter struct {
+	Actor      string
+	Action     string
+	Resource   string
+	ResourceID string
+	From       int64 // unix seconds, 0 for no lower bound
+	To         int64 // unix seconds, 0 for no upper bound
+	Limit      uint64
+	Offset     uint64
+}
+
+const ContextAuditActorKey ContextKey = "audit-actor"
+
+func WithAuditActor(ctx context.Context, actor *AuditActor) context.Context {
+	return context.WithValue(ctx, ContextAuditActorKey, actor)
+}
+
+// GetAuditActorFromContext returns the actor stored by WithAuditActor. If
+// there is none, the authenticated user (if any) is used without source IP.
+func GetAuditActorFromContext(ctx context.Context) *AuditActor {
+	if actor, ok := ctx.Value(ContextAuditActorKey).(*AuditActor); ok && actor != nil {
+		return actor
+	}
+
+	if user := GetUserFromContext(ctx); user != nil {
+		return &AuditActor{Username: user.Username, AuthType: AuditAuthType(user.AuthType)}
+	}
+
+	return &AuditActor{Username: "system", AuthType: "internal"}
+}
+
+func AuditAuthType(t schema.AuthType) string {
+	switch t {
+	case schema.AuthToken:
+		return "token"
+	case schema.AuthSession:
+		return "session"
+	default:
+		return "unknown"
+	}
+}
+
+// RecordAudit inserts an audit entry using runner, which should be the
+// transaction the audited change is executed in.
+func RecordAudit(ctx context.Context, runner sq.BaseRunner, entry *AuditEntry) error {
+	if entry.CreatedAt == 0 {
+		entry.CreatedAt = time.Now().Unix()
+	}
+
+	var diff interface{}
+	if len(entry.Diff) != 0 {
+		diff = string(entry.Diff)
+	}
+
+	_, err := sq.Insert("audit_log").
+		Columns("created_at", "actor", "auth_type", "source_ip", "action", "resource", "resource_id", "diff").
+		Values(entry.CreatedAt, entry.Actor, entry.AuthType, entry.SourceIP, entry.Action, entry.Resource, entry.ResourceID, diff).
+		RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// RecordAuditFromContext records a change of resource by the actor found in
+// ctx. before and after are the states of the resource around the change
+// (nil if it did not exist) and are reduced to a redacted diff.
+func RecordAuditFromContext(
+	ctx context.Context,
+	runner sq.BaseRunner,
+	action, resource, resourceID string,
+	before, after interface{},
+) error {
+	actor := GetAuditActorFromContext(ctx)
+	diff, err := AuditDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	return RecordAudit(ctx, runner, &AuditEntry{
+		Actor:      actor.Username,
+		AuthType:   actor.AuthType,
+		SourceIP:   actor.SourceIP,
+		Action:     action,
+		Resource:   resource,
+		ResourceID: resourceID,
+		Diff:       diff,
+	})
+}
+
+// QueryAudit calls fn for every audit entry matching filter, newest first.
+func QueryAudit(ctx context.Context, runner sq.BaseRunner, filter AuditFilter, fn func(*AuditEntry) error) error {
+	q := sq.Select("id", "created_at", "actor", "auth_type", "source_ip", "action", "resource", "resource_id", "diff").
+		From("audit_log").OrderBy("created_at DESC", "id DESC")
+
+	if filter.Actor != "" {
+		q = q.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if filter.Resource != "" {
+		q = q.Where("resource = ?", filter.Resource)
+	}
+	if filter.ResourceID != "" {
+		q = q.Where("resource_id = ?", filter.ResourceID)
+	}
+	if filter.From != 0 {
+		q = q.Where("created_at >= ?", filter.From)
+	}
+	if filter.To != 0 {
+		q = q.Where("created_at <= ?", filter.To)
+	}
+	if filter.Limit != 0 {
+		q = q.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		entry := &AuditEntry{}
+		var diff sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.CreatedAt, &entry.Actor, &entry.AuthType, &entry.SourceIP,
+			&entry.Action, &entry.Resource, &entry.ResourceID, &diff); err != nil {
+			return err
+		}
+		if diff.Valid {
+			entry.Diff = json.RawMessage(diff.String)
+		}
+
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+type auditChange struct {
+	Old interface{} `json:"old,omitempty"`
+	New interface{} `json:"new,omitempty"`
+}
+
+// AuditDiff returns the fields that differ between before and after as JSON
+// object of the form {"Field": {"old": ..., "new": ...}}. Credentials are
+// replaced by AuditRedacted and passwords embedded in URLs are masked.
+func AuditDiff(before, after interface{}) (json.RawMessage, error) {
+	old, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	cur, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]auditChange)
+	for key, o := range old {
+		if n, ok := cur[key]; !ok || !reflect.DeepEqual(o, n) {
+			diff[key] = auditChange{Old: o, New: cur[key]}
+		}
+	}
+	for key, n := range cur {
+		if _, ok := old[key]; !ok {
+			diff[key] = auditChange{New: n}
+		}
+	}
+
+	for key, change := range diff {
+		change.Old = redactAuditValue(key, change.Old)
+		change.New = redactAuditValue(key, change.New)
+		diff[key] = change
+	}
+
+	if len(diff) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(diff)
+}
+
+// auditFields flattens v into a map of its JSON encoded top level fields.
+// sql.Null* wrappers are replaced by their value, null fields are dropped.
+func auditFields(v interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return fields, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+
+	for key, val := range fields {
+		if m, ok := val.(map[string]interface{}); ok && len(m) == 2 {
+			if valid, ok := m["Valid"].(bool); ok {
+				val = nil
+				if valid {
+					for k, inner := range m {
+						if k != "Valid" {
+							val = inner
+						}
+					}
+				}
+			}
+		}
+
+		if val == nil {
+			delete(fields, key)
+		} else {
+			fields[key] = val
+		}
+	}
+
+	return fields, nil
+}
+
+// redactAuditValue hides credentials in the value of field. Secret fields are
+// replaced as a whole, passwords embedded in URLs are masked.
+func redactAuditValue(field string, val interface{}) interface{} {
+	if val == nil {
+		return nil
+	}
+
+	if isAuditSecret(field) {
+		return AuditRedacted
+	}
+
+	if s, ok := val.(string); ok {
+		return RedactURL(s)
+	}
+
+	return val
+}
+
+// RedactURL masks the password of s if it is an URL with user info. Other
+// strings are returned unchanged.
+func RedactURL(s string) string {
+	if strings.Contains(s, "://") {
+		if u, err := url.Parse(s); err == nil {
+			return u.Redacted()
+		}
+	}
+	return s
+}
+
+func isAuditSecret(field string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(field))
+	for _, s := range auditSecretFields {
+		if strings.Contains(normalized, s) {
+			return true
+		}
+	}
+	return false
+}
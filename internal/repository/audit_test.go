@@ -0,0 +1,99 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestAuditDiff(t *testing.T) {
+	type conf struct {
+		Hostname string
+		HostKey  sql.NullString
+		Password sql.NullString
+		ConnUrl  string
+	}
+
+	before := conf{Hostname: "a", HostKey: sql.NullString{String: "k1", Valid: true}, ConnUrl: "amqp://u:pw@mq/"}
+	after := conf{Hostname: "a", HostKey: sql.NullString{String: "k2", Valid: true},
+		Password: sql.NullString{String: "pw", Valid: true}, ConnUrl: "amqp://u:pw2@mq/"}
+
+	raw, err := AuditDiff(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var diff map[string]map[string]interface{}
+	if err := json.Unmarshal(raw, &diff); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := diff["Hostname"]; ok {
+		t.Errorf("unchanged field in diff: %s", raw)
+	}
+	if diff["HostKey"]["old"] != AuditRedacted || diff["HostKey"]["new"] != AuditRedacted {
+		t.Errorf("host key not redacted: %s", raw)
+	}
+	if _, ok := diff["Password"]["old"]; ok || diff["Password"]["new"] != AuditRedacted {
+		t.Errorf("unexpected password diff: %s", raw)
+	}
+	if diff["ConnUrl"]["new"] != "amqp://u:xxxxx@mq/" {
+		t.Errorf("URL credentials not masked: %s", raw)
+	}
+
+	if raw, err := AuditDiff(before, before); err != nil || raw != nil {
+		t.Errorf("expected empty diff, got %s (%v)", raw, err)
+	}
+}
+
+func TestAuditUserRoleChange(t *testing.T) {
+	dbfilepath := filepath.Join(t.TempDir(), "audit.db")
+	if err := MigrateDB("sqlite3", dbfilepath); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlx.Open("sqlite3", dbfilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	r := &UserRepository{DB: db, driver: "sqlite3"}
+	if err := r.AddUser(&schema.User{Username: "bob", Roles: []string{"user"}, Projects: []string{}, AuthSource: -1}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithAuditActor(context.Background(), &AuditActor{Username: "alice", AuthType: "session", SourceIP: "10.0.0.1"})
+	if err := r.AddRole(ctx, "bob", "support"); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []*AuditEntry
+	if err := QueryAudit(ctx, db, AuditFilter{Resource: "user"}, func(e *AuditEntry) error {
+		entries = append(entries, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	e := entries[0]
+	if e.Actor != "alice" || e.SourceIP != "10.0.0.1" || e.Action != AuditUpdate || e.ResourceID != "bob" {
+		t.Errorf("unexpected entry: %#v", e)
+	}
+	if string(e.Diff) != `{"roles":{"old":["user"],"new":["user","support"]}}` {
+		t.Errorf("unexpected diff: %s", e.Diff)
+	}
+}
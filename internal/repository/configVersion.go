@@ -0,0 +1,233 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	sq "github.com/Masterminds/squirrel"
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Singleton configuration objects with a version history.
+const (
+	ConfigRabbitMQ  = "rabbitmq_config"
+	ConfigInfluxDB  = "influxdb_config"
+	ConfigFileStash = "file_stash_url"
+)
+
+// Version actions in addition to AuditCreate, AuditUpdate and AuditDelete.
+const (
+	// State found when the first change of a config is recorded
+	ConfigBaseline = "baseline"
+	ConfigRollback = "rollback"
+)
+
+func IsVersionedConfig(configType string) bool {
+	switch configType {
+	case ConfigRabbitMQ, ConfigInfluxDB, ConfigFileStash:
+		return true
+	default:
+		return false
+	}
+}
+
+// ConfigVersion is a snapshot of a config object. Data is nil for versions
+// in which the config was deleted.
+type ConfigVersion struct {
+	ConfigType string          `json:"configType"`
+	Version    int64           `json:"version"`
+	Action     string          `json:"action"`
+	Data       json.RawMessage `json:"data,omitempty" swaggertype:"object"`
+	CreatedAt  int64           `json:"createdAt"`
+	CreatedBy  string          `json:"createdBy"`
+}
+
+// Redacted returns a copy of v with credentials in Data replaced.
+func (v *ConfigVersion) Redacted() *ConfigVersion {
+	c := *v
+	if len(v.Data) == 0 {
+		return &c
+	}
+
+	fields, err := auditFields(v.Data)
+	if err != nil {
+		c.Data = nil
+		return &c
+	}
+	for key, val := range fields {
+		fields[key] = redactAuditValue(key, val)
+	}
+	c.Data, _ = json.Marshal(fields)
+	return &c
+}
+
+// ErrConfigVersionConflict is returned by RecordConfigVersion if another
+// transaction recorded the same version first. The whole transaction should
+// be retried, the next version number is only visible to a new one.
+var ErrConfigVersionConflict = errors.New("config was changed concurrently")
+
+// RecordConfigVersion appends the state after to the history of configType.
+// If the history is empty and before is not nil, before is recorded first as
+// baseline so that the state prior to the first tracked change can be
+// restored. runner should be the transaction the change is executed in.
+// Versions are unique per config type, concurrent changes fail with
+// ErrConfigVersionConflict.
+func RecordConfigVersion(
+	ctx context.Context,
+	runner sq.BaseRunner,
+	configType, action string,
+	before, after interface{},
+) (*ConfigVersion, error) {
+	var latest int64
+	if err := sq.Select("COALESCE(MAX(version), 0)").From("config_versions").
+		Where("config_type = ?", configType).
+		RunWith(runner).QueryRowContext(ctx).Scan(&latest); err != nil {
+		return nil, err
+	}
+
+	actor := GetAuditActorFromContext(ctx)
+	if latest == 0 && before != nil {
+		if _, err := insertConfigVersion(ctx, runner, configType, 1, ConfigBaseline, "", before); err != nil {
+			return nil, err
+		}
+		latest = 1
+	}
+
+	return insertConfigVersion(ctx, runner, configType, latest+1, action, actor.Username, after)
+}
+
+func insertConfigVersion(
+	ctx context.Context,
+	runner sq.BaseRunner,
+	configType string,
+	version int64,
+	action, createdBy string,
+	state interface{},
+) (*ConfigVersion, error) {
+	v := &ConfigVersion{
+		ConfigType: configType,
+		Version:    version,
+		Action:     action,
+		CreatedAt:  time.Now().Unix(),
+		CreatedBy:  createdBy,
+	}
+
+	var data interface{}
+	if state != nil {
+		raw, err := json.Marshal(state)
+		if err != nil {
+			return nil, err
+		}
+		v.Data = raw
+		data = string(raw)
+	}
+
+	if _, err := sq.Insert("config_versions").
+		Columns("config_type", "version", "action", "data", "created_at", "created_by").
+		Values(v.ConfigType, v.Version, v.Action, data, v.CreatedAt, v.CreatedBy).
+		RunWith(runner).ExecContext(ctx); err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrConfigVersionConflict
+		}
+		return nil, err
+	}
+
+	return v, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	}
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		return mysqlErr.Number == 1062 // ER_DUP_ENTRY
+	}
+	return false
+}
+
+func scanConfigVersion(row sq.RowScanner) (*ConfigVersion, error) {
+	v := &ConfigVersion{}
+	var data sql.NullString
+	if err := row.Scan(&v.ConfigType, &v.Version, &v.Action, &data, &v.CreatedAt, &v.CreatedBy); err != nil {
+		return nil, err
+	}
+	if data.Valid {
+		v.Data = json.RawMessage(data.String)
+	}
+	return v, nil
+}
+
+var configVersionColumns = []string{"config_type", "version", "action", "data", "created_at", "created_by"}
+
+// ListConfigVersions returns the history of configType, newest first.
+func ListConfigVersions(ctx context.Context, runner sq.BaseRunner, configType string) ([]*ConfigVersion, error) {
+	rows, err := sq.Select(configVersionColumns...).From("config_versions").
+		Where("config_type = ?", configType).OrderBy("version DESC").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]*ConfigVersion, 0)
+	for rows.Next() {
+		v, err := scanConfigVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, rows.Err()
+}
+
+// GetConfigVersion returns sql.ErrNoRows if the version does not exist.
+func GetConfigVersion(ctx context.Context, runner sq.BaseRunner, configType string, version int64) (*ConfigVersion, error) {
+	return scanConfigVersion(sq.Select(configVersionColumns...).From("config_versions").
+		Where("config_type = ? AND version = ?", configType, version).
+		RunWith(runner).QueryRowContext(ctx))
+}
+
+// ConfigListener is called after a new version of a config was committed.
+// Listeners are called synchronously and must not block.
+type ConfigListener func(v *ConfigVersion)
+
+var (
+	configListenersLock sync.RWMutex
+	configListeners     = make(map[string][]ConfigListener)
+)
+
+// OnConfigChange registers fn to be called whenever configType changes. The
+// configs are not used by cc-backend itself, only by agents and services
+// reading them via the API. The pubsub package forwards the changes to the
+// configChanged subscription, which these can watch to reload the configs.
+func OnConfigChange(configType string, fn ConfigListener) {
+	configListenersLock.Lock()
+	defer configListenersLock.Unlock()
+	configListeners[configType] = append(configListeners[configType], fn)
+}
+
+// NotifyConfigChange informs all listeners registered for v.ConfigType.
+func NotifyConfigChange(v *ConfigVersion) {
+	configListenersLock.RLock()
+	listeners := configListeners[v.ConfigType]
+	configListenersLock.RUnlock()
+
+	log.Infof("config '%s' changed to version %d (%s)", v.ConfigType, v.Version, v.Action)
+	for _, fn := range listeners {
+		fn(v)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestRecordConfigVersion(t *testing.T) {
+	dbfilepath := filepath.Join(t.TempDir(), "versions.db")
+	if err := MigrateDB("sqlite3", dbfilepath); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sqlx.Open("sqlite3", dbfilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := WithAuditActor(context.Background(), &AuditActor{Username: "alice"})
+	before, after := map[string]string{"url": "a"}, map[string]string{"url": "b"}
+	v, err := RecordConfigVersion(ctx, db, ConfigFileStash, AuditUpdate, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Version != 2 || v.CreatedBy != "alice" {
+		t.Errorf("unexpected version: %+v", v)
+	}
+	if baseline, err := GetConfigVersion(ctx, db, ConfigFileStash, 1); err != nil || baseline.Action != ConfigBaseline {
+		t.Errorf("expected baseline, got %+v (%v)", baseline, err)
+	}
+
+	// A transaction that read the history before a concurrent change was
+	// committed records a version that exists already
+	if _, err := insertConfigVersion(ctx, db, ConfigFileStash, 2, AuditUpdate, "bob", after); err != ErrConfigVersionConflict {
+		t.Errorf("expected %v, got %v", ErrConfigVersionConflict, err)
+	}
+	if v, err := RecordConfigVersion(ctx, db, ConfigFileStash, AuditUpdate, after, before); err != nil || v.Version != 3 {
+		t.Errorf("expected version 3, got %+v (%v)", v, err)
+	}
+}
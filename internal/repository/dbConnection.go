@@ -9,7 +9,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 	"github.com/mattn/go-sqlite3"
 	"github.com/qustavo/sqlhooks/v2"
@@ -55,8 +57,8 @@ func Connect(driver string, db string) {
 			// - Enable foreign key checks
 			opts.URL += "?_journal=WAL&_timeout=5000&_fk=true"
 
-			if log.Loglevel() == "debug" {
-				sql.Register("sqlite3WithHooks", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, &Hooks{}))
+			if log.Loglevel() == "debug" || tracing.Enabled() {
+				sql.Register("sqlite3WithHooks", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, &Hooks{Driver: driver}))
 				dbHandle, err = sqlx.Open("sqlite3WithHooks", opts.URL)
 			} else {
 				dbHandle, err = sqlx.Open("sqlite3", opts.URL)
@@ -66,7 +68,12 @@ func Connect(driver string, db string) {
 			}
 		case "mysql":
 			opts.URL += "?multiStatements=true"
-			dbHandle, err = sqlx.Open("mysql", opts.URL)
+			if tracing.Enabled() {
+				sql.Register("mysqlWithHooks", sqlhooks.Wrap(&mysql.MySQLDriver{}, &Hooks{Driver: driver}))
+				dbHandle, err = sqlx.Open("mysqlWithHooks", opts.URL)
+			} else {
+				dbHandle, err = sqlx.Open("mysql", opts.URL)
+			}
 			sqlconn = dbHandle.DB
 			if err != nil {
 				log.Fatalf("sqlx.Open() error: %v", err)
@@ -88,6 +95,12 @@ func Connect(driver string, db string) {
 	})
 }
 
+// CheckVersion returns an error if the database is not at the version this
+// build expects, i.e. if migrations are missing or failed.
+func (c *DBConnection) CheckVersion() error {
+	return checkDBVersion(c.Driver, c.DB.DB)
+}
+
 func GetConnection() *DBConnection {
 	if dbConnInstance == nil {
 		log.Fatalf("Database connection not initialized!")
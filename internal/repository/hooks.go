@@ -6,23 +6,81 @@ package repository
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/Deepbinder-main/cc-backend/internal/repository")
+
+type beginKey struct{}
+
 // Hooks satisfies the sqlhook.Hooks interface
-type Hooks struct{}
+type Hooks struct {
+	// Database driver, recorded in the spans
+	Driver string
+}
 
-// Before hook will print the query with it's args and return the context with the timestamp
+// Before hook will print the query, start a span and return the context with
+// the timestamp. The args are not logged, they include password hashes, TOTP
+// secrets and sealed secrets.
 func (h *Hooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	log.Debugf("SQL query %s %q", query, args)
-	return context.WithValue(ctx, "begin", time.Now()), nil
+	log.Debugf("SQL query %s (%d args)", query, len(args))
+	ctx, _ = tracer.Start(ctx, "sql "+operation(query),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			dbSystem(h.Driver),
+			semconv.DBQueryText(query)))
+	return context.WithValue(ctx, beginKey{}, time.Now()), nil
 }
 
-// After hook will get the timestamp registered on the Before hook and print the elapsed time
+// After hook will get the timestamp registered on the Before hook, print the
+// elapsed time and end the span
 func (h *Hooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
-	begin := ctx.Value("begin").(time.Time)
+	begin := ctx.Value(beginKey{}).(time.Time)
 	log.Debugf("Took: %s\n", time.Since(begin))
+	trace.SpanFromContext(ctx).End()
 	return ctx, nil
 }
+
+// OnError ends the span of a failed query, After is not called then.
+// driver.ErrSkip only makes database/sql retry with a prepared statement,
+// which gets a span of its own.
+func (h *Hooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+	if errors.Is(err, driver.ErrSkip) {
+		return err
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	return err
+}
+
+// dbSystem names the database as the OpenTelemetry conventions do
+func dbSystem(driver string) attribute.KeyValue {
+	switch driver {
+	case "sqlite3":
+		return semconv.DBSystemSqlite
+	case "mysql":
+		return semconv.DBSystemMySQL
+	default:
+		return semconv.DBSystemKey.String(driver)
+	}
+}
+
+// operation is the first keyword of the query, e.g. SELECT
+func operation(query string) string {
+	if fields := strings.Fields(query); len(fields) > 0 {
+		return strings.ToUpper(fields[0])
+	}
+	return "query"
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/tracing"
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestHooksTracing(t *testing.T) {
+	spans := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans))
+	tracing.Start(tp)
+	t.Cleanup(func() { tracing.Shutdown(context.Background()) })
+
+	sql.Register("sqlite3-traced", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, &Hooks{Driver: "sqlite3"}))
+	db, err := sql.Open("sqlite3-traced", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "Query.machines")
+	if _, err := db.ExecContext(ctx, "CREATE TABLE machine (hostname TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRowContext(ctx, "select count(*) from machine").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "INSERT INTO missing VALUES (1)"); err == nil {
+		t.Fatal("expected the insert to fail")
+	}
+	parent.End()
+
+	ended := spans.GetSpans()
+	if len(ended) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(ended))
+	}
+	for i, name := range []string{"sql CREATE", "sql SELECT", "sql INSERT"} {
+		span := ended[i]
+		if span.Name != name || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("expected %s below the resolver, got %s", name, span.Name)
+		}
+		found := false
+		for _, attr := range span.Attributes {
+			if attr == semconv.DBSystemSqlite {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected the database system in the attributes: %v", span.Attributes)
+		}
+	}
+	if ended[1].Status.Code == codes.Error {
+		t.Errorf("unexpected status: %v", ended[1].Status)
+	}
+	if failed := ended[2]; failed.Status.Code != codes.Error || len(failed.Events) == 0 {
+		t.Errorf("expected the failed query to be recorded: %+v", failed.Status)
+	}
+}
@@ -0,0 +1,142 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Kinds of keys failed logins are counted for.
+const (
+	LoginKeyUser = "user"
+	LoginKeyIP   = "ip"
+)
+
+// LoginAttempts counts the failed logins for a username or a source IP
+// since the last successful login or expired lockout.
+type LoginAttempts struct {
+	Kind        string `json:"kind"`
+	Name        string `json:"name"`
+	Failures    int    `json:"failures"`
+	LastFailure int64  `json:"lastFailure"`
+	LockedUntil int64  `json:"lockedUntil,omitempty"` // 0 if not locked
+}
+
+var loginAttemptsColumns = []string{"kind", "name", "failures", "last_failure", "locked_until"}
+
+func scanLoginAttempts(row sq.RowScanner) (*LoginAttempts, error) {
+	a := &LoginAttempts{}
+	if err := row.Scan(&a.Kind, &a.Name, &a.Failures, &a.LastFailure, &a.LockedUntil); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// GetLoginAttempts returns the failed logins for name, without failures if
+// none were recorded.
+func GetLoginAttempts(ctx context.Context, runner sq.BaseRunner, kind, name string) (*LoginAttempts, error) {
+	a, err := scanLoginAttempts(sq.Select(loginAttemptsColumns...).From("login_attempts").
+		Where("kind = ? AND name = ?", kind, name).RunWith(runner).QueryRowContext(ctx))
+	if err == sql.ErrNoRows {
+		return &LoginAttempts{Kind: kind, Name: name}, nil
+	}
+	return a, err
+}
+
+// expiredLoginAttempts matches counts whose lockout ended at now or whose
+// last failure is older than forgetBefore, if not locked.
+const expiredLoginAttempts = "(locked_until <> 0 AND locked_until <= ?) OR (locked_until = 0 AND last_failure < ?)"
+
+// CountLoginFailure atomically adds a failed login at now (unix seconds) to
+// the count for name, which starts again at one if it expired. It returns
+// the updated count.
+func CountLoginFailure(ctx context.Context, runner sq.BaseRunner, driver, kind, name string, now, forgetBefore int64) (*LoginAttempts, error) {
+	// MySQL sees the columns already assigned in the same statement, so
+	// last_failure is set last
+	update := "failures = CASE WHEN " + expiredLoginAttempts + " THEN 1 ELSE failures + 1 END, " +
+		"locked_until = CASE WHEN " + expiredLoginAttempts + " THEN 0 ELSE locked_until END, " +
+		"last_failure = ?"
+	switch driver {
+	case "mysql":
+		update = "ON DUPLICATE KEY UPDATE " + update
+	default:
+		update = "ON CONFLICT (kind, name) DO UPDATE SET " + update
+	}
+
+	if _, err := sq.Insert("login_attempts").Columns(loginAttemptsColumns...).
+		Values(kind, name, 1, now, 0).
+		Suffix(update, now, forgetBefore, now, forgetBefore, now).
+		RunWith(runner).ExecContext(ctx); err != nil {
+		return nil, err
+	}
+	return GetLoginAttempts(ctx, runner, kind, name)
+}
+
+// LockLoginAttempts locks name until the given time if it has at least
+// limit failures and is not locked yet. It reports whether it did, so that
+// only one of concurrent failures reports the lockout.
+func LockLoginAttempts(ctx context.Context, runner sq.BaseRunner, kind, name string, limit int, until int64) (bool, error) {
+	res, err := sq.Update("login_attempts").Set("locked_until", until).
+		Where("kind = ? AND name = ? AND locked_until = 0 AND failures >= ?", kind, name, limit).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteLoginAttempts forgets the failed logins for name, which also lifts
+// a lockout. It returns sql.ErrNoRows if there were none.
+func DeleteLoginAttempts(ctx context.Context, runner sq.BaseRunner, kind, name string) error {
+	res, err := sq.Delete("login_attempts").Where("kind = ? AND name = ?", kind, name).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteExpiredLoginAttempts removes the counts that expired at now or
+// whose last failure is older than forgetBefore (unix seconds), so that
+// guessed usernames do not pile up. It returns the number removed.
+func DeleteExpiredLoginAttempts(ctx context.Context, runner sq.BaseRunner, now, forgetBefore int64) (int64, error) {
+	res, err := sq.Delete("login_attempts").Where(expiredLoginAttempts, now, forgetBefore).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListLoginLockouts returns the usernames and IPs locked at time now
+// (unix seconds), the latest lockout first.
+func ListLoginLockouts(ctx context.Context, runner sq.BaseRunner, now int64) ([]*LoginAttempts, error) {
+	rows, err := sq.Select(loginAttemptsColumns...).From("login_attempts").
+		Where("locked_until > ?", now).OrderBy("locked_until DESC", "kind", "name").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lockouts := make([]*LoginAttempts, 0)
+	for rows.Next() {
+		a, err := scanLoginAttempts(rows)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, a)
+	}
+	return lockouts, rows.Err()
+}
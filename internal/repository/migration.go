@@ -5,6 +5,7 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
@@ -16,7 +17,7 @@ import (
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
-const Version uint = 6
+const Version uint = 19
 
 //go:embed migrations/*
 var migrationFiles embed.FS
@@ -40,7 +41,14 @@ func checkDBVersion(backend string, db *sql.DB) error {
 			return err
 		}
 	case "mysql":
-		driver, err := mysql.WithInstance(db, &mysql.Config{})
+		// WithInstance would take a connection out of the pool for good,
+		// this is called by the readiness probe over and over again.
+		conn, err := db.Conn(context.Background())
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		driver, err := mysql.WithConnection(context.Background(), conn, &mysql.Config{})
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,47 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+func TestCheckVersion(t *testing.T) {
+	dbfilepath := filepath.Join(t.TempDir(), "version.db")
+	if err := MigrateDB("sqlite3", dbfilepath); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sqlx.Open("sqlite3", dbfilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	conn := &DBConnection{DB: db, Driver: "sqlite3"}
+	// Run repeatedly by the readiness probe
+	for i := 0; i < 3; i++ {
+		if err := conn.CheckVersion(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := db.Exec("UPDATE schema_migrations SET dirty = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.CheckVersion(); err == nil {
+		t.Error("expected the failed migration to be reported")
+	}
+
+	if _, err := db.Exec("UPDATE schema_migrations SET version = version - 1, dirty = 0"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.CheckVersion(); err == nil {
+		t.Error("expected the missing migration to be reported")
+	}
+}
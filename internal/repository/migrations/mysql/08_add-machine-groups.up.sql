@@ -0,0 +1,10 @@
+CREATE TABLE
+    `machine_groups` (
+        `id` INT PRIMARY KEY AUTO_INCREMENT,
+        `machine_id` VARCHAR(255) NOT NULL,
+        `group_name` VARCHAR(255) NOT NULL
+    );
+
+CREATE UNIQUE INDEX `machine_group_unique` ON `machine_groups` (`machine_id`, `group_name`);
+
+ALTER TABLE `machine_groups` ADD FOREIGN KEY (`machine_id`) REFERENCES `machines` (`machine_id`) ON DELETE CASCADE;
@@ -0,0 +1,15 @@
+CREATE TABLE
+    `audit_log` (
+        `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+        `created_at` BIGINT NOT NULL,
+        `actor` VARCHAR(255) NOT NULL,
+        `auth_type` VARCHAR(32) NOT NULL,
+        `source_ip` VARCHAR(64) NOT NULL DEFAULT '',
+        `action` VARCHAR(32) NOT NULL,
+        `resource` VARCHAR(64) NOT NULL,
+        `resource_id` VARCHAR(255) NOT NULL DEFAULT '',
+        `diff` TEXT
+    );
+
+CREATE INDEX `audit_log_created_at` ON `audit_log` (`created_at`);
+CREATE INDEX `audit_log_resource` ON `audit_log` (`resource`, `resource_id`);
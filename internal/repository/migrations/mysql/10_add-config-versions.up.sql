@@ -0,0 +1,12 @@
+CREATE TABLE
+    `config_versions` (
+        `id` BIGINT PRIMARY KEY AUTO_INCREMENT,
+        `config_type` VARCHAR(64) NOT NULL,
+        `version` INT NOT NULL,
+        `action` VARCHAR(32) NOT NULL,
+        `data` TEXT,
+        `created_at` BIGINT NOT NULL,
+        `created_by` VARCHAR(255) NOT NULL DEFAULT ''
+    );
+
+CREATE UNIQUE INDEX `config_versions_unique` ON `config_versions` (`config_type`, `version`);
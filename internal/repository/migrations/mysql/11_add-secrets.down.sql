@@ -0,0 +1,6 @@
+UPDATE `rabbit_mq_config` SET `conn_url` = SUBSTRING(`conn_url`, 7) WHERE `conn_url` LIKE 'plain:%';
+UPDATE `rabbit_mq_config` SET `password` = SUBSTRING(`password`, 7) WHERE `password` LIKE 'plain:%';
+UPDATE `influxdb_configurations` SET `password` = SUBSTRING(`password`, 7) WHERE `password` LIKE 'plain:%';
+UPDATE `machine_conf` SET `password` = SUBSTRING(`password`, 7) WHERE `password` LIKE 'plain:%';
+UPDATE `machine_conf` SET `passphrase` = SUBSTRING(`passphrase`, 7) WHERE `passphrase` LIKE 'plain:%';
+DROP TABLE IF EXISTS `secrets`;
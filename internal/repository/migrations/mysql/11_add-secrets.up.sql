@@ -0,0 +1,15 @@
+CREATE TABLE
+    `secrets` (
+        `name` VARCHAR(255) PRIMARY KEY,
+        `ciphertext` TEXT NOT NULL,
+        `created_at` BIGINT NOT NULL,
+        `updated_at` BIGINT NOT NULL,
+        `updated_by` VARCHAR(255) NOT NULL DEFAULT ''
+    );
+
+-- Credentials stored before secret references existed keep their meaning
+UPDATE `rabbit_mq_config` SET `conn_url` = CONCAT('plain:', `conn_url`) WHERE `conn_url` LIKE 'env:%' OR `conn_url` LIKE 'file:%' OR `conn_url` LIKE 'db:%' OR `conn_url` LIKE 'plain:%';
+UPDATE `rabbit_mq_config` SET `password` = CONCAT('plain:', `password`) WHERE `password` LIKE 'env:%' OR `password` LIKE 'file:%' OR `password` LIKE 'db:%' OR `password` LIKE 'plain:%';
+UPDATE `influxdb_configurations` SET `password` = CONCAT('plain:', `password`) WHERE `password` LIKE 'env:%' OR `password` LIKE 'file:%' OR `password` LIKE 'db:%' OR `password` LIKE 'plain:%';
+UPDATE `machine_conf` SET `password` = CONCAT('plain:', `password`) WHERE `password` LIKE 'env:%' OR `password` LIKE 'file:%' OR `password` LIKE 'db:%' OR `password` LIKE 'plain:%';
+UPDATE `machine_conf` SET `passphrase` = CONCAT('plain:', `passphrase`) WHERE `passphrase` LIKE 'env:%' OR `passphrase` LIKE 'file:%' OR `passphrase` LIKE 'db:%' OR `passphrase` LIKE 'plain:%';
@@ -0,0 +1,13 @@
+CREATE TABLE
+    `api_tokens` (
+        `id` VARCHAR(64) PRIMARY KEY,
+        `username` VARCHAR(255) NOT NULL,
+        `name` VARCHAR(255) NOT NULL,
+        `scopes` TEXT NOT NULL,
+        `created_at` BIGINT NOT NULL,
+        `expires_at` BIGINT NOT NULL DEFAULT 0,
+        `last_used_at` BIGINT NOT NULL DEFAULT 0,
+        `revoked_at` BIGINT NOT NULL DEFAULT 0
+    );
+
+CREATE INDEX `api_tokens_username` ON `api_tokens` (`username`);
@@ -0,0 +1,9 @@
+CREATE TABLE
+    `login_attempts` (
+        `kind` VARCHAR(8) NOT NULL,
+        `name` VARCHAR(255) NOT NULL,
+        `failures` INT NOT NULL DEFAULT 0,
+        `last_failure` BIGINT NOT NULL DEFAULT 0,
+        `locked_until` BIGINT NOT NULL DEFAULT 0,
+        PRIMARY KEY (`kind`, `name`)
+    );
@@ -0,0 +1,11 @@
+CREATE TABLE
+    `user_totp` (
+        `username` VARCHAR(255) PRIMARY KEY,
+        `secret` TEXT NOT NULL,
+        `encrypted` TINYINT NOT NULL DEFAULT 0,
+        `enabled` TINYINT NOT NULL DEFAULT 0,
+        `last_step` BIGINT NOT NULL DEFAULT 0,
+        `recovery_codes` TEXT NOT NULL,
+        `created_at` BIGINT NOT NULL,
+        `enabled_at` BIGINT NOT NULL DEFAULT 0
+    );
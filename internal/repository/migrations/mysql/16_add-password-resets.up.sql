@@ -0,0 +1,10 @@
+CREATE TABLE
+    `password_resets` (
+        `token_hash` VARCHAR(64) PRIMARY KEY,
+        `username` VARCHAR(255) NOT NULL,
+        `created_by` VARCHAR(255) NOT NULL,
+        `created_at` BIGINT NOT NULL,
+        `expires_at` BIGINT NOT NULL
+    );
+
+CREATE INDEX `password_resets_username` ON `password_resets` (`username`);
@@ -0,0 +1 @@
+ALTER TABLE user ADD COLUMN disabled TINYINT NOT NULL DEFAULT 0;
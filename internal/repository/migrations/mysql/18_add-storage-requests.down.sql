@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `lv_requests`;
+ALTER TABLE `notifications` DROP COLUMN `acknowledged_by`;
+ALTER TABLE `notifications` DROP COLUMN `acknowledged_at`;
@@ -0,0 +1,21 @@
+ALTER TABLE `notifications` ADD COLUMN `acknowledged_at` BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE `notifications` ADD COLUMN `acknowledged_by` VARCHAR(255) NOT NULL DEFAULT '';
+
+CREATE TABLE
+    `lv_requests` (
+        `id` INTEGER AUTO_INCREMENT PRIMARY KEY,
+        `machine_id` VARCHAR(255) NOT NULL,
+        `operation` VARCHAR(16) NOT NULL,
+        `vg_name` VARCHAR(255) NOT NULL,
+        `lv_name` VARCHAR(255) NOT NULL,
+        `size_gb` DOUBLE NOT NULL,
+        `filesystem` VARCHAR(64) NOT NULL DEFAULT '',
+        `status` VARCHAR(16) NOT NULL DEFAULT 'pending',
+        `message` TEXT NOT NULL,
+        `requested_by` VARCHAR(255) NOT NULL,
+        `requested_at` BIGINT NOT NULL,
+        `updated_at` BIGINT NOT NULL,
+        FOREIGN KEY (`machine_id`) REFERENCES `machines` (`machine_id`) ON DELETE CASCADE
+    );
+
+CREATE INDEX `lv_requests_machine_status` ON `lv_requests` (`machine_id`, `status`);
@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS influxdb_configurations;
+DROP TABLE IF EXISTS rabbit_mq_config;
+DROP TABLE IF EXISTS file_stash_url;
+DROP TABLE IF EXISTS machine_conf;
+DROP TABLE IF EXISTS lv_storage_issuer;
+DROP TABLE IF EXISTS physical_volumes;
+DROP TABLE IF EXISTS volume_groups;
+DROP TABLE IF EXISTS logical_volumes;
+DROP TABLE IF EXISTS lvm_conf;
+DROP TABLE IF EXISTS realtime_logs;
+DROP TABLE IF EXISTS machines;
+DROP TABLE IF EXISTS notifications;
@@ -0,0 +1,116 @@
+CREATE TABLE IF NOT EXISTS notifications (
+id         INTEGER PRIMARY KEY,
+message    TEXT NOT NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+
+CREATE TABLE IF NOT EXISTS machines (
+machine_id VARCHAR(255) PRIMARY KEY,
+hostname   VARCHAR(255) NOT NULL,
+os_version VARCHAR(255) NOT NULL,
+ip_address VARCHAR(255) NOT NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP);
+
+CREATE TABLE IF NOT EXISTS realtime_logs (
+id          INTEGER PRIMARY KEY,
+log_message TEXT NOT NULL,
+machine_id  VARCHAR(255) NOT NULL,
+created_at  TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS lvm_conf (
+id                  INTEGER PRIMARY KEY,
+machine_id          VARCHAR(255) NOT NULL,
+username            VARCHAR(255) NOT NULL,
+minAvailableSpaceGB REAL NOT NULL,
+maxAvailableSpaceGB REAL NOT NULL,
+created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS logical_volumes (
+lv_id      INTEGER PRIMARY KEY,
+machine_id VARCHAR(255) NOT NULL,
+lv_name    VARCHAR(255) NOT NULL,
+vg_name    VARCHAR(255) NOT NULL,
+lv_attr    VARCHAR(255) NOT NULL,
+lv_size    VARCHAR(255) NOT NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS volume_groups (
+vg_id      INTEGER PRIMARY KEY,
+machine_id VARCHAR(255) NOT NULL,
+vg_name    VARCHAR(255) NOT NULL,
+pv_count   VARCHAR(255) NOT NULL,
+lv_count   VARCHAR(255) NOT NULL,
+snap_count VARCHAR(255) NOT NULL,
+vg_attr    VARCHAR(255) NOT NULL,
+vg_size    VARCHAR(255) NOT NULL,
+vg_free    VARCHAR(255) NOT NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS physical_volumes (
+pv_id      INTEGER PRIMARY KEY,
+machine_id VARCHAR(255) NOT NULL,
+pv_name    VARCHAR(255) NOT NULL,
+vg_name    VARCHAR(255) NOT NULL,
+pv_fmt     VARCHAR(255) NOT NULL,
+pv_attr    VARCHAR(255) NOT NULL,
+pv_size    VARCHAR(255) NOT NULL,
+pv_free    VARCHAR(255) NOT NULL,
+created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS lv_storage_issuer (
+id                  INTEGER PRIMARY KEY,
+machine_id          VARCHAR(255) NOT NULL,
+inc_buffer          INT DEFAULT 0,
+dec_buffer          INT DEFAULT 0,
+hostname            VARCHAR(255) NOT NULL,
+username            VARCHAR(255) NOT NULL,
+minAvailableSpaceGB REAL NOT NULL,
+maxAvailableSpaceGB REAL NOT NULL,
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS machine_conf (
+id          INTEGER PRIMARY KEY,
+machine_id  VARCHAR(255) NOT NULL,
+hostname    VARCHAR(255) NOT NULL,
+username    VARCHAR(255) NOT NULL,
+passphrase  TEXT,
+port_number INT NOT NULL,
+password    VARCHAR(255),
+host_key    VARCHAR(255),
+folder_path VARCHAR(255),
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id));
+
+CREATE TABLE IF NOT EXISTS file_stash_url (
+id                  INTEGER PRIMARY KEY,
+url                 VARCHAR(255) NOT NULL,
+created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+single_row_enforcer INT NOT NULL DEFAULT 1 UNIQUE);
+
+CREATE TABLE IF NOT EXISTS rabbit_mq_config (
+conn_url            VARCHAR(255) NOT NULL,
+username            VARCHAR(255) NOT NULL,
+password            VARCHAR(255) NOT NULL,
+created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+single_row_enforcer INT NOT NULL DEFAULT 1 UNIQUE);
+
+CREATE TABLE IF NOT EXISTS influxdb_configurations (
+id                     INTEGER PRIMARY KEY,
+type                   VARCHAR(255) NOT NULL,
+database_name          VARCHAR(255) NOT NULL,
+host                   VARCHAR(255) NOT NULL,
+port                   INT NOT NULL,
+user                   VARCHAR(255) NOT NULL,
+password               VARCHAR(255) NOT NULL,
+organization           VARCHAR(255) NOT NULL,
+ssl_enabled            TINYINT NOT NULL,
+batch_size             INT NOT NULL,
+retry_interval         VARCHAR(255) NOT NULL,
+retry_exponential_base INT NOT NULL,
+max_retries            INT NOT NULL,
+max_retry_time         VARCHAR(255) NOT NULL,
+meta_as_tags           TEXT,
+single_row_enforcer    INT NOT NULL DEFAULT 1 UNIQUE);
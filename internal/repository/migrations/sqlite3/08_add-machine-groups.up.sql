@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS machine_groups (
+id         INTEGER PRIMARY KEY,
+machine_id VARCHAR(255) NOT NULL,
+group_name VARCHAR(255) NOT NULL,
+UNIQUE (machine_id, group_name),
+FOREIGN KEY (machine_id) REFERENCES machines (machine_id) ON DELETE CASCADE);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS audit_log (
+id          INTEGER PRIMARY KEY,
+created_at  BIGINT NOT NULL,
+actor       VARCHAR(255) NOT NULL,
+auth_type   VARCHAR(32) NOT NULL,
+source_ip   VARCHAR(64) NOT NULL DEFAULT '',
+action      VARCHAR(32) NOT NULL,
+resource    VARCHAR(64) NOT NULL,
+resource_id VARCHAR(255) NOT NULL DEFAULT '',
+diff        TEXT);
+
+CREATE INDEX IF NOT EXISTS audit_log_created_at ON audit_log (created_at);
+CREATE INDEX IF NOT EXISTS audit_log_resource ON audit_log (resource, resource_id);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS config_versions (
+id          INTEGER PRIMARY KEY,
+config_type VARCHAR(64) NOT NULL,
+version     INT NOT NULL,
+action      VARCHAR(32) NOT NULL,
+data        TEXT,
+created_at  BIGINT NOT NULL,
+created_by  VARCHAR(255) NOT NULL DEFAULT '',
+UNIQUE (config_type, version));
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS api_tokens (
+id           VARCHAR(64) PRIMARY KEY,
+username     VARCHAR(255) NOT NULL,
+name         VARCHAR(255) NOT NULL,
+scopes       TEXT NOT NULL DEFAULT '[]',
+created_at   BIGINT NOT NULL,
+expires_at   BIGINT NOT NULL DEFAULT 0,
+last_used_at BIGINT NOT NULL DEFAULT 0,
+revoked_at   BIGINT NOT NULL DEFAULT 0);
+CREATE INDEX IF NOT EXISTS api_tokens_username ON api_tokens (username);
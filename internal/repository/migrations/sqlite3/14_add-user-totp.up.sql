@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS user_totp (
+username       VARCHAR(255) PRIMARY KEY,
+secret         TEXT NOT NULL,
+encrypted      TINYINT NOT NULL DEFAULT 0,
+enabled        TINYINT NOT NULL DEFAULT 0,
+last_step      BIGINT NOT NULL DEFAULT 0,
+recovery_codes TEXT NOT NULL DEFAULT '[]',
+created_at     BIGINT NOT NULL,
+enabled_at     BIGINT NOT NULL DEFAULT 0);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS sessions (
+id            VARCHAR(64) PRIMARY KEY,
+token_hash    VARCHAR(64) NOT NULL UNIQUE,
+username      VARCHAR(255) NOT NULL,
+created_at    BIGINT NOT NULL,
+last_activity BIGINT NOT NULL,
+expires_at    BIGINT NOT NULL DEFAULT 0,
+source_ip     VARCHAR(64) NOT NULL DEFAULT '',
+user_agent    VARCHAR(255) NOT NULL DEFAULT '',
+roles         TEXT NOT NULL DEFAULT '[]',
+projects      TEXT NOT NULL DEFAULT '[]');
+CREATE INDEX IF NOT EXISTS sessions_username ON sessions (username);
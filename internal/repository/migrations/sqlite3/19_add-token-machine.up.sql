@@ -0,0 +1 @@
+ALTER TABLE api_tokens ADD COLUMN machine_id VARCHAR(255) NOT NULL DEFAULT '';
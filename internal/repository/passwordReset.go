@@ -0,0 +1,86 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordReset is a one-time token an admin created for a user to set a
+// new password. Only the hash of the token is stored.
+type PasswordReset struct {
+	TokenHash string `json:"-"`
+	Username  string `json:"username"`
+	CreatedBy string `json:"createdBy"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt"`
+}
+
+// CreatePasswordReset stores p, replacing earlier tokens of the same user
+// and removing tokens of all users that expired at p.CreatedAt.
+func CreatePasswordReset(ctx context.Context, runner sq.BaseRunner, p *PasswordReset) error {
+	if _, err := sq.Delete("password_resets").
+		Where(sq.Or{sq.Eq{"username": p.Username}, sq.LtOrEq{"expires_at": p.CreatedAt}}).
+		RunWith(runner).ExecContext(ctx); err != nil {
+		return err
+	}
+
+	_, err := sq.Insert("password_resets").
+		Columns("token_hash", "username", "created_by", "created_at", "expires_at").
+		Values(p.TokenHash, p.Username, p.CreatedBy, p.CreatedAt, p.ExpiresAt).
+		RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// TakePasswordReset removes the token with tokenHash and returns it, so that
+// it can be used only once. It returns sql.ErrNoRows if there is none.
+// Whether it expired is up to the caller.
+func TakePasswordReset(ctx context.Context, runner sq.BaseRunner, tokenHash string) (*PasswordReset, error) {
+	p := &PasswordReset{}
+	if err := sq.Select("token_hash", "username", "created_by", "created_at", "expires_at").
+		From("password_resets").Where("token_hash = ?", tokenHash).
+		RunWith(runner).QueryRowContext(ctx).
+		Scan(&p.TokenHash, &p.Username, &p.CreatedBy, &p.CreatedAt, &p.ExpiresAt); err != nil {
+		return nil, err
+	}
+
+	res, err := sq.Delete("password_resets").Where("token_hash = ?", tokenHash).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Redeemed concurrently
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+	return p, nil
+}
+
+// SetUserPassword replaces the password of username by the hash of
+// password. It returns sql.ErrNoRows if there is no such user.
+func SetUserPassword(ctx context.Context, runner sq.BaseRunner, username, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	res, err := sq.Update("user").Set("password", string(hash)).
+		Where("user.username = ?", username).RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// StoredSecret is an encrypted secret in the secrets table. Encryption and
+// decryption are left to the secrets package, the ciphertext is never
+// returned by the API.
+type StoredSecret struct {
+	Name       string `json:"name"`
+	Ciphertext string `json:"-"`
+	CreatedAt  int64  `json:"createdAt"`
+	UpdatedAt  int64  `json:"updatedAt"`
+	UpdatedBy  string `json:"updatedBy"`
+}
+
+var storedSecretColumns = []string{"name", "ciphertext", "created_at", "updated_at", "updated_by"}
+
+func scanStoredSecret(row sq.RowScanner) (*StoredSecret, error) {
+	s := &StoredSecret{}
+	if err := row.Scan(&s.Name, &s.Ciphertext, &s.CreatedAt, &s.UpdatedAt, &s.UpdatedBy); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetStoredSecret returns sql.ErrNoRows if there is no secret called name.
+func GetStoredSecret(ctx context.Context, runner sq.BaseRunner, name string) (*StoredSecret, error) {
+	return scanStoredSecret(sq.Select(storedSecretColumns...).From("secrets").
+		Where("name = ?", name).RunWith(runner).QueryRowContext(ctx))
+}
+
+// ListStoredSecrets returns all secrets ordered by name.
+func ListStoredSecrets(ctx context.Context, runner sq.BaseRunner) ([]*StoredSecret, error) {
+	rows, err := sq.Select(storedSecretColumns...).From("secrets").OrderBy("name").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	secrets := make([]*StoredSecret, 0)
+	for rows.Next() {
+		s, err := scanStoredSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, s)
+	}
+
+	return secrets, rows.Err()
+}
+
+// PutStoredSecret creates or replaces the secret called name on behalf of
+// the actor found in ctx.
+func PutStoredSecret(ctx context.Context, runner sq.BaseRunner, name, ciphertext string) error {
+	now := time.Now().Unix()
+	actor := GetAuditActorFromContext(ctx).Username
+
+	var exists int
+	if err := sq.Select("COUNT(*)").From("secrets").Where("name = ?", name).
+		RunWith(runner).QueryRowContext(ctx).Scan(&exists); err != nil {
+		return err
+	}
+
+	var err error
+	if exists > 0 {
+		_, err = sq.Update("secrets").
+			Set("ciphertext", ciphertext).Set("updated_at", now).Set("updated_by", actor).
+			Where("name = ?", name).RunWith(runner).ExecContext(ctx)
+	} else {
+		_, err = sq.Insert("secrets").Columns(storedSecretColumns...).
+			Values(name, ciphertext, now, now, actor).RunWith(runner).ExecContext(ctx)
+	}
+	return err
+}
+
+func DeleteStoredSecret(ctx context.Context, runner sq.BaseRunner, name string) error {
+	_, err := sq.Delete("secrets").Where("name = ?", name).RunWith(runner).ExecContext(ctx)
+	return err
+}
@@ -0,0 +1,166 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Session is a login session of the web interface. The session cookie
+// carries a token, of which only the hash is stored. The ID is used to list
+// and end sessions.
+type Session struct {
+	ID           string `json:"id"`
+	TokenHash    string `json:"-"`
+	Username     string `json:"username"`
+	CreatedAt    int64  `json:"createdAt"`
+	LastActivity int64  `json:"lastActivity"`
+	ExpiresAt    int64  `json:"expiresAt,omitempty"` // 0 if only the idle timeout applies
+	SourceIP     string `json:"sourceIp"`
+	UserAgent    string `json:"userAgent"`
+	// Roles and projects at login, used for users not stored in the user
+	// table, e.g. those of OIDC without sync
+	Roles    []string `json:"roles"`
+	Projects []string `json:"projects"`
+	Current  bool     `json:"current,omitempty"` // the session of the request, not stored
+}
+
+// ContextSessionKey holds the ID of the session a request was
+// authenticated by.
+const ContextSessionKey ContextKey = "session"
+
+// GetSessionIDFromContext returns an empty string if the request was not
+// authenticated by a session.
+func GetSessionIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ContextSessionKey).(string)
+	return id
+}
+
+var sessionColumns = []string{"id", "token_hash", "username", "created_at", "last_activity", "expires_at", "source_ip", "user_agent", "roles", "projects"}
+
+func scanSession(row sq.RowScanner) (*Session, error) {
+	s := &Session{}
+	var roles, projects string
+	if err := row.Scan(&s.ID, &s.TokenHash, &s.Username, &s.CreatedAt, &s.LastActivity, &s.ExpiresAt, &s.SourceIP, &s.UserAgent, &roles, &projects); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(roles), &s.Roles); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(projects), &s.Projects); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func CreateSession(ctx context.Context, runner sq.BaseRunner, s *Session) error {
+	if s.Roles == nil {
+		s.Roles = []string{}
+	}
+	if s.Projects == nil {
+		s.Projects = []string{}
+	}
+	roles, err := json.Marshal(s.Roles)
+	if err != nil {
+		return err
+	}
+	projects, err := json.Marshal(s.Projects)
+	if err != nil {
+		return err
+	}
+
+	_, err = sq.Insert("sessions").Columns(sessionColumns...).
+		Values(s.ID, s.TokenHash, s.Username, s.CreatedAt, s.LastActivity, s.ExpiresAt, s.SourceIP, s.UserAgent, string(roles), string(projects)).
+		RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// GetSession returns sql.ErrNoRows if there is no session with that ID.
+func GetSession(ctx context.Context, runner sq.BaseRunner, id string) (*Session, error) {
+	return scanSession(sq.Select(sessionColumns...).From("sessions").
+		Where("id = ?", id).RunWith(runner).QueryRowContext(ctx))
+}
+
+// GetSessionByToken returns sql.ErrNoRows if no session has that token
+// hash.
+func GetSessionByToken(ctx context.Context, runner sq.BaseRunner, tokenHash string) (*Session, error) {
+	return scanSession(sq.Select(sessionColumns...).From("sessions").
+		Where("token_hash = ?", tokenHash).RunWith(runner).QueryRowContext(ctx))
+}
+
+// ListSessions returns the sessions of username (of all users if empty),
+// most recently active first.
+func ListSessions(ctx context.Context, runner sq.BaseRunner, username string) ([]*Session, error) {
+	q := sq.Select(sessionColumns...).From("sessions").OrderBy("last_activity DESC", "id")
+	if username != "" {
+		q = q.Where("username = ?", username)
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*Session, 0)
+	for rows.Next() {
+		s, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// TouchSession records activity in the session at time now.
+func TouchSession(ctx context.Context, runner sq.BaseRunner, id string, now int64) error {
+	_, err := sq.Update("sessions").Set("last_activity", now).
+		Where("id = ?", id).RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// DeleteSession ends the session with that ID. It returns sql.ErrNoRows if
+// there is none.
+func DeleteSession(ctx context.Context, runner sq.BaseRunner, id string) error {
+	res, err := sq.Delete("sessions").Where("id = ?", id).RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteUserSessions ends all sessions of username except the one with ID
+// keep, which may be empty. It returns the number of ended sessions.
+func DeleteUserSessions(ctx context.Context, runner sq.BaseRunner, username, keep string) (int64, error) {
+	res, err := sq.Delete("sessions").Where("username = ? AND id != ?", username, keep).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// DeleteExpiredSessions removes sessions that expired at now or were
+// inactive since idleSince.
+func DeleteExpiredSessions(ctx context.Context, runner sq.BaseRunner, now, idleSince int64) (int64, error) {
+	res, err := sq.Delete("sessions").
+		Where(sq.Or{sq.And{sq.NotEq{"expires_at": 0}, sq.LtOrEq{"expires_at": now}}, sq.Lt{"last_activity": idleSince}}).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -84,6 +84,12 @@ type MachineConf struct {
 	FolderPath sql.NullString
 }
 
+type MachineGroup struct {
+	ID        int32
+	MachineID string
+	GroupName string
+}
+
 type Notification struct {
 	ID        int32
 	Message   string
@@ -215,6 +215,22 @@ func (q *Queries) CreateMachineConf(ctx context.Context, arg CreateMachineConfPa
 	return err
 }
 
+const createMachineGroup = `-- name: CreateMachineGroup :exec
+INSERT INTO machine_groups (machine_id, group_name)
+VALUES (?, ?)
+`
+
+type CreateMachineGroupParams struct {
+	MachineID string
+	GroupName string
+}
+
+// Machine Groups
+func (q *Queries) CreateMachineGroup(ctx context.Context, arg CreateMachineGroupParams) error {
+	_, err := q.db.ExecContext(ctx, createMachineGroup, arg.MachineID, arg.GroupName)
+	return err
+}
+
 const createNotification = `-- name: CreateNotification :exec
 INSERT INTO notifications (message) VALUES (?)
 `
@@ -383,6 +399,21 @@ func (q *Queries) DeleteMachineConf(ctx context.Context, id int32) error {
 	return err
 }
 
+const deleteMachineGroup = `-- name: DeleteMachineGroup :exec
+DELETE FROM machine_groups
+WHERE machine_id = ? AND group_name = ?
+`
+
+type DeleteMachineGroupParams struct {
+	MachineID string
+	GroupName string
+}
+
+func (q *Queries) DeleteMachineGroup(ctx context.Context, arg DeleteMachineGroupParams) error {
+	_, err := q.db.ExecContext(ctx, deleteMachineGroup, arg.MachineID, arg.GroupName)
+	return err
+}
+
 const deleteNotification = `-- name: DeleteNotification :exec
 DELETE FROM notifications WHERE id = ?
 `
@@ -497,6 +528,39 @@ func (q *Queries) GetLVMConf(ctx context.Context, machineID string) (LvmConf, er
 	return i, err
 }
 
+const getLVStorageIssuer = `-- name: GetLVStorageIssuer :one
+SELECT id, machine_id, inc_buffer, dec_buffer, hostname, username, minavailablespacegb, maxavailablespacegb FROM lv_storage_issuer
+WHERE id = ?
+`
+
+func (q *Queries) GetLVStorageIssuer(ctx context.Context, id int32) (LvStorageIssuer, error) {
+	row := q.db.QueryRowContext(ctx, getLVStorageIssuer, id)
+	var i LvStorageIssuer
+	err := row.Scan(
+		&i.ID,
+		&i.MachineID,
+		&i.IncBuffer,
+		&i.DecBuffer,
+		&i.Hostname,
+		&i.Username,
+		&i.Minavailablespacegb,
+		&i.Maxavailablespacegb,
+	)
+	return i, err
+}
+
+const getLVStorageIssuerOwner = `-- name: GetLVStorageIssuerOwner :one
+SELECT machine_id FROM lv_storage_issuer
+WHERE id = ?
+`
+
+func (q *Queries) GetLVStorageIssuerOwner(ctx context.Context, id int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getLVStorageIssuerOwner, id)
+	var machine_id string
+	err := row.Scan(&machine_id)
+	return machine_id, err
+}
+
 const getLVStorageIssuers = `-- name: GetLVStorageIssuers :many
 SELECT id, machine_id, inc_buffer, dec_buffer, hostname, username, minavailablespacegb, maxavailablespacegb FROM lv_storage_issuer
 `
@@ -533,6 +597,18 @@ func (q *Queries) GetLVStorageIssuers(ctx context.Context) ([]LvStorageIssuer, e
 	return items, nil
 }
 
+const getLogicalVolumeOwner = `-- name: GetLogicalVolumeOwner :one
+SELECT machine_id FROM logical_volumes
+WHERE lv_id = ?
+`
+
+func (q *Queries) GetLogicalVolumeOwner(ctx context.Context, lvID int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getLogicalVolumeOwner, lvID)
+	var machine_id string
+	err := row.Scan(&machine_id)
+	return machine_id, err
+}
+
 const getLogicalVolumes = `-- name: GetLogicalVolumes :many
 SELECT lv_id, machine_id, lv_name, vg_name, lv_attr, lv_size, created_at FROM logical_volumes
 WHERE machine_id = ?
@@ -609,6 +685,69 @@ func (q *Queries) GetMachineConf(ctx context.Context, machineID string) (Machine
 	return i, err
 }
 
+const getMachineConfByID = `-- name: GetMachineConfByID :one
+SELECT id, machine_id, hostname, username, passphrase, port_number, password, host_key, folder_path FROM machine_conf
+WHERE id = ?
+`
+
+func (q *Queries) GetMachineConfByID(ctx context.Context, id int32) (MachineConf, error) {
+	row := q.db.QueryRowContext(ctx, getMachineConfByID, id)
+	var i MachineConf
+	err := row.Scan(
+		&i.ID,
+		&i.MachineID,
+		&i.Hostname,
+		&i.Username,
+		&i.Passphrase,
+		&i.PortNumber,
+		&i.Password,
+		&i.HostKey,
+		&i.FolderPath,
+	)
+	return i, err
+}
+
+const getMachineConfOwner = `-- name: GetMachineConfOwner :one
+SELECT machine_id FROM machine_conf
+WHERE id = ?
+`
+
+// Owning machine lookups (used for authorization)
+func (q *Queries) GetMachineConfOwner(ctx context.Context, id int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getMachineConfOwner, id)
+	var machine_id string
+	err := row.Scan(&machine_id)
+	return machine_id, err
+}
+
+const getMachineGroups = `-- name: GetMachineGroups :many
+SELECT group_name FROM machine_groups
+WHERE machine_id = ?
+`
+
+func (q *Queries) GetMachineGroups(ctx context.Context, machineID string) ([]string, error) {
+	rows, err := q.db.QueryContext(ctx, getMachineGroups, machineID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var group_name string
+		if err := rows.Scan(&group_name); err != nil {
+			return nil, err
+		}
+		items = append(items, group_name)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getNotifications = `-- name: GetNotifications :many
 SELECT id, message, created_at FROM notifications
 ORDER BY created_at DESC
@@ -638,6 +777,18 @@ func (q *Queries) GetNotifications(ctx context.Context, limit int32) ([]Notifica
 	return items, nil
 }
 
+const getPhysicalVolumeOwner = `-- name: GetPhysicalVolumeOwner :one
+SELECT machine_id FROM physical_volumes
+WHERE pv_id = ?
+`
+
+func (q *Queries) GetPhysicalVolumeOwner(ctx context.Context, pvID int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getPhysicalVolumeOwner, pvID)
+	var machine_id string
+	err := row.Scan(&machine_id)
+	return machine_id, err
+}
+
 const getPhysicalVolumes = `-- name: GetPhysicalVolumes :many
 SELECT pv_id, machine_id, pv_name, vg_name, pv_fmt, pv_attr, pv_size, pv_free, created_at FROM physical_volumes
 WHERE machine_id = ?
@@ -694,6 +845,18 @@ func (q *Queries) GetRabbitMQConfig(ctx context.Context) (RabbitMqConfig, error)
 	return i, err
 }
 
+const getRealtimeLogOwner = `-- name: GetRealtimeLogOwner :one
+SELECT machine_id FROM realtime_logs
+WHERE id = ?
+`
+
+func (q *Queries) GetRealtimeLogOwner(ctx context.Context, id int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getRealtimeLogOwner, id)
+	var machine_id string
+	err := row.Scan(&machine_id)
+	return machine_id, err
+}
+
 const getRealtimeLogs = `-- name: GetRealtimeLogs :many
 SELECT id, log_message, machine_id, created_at FROM realtime_logs
 WHERE machine_id = ?
@@ -734,6 +897,18 @@ func (q *Queries) GetRealtimeLogs(ctx context.Context, arg GetRealtimeLogsParams
 	return items, nil
 }
 
+const getVolumeGroupOwner = `-- name: GetVolumeGroupOwner :one
+SELECT machine_id FROM volume_groups
+WHERE vg_id = ?
+`
+
+func (q *Queries) GetVolumeGroupOwner(ctx context.Context, vgID int32) (string, error) {
+	row := q.db.QueryRowContext(ctx, getVolumeGroupOwner, vgID)
+	var machine_id string
+	err := row.Scan(&machine_id)
+	return machine_id, err
+}
+
 const getVolumeGroups = `-- name: GetVolumeGroups :many
 SELECT vg_id, machine_id, vg_name, pv_count, lv_count, snap_count, vg_attr, vg_size, vg_free, created_at FROM volume_groups
 WHERE machine_id = ?
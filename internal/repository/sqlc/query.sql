@@ -60,6 +60,44 @@ WHERE machine_id = ?;
 DELETE FROM machines
 WHERE machine_id = ?;
 
+-- Machine Groups
+-- name: CreateMachineGroup :exec
+INSERT INTO machine_groups (machine_id, group_name)
+VALUES (?, ?);
+
+-- name: GetMachineGroups :many
+SELECT group_name FROM machine_groups
+WHERE machine_id = ?;
+
+-- name: DeleteMachineGroup :exec
+DELETE FROM machine_groups
+WHERE machine_id = ? AND group_name = ?;
+
+-- Owning machine lookups (used for authorization)
+-- name: GetMachineConfOwner :one
+SELECT machine_id FROM machine_conf
+WHERE id = ?;
+
+-- name: GetLogicalVolumeOwner :one
+SELECT machine_id FROM logical_volumes
+WHERE lv_id = ?;
+
+-- name: GetVolumeGroupOwner :one
+SELECT machine_id FROM volume_groups
+WHERE vg_id = ?;
+
+-- name: GetPhysicalVolumeOwner :one
+SELECT machine_id FROM physical_volumes
+WHERE pv_id = ?;
+
+-- name: GetLVStorageIssuerOwner :one
+SELECT machine_id FROM lv_storage_issuer
+WHERE id = ?;
+
+-- name: GetRealtimeLogOwner :one
+SELECT machine_id FROM realtime_logs
+WHERE id = ?;
+
 -- Logical Volumes
 -- name: CreateLogicalVolume :exec
 INSERT INTO logical_volumes (machine_id, lv_name, vg_name, lv_attr, lv_size)
@@ -122,6 +160,10 @@ VALUES (?, ?, ?, ?, ?, ?, ?);
 -- name: GetLVStorageIssuers :many
 SELECT * FROM lv_storage_issuer;
 
+-- name: GetLVStorageIssuer :one
+SELECT * FROM lv_storage_issuer
+WHERE id = ?;
+
 -- name: UpdateLVStorageIssuer :exec
 UPDATE lv_storage_issuer
 SET inc_buffer = ?, dec_buffer = ?, hostname = ?, username = ?, minAvailableSpaceGB = ?, maxAvailableSpaceGB = ?
@@ -139,6 +181,10 @@ VALUES (?, ?, ?, ?, ?, ?, ?, ?);
 SELECT * FROM machine_conf
 WHERE machine_id = ?;
 
+-- name: GetMachineConfByID :one
+SELECT * FROM machine_conf
+WHERE id = ?;
+
 -- name: UpdateMachineConf :exec
 UPDATE machine_conf
 SET hostname = ?, username = ?, passphrase = ?, port_number = ?, password = ?, host_key = ?, folder_path = ?
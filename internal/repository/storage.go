@@ -0,0 +1,459 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/Deepbinder-main/cc-backend/internal/graph/model"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// The functions in this file read the machine inventory for many machines
+// at once, so that nested lists cost one query per level instead of one
+// per machine. Results are keyed by machine id.
+
+// MachineFilter restricts ListMachines. A nil slice does not restrict, an
+// empty slice matches no machine.
+type MachineFilter struct {
+	IDs    []string
+	Groups []string // Machines in at least one of the machine groups
+}
+
+func nullTime(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func ListMachines(ctx context.Context, runner sq.BaseRunner, filter MachineFilter) ([]*model.Machine, error) {
+	q := sq.Select("machine_id", "hostname", "os_version", "ip_address", "created_at").
+		From("machines").OrderBy("hostname", "machine_id")
+	if filter.IDs != nil {
+		q = q.Where(sq.Eq{"machine_id": filter.IDs})
+	}
+	if filter.Groups != nil {
+		groups, args, err := sq.Select("machine_id").From("machine_groups").
+			Where(sq.Eq{"group_name": filter.Groups}).ToSql()
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where("machine_id IN ("+groups+")", args...)
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	machines := make([]*model.Machine, 0)
+	for rows.Next() {
+		m := &model.Machine{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&m.ID, &m.Hostname, &m.OsVersion, &m.IPAddress, &createdAt); err != nil {
+			return nil, err
+		}
+		m.CreatedAt = nullTime(createdAt)
+		machines = append(machines, m)
+	}
+	return machines, rows.Err()
+}
+
+func LoadMachineGroups(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string][]string, error) {
+	rows, err := sq.Select("machine_id", "group_name").From("machine_groups").
+		Where(sq.Eq{"machine_id": machineIDs}).OrderBy("machine_id", "group_name").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := make(map[string][]string)
+	for rows.Next() {
+		var machineID, group string
+		if err := rows.Scan(&machineID, &group); err != nil {
+			return nil, err
+		}
+		groups[machineID] = append(groups[machineID], group)
+	}
+	return groups, rows.Err()
+}
+
+func LoadPhysicalVolumes(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string][]*model.PhysicalVolume, error) {
+	rows, err := sq.Select("pv_id", "machine_id", "pv_name", "vg_name", "pv_fmt", "pv_attr", "pv_size", "pv_free", "created_at").
+		From("physical_volumes").Where(sq.Eq{"machine_id": machineIDs}).OrderBy("machine_id", "pv_name").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pvs := make(map[string][]*model.PhysicalVolume)
+	for rows.Next() {
+		pv := &model.PhysicalVolume{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&pv.ID, &pv.MachineID, &pv.Name, &pv.VgName, &pv.Format, &pv.Attr, &pv.Size, &pv.Free, &createdAt); err != nil {
+			return nil, err
+		}
+		pv.CreatedAt = nullTime(createdAt)
+		pvs[pv.MachineID] = append(pvs[pv.MachineID], pv)
+	}
+	return pvs, rows.Err()
+}
+
+func LoadVolumeGroups(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string][]*model.VolumeGroup, error) {
+	rows, err := sq.Select("vg_id", "machine_id", "vg_name", "pv_count", "lv_count", "snap_count", "vg_attr", "vg_size", "vg_free", "created_at").
+		From("volume_groups").Where(sq.Eq{"machine_id": machineIDs}).OrderBy("machine_id", "vg_name").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	vgs := make(map[string][]*model.VolumeGroup)
+	for rows.Next() {
+		vg := &model.VolumeGroup{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&vg.ID, &vg.MachineID, &vg.Name, &vg.PvCount, &vg.LvCount, &vg.SnapCount, &vg.Attr, &vg.Size, &vg.Free, &createdAt); err != nil {
+			return nil, err
+		}
+		vg.CreatedAt = nullTime(createdAt)
+		vgs[vg.MachineID] = append(vgs[vg.MachineID], vg)
+	}
+	return vgs, rows.Err()
+}
+
+func LoadLogicalVolumes(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string][]*model.LogicalVolume, error) {
+	rows, err := sq.Select("lv_id", "machine_id", "lv_name", "vg_name", "lv_attr", "lv_size", "created_at").
+		From("logical_volumes").Where(sq.Eq{"machine_id": machineIDs}).OrderBy("machine_id", "vg_name", "lv_name").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	lvs := make(map[string][]*model.LogicalVolume)
+	for rows.Next() {
+		lv := &model.LogicalVolume{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&lv.ID, &lv.MachineID, &lv.Name, &lv.VgName, &lv.Attr, &lv.Size, &createdAt); err != nil {
+			return nil, err
+		}
+		lv.CreatedAt = nullTime(createdAt)
+		lvs[lv.MachineID] = append(lvs[lv.MachineID], lv)
+	}
+	return lvs, rows.Err()
+}
+
+// LoadMachineConfs returns the SSH configurations without credentials.
+func LoadMachineConfs(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string]*model.MachineConf, error) {
+	rows, err := sq.Select("id", "machine_id", "hostname", "username", "port_number", "folder_path",
+		"password IS NOT NULL AND password <> ''",
+		"passphrase IS NOT NULL AND passphrase <> ''",
+		"host_key IS NOT NULL AND host_key <> ''").
+		From("machine_conf").Where(sq.Eq{"machine_id": machineIDs}).OrderBy("id").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	confs := make(map[string]*model.MachineConf)
+	for rows.Next() {
+		c := &model.MachineConf{}
+		var folderPath sql.NullString
+		if err := rows.Scan(&c.ID, &c.MachineID, &c.Hostname, &c.Username, &c.PortNumber, &folderPath,
+			&c.HasPassword, &c.HasPassphrase, &c.HasHostKey); err != nil {
+			return nil, err
+		}
+		if folderPath.Valid {
+			c.FolderPath = &folderPath.String
+		}
+		// GetMachineConf returns the first configuration as well
+		if _, ok := confs[c.MachineID]; !ok {
+			confs[c.MachineID] = c
+		}
+	}
+	return confs, rows.Err()
+}
+
+// LoadLatestRealtimeLogs returns up to limit log entries per machine, newest
+// first.
+func LoadLatestRealtimeLogs(ctx context.Context, runner sq.BaseRunner, machineIDs []string, limit int) (map[string][]*model.RealtimeLog, error) {
+	latest := sq.Select("id", "machine_id", "log_message", "created_at",
+		"ROW_NUMBER() OVER (PARTITION BY machine_id ORDER BY created_at DESC, id DESC) AS n").
+		From("realtime_logs").Where(sq.Eq{"machine_id": machineIDs})
+	rows, err := sq.Select("id", "machine_id", "log_message", "created_at").
+		FromSelect(latest, "l").Where("n <= ?", limit).OrderBy("machine_id", "n").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := make(map[string][]*model.RealtimeLog)
+	for rows.Next() {
+		l := &model.RealtimeLog{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&l.ID, &l.MachineID, &l.Message, &createdAt); err != nil {
+			return nil, err
+		}
+		l.CreatedAt = nullTime(createdAt)
+		logs[l.MachineID] = append(logs[l.MachineID], l)
+	}
+	return logs, rows.Err()
+}
+
+// CreateRealtimeLog stores a log message pushed by the agent of a machine
+// and returns it as stored.
+func CreateRealtimeLog(ctx context.Context, runner sq.BaseRunner, machineID, message string) (*model.RealtimeLog, error) {
+	res, err := sq.Insert("realtime_logs").Columns("log_message", "machine_id").Values(message, machineID).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	l := &model.RealtimeLog{}
+	var createdAt sql.NullTime
+	if err := sq.Select("id", "machine_id", "log_message", "created_at").From("realtime_logs").Where("id = ?", id).
+		RunWith(runner).QueryRowContext(ctx).Scan(&l.ID, &l.MachineID, &l.Message, &createdAt); err != nil {
+		return nil, err
+	}
+	l.CreatedAt = nullTime(createdAt)
+	return l, nil
+}
+
+// CreateNotification stores a notification and returns it as stored.
+func CreateNotification(ctx context.Context, runner sq.BaseRunner, message string) (*model.Notification, error) {
+	res, err := sq.Insert("notifications").Columns("message").Values(message).RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return GetNotification(ctx, runner, int(id))
+}
+
+// ListNotifications returns notifications newest first, optionally only
+// those not acknowledged yet.
+func ListNotifications(ctx context.Context, runner sq.BaseRunner, limit, offset int, unacknowledged bool) ([]*model.Notification, error) {
+	q := sq.Select(notificationColumns...).From("notifications").
+		OrderBy("created_at DESC", "id DESC").Limit(uint64(limit)).Offset(uint64(offset))
+	if unacknowledged {
+		q = q.Where("acknowledged_at = 0")
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := make([]*model.Notification, 0)
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+var notificationColumns = []string{"id", "message", "created_at", "acknowledged_at", "acknowledged_by"}
+
+func scanNotification(row sq.RowScanner) (*model.Notification, error) {
+	n := &model.Notification{}
+	var createdAt sql.NullTime
+	var acknowledgedAt int64
+	var acknowledgedBy string
+	if err := row.Scan(&n.ID, &n.Message, &createdAt, &acknowledgedAt, &acknowledgedBy); err != nil {
+		return nil, err
+	}
+	n.CreatedAt = nullTime(createdAt)
+	if acknowledgedAt != 0 {
+		t := time.Unix(acknowledgedAt, 0)
+		n.AcknowledgedAt, n.AcknowledgedBy = &t, &acknowledgedBy
+	}
+	return n, nil
+}
+
+// GetNotification returns sql.ErrNoRows if there is no notification with
+// that id.
+func GetNotification(ctx context.Context, runner sq.BaseRunner, id int) (*model.Notification, error) {
+	return scanNotification(sq.Select(notificationColumns...).From("notifications").
+		Where("id = ?", id).RunWith(runner).QueryRowContext(ctx))
+}
+
+// AcknowledgeNotification marks a notification as seen by username. The
+// first acknowledgement is kept. Returns sql.ErrNoRows if there is no
+// notification with that id.
+func AcknowledgeNotification(ctx context.Context, runner sq.BaseRunner, id int, username string, now int64) error {
+	if _, err := GetNotification(ctx, runner, id); err != nil {
+		return err
+	}
+
+	_, err := sq.Update("notifications").Set("acknowledged_at", now).Set("acknowledged_by", username).
+		Where("id = ? AND acknowledged_at = 0", id).RunWith(runner).ExecContext(ctx)
+	return err
+}
+
+// LoadLvmConfs returns the latest auto-extend settings per machine, like
+// GetLVMConf.
+func LoadLvmConfs(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string]*model.LvmConf, error) {
+	rows, err := sq.Select("id", "machine_id", "username", "minAvailableSpaceGB", "maxAvailableSpaceGB", "created_at").
+		From("lvm_conf").Where(sq.Eq{"machine_id": machineIDs}).OrderBy("created_at", "id").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	confs := make(map[string]*model.LvmConf)
+	for rows.Next() {
+		c := &model.LvmConf{}
+		var createdAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.MachineID, &c.Username, &c.MinAvailableSpaceGb, &c.MaxAvailableSpaceGb, &createdAt); err != nil {
+			return nil, err
+		}
+		c.CreatedAt = nullTime(createdAt)
+		confs[c.MachineID] = c
+	}
+	return confs, rows.Err()
+}
+
+func LoadLVStorageIssuers(ctx context.Context, runner sq.BaseRunner, machineIDs []string) (map[string][]*model.LvStorageIssuer, error) {
+	rows, err := sq.Select("id", "machine_id", "inc_buffer", "dec_buffer", "hostname", "username", "minAvailableSpaceGB", "maxAvailableSpaceGB").
+		From("lv_storage_issuer").Where(sq.Eq{"machine_id": machineIDs}).OrderBy("machine_id", "id").
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	issuers := make(map[string][]*model.LvStorageIssuer)
+	for rows.Next() {
+		i := &model.LvStorageIssuer{}
+		var incBuffer, decBuffer sql.NullInt32
+		if err := rows.Scan(&i.ID, &i.MachineID, &incBuffer, &decBuffer, &i.Hostname, &i.Username, &i.MinAvailableSpaceGb, &i.MaxAvailableSpaceGb); err != nil {
+			return nil, err
+		}
+		if incBuffer.Valid {
+			v := int(incBuffer.Int32)
+			i.IncBuffer = &v
+		}
+		if decBuffer.Valid {
+			v := int(decBuffer.Int32)
+			i.DecBuffer = &v
+		}
+		issuers[i.MachineID] = append(issuers[i.MachineID], i)
+	}
+	return issuers, rows.Err()
+}
+
+// CreateLVStorageIssuer is like the sqlc query of the same name, but sets
+// the id of issuer.
+func CreateLVStorageIssuer(ctx context.Context, runner sq.BaseRunner, issuer *model.LvStorageIssuer) error {
+	res, err := sq.Insert("lv_storage_issuer").
+		Columns("machine_id", "inc_buffer", "dec_buffer", "hostname", "username", "minAvailableSpaceGB", "maxAvailableSpaceGB").
+		Values(issuer.MachineID, issuer.IncBuffer, issuer.DecBuffer, issuer.Hostname, issuer.Username,
+			issuer.MinAvailableSpaceGb, issuer.MaxAvailableSpaceGb).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	issuer.ID = int(id)
+	return err
+}
+
+var lvRequestColumns = []string{"id", "machine_id", "operation", "vg_name", "lv_name", "size_gb", "filesystem",
+	"status", "message", "requested_by", "requested_at", "updated_at"}
+
+func scanLVRequest(row sq.RowScanner) (*model.LVRequest, error) {
+	req := &model.LVRequest{}
+	var requestedAt, updatedAt int64
+	if err := row.Scan(&req.ID, &req.MachineID, &req.Operation, &req.VgName, &req.LvName, &req.SizeGb, &req.Filesystem,
+		&req.Status, &req.Message, &req.RequestedBy, &requestedAt, &updatedAt); err != nil {
+		return nil, err
+	}
+	req.RequestedAt, req.UpdatedAt = time.Unix(requestedAt, 0), time.Unix(updatedAt, 0)
+	return req, nil
+}
+
+// CreateLVRequest stores a pending request and sets its id.
+func CreateLVRequest(ctx context.Context, runner sq.BaseRunner, req *model.LVRequest) error {
+	req.Status = model.LVRequestPending
+	res, err := sq.Insert("lv_requests").Columns(lvRequestColumns[1:]...).
+		Values(req.MachineID, req.Operation, req.VgName, req.LvName, req.SizeGb, req.Filesystem,
+			req.Status, req.Message, req.RequestedBy, req.RequestedAt.Unix(), req.UpdatedAt.Unix()).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	req.ID = int(id)
+	return err
+}
+
+// GetLVRequest returns sql.ErrNoRows if there is no request with that id.
+func GetLVRequest(ctx context.Context, runner sq.BaseRunner, id int) (*model.LVRequest, error) {
+	return scanLVRequest(sq.Select(lvRequestColumns...).From("lv_requests").
+		Where("id = ?", id).RunWith(runner).QueryRowContext(ctx))
+}
+
+// LoadLVRequests returns the requests of the machines, oldest first. If
+// status is not empty, only requests in that state are returned.
+func LoadLVRequests(ctx context.Context, runner sq.BaseRunner, machineIDs []string, status model.LVRequestStatus) (map[string][]*model.LVRequest, error) {
+	q := sq.Select(lvRequestColumns...).From("lv_requests").
+		Where(sq.Eq{"machine_id": machineIDs}).OrderBy("requested_at", "id")
+	if status != "" {
+		q = q.Where("status = ?", status)
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	requests := make(map[string][]*model.LVRequest)
+	for rows.Next() {
+		req, err := scanLVRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests[req.MachineID] = append(requests[req.MachineID], req)
+	}
+	return requests, rows.Err()
+}
+
+// UpdateLVRequestStatus is called by the agent working on a request.
+// Returns sql.ErrNoRows if there is no request with that id.
+func UpdateLVRequestStatus(ctx context.Context, runner sq.BaseRunner, id int, status model.LVRequestStatus, message string, now int64) error {
+	res, err := sq.Update("lv_requests").Set("status", status).Set("message", message).Set("updated_at", now).
+		Where("id = ?", id).RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
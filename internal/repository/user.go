@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"sync"
 
@@ -46,11 +47,16 @@ func GetUserRepository() *UserRepository {
 }
 
 func (r *UserRepository) GetUser(username string) (*schema.User, error) {
+	return LoadUser(context.Background(), r.DB, username)
+}
+
+// LoadUser is like GetUser using runner, which may be a transaction.
+func LoadUser(ctx context.Context, runner sq.BaseRunner, username string) (*schema.User, error) {
 	user := &schema.User{Username: username}
 	var hashedPassword, name, rawRoles, email, rawProjects sql.NullString
-	if err := sq.Select("password", "ldap", "name", "roles", "email", "projects").From("user").
-		Where("user.username = ?", username).RunWith(r.DB).
-		QueryRow().Scan(&hashedPassword, &user.AuthSource, &name, &rawRoles, &email, &rawProjects); err != nil {
+	if err := sq.Select("password", "ldap", "name", "roles", "email", "projects", "disabled").From("user").
+		Where("user.username = ?", username).RunWith(runner).
+		QueryRowContext(ctx).Scan(&hashedPassword, &user.AuthSource, &name, &rawRoles, &email, &rawProjects, &user.Disabled); err != nil {
 		log.Warnf("Error while querying user '%v' from database", username)
 		return nil, err
 	}
@@ -96,6 +102,11 @@ func (r *UserRepository) GetLdapUsernames() ([]string, error) {
 }
 
 func (r *UserRepository) AddUser(user *schema.User) error {
+	return InsertUser(context.Background(), r.DB, user)
+}
+
+// InsertUser is like AddUser using runner, which may be a transaction.
+func InsertUser(ctx context.Context, runner sq.BaseRunner, user *schema.User) error {
 	rolesJson, _ := json.Marshal(user.Roles)
 	projectsJson, _ := json.Marshal(user.Projects)
 
@@ -123,8 +134,12 @@ func (r *UserRepository) AddUser(user *schema.User) error {
 		cols = append(cols, "ldap")
 		vals = append(vals, int(user.AuthSource))
 	}
+	if user.Disabled {
+		cols = append(cols, "disabled")
+		vals = append(vals, 1)
+	}
 
-	if _, err := sq.Insert("user").Columns(cols...).Values(vals...).RunWith(r.DB).Exec(); err != nil {
+	if _, err := sq.Insert("user").Columns(cols...).Values(vals...).RunWith(runner).ExecContext(ctx); err != nil {
 		log.Errorf("Error while inserting new user '%v' into DB", user.Username)
 		return err
 	}
@@ -134,24 +149,153 @@ func (r *UserRepository) AddUser(user *schema.User) error {
 }
 
 func (r *UserRepository) DelUser(username string) error {
+	if _, err := DeleteUser(context.Background(), r.DB, username); err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	return nil
+}
 
-	_, err := r.DB.Exec(`DELETE FROM user WHERE user.username = ?`, username)
+// DeleteUser removes username with its second factor, sessions, password
+// reset links and API tokens. It returns the IDs of the revoked tokens for
+// TokenDenylist.Revoked, or sql.ErrNoRows if there is no such user.
+func DeleteUser(ctx context.Context, runner sq.BaseRunner, username string) ([]string, error) {
+	res, err := sq.Delete("user").Where("user.username = ?", username).RunWith(runner).ExecContext(ctx)
 	if err != nil {
 		log.Errorf("Error while deleting user '%s' from DB", username)
-		return err
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	for _, table := range []string{"user_totp", "sessions", "password_resets"} {
+		if _, err := sq.Delete(table).Where("username = ?", username).RunWith(runner).ExecContext(ctx); err != nil {
+			log.Errorf("Error while deleting %s of user '%s' from DB", table, username)
+			return nil, err
+		}
 	}
+	revoked, err := RevokeUserApiTokens(ctx, runner, username)
+	if err != nil {
+		log.Errorf("Error while revoking API tokens of user '%s'", username)
+		return nil, err
+	}
+
 	log.Infof("deleted user '%s' from DB", username)
-	return nil
+	return revoked, nil
 }
 
 func (r *UserRepository) ListUsers(specialsOnly bool) ([]*schema.User, error) {
+	return LoadUsers(context.Background(), r.DB, specialsOnly)
+}
+
+// LoadUsers is like ListUsers using runner, which may be a transaction.
+func LoadUsers(ctx context.Context, runner sq.BaseRunner, specialsOnly bool) ([]*schema.User, error) {
+	return QueryUsers(ctx, runner, &UserFilter{SpecialsOnly: specialsOnly})
+}
 
-	q := sq.Select("username", "name", "email", "roles", "projects").From("user")
-	if specialsOnly {
+// ErrInvalidUserFilter is wrapped by the errors of QueryUsers and CountUsers
+// for filters that cannot be applied.
+var ErrInvalidUserFilter = errors.New("invalid user filter")
+
+// UserFilter selects and orders users, zero fields match all users.
+type UserFilter struct {
+	// Only users with a role other than user
+	SpecialsOnly bool
+	// Users with any of these auth sources
+	AuthSources []schema.AuthSource
+	// Users with none of these auth sources
+	NotAuthSources []schema.AuthSource
+	Role           string
+	Project        string
+	// Case-insensitive substring of username, name or email
+	Search string
+
+	// One of username (default), name, email or auth-source
+	SortBy string
+	Desc   bool
+	Limit  uint64 // 0 for no limit
+	Offset uint64
+}
+
+var userSortColumns = map[string]string{
+	"":            "username",
+	"username":    "username",
+	"name":        "name",
+	"email":       "email",
+	"auth-source": "ldap",
+}
+
+// likeEscape escapes the wildcards of LIKE patterns in s, to be used with
+// ESCAPE '!'. Unlike a backslash, '!' needs no quoting in MySQL.
+func likeEscape(s string) string {
+	return strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`).Replace(s)
+}
+
+// jsonArrayContains matches JSON string arrays in column that contain value.
+func jsonArrayContains(column, value string) sq.Sqlizer {
+	element, _ := json.Marshal(value)
+	return sq.Expr(column+` LIKE ? ESCAPE '!'`, "%"+likeEscape(string(element))+"%")
+}
+
+func buildUserFilter(q sq.SelectBuilder, filter *UserFilter) (sq.SelectBuilder, error) {
+	if filter.SpecialsOnly {
 		q = q.Where("(roles != '[\"user\"]' AND roles != '[]')")
 	}
+	if len(filter.AuthSources) != 0 {
+		q = q.Where(sq.Eq{"ldap": filter.AuthSources})
+	}
+	if len(filter.NotAuthSources) != 0 {
+		q = q.Where(sq.NotEq{"ldap": filter.NotAuthSources})
+	}
+	if filter.Role != "" {
+		if !schema.IsValidRole(filter.Role) {
+			return q, fmt.Errorf("%w: invalid role '%s'", ErrInvalidUserFilter, filter.Role)
+		}
+		q = q.Where(jsonArrayContains("roles", strings.ToLower(filter.Role)))
+	}
+	if filter.Project != "" {
+		q = q.Where(jsonArrayContains("projects", filter.Project))
+	}
+	if filter.Search != "" {
+		pattern := "%" + likeEscape(strings.ToLower(filter.Search)) + "%"
+		q = q.Where(sq.Or{
+			sq.Expr(`LOWER(username) LIKE ? ESCAPE '!'`, pattern),
+			sq.Expr(`LOWER(name) LIKE ? ESCAPE '!'`, pattern),
+			sq.Expr(`LOWER(email) LIKE ? ESCAPE '!'`, pattern),
+		})
+	}
+	return q, nil
+}
 
-	rows, err := q.RunWith(r.DB).Query()
+// QueryUsers returns the users matching filter.
+func QueryUsers(ctx context.Context, runner sq.BaseRunner, filter *UserFilter) ([]*schema.User, error) {
+	q, err := buildUserFilter(sq.Select("username", "name", "email", "roles", "projects", "ldap", "disabled").From("user"), filter)
+	if err != nil {
+		return nil, err
+	}
+	column, ok := userSortColumns[filter.SortBy]
+	if !ok {
+		return nil, fmt.Errorf("%w: cannot sort by '%s'", ErrInvalidUserFilter, filter.SortBy)
+	}
+	order := "ASC"
+	if filter.Desc {
+		order = "DESC"
+	}
+	q = q.OrderBy(column+" "+order, "username "+order)
+	if filter.Limit != 0 {
+		q = q.Limit(filter.Limit)
+	}
+	if filter.Offset != 0 {
+		if filter.Limit == 0 {
+			// SQLite and MySQL need a limit for an offset
+			q = q.Limit(math.MaxInt64)
+		}
+		q = q.Offset(filter.Offset)
+	}
+
+	rows, err := q.RunWith(runner).QueryContext(ctx)
 	if err != nil {
 		log.Warn("Error while querying user list")
 		return nil, err
@@ -164,7 +308,7 @@ func (r *UserRepository) ListUsers(specialsOnly bool) ([]*schema.User, error) {
 		rawprojects := ""
 		user := &schema.User{}
 		var name, email sql.NullString
-		if err := rows.Scan(&user.Username, &name, &email, &rawroles, &rawprojects); err != nil {
+		if err := rows.Scan(&user.Username, &name, &email, &rawroles, &rawprojects, &user.AuthSource, &user.Disabled); err != nil {
 			log.Warn("Error while scanning user list")
 			return nil, err
 		}
@@ -182,7 +326,21 @@ func (r *UserRepository) ListUsers(specialsOnly bool) ([]*schema.User, error) {
 		user.Email = email.String
 		users = append(users, user)
 	}
-	return users, nil
+	return users, rows.Err()
+}
+
+// CountUsers returns the number of users matching filter, ignoring its
+// limit and offset.
+func CountUsers(ctx context.Context, runner sq.BaseRunner, filter *UserFilter) (int, error) {
+	q, err := buildUserFilter(sq.Select("COUNT(*)").From("user"), filter)
+	if err != nil {
+		return 0, err
+	}
+	var count int
+	if err := q.RunWith(runner).QueryRowContext(ctx).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
 }
 
 func (r *UserRepository) AddRole(
@@ -206,8 +364,10 @@ func (r *UserRepository) AddRole(
 		return fmt.Errorf("User %v already has role %v", username, newRole)
 	}
 
-	roles, _ := json.Marshal(append(user.Roles, newRole))
-	if _, err := sq.Update("user").Set("roles", roles).Where("user.username = ?", username).RunWith(r.DB).Exec(); err != nil {
+	updated := *user
+	updated.Roles = append(append([]string{}, user.Roles...), newRole)
+	roles, _ := json.Marshal(updated.Roles)
+	if err := r.updateUserAudited(ctx, user, &updated, sq.Update("user").Set("roles", roles).Where("user.username = ?", username)); err != nil {
 		log.Errorf("Error while adding new role for user '%s'", user.Username)
 		return err
 	}
@@ -242,8 +402,10 @@ func (r *UserRepository) RemoveRole(ctx context.Context, username string, queryr
 		}
 	}
 
+	updated := *user
+	updated.Roles = append([]string{}, newroles...)
 	var mroles, _ = json.Marshal(newroles)
-	if _, err := sq.Update("user").Set("roles", mroles).Where("user.username = ?", username).RunWith(r.DB).Exec(); err != nil {
+	if err := r.updateUserAudited(ctx, user, &updated, sq.Update("user").Set("roles", mroles).Where("user.username = ?", username)); err != nil {
 		log.Errorf("Error while removing role for user '%s'", user.Username)
 		return err
 	}
@@ -268,8 +430,10 @@ func (r *UserRepository) AddProject(
 		return fmt.Errorf("user '%s' already manages project '%s'", username, project)
 	}
 
-	projects, _ := json.Marshal(append(user.Projects, project))
-	if _, err := sq.Update("user").Set("projects", projects).Where("user.username = ?", username).RunWith(r.DB).Exec(); err != nil {
+	updated := *user
+	updated.Projects = append(append([]string{}, user.Projects...), project)
+	projects, _ := json.Marshal(updated.Projects)
+	if err := r.updateUserAudited(ctx, user, &updated, sq.Update("user").Set("projects", projects).Where("user.username = ?", username)); err != nil {
 		return err
 	}
 
@@ -307,7 +471,9 @@ func (r *UserRepository) RemoveProject(ctx context.Context, username string, pro
 		} else {
 			result, _ = json.Marshal(newprojects)
 		}
-		if _, err := sq.Update("user").Set("projects", result).Where("user.username = ?", username).RunWith(r.DB).Exec(); err != nil {
+		updated := *user
+		updated.Projects = append([]string{}, newprojects...)
+		if err := r.updateUserAudited(ctx, user, &updated, sq.Update("user").Set("projects", result).Where("user.username = ?", username)); err != nil {
 			return err
 		}
 		return nil
@@ -316,6 +482,160 @@ func (r *UserRepository) RemoveProject(ctx context.Context, username string, pro
 	}
 }
 
+// SetRolesAndProjects replaces the roles and projects of a user, e.g. with
+// those mapped from the claims of an identity provider at login. Nothing is
+// written if they did not change, which is reported by the returned bool.
+func (r *UserRepository) SetRolesAndProjects(ctx context.Context, username string, roles, projects []string) (bool, error) {
+	user, err := r.GetUser(username)
+	if err != nil {
+		return false, err
+	}
+	if stringsEqual(user.Roles, roles) && stringsEqual(user.Projects, projects) {
+		return false, nil
+	}
+
+	updated := *user
+	updated.Roles = append([]string{}, roles...)
+	updated.Projects = append([]string{}, projects...)
+	rolesJson, _ := json.Marshal(updated.Roles)
+	projectsJson, _ := json.Marshal(updated.Projects)
+	err = r.updateUserAudited(ctx, user, &updated, sq.Update("user").
+		Set("roles", string(rolesJson)).Set("projects", string(projectsJson)).
+		Where("user.username = ?", username))
+	return err == nil, err
+}
+
+// UserUpdate holds the fields of a user to change, nil fields are kept.
+type UserUpdate struct {
+	Name       *string            `json:"name"`
+	Email      *string            `json:"email"`
+	Roles      *[]string          `json:"roles"`
+	Projects   *[]string          `json:"projects"`
+	AuthSource *schema.AuthSource `json:"authSource" swaggertype:"integer"`
+	Disabled   *bool              `json:"disabled"`
+	// Plain text, stored as hash. An empty password makes local login
+	// impossible.
+	Password *string `json:"password"`
+}
+
+// ErrInvalidUser is wrapped by the errors of ValidateUser.
+var ErrInvalidUser = errors.New("invalid user")
+
+// ValidateUser checks the roles and projects of user and its auth source.
+func ValidateUser(user *schema.User) error {
+	for _, role := range user.Roles {
+		if !schema.IsValidRole(role) {
+			return fmt.Errorf("%w: invalid role '%s'", ErrInvalidUser, role)
+		}
+	}
+	if len(user.Projects) != 0 && !user.HasRole(schema.RoleManager) {
+		return fmt.Errorf("%w: only managers can have projects, user '%s' is none", ErrInvalidUser, user.Username)
+	}
+	if user.AuthSource < schema.AuthViaLocalPassword || user.AuthSource >= schema.AuthViaAll {
+		return fmt.Errorf("%w: invalid auth source %d", ErrInvalidUser, user.AuthSource)
+	}
+	return nil
+}
+
+// UpdateUser applies all fields of u to the user at once and returns the
+// user before and after the change. It returns sql.ErrNoRows if there is no
+// such user.
+func UpdateUser(ctx context.Context, runner sq.BaseRunner, username string, u *UserUpdate) (*schema.User, *schema.User, error) {
+	before, err := LoadUser(ctx, runner, username)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	after := *before
+	if u.Name != nil {
+		after.Name = *u.Name
+	}
+	if u.Email != nil {
+		after.Email = *u.Email
+	}
+	if u.Roles != nil {
+		after.Roles = make([]string, 0, len(*u.Roles))
+		for _, role := range *u.Roles {
+			after.Roles = append(after.Roles, strings.ToLower(role))
+		}
+	}
+	if u.Projects != nil {
+		after.Projects = append([]string{}, *u.Projects...)
+	}
+	if u.AuthSource != nil {
+		after.AuthSource = *u.AuthSource
+	}
+	if u.Disabled != nil {
+		after.Disabled = *u.Disabled
+	}
+	if after.Roles == nil {
+		after.Roles = []string{}
+	}
+	if after.Projects == nil {
+		after.Projects = []string{}
+	}
+	if err := ValidateUser(&after); err != nil {
+		return nil, nil, err
+	}
+
+	rolesJson, _ := json.Marshal(after.Roles)
+	projectsJson, _ := json.Marshal(after.Projects)
+	update := sq.Update("user").
+		Set("name", after.Name).Set("email", after.Email).
+		Set("roles", string(rolesJson)).Set("projects", string(projectsJson)).
+		Set("ldap", int(after.AuthSource)).Set("disabled", after.Disabled)
+	if u.Password != nil {
+		var password interface{}
+		if *u.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(*u.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return nil, nil, err
+			}
+			password = string(hash)
+		}
+		after.Password, _ = password.(string)
+		update = update.Set("password", password)
+	}
+
+	if _, err := update.Where("user.username = ?", username).RunWith(runner).ExecContext(ctx); err != nil {
+		return nil, nil, err
+	}
+	return before, &after, nil
+}
+
+func stringsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// updateUserAudited executes update and records the change from before to
+// after in the audit log within the same transaction.
+func (r *UserRepository) updateUserAudited(ctx context.Context, before, after *schema.User, update sq.UpdateBuilder) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := update.RunWith(tx).ExecContext(ctx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := RecordAuditFromContext(ctx, tx, AuditUpdate, "user", before.Username, before, after); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
 type ContextKey string
 
 const ContextUserKey ContextKey = "user"
@@ -5,20 +5,27 @@
 package repository
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/metrics"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	sq "github.com/Masterminds/squirrel"
 	"github.com/jmoiron/sqlx"
 )
 
 var (
 	userCfgRepoOnce     sync.Once
 	userCfgRepoInstance *UserCfgRepo
+
+	// uiConfigCache holds the UI configuration of users by username
+	uiConfigCache = lrucache.New(1024)
 )
 
 type UserCfgRepo struct {
@@ -42,8 +49,9 @@ func GetUserCfgRepo() *UserCfgRepo {
 			DB:         db.DB,
 			Lookup:     lookupConfigStmt,
 			uiDefaults: config.Keys.UiDefaults,
-			cache:      lrucache.New(1024),
+			cache:      uiConfigCache,
 		}
+		metrics.RegisterCache("ui-config", uiConfigCache)
 	})
 
 	return userCfgRepoInstance
@@ -109,29 +117,117 @@ func (uCfg *UserCfgRepo) GetUIConfig(user *schema.User) (map[string]interface{},
 
 // If the context does not have a user, update the global ui configuration
 // without persisting it!  If there is a (authenticated) user, update only his
-// configuration.
+// configuration. The key and value are validated against the UI
+// configuration schema.
 func (uCfg *UserCfgRepo) UpdateConfig(
 	key, value string,
 	user *schema.User) error {
 
+	var val interface{}
+	if err := json.Unmarshal([]byte(value), &val); err != nil {
+		log.Warn("Error while unmarshaling raw user config json")
+		return fmt.Errorf("%w: %s", schema.ErrInvalidUIConfig, err.Error())
+	}
+
 	if user == nil {
-		var val interface{}
-		if err := json.Unmarshal([]byte(value), &val); err != nil {
-			log.Warn("Error while unmarshaling raw user config json")
+		if err := schema.ValidateUIConfig(map[string]interface{}{key: val}); err != nil {
 			return err
 		}
-
 		uCfg.lock.Lock()
 		defer uCfg.lock.Unlock()
 		uCfg.uiDefaults[key] = val
 		return nil
 	}
 
-	if _, err := uCfg.DB.Exec(`REPLACE INTO configuration (username, confkey, value) VALUES (?, ?, ?)`, user.Username, key, value); err != nil {
+	if err := SetUIConfig(context.Background(), uCfg.DB, user.Username, map[string]interface{}{key: val}); err != nil {
 		log.Warnf("Error while replacing user config in DB for user '%v'", user.Username)
 		return err
 	}
+	InvalidateUIConfig(user.Username)
+	return nil
+}
 
-	uCfg.cache.Del(user.Username)
+// InvalidateUIConfig makes the next lookups of the UI configuration of
+// usernames read the database again. Call it after committing changes made
+// with SetUIConfig, DeleteUIConfig or PushUIConfig.
+func InvalidateUIConfig(usernames ...string) {
+	for _, username := range usernames {
+		uiConfigCache.Del(username)
+	}
+}
+
+// LoadUIConfig returns the settings username changed, without defaults.
+func LoadUIConfig(ctx context.Context, runner sq.BaseRunner, username string) (map[string]interface{}, error) {
+	rows, err := sq.Select("confkey", "value").From("configuration").Where("username = ?", username).
+		RunWith(runner).QueryContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := make(map[string]interface{})
+	for rows.Next() {
+		var key, rawval string
+		if err := rows.Scan(&key, &rawval); err != nil {
+			return nil, err
+		}
+		var val interface{}
+		if err := json.Unmarshal([]byte(rawval), &val); err != nil {
+			return nil, err
+		}
+		settings[key] = val
+	}
+	return settings, rows.Err()
+}
+
+// SetUIConfig validates settings and stores them for username, other
+// settings of the user are kept.
+func SetUIConfig(ctx context.Context, runner sq.BaseRunner, username string, settings map[string]interface{}) error {
+	if err := schema.ValidateUIConfig(settings); err != nil {
+		return err
+	}
+	for key, val := range settings {
+		raw, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		if _, err := sq.Replace("configuration").Columns("username", "confkey", "value").
+			Values(username, key, string(raw)).RunWith(runner).ExecContext(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteUIConfig resets the settings keys of username to their defaults,
+// all settings if no keys are given.
+func DeleteUIConfig(ctx context.Context, runner sq.BaseRunner, username string, keys ...string) error {
+	q := sq.Delete("configuration").Where("username = ?", username)
+	if len(keys) != 0 {
+		q = q.Where(sq.Eq{"confkey": keys})
+	}
+	if _, err := q.RunWith(runner).ExecContext(ctx); err != nil {
+		return err
+	}
 	return nil
 }
+
+// PushUIConfig sets settings for all users, replacing their own values of
+// these keys. It returns the usernames of the users.
+func PushUIConfig(ctx context.Context, runner sq.BaseRunner, settings map[string]interface{}) ([]string, error) {
+	if err := schema.ValidateUIConfig(settings); err != nil {
+		return nil, err
+	}
+	users, err := LoadUsers(ctx, runner, false)
+	if err != nil {
+		return nil, err
+	}
+	usernames := make([]string, 0, len(users))
+	for _, user := range users {
+		if err := SetUIConfig(ctx, runner, user.Username, settings); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, user.Username)
+	}
+	return usernames, nil
+}
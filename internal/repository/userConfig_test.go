@@ -5,16 +5,26 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 
 	"github.com/Deepbinder-main/cc-backend/internal/config"
+	"github.com/Deepbinder-main/cc-backend/internal/util"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+var testUserDB struct {
+	once sync.Once
+	err  error
+}
+
 func setupUserTest(t *testing.T) *UserCfgRepo {
 	const testconfig = `{
 	"addr":            "0.0.0.0:8080",
@@ -37,12 +47,19 @@ func setupUserTest(t *testing.T) *UserCfgRepo {
 }`
 
 	log.Init("info", true)
-	dbfilepath := "testdata/job.db"
-	err := MigrateDB("sqlite3", dbfilepath)
-	if err != nil {
-		t.Fatal(err)
+	testUserDB.once.Do(func() {
+		// Migrate a copy, the database in testdata must not change
+		dbfilepath := filepath.Join(os.TempDir(), fmt.Sprintf("cc-backend-repository-test-%d.db", os.Getpid()))
+		if testUserDB.err = util.CopyFile("testdata/job.db", dbfilepath); testUserDB.err != nil {
+			return
+		}
+		if testUserDB.err = MigrateDB("sqlite3", dbfilepath); testUserDB.err == nil {
+			Connect("sqlite3", dbfilepath)
+		}
+	})
+	if testUserDB.err != nil {
+		t.Fatal(testUserDB.err)
 	}
-	Connect("sqlite3", dbfilepath)
 
 	tmpdir := t.TempDir()
 	cfgFilePath := filepath.Join(tmpdir, "config.json")
@@ -70,3 +87,34 @@ func TestGetUIConfig(t *testing.T) {
 		t.Errorf("wrong config\ngot: %s \nwant: flops_any", str)
 	}
 }
+
+func TestUpdateUIConfig(t *testing.T) {
+	r := setupUserTest(t)
+	u := schema.User{Username: "demo"}
+
+	if err := r.UpdateConfig("plot_list_jobsPerPage", "20", &u); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateConfig("plot_list_jobsPerPage", `"all"`, &u); !errors.Is(err, schema.ErrInvalidUIConfig) {
+		t.Errorf("invalid value accepted: %v", err)
+	}
+	if err := r.UpdateConfig("no_such_key", "1", &u); !errors.Is(err, schema.ErrInvalidUIConfig) {
+		t.Errorf("unknown key accepted: %v", err)
+	}
+
+	cfg, err := r.GetUIConfig(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg["plot_list_jobsPerPage"] != float64(20) {
+		t.Errorf("wrong config\ngot: %v \nwant: 20", cfg["plot_list_jobsPerPage"])
+	}
+
+	if err := DeleteUIConfig(context.Background(), r.DB, u.Username); err != nil {
+		t.Fatal(err)
+	}
+	InvalidateUIConfig(u.Username)
+	if cfg, _ := r.GetUIConfig(&u); cfg["plot_list_jobsPerPage"] != 50 {
+		t.Errorf("wrong config after reset\ngot: %v \nwant: 50", cfg["plot_list_jobsPerPage"])
+	}
+}
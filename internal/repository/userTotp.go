@@ -0,0 +1,135 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// UserTOTP is the TOTP second factor of a user. Until the user confirmed
+// the enrollment with a valid code, it is not enabled. Secret and recovery
+// codes are never returned by the API.
+type UserTOTP struct {
+	Username      string   `json:"username"`
+	Secret        string   `json:"-"` // base32, sealed by the secrets key if Encrypted
+	Encrypted     bool     `json:"-"`
+	Enabled       bool     `json:"enabled"`
+	LastStep      int64    `json:"-"` // time step of the last accepted code
+	RecoveryCodes []string `json:"-"` // hashes of the unused recovery codes
+	CreatedAt     int64    `json:"createdAt"`
+	EnabledAt     int64    `json:"enabledAt,omitempty"` // 0 if not enabled
+}
+
+var userTOTPColumns = []string{"username", "secret", "encrypted", "enabled", "last_step", "recovery_codes", "created_at", "enabled_at"}
+
+func scanUserTOTP(row sq.RowScanner) (*UserTOTP, error) {
+	t := &UserTOTP{}
+	var codes string
+	if err := row.Scan(&t.Username, &t.Secret, &t.Encrypted, &t.Enabled, &t.LastStep, &codes, &t.CreatedAt, &t.EnabledAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(codes), &t.RecoveryCodes); err != nil {
+		return nil, err
+	}
+	if t.RecoveryCodes == nil {
+		t.RecoveryCodes = []string{}
+	}
+	return t, nil
+}
+
+// GetUserTOTP returns sql.ErrNoRows if username has no second factor.
+func GetUserTOTP(ctx context.Context, runner sq.BaseRunner, username string) (*UserTOTP, error) {
+	return scanUserTOTP(sq.Select(userTOTPColumns...).From("user_totp").
+		Where("username = ?", username).RunWith(runner).QueryRowContext(ctx))
+}
+
+// SaveUserTOTP inserts or replaces the second factor of t.Username.
+func SaveUserTOTP(ctx context.Context, runner sq.BaseRunner, t *UserTOTP) error {
+	if t.RecoveryCodes == nil {
+		t.RecoveryCodes = []string{}
+	}
+	codes, err := json.Marshal(t.RecoveryCodes)
+	if err != nil {
+		return err
+	}
+
+	var exists int
+	if err := sq.Select("COUNT(*)").From("user_totp").Where("username = ?", t.Username).
+		RunWith(runner).QueryRowContext(ctx).Scan(&exists); err != nil {
+		return err
+	}
+
+	if exists > 0 {
+		_, err = sq.Update("user_totp").
+			Set("secret", t.Secret).Set("encrypted", t.Encrypted).Set("enabled", t.Enabled).
+			Set("last_step", t.LastStep).Set("recovery_codes", string(codes)).
+			Set("created_at", t.CreatedAt).Set("enabled_at", t.EnabledAt).
+			Where("username = ?", t.Username).RunWith(runner).ExecContext(ctx)
+	} else {
+		_, err = sq.Insert("user_totp").Columns(userTOTPColumns...).
+			Values(t.Username, t.Secret, t.Encrypted, t.Enabled, t.LastStep, string(codes), t.CreatedAt, t.EnabledAt).
+			RunWith(runner).ExecContext(ctx)
+	}
+	return err
+}
+
+// DeleteUserTOTP removes the second factor of username. It returns
+// sql.ErrNoRows if there was none.
+func DeleteUserTOTP(ctx context.Context, runner sq.BaseRunner, username string) error {
+	res, err := sq.Delete("user_totp").Where("username = ?", username).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// AdvanceUserTOTPStep records step as the last accepted time step of
+// username. It reports false if a code of that or a later step was already
+// accepted, which prevents using a code twice.
+func AdvanceUserTOTPStep(ctx context.Context, runner sq.BaseRunner, username string, step int64) (bool, error) {
+	res, err := sq.Update("user_totp").Set("last_step", step).
+		Where("username = ? AND last_step < ?", username, step).RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// ConsumeUserTOTPRecoveryCodes replaces the recovery codes of username by
+// remaining, if they still are codes. It reports false if the codes were
+// changed meanwhile, e.g. because the same code was used concurrently.
+func ConsumeUserTOTPRecoveryCodes(ctx context.Context, runner sq.BaseRunner, username string, codes, remaining []string) (bool, error) {
+	old, err := json.Marshal(codes)
+	if err != nil {
+		return false, err
+	}
+	if remaining == nil {
+		remaining = []string{}
+	}
+	updated, err := json.Marshal(remaining)
+	if err != nil {
+		return false, err
+	}
+
+	res, err := sq.Update("user_totp").Set("recovery_codes", string(updated)).
+		Where("username = ? AND enabled = 1 AND recovery_codes = ?", username, string(old)).
+		RunWith(runner).ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	sq "github.com/Masterminds/squirrel"
+)
+
+// DBProvider resolves "db:" references to secrets stored in the secrets
+// table, encrypted with AES-256-GCM. The name of a secret is authenticated
+// along with it, so ciphertexts cannot be swapped between names.
+type DBProvider struct {
+	db   sq.BaseRunner
+	aead cipher.AEAD
+}
+
+// ParseKey decodes a 32 byte key given as base64 or hex string.
+func ParseKey(s string) ([]byte, error) {
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("SECRETS > key must be 32 bytes, encoded as base64 or hex")
+}
+
+func NewDBProvider(db sq.BaseRunner, key []byte) (*DBProvider, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &DBProvider{db: db, aead: aead}, nil
+}
+
+func (p *DBProvider) Lookup(ctx context.Context, name string) (string, error) {
+	s, err := repository.GetStoredSecret(ctx, p.db, name)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return p.open(name, s.Ciphertext)
+}
+
+// Store encrypts value and saves it as name using runner, which may be a
+// transaction.
+func (p *DBProvider) Store(ctx context.Context, runner sq.BaseRunner, name, value string) error {
+	ciphertext, err := p.seal(name, value)
+	if err != nil {
+		return err
+	}
+	return repository.PutStoredSecret(ctx, runner, name, ciphertext)
+}
+
+// Seal encrypts value for storage outside of the secrets table. Like for
+// stored secrets, name is authenticated along with the value and has to be
+// passed to Open again.
+func (p *DBProvider) Seal(name, value string) (string, error) {
+	return p.seal(name, value)
+}
+
+// Open decrypts a value encrypted by Seal.
+func (p *DBProvider) Open(name, ciphertext string) (string, error) {
+	return p.open(name, ciphertext)
+}
+
+func (p *DBProvider) seal(name, value string) (string, error) {
+	nonce := make([]byte, p.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := p.aead.Seal(nonce, nonce, []byte(value), []byte(name))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (p *DBProvider) open(name, ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil || len(sealed) < p.aead.NonceSize() {
+		return "", errors.New("malformed ciphertext")
+	}
+	n := p.aead.NonceSize()
+	value, err := p.aead.Open(nil, sealed[:n], sealed[n:], []byte(name))
+	if err != nil {
+		return "", fmt.Errorf("decryption failed, was the key changed? (%w)", err)
+	}
+	return string(value), nil
+}
+
+var (
+	dbProviderLock sync.RWMutex
+	dbProvider     *DBProvider
+)
+
+// UseDB registers p for "db:" references and makes it available to code
+// that stores secrets.
+func UseDB(p *DBProvider) {
+	Register(SchemeDB, p)
+	dbProviderLock.Lock()
+	defer dbProviderLock.Unlock()
+	dbProvider = p
+}
+
+// DB returns the provider registered by UseDB, or nil if no key for the
+// encryption of secrets in the database is configured.
+func DB() *DBProvider {
+	dbProviderLock.RLock()
+	defer dbProviderLock.RUnlock()
+	return dbProvider
+}
@@ -0,0 +1,231 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package secrets resolves references to credentials so that configuration
+// files and tables do not need to contain them in plain text.
+//
+// A reference has the form "<scheme>:<key>":
+//
+//	env:NAME     value of the environment variable NAME
+//	file:PATH    content of the file PATH without trailing newlines
+//	db:NAME      secret NAME encrypted in the database (see DBProvider)
+//	plain:VALUE  VALUE itself, to escape values that look like a reference
+//
+// Values without a known scheme are returned as they are, which keeps
+// existing plain text configurations working.
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	SchemeEnv   = "env"
+	SchemeFile  = "file"
+	SchemeDB    = "db"
+	SchemePlain = "plain"
+)
+
+// Prefix of the environment variables that references in API requests may
+// name if no other prefix is configured.
+const DefaultEnvPrefix = "CC_SECRET_"
+
+var (
+	ErrNotFound   = errors.New("secret not found")
+	ErrNotAllowed = errors.New("secret reference not allowed")
+)
+
+// Provider looks up the secret identified by key, the part of a reference
+// after the scheme.
+type Provider interface {
+	Lookup(ctx context.Context, key string) (string, error)
+}
+
+type ProviderFunc func(ctx context.Context, key string) (string, error)
+
+func (fn ProviderFunc) Lookup(ctx context.Context, key string) (string, error) {
+	return fn(ctx, key)
+}
+
+var (
+	providersLock sync.RWMutex
+	providers     = map[string]Provider{
+		SchemeEnv:   ProviderFunc(lookupEnv),
+		SchemeFile:  ProviderFunc(lookupFile),
+		SchemePlain: ProviderFunc(func(_ context.Context, key string) (string, error) { return key, nil }),
+	}
+
+	// Schemes that denote a reference even if no provider is registered, so
+	// that a missing provider is reported instead of using the reference as
+	// value.
+	knownSchemes = []string{SchemeEnv, SchemeFile, SchemeDB, SchemePlain}
+)
+
+// Register makes p responsible for references with the given scheme,
+// replacing a previously registered provider.
+func Register(scheme string, p Provider) {
+	providersLock.Lock()
+	defer providersLock.Unlock()
+	providers[scheme] = p
+	for _, s := range knownSchemes {
+		if s == scheme {
+			return
+		}
+	}
+	knownSchemes = append(knownSchemes, scheme)
+}
+
+func parse(value string) (scheme, key string, ok bool) {
+	scheme, key, ok = strings.Cut(value, ":")
+	if !ok {
+		return "", "", false
+	}
+
+	providersLock.RLock()
+	defer providersLock.RUnlock()
+	for _, s := range knownSchemes {
+		if s == scheme {
+			return scheme, key, true
+		}
+	}
+	return "", "", false
+}
+
+// IsReference reports whether value refers to a secret instead of being one.
+func IsReference(value string) bool {
+	_, _, ok := parse(value)
+	return ok
+}
+
+// Resolve returns the secret value refers to, or value itself if it is no
+// reference.
+func Resolve(ctx context.Context, value string) (string, error) {
+	scheme, key, ok := parse(value)
+	if !ok {
+		return value, nil
+	}
+
+	providersLock.RLock()
+	p := providers[scheme]
+	providersLock.RUnlock()
+	if p == nil {
+		return "", fmt.Errorf("SECRETS > no provider for '%s:' references configured", scheme)
+	}
+
+	secret, err := p.Lookup(ctx, key)
+	if err != nil {
+		return "", fmt.Errorf("SECRETS > resolving '%s:%s' failed: %w", scheme, key, err)
+	}
+	return secret, nil
+}
+
+// Check returns an error if value is a reference that cannot be resolved.
+func Check(ctx context.Context, value string) error {
+	_, err := Resolve(ctx, value)
+	return err
+}
+
+// Policy limits the references accepted from API requests. Unlike
+// config.json, those are written by any admin, and must not reach arbitrary
+// environment variables (e.g. JWT_PRIVATE_KEY) or files. db: and plain:
+// references are always allowed.
+type Policy struct {
+	// env: references must name a variable with this prefix, none are
+	// allowed if empty
+	EnvPrefix string
+	// file: references must point into this directory, none are allowed if
+	// empty
+	FileDir string
+}
+
+var (
+	policyLock sync.RWMutex
+	policy     = Policy{EnvPrefix: DefaultEnvPrefix}
+)
+
+// Restrict replaces the policy checked by Allowed.
+func Restrict(p Policy) {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+	policy = p
+}
+
+// Allowed returns ErrNotAllowed if value is a reference the policy does not
+// permit.
+func Allowed(value string) error {
+	scheme, key, ok := parse(value)
+	if !ok {
+		return nil
+	}
+
+	policyLock.RLock()
+	p := policy
+	policyLock.RUnlock()
+	switch scheme {
+	case SchemeDB, SchemePlain:
+		return nil
+	case SchemeEnv:
+		if p.EnvPrefix != "" && strings.HasPrefix(key, p.EnvPrefix) {
+			return nil
+		}
+	case SchemeFile:
+		if p.FileDir != "" && inDir(p.FileDir, key) {
+			return nil
+		}
+	}
+	return fmt.Errorf("SECRETS > '%s:%s': %w", scheme, key, ErrNotAllowed)
+}
+
+// ResolveAllowed is Resolve for values from API requests.
+func ResolveAllowed(ctx context.Context, value string) (string, error) {
+	if err := Allowed(value); err != nil {
+		return "", err
+	}
+	return Resolve(ctx, value)
+}
+
+// inDir reports whether path is inside dir, also after following symlinks.
+func inDir(dir, path string) bool {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	if path, err = filepath.Abs(path); err != nil {
+		return false
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if dir, err = filepath.Abs(dir); err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func lookupEnv(_ context.Context, key string) (string, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func lookupFile(_ context.Context, key string) (string, error) {
+	data, err := os.ReadFile(key)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package secrets
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/internal/repository"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestResolve(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("CC_TEST_SECRET", "from-env")
+	path := filepath.Join(t.TempDir(), "secret")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for value, expected := range map[string]string{
+		"env:CC_TEST_SECRET":   "from-env",
+		"file:" + path:         "from-file",
+		"plain:env:NOT_A_REF":  "env:NOT_A_REF",
+		"literal":              "literal",
+		"amqp://u:p@host/":     "amqp://u:p@host/",
+		"unknown:scheme:value": "unknown:scheme:value",
+	} {
+		got, err := Resolve(ctx, value)
+		if err != nil || got != expected {
+			t.Errorf("Resolve(%q) = %q, %v; expected %q", value, got, err, expected)
+		}
+	}
+
+	if _, err := Resolve(ctx, "env:CC_TEST_SECRET_MISSING"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := Resolve(ctx, "file:"+path+".missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "influx")
+	if err := os.WriteFile(path, []byte("token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "jwt")
+	if err := os.WriteFile(outside, []byte("key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { Restrict(Policy{EnvPrefix: DefaultEnvPrefix}) })
+	for _, p := range []Policy{{EnvPrefix: DefaultEnvPrefix}, {EnvPrefix: "CC_SECRET_", FileDir: dir}} {
+		Restrict(p)
+		for value, allowed := range map[string]bool{
+			"literal":                  true,
+			"db:influx":                true,
+			"plain:env:JWT_PUBLIC_KEY": true,
+			"env:CC_SECRET_INFLUX":     true,
+			"env:JWT_PRIVATE_KEY":      false,
+			"env:CC_SECRETS_KEY":       false,
+			"file:" + path:             p.FileDir != "",
+			"file:" + dir + "/../jwt":  false,
+			"file:" + outside:          false,
+			"file:" + link:             false,
+			"file:/etc/passwd":         false,
+		} {
+			err := Allowed(value)
+			if allowed && err != nil {
+				t.Errorf("expected %q to be allowed with %+v: %v", value, p, err)
+			}
+			if !allowed && !errors.Is(err, ErrNotAllowed) {
+				t.Errorf("expected %q to be rejected with %+v, got %v", value, p, err)
+			}
+		}
+	}
+
+	t.Setenv("JWT_PRIVATE_KEY", "private")
+	if _, err := ResolveAllowed(context.Background(), "env:JWT_PRIVATE_KEY"); !errors.Is(err, ErrNotAllowed) {
+		t.Errorf("expected ErrNotAllowed, got %v", err)
+	}
+}
+
+func TestDBProvider(t *testing.T) {
+	ctx := context.Background()
+
+	providersLock.Lock()
+	delete(providers, SchemeDB)
+	providersLock.Unlock()
+	if _, err := Resolve(ctx, "db:influx"); err == nil || !strings.Contains(err.Error(), "no provider") {
+		t.Errorf("expected missing provider, got %v", err)
+	}
+
+	dbfilepath := filepath.Join(t.TempDir(), "secrets.db")
+	if err := repository.MigrateDB("sqlite3", dbfilepath); err != nil {
+		t.Fatal(err)
+	}
+	db, err := sql.Open("sqlite3", dbfilepath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	key, err := ParseKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewDBProvider(db, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	UseDB(p)
+
+	if err := p.Store(ctx, db, "influx", "s3cret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Store(ctx, db, "rabbit", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Resolve(ctx, "db:influx"); err != nil || got != "s3cret" {
+		t.Errorf("unexpected secret %q (%v)", got, err)
+	}
+
+	var ciphertext string
+	if err := db.QueryRow(`SELECT ciphertext FROM secrets WHERE name = 'influx'`).Scan(&ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(ciphertext, "s3cret") {
+		t.Errorf("secret stored in plain text")
+	}
+
+	// Ciphertexts are bound to their name
+	if _, err := db.Exec(`UPDATE secrets SET ciphertext = ? WHERE name = 'rabbit'`, ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve(ctx, "db:rabbit"); err == nil {
+		t.Errorf("swapped ciphertext was accepted")
+	}
+
+	if _, err := Resolve(ctx, "db:missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+
+// Package tracing sets up the export of OpenTelemetry traces. Packages
+// create their spans with otel.Tracer, which does nothing until Init was
+// called with an exporter configured.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const DefaultServiceName = "cc-backend"
+
+var provider *sdktrace.TracerProvider
+
+// Init installs a tracer provider exporting to the configured exporter. If
+// conf is nil, tracing stays off.
+func Init(conf *schema.TracingConfig) error {
+	if conf == nil {
+		return nil
+	}
+
+	exporter, err := newExporter(conf)
+	if err != nil {
+		return err
+	}
+
+	name := conf.ServiceName
+	if name == "" {
+		name = DefaultServiceName
+	}
+	res, err := resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceName(name)))
+	if err != nil {
+		return err
+	}
+
+	ratio := conf.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+
+	Start(sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio)))))
+	log.Infof("exporting traces via %s", conf.Exporter)
+	return nil
+}
+
+func newExporter(conf *schema.TracingConfig) (sdktrace.SpanExporter, error) {
+	switch conf.Exporter {
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if conf.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(conf.Endpoint))
+		}
+		return otlptracehttp.New(context.Background(), opts...)
+	case "stdout":
+		var w io.Writer = os.Stdout
+		if conf.File != "" {
+			f, err := os.OpenFile(conf.File, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+			if err != nil {
+				return nil, err
+			}
+			w = f
+		}
+		return stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("TRACING > unknown exporter '%s'", conf.Exporter)
+	}
+}
+
+// Start makes tp the global tracer provider and propagates the W3C trace
+// context. Init calls it, tests use it with an in-memory exporter.
+func Start(tp *sdktrace.TracerProvider) {
+	provider = tp
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Enabled reports whether spans are exported.
+func Enabled() bool {
+	return provider != nil
+}
+
+// Shutdown exports the buffered spans and stops the exporter.
+func Shutdown(ctx context.Context) error {
+	if provider == nil {
+		return nil
+	}
+	return provider.Shutdown(ctx)
+}
+
+// Middleware starts a server span for each request, continuing the trace of
+// the caller if it sent a trace context. Spans are named after the path
+// template of the matched mux route, like the HTTP metrics. Use it with
+// mux.Router.Use.
+func Middleware(next http.Handler) http.Handler {
+	withRoute := http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if route := routeOf(r); route != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(route))
+		}
+		next.ServeHTTP(rw, r)
+	})
+	return otelhttp.NewHandler(withRoute, "http",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			if route := routeOf(r); route != "" {
+				return r.Method + " " + route
+			}
+			return r.Method
+		}))
+}
+
+func routeOf(r *http.Request) string {
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return ""
+}
+
+// Transport starts a client span for each request sent via rt (or
+// http.DefaultTransport if nil) and passes the trace context on.
+func Transport(rt http.RoundTripper) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(rt)
+}
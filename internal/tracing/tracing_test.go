@@ -0,0 +1,132 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/schema"
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func record(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	spans := tracetest.NewInMemoryExporter()
+	Start(sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)))
+	t.Cleanup(func() { provider = nil })
+	return spans
+}
+
+func TestMiddleware(t *testing.T) {
+	spans := record(t)
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/machines/{id}", func(rw http.ResponseWriter, r *http.Request) {})
+	r.Use(Middleware)
+
+	// Continues the trace of the caller
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/machines/m1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	ended := spans.GetSpans()
+	if len(ended) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(ended))
+	}
+	span := ended[0]
+	if span.Name != "GET /api/machines/{id}" || span.SpanKind != trace.SpanKindServer {
+		t.Errorf("unexpected span: %s (%s)", span.Name, span.SpanKind)
+	}
+	if span.SpanContext.TraceID().String() != traceID {
+		t.Errorf("expected trace %s, got %s", traceID, span.SpanContext.TraceID())
+	}
+	found := false
+	for _, attr := range span.Attributes {
+		if attr == semconv.HTTPRoute("/api/machines/{id}") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the route in the attributes: %v", span.Attributes)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	spans := record(t)
+
+	var traceparent string
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "LoadData")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/api/query", nil)
+	client := http.Client{Transport: Transport(nil)}
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	parent.End()
+
+	ended := spans.GetSpans()
+	if len(ended) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(ended))
+	}
+	span := ended[0]
+	if span.SpanKind != trace.SpanKindClient || span.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("expected a client span below the parent: %+v", span)
+	}
+	if !strings.Contains(traceparent, span.SpanContext.SpanID().String()) {
+		t.Errorf("expected the trace context of the client span to be sent, got %q", traceparent)
+	}
+}
+
+func TestInit(t *testing.T) {
+	t.Cleanup(func() { provider = nil })
+
+	if err := Init(nil); err != nil || Enabled() {
+		t.Fatalf("expected tracing to stay off: %v", err)
+	}
+	if err := Init(&schema.TracingConfig{Exporter: "zipkin"}); err == nil {
+		t.Error("expected unknown exporters to be rejected")
+	}
+
+	file := filepath.Join(t.TempDir(), "spans.json")
+	if err := Init(&schema.TracingConfig{Exporter: "stdout", File: file, ServiceName: "cc-test"}); err != nil {
+		t.Fatal(err)
+	}
+	if !Enabled() {
+		t.Fatal("expected tracing to be enabled")
+	}
+	_, span := otel.Tracer("test").Start(context.Background(), "archive.Init")
+	span.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"Name":"archive.Init"`, `"Value":"cc-test"`} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("expected %s in the exported spans: %s", s, out)
+		}
+	}
+}
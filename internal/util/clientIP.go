@@ -0,0 +1,114 @@
+// Copyright (C) 2023 NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package util
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/Deepbinder-main/cc-backend/pkg/log"
+)
+
+var (
+	proxiesLock    sync.RWMutex
+	trustedProxies []*net.IPNet
+
+	// Logged once if proxy headers arrive while no proxy is trusted
+	untrustedHeadersOnce sync.Once
+)
+
+// TrustProxies sets the addresses (IPs or CIDR ranges) of the reverse
+// proxies whose X-Forwarded-For and X-Real-Ip headers ClientIP honors.
+func TrustProxies(proxies []string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, p := range proxies {
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				return fmt.Errorf("UTIL > invalid proxy address '%s'", p)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(p)
+		if err != nil {
+			return fmt.Errorf("UTIL > invalid proxy address '%s': %w", p, err)
+		}
+		nets = append(nets, ipnet)
+	}
+
+	proxiesLock.Lock()
+	defer proxiesLock.Unlock()
+	trustedProxies = nets
+	return nil
+}
+
+// ProxiesTrusted reports whether any reverse proxy is trusted.
+func ProxiesTrusted() bool {
+	proxiesLock.RLock()
+	defer proxiesLock.RUnlock()
+	return len(trustedProxies) > 0
+}
+
+func isTrustedProxy(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	proxiesLock.RLock()
+	defer proxiesLock.RUnlock()
+	for _, ipnet := range trustedProxies {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. The proxy headers are only
+// honored if the request came from a trusted proxy, anybody else could set
+// them to any value. Of X-Forwarded-For, the last address not belonging to
+// a trusted proxy is taken, earlier ones were added by the client.
+func ClientIP(r *http.Request) string {
+	IPAddress := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		IPAddress = host
+	}
+	if !isTrustedProxy(IPAddress) {
+		if !ProxiesTrusted() && (r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("X-Real-Ip") != "") {
+			untrustedHeadersOnce.Do(func() {
+				log.Warnf("ignoring X-Forwarded-For/X-Real-Ip headers of requests from %s: "+
+					"add the address of your reverse proxy to 'trusted-proxies'", IPAddress)
+			})
+		}
+		return IPAddress
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+			IPAddress = hop
+			if !isTrustedProxy(hop) {
+				break
+			}
+		}
+		return IPAddress
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); net.ParseIP(realIP) != nil {
+		IPAddress = realIP
+	}
+	return IPAddress
+}
@@ -6,6 +6,7 @@ package util_test
 
 import (
 	"fmt"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
@@ -73,3 +74,38 @@ func TestGetFileCount(t *testing.T) {
 		t.Fatalf("expected 0, got %d", c)
 	}
 }
+
+func TestClientIP(t *testing.T) {
+	if err := util.TrustProxies([]string{"10.0.0.1", "fd00::/64"}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { util.TrustProxies(nil) })
+	if err := util.TrustProxies([]string{"proxy.local"}); err == nil {
+		t.Error("expected invalid proxy addresses to be rejected")
+	}
+
+	for _, tc := range []struct {
+		remote, realIP, forwarded, want string
+	}{
+		// Headers of clients that are not a trusted proxy are ignored
+		{"192.0.2.7:51234", "198.51.100.1", "198.51.100.2", "192.0.2.7"},
+		{"[2001:db8::7]:51234", "", "", "2001:db8::7"},
+		{"10.0.0.1:443", "198.51.100.1", "", "198.51.100.1"},
+		// A client can prepend addresses, only the last untrusted one counts
+		{"10.0.0.1:443", "", "203.0.113.9, 198.51.100.2", "198.51.100.2"},
+		{"[fd00::2]:443", "", "2001:db8::9, 10.0.0.1", "2001:db8::9"},
+		{"10.0.0.1:443", "", "unknown", "10.0.0.1"},
+	} {
+		r := httptest.NewRequest("GET", "/login", nil)
+		r.RemoteAddr = tc.remote
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-Ip", tc.realIP)
+		}
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if got := util.ClientIP(r); got != tc.want {
+			t.Errorf("%s (%q, %q): expected %s, got %s", tc.remote, tc.realIP, tc.forwarded, tc.want, got)
+		}
+	}
+}
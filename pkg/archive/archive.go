@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	"github.com/Deepbinder-main/cc-backend/internal/metrics"
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/Deepbinder-main/cc-backend/pkg/lrucache"
 	"github.com/Deepbinder-main/cc-backend/pkg/schema"
@@ -20,6 +21,9 @@ type ArchiveBackend interface {
 
 	Info()
 
+	// Check that the archive can still be read. Used by the readiness probe.
+	HealthCheck() error
+
 	Exists(job *schema.Job) bool
 
 	LoadJobMeta(job *schema.Job) (*schema.JobMeta, error)
@@ -83,6 +87,7 @@ func Init(rawConfig json.RawMessage, disableArchive bool) error {
 		return err
 	}
 	log.Infof("Load archive version %d", version)
+	metrics.RegisterCache("archive", cache)
 
 	return initClusterConfig()
 }
@@ -91,6 +96,14 @@ func GetHandle() ArchiveBackend {
 	return ar
 }
 
+// HealthCheck returns an error if the archive cannot be read.
+func HealthCheck() error {
+	if ar == nil {
+		return fmt.Errorf("ARCHIVE/ARCHIVE > archive not initialized")
+	}
+	return ar.HealthCheck()
+}
+
 // Helper to metricdata.LoadAverages().
 func LoadAveragesFromArchive(
 	job *schema.Job,
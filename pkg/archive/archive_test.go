@@ -7,6 +7,7 @@ package archive_test
 import (
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"testing"
 	"time"
@@ -67,3 +68,24 @@ func TestCleanUp(t *testing.T) {
 // 		t.Error("Jobs still exist")
 // 	}
 // }
+
+func TestHealthCheck(t *testing.T) {
+	jobarchive := filepath.Join(t.TempDir(), "job-archive")
+	util.CopyDir("./testdata/archive/", jobarchive)
+	archiveCfg := fmt.Sprintf("{\"kind\": \"file\",\"path\": \"%s\"}", jobarchive)
+	if err := archive.Init(json.RawMessage(archiveCfg), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := archive.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	// As if the file system was unmounted
+	if err := os.RemoveAll(jobarchive); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.HealthCheck(); err == nil {
+		t.Error("expected the missing archive to be reported")
+	}
+}
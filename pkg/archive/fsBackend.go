@@ -161,6 +161,15 @@ func (fsa *FsArchive) Init(rawConfig json.RawMessage) (uint64, error) {
 	return version, nil
 }
 
+// HealthCheck reads the version file, which fails if the archive is not
+// mounted anymore.
+func (fsa *FsArchive) HealthCheck() error {
+	if _, err := os.ReadFile(filepath.Join(fsa.path, "version.txt")); err != nil {
+		return fmt.Errorf("archive at '%s' not readable: %w", fsa.path, err)
+	}
+	return nil
+}
+
 func (fsa *FsArchive) Info() {
 	fmt.Printf("Job archive %s\n", fsa.path)
 	clusters, err := os.ReadDir(fsa.path)
@@ -33,6 +33,23 @@ type Cache struct {
 	maxmemory, usedmemory int
 	entries               map[string]*cacheEntry
 	head, tail            *cacheEntry
+
+	hits, misses, evictions uint64
+}
+
+// Statistics of a cache, see `Stats`.
+type Stats struct {
+	// Number of `Get` calls that found a value
+	Hits uint64
+	// Number of `Get` calls that had to compute the value or returned nil
+	Misses uint64
+	// Number of entries removed because they expired or to free memory,
+	// entries removed by `Del` do not count
+	Evictions uint64
+
+	Entries    int
+	UsedMemory int
+	MaxMemory  int
 }
 
 // Return a new instance of a LRU In-Memory Cache.
@@ -71,19 +88,23 @@ func (c *Cache) Get(key string, computeValue ComputeValue) interface{} {
 				if entry.expiration.IsZero() {
 					panic("LRUCACHE/CACHE > cache entry that shoud have been waited for could not be evicted.")
 				}
+				c.hits += 1
 				c.mutex.Unlock()
 				return entry.value
 			}
+			c.evictions += 1
 		} else {
 			if entry != c.head {
 				c.unlinkEntry(entry)
 				c.insertFront(entry)
 			}
+			c.hits += 1
 			c.mutex.Unlock()
 			return entry.value
 		}
 	}
 
+	c.misses += 1
 	if computeValue == nil {
 		c.mutex.Unlock()
 		return nil
@@ -127,21 +148,26 @@ func (c *Cache) Get(key string, computeValue ComputeValue) interface{} {
 
 	c.usedmemory += size
 	c.insertFront(entry)
+	c.evictOverflow(now)
+
+	return value
+}
 
-	// Evict only entries with a size of more than zero.
-	// This is the only loop in the implementation outside of the `Keys`
-	// method.
+// Evict the least recently used entries until the cache fits into maxmemory
+// again. Evict only entries with a size of more than zero.
+// This is the only loop in the implementation outside of the `Keys`
+// method.
+func (c *Cache) evictOverflow(now time.Time) {
 	evictionCandidate := c.tail
 	for c.usedmemory > c.maxmemory && evictionCandidate != nil {
 		nextCandidate := evictionCandidate.prev
 		if (evictionCandidate.size > 0 || now.After(evictionCandidate.expiration)) &&
 			evictionCandidate.waitingForComputation == 0 {
 			c.evictEntry(evictionCandidate)
+			c.evictions += 1
 		}
 		evictionCandidate = nextCandidate
 	}
-
-	return value
 }
 
 // Put a new value in the cache. If another goroutine is calling `Get` and
@@ -167,6 +193,7 @@ func (c *Cache) Put(key string, value interface{}, size int, ttl time.Duration)
 
 		c.unlinkEntry(entry)
 		c.insertFront(entry)
+		c.evictOverflow(now)
 		return
 	}
 
@@ -174,9 +201,12 @@ func (c *Cache) Put(key string, value interface{}, size int, ttl time.Duration)
 		key:        key,
 		value:      value,
 		expiration: now.Add(ttl),
+		size:       size,
 	}
 	c.entries[key] = entry
+	c.usedmemory += size
 	c.insertFront(entry)
+	c.evictOverflow(now)
 }
 
 // Remove the value at key `key` from the cache.
@@ -213,6 +243,7 @@ func (c *Cache) Keys(f func(key string, val interface{})) {
 
 		if now.After(e.expiration) {
 			if c.evictEntry(e) {
+				c.evictions += 1
 				continue
 			}
 		}
@@ -250,6 +281,21 @@ func (c *Cache) Keys(f func(key string, val interface{})) {
 	}
 }
 
+// Return the statistics of the cache since it was created.
+func (c *Cache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return Stats{
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+		Entries:    len(c.entries),
+		UsedMemory: c.usedmemory,
+		MaxMemory:  c.maxmemory,
+	}
+}
+
 func (c *Cache) insertFront(e *cacheEntry) {
 	e.next = c.head
 	c.head = e
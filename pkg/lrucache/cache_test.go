@@ -151,6 +151,49 @@ func TestEviction(t *testing.T) {
 	})
 }
 
+func TestPutEviction(t *testing.T) {
+	c := New(100)
+	c.Put("A", "a", 50, 1*time.Second)
+	c.Put("B", "b", 50, 1*time.Second)
+	if c.Get("A", nil) == nil || c.Get("B", nil) == nil {
+		t.Fatal("values should be cached")
+	}
+
+	c.Put("C", "c", 50, 1*time.Second)
+	if c.Get("A", nil) != nil {
+		t.Error("'A' should have been evicted")
+	}
+
+	// Growing an entry evicts others as well
+	c.Put("C", "cc", 100, 1*time.Second)
+	c.Keys(func(key string, val interface{}) {
+		if key != "C" {
+			t.Errorf("'%s' was not expected", key)
+		}
+	})
+}
+
+func TestStats(t *testing.T) {
+	c := New(100)
+	compute := func() (interface{}, time.Duration, int) {
+		return "value", 1 * time.Minute, 60
+	}
+
+	_ = c.Get("A", compute)
+	_ = c.Get("A", compute)
+	_ = c.Get("B", nil)
+	_ = c.Get("B", compute)
+	c.Del("B")
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 {
+		t.Errorf("unexpected counters: %+v", stats)
+	}
+	if stats.Entries != 0 || stats.UsedMemory != 0 || stats.MaxMemory != 100 {
+		t.Errorf("unexpected memory usage: %+v", stats)
+	}
+}
+
 // I know that this is a shity test,
 // time is relative and unreliable.
 func TestConcurrency(t *testing.T) {
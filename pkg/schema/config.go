@@ -6,26 +6,89 @@ package schema
 
 import (
 	"encoding/json"
+	"strings"
 	"time"
 )
 
+// LdapURLs are the LDAP servers, tried in order until one is reachable. In
+// the configuration they are an array or a single string with the URLs
+// separated by spaces.
+type LdapURLs []string
+
+func (u *LdapURLs) UnmarshalJSON(input []byte) error {
+	var urls []string
+	if err := json.Unmarshal(input, &urls); err == nil {
+		*u = urls
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(input, &s); err != nil {
+		return err
+	}
+	*u = strings.Fields(s)
+	return nil
+}
+
 type LdapConfig struct {
-	Url             string `json:"url"`
-	UserBase        string `json:"user_base"`
-	SearchDN        string `json:"search_dn"`
-	UserBind        string `json:"user_bind"`
-	UserFilter      string `json:"user_filter"`
-	UserAttr        string `json:"username_attr"`
-	SyncInterval    string `json:"sync_interval"` // Parsed using time.ParseDuration.
-	SyncDelOldUsers bool   `json:"sync_del_old_users"`
+	Url             LdapURLs `json:"url"`
+	UserBase        string   `json:"user_base"`
+	SearchDN        string   `json:"search_dn"`
+	UserBind        string   `json:"user_bind"`
+	UserFilter      string   `json:"user_filter"`
+	UserAttr        string   `json:"username_attr"`
+	SyncInterval    string   `json:"sync_interval"` // Parsed using time.ParseDuration.
+	SyncDelOldUsers bool     `json:"sync_del_old_users"`
 
 	// Should an non-existent user be added to the DB if user exists in ldap directory
 	SyncUserOnLogin bool `json:"syncUserOnLogin"`
+
+	// Bases to search for the groups of users. Groups in the memberOf
+	// attribute of user entries are used as well.
+	GroupBases []string `json:"group_bases"`
+	// Filter for group entries, defaults to groupOfNames, groupOfUniqueNames
+	// and posixGroup objects
+	GroupFilter string `json:"group_filter"`
+
+	// Map group DNs to the roles and projects of their members. If set, they
+	// replace the roles and projects of LDAP users on login and sync.
+	RoleMapping    map[string][]string `json:"role_mapping"`
+	ProjectMapping map[string][]string `json:"project_mapping"`
+
+	// Upgrade ldap:// connections with StartTLS
+	StartTLS bool `json:"start_tls"`
+	// PEM file with the CA certificates to verify the servers with instead
+	// of the system ones, for both StartTLS and ldaps://
+	CaFile string `json:"ca_file"`
+
+	DialTimeout    string `json:"dial_timeout"`    // Parsed using time.ParseDuration.
+	RequestTimeout string `json:"request_timeout"` // Parsed using time.ParseDuration.
+	IdleTimeout    string `json:"idle_timeout"`    // Parsed using time.ParseDuration.
+	// Number of idle connections kept open
+	PoolSize int `json:"pool_size"`
 }
 
 type OpenIDConfig struct {
 	Provider        string `json:"provider"`
 	SyncUserOnLogin bool   `json:"syncUserOnLogin"`
+
+	// URL of the /oidc-callback endpoint as registered with the provider
+	RedirectURL string `json:"redirectUrl"`
+
+	// Scopes requested in addition to 'openid', 'profile' and 'email'
+	Scopes []string `json:"scopes"`
+
+	// Claim holding the groups or roles of the user. Nested claims are
+	// addressed by a dot separated path.
+	GroupsClaim string `json:"groupsClaim"`
+
+	// Roles and projects granted to members of a group (values of the
+	// groups claim). Applied on every login.
+	RoleMapping    map[string][]string `json:"roleMapping"`
+	ProjectMapping map[string][]string `json:"projectMapping"`
+
+	// Where the provider sends the user after logging out
+	PostLogoutRedirectURL string `json:"postLogoutRedirectUrl"`
 }
 
 type JWTAuthConfig struct {
@@ -45,6 +108,102 @@ type JWTAuthConfig struct {
 
 	// Should an non-existent user be added to the DB based on the information in the token
 	SyncUserOnLogin bool `json:"syncUserOnLogin"`
+
+	// Reject tokens without ID ('jti' claim). Such tokens were issued before
+	// tokens could be revoked and stay valid until they expire otherwise.
+	RequireTokenID bool `json:"requireTokenId"`
+
+	// File holding the key set for signing and verifying tokens, written by
+	// the -rotate-jwt-keys command line flag. If empty, only the key pair
+	// from the environment variables is used.
+	KeysFile string `json:"keysFile"`
+
+	// Do not allow non-admins to issue tokens for themselves
+	DisableSelfIssue bool `json:"disableSelfIssue"`
+
+	// Lifetime of tokens non-admins issue for themselves, capped by
+	// max-age. If empty, max-age applies.
+	SelfIssueMaxAge string `json:"selfIssueMaxAge"`
+}
+
+// LoginLockoutConfig configures how failed logins are throttled. Durations
+// are parsed using time.ParseDuration.
+type LoginLockoutConfig struct {
+	// Lock a username after this many failures in a row (default 5)
+	MaxFailures int `json:"maxFailures"`
+	// Lock a source IP after this many failures (default 50)
+	MaxIPFailures int `json:"maxIpFailures"`
+	// Wait after the first failure of a username, doubled with every
+	// further failure (default 1s, 0s disables the backoff)
+	BaseDelay string `json:"baseDelay"`
+	// Upper bound of the wait between attempts (default 30s)
+	MaxDelay string `json:"maxDelay"`
+	// How long a lockout lasts. Failures older than this are forgotten
+	// (default 15m).
+	LockoutDuration string `json:"lockoutDuration"`
+}
+
+// TOTPConfig configures the second factor of local and LDAP accounts.
+type TOTPConfig struct {
+	// Issuer shown by authenticator apps (default "ClusterCockpit")
+	Issuer string `json:"issuer"`
+	// Users with any of these roles have to set up a second factor on their
+	// next login
+	RequiredRoles []string `json:"required-roles"`
+}
+
+type PasswordPolicyConfig struct {
+	// Minimum number of characters (default 8)
+	MinLength int `json:"min-length"`
+	// Minimum number of character classes out of lower case, upper case,
+	// digits and others (default 1)
+	MinClasses int `json:"min-classes"`
+	// File with one breached password per line, or their SHA-1 hashes in
+	// hex as in the Have I Been Pwned downloads ("HASH:count" lines)
+	BreachList string `json:"breach-list"`
+	// How long password reset links are valid (default 24h)
+	ResetLinkLifetime string `json:"reset-link-lifetime"`
+}
+
+// GraphQLConfig limits what a single GraphQL operation may cost.
+type GraphQLConfig struct {
+	// Reject operations with a higher complexity (default 1000). Every
+	// field counts one, lists with a limit argument count their children
+	// that many times.
+	MaxComplexity int `json:"max-complexity"`
+	// Reject operations with selections nested deeper than this (default 10)
+	MaxDepth int `json:"max-depth"`
+	// Memory in bytes for the queries of automatic persisted queries
+	// (default 1MB)
+	PersistedQueriesCacheSize int `json:"persisted-queries-cache-size"`
+}
+
+// SecretReferencesConfig limits the secret references in configurations
+// saved via the API. db: and plain: references are always allowed.
+type SecretReferencesConfig struct {
+	// env: references must name a variable with this prefix (default
+	// CC_SECRET_)
+	EnvPrefix string `json:"env-prefix"`
+	// file: references must point into this directory, none are allowed if
+	// empty
+	FileDir string `json:"file-dir"`
+}
+
+// TracingConfig selects where OpenTelemetry traces are exported to.
+type TracingConfig struct {
+	// "otlp" to send the spans to an OTLP/HTTP collector, "stdout" to write
+	// them as JSON for local testing. Tracing is off if empty.
+	Exporter string `json:"exporter"`
+	// URL of the OTLP/HTTP endpoint (default from the OTEL_EXPORTER_OTLP_*
+	// environment variables, else http://localhost:4318)
+	Endpoint string `json:"endpoint"`
+	// File the stdout exporter appends to (default stdout)
+	File string `json:"file"`
+	// Fraction of the traces started here that are recorded (default 1).
+	// Requests that carry a sampled trace context are always recorded.
+	SampleRatio float64 `json:"sample-ratio"`
+	// Name of the service in the traces (default cc-backend)
+	ServiceName string `json:"service-name"`
 }
 
 type IntRange struct {
@@ -84,6 +243,14 @@ type ProgramConfig struct {
 	// Addresses from which secured API endpoints can be reached
 	ApiAllowedIPs []string `json:"apiAllowedIPs"`
 
+	// Reverse proxies (IPs or CIDR ranges) whose X-Forwarded-For and
+	// X-Real-Ip headers are trusted to name the client address
+	TrustedProxies []string `json:"trusted-proxies"`
+
+	// Origins (scheme://host[:port]) besides the host itself from which
+	// browsers may open GraphQL websocket connections
+	WebsocketAllowedOrigins []string `json:"websocket-allowed-origins"`
+
 	// Drop root permissions once .env was read and the port was taken.
 	User  string `json:"user"`
 	Group string `json:"group"`
@@ -101,8 +268,18 @@ type ProgramConfig struct {
 	DBDriver string `json:"db-driver"`
 
 	// For sqlite3 a filename, for mysql a DSN in this format: https://github.com/go-sql-driver/mysql#dsn-data-source-name (Without query parameters!).
+	// May be an env: reference like 'env:DB_DSN', other schemes are taken literally.
 	DB string `json:"db"`
 
+	// Key encrypting secrets stored in the database, which can then be used as
+	// `db:<name>` references. 32 bytes encoded as base64 or hex, usually given
+	// as reference itself (default: 'env:CC_SECRETS_KEY').
+	SecretsKey string `json:"secrets-key"`
+
+	// Which env: and file: references may be saved via the API, defaults
+	// apply if not set
+	SecretReferences *SecretReferencesConfig `json:"secret-references"`
+
 	// Config for job archive
 	Archive json.RawMessage `json:"archive"`
 
@@ -121,6 +298,25 @@ type ProgramConfig struct {
 	// If 0 or empty, the session does not expire!
 	SessionMaxAge string `json:"session-max-age"`
 
+	// Sessions inactive for this long end, as string parsable by
+	// time.ParseDuration(). If 0 or empty, only session-max-age applies.
+	SessionIdleTimeout string `json:"session-idle-timeout"`
+
+	// Throttling of failed logins, defaults apply if not set
+	LoginLockout *LoginLockoutConfig `json:"login-lockout"`
+
+	// TOTP second factor for local and LDAP accounts
+	TOTP *TOTPConfig `json:"totp"`
+
+	// Requirements for passwords of local accounts, defaults apply if not set
+	PasswordPolicy *PasswordPolicyConfig `json:"password-policy"`
+
+	// Limits of the GraphQL API, defaults apply if not set
+	GraphQL *GraphQLConfig `json:"graphql"`
+
+	// Export of OpenTelemetry traces, off if not set
+	Tracing *TracingConfig `json:"tracing"`
+
 	// If both those options are not empty, use HTTPS using those certificates.
 	HttpsCertFile string `json:"https-cert-file"`
 	HttpsKeyFile  string `json:"https-key-file"`
@@ -129,6 +325,11 @@ type ProgramConfig struct {
 	// redirect every request incoming at port 80 to that url.
 	RedirectHttpTo string `json:"redirect-http-to"`
 
+	// Time between failing the readiness probe and shutting down the server
+	// on SIGINT/SIGTERM, so that load balancers stop sending requests first.
+	// As string parsable by time.ParseDuration() (default 0s).
+	ShutdownDelay string `json:"shutdown-delay"`
+
 	// If overwritten, at least all the options in the defaults below must
 	// be provided! Most options here can be overwritten by the user.
 	UiDefaults map[string]interface{} `json:"ui-defaults"`
@@ -0,0 +1,35 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLdapURLs(t *testing.T) {
+	for input, expected := range map[string]LdapURLs{
+		`"ldap://a"`:                    {"ldap://a"},
+		`"ldap://a  ldaps://b"`:         {"ldap://a", "ldaps://b"},
+		`["ldap://a", "ldaps://b:636"]`: {"ldap://a", "ldaps://b:636"},
+	} {
+		config := []byte(`{
+	"jwts": {"max-age": "2m"},
+	"ldap": {"url": ` + input + `, "user_base": "ou=people", "search_dn": "cn=admin", "user_bind": "uid={username}", "user_filter": "(uid=*)"},
+	"clusters": [{"name": "c", "metricDataRepository": {"kind": "cc-metric-store", "url": "localhost:8082"},
+		"filterRanges": {"numNodes": {"from": 1, "to": 64}, "duration": {"from": 0, "to": 86400}, "startTime": {"from": "2022-01-01T00:00:00Z", "to": null}}}]
+}`)
+		if err := Validate(Config, bytes.NewReader(config)); err != nil {
+			t.Errorf("%s: %v", input, err)
+		}
+
+		var lc LdapConfig
+		if err := json.Unmarshal([]byte(`{"url": `+input+`}`), &lc); err != nil || !reflect.DeepEqual(lc.Url, expected) {
+			t.Errorf("%s: expected %v, got %v (%v)", input, expected, lc.Url, err)
+		}
+	}
+}
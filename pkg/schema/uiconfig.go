@@ -0,0 +1,119 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var ErrInvalidUIConfig = errors.New("invalid UI configuration")
+
+// UIConfigKey is a setting of the web UI users can change, as defined by
+// ui-config.schema.json.
+type UIConfigKey struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// The key can also be set for a single cluster as "<name>:<cluster>"
+	PerCluster bool `json:"perCluster"`
+	// JSON schema of the value
+	Schema json.RawMessage `json:"schema" swaggertype:"object"`
+}
+
+var (
+	uiConfigOnce   sync.Once
+	uiConfigSchema *jsonschema.Schema
+	uiConfigKeys   []UIConfigKey
+	uiConfigErr    error
+)
+
+func loadUIConfigSchema() {
+	if uiConfigSchema, uiConfigErr = compileSchema("ui-config.schema.json"); uiConfigErr != nil {
+		return
+	}
+
+	raw, err := schemaFiles.ReadFile("schemas/ui-config.schema.json")
+	if err != nil {
+		uiConfigErr = err
+		return
+	}
+	var doc struct {
+		Properties        map[string]json.RawMessage `json:"properties"`
+		PatternProperties map[string]json.RawMessage `json:"patternProperties"`
+	}
+	if uiConfigErr = json.Unmarshal(raw, &doc); uiConfigErr != nil {
+		return
+	}
+	for name, s := range doc.Properties {
+		var prop struct {
+			Description string `json:"description"`
+		}
+		json.Unmarshal(s, &prop)
+		_, perCluster := doc.PatternProperties["^"+name+":[^:]+$"]
+		uiConfigKeys = append(uiConfigKeys, UIConfigKey{
+			Name:        name,
+			Description: prop.Description,
+			PerCluster:  perCluster,
+			Schema:      s,
+		})
+	}
+	sort.Slice(uiConfigKeys, func(i, j int) bool { return uiConfigKeys[i].Name < uiConfigKeys[j].Name })
+}
+
+// UIConfigKeys returns all keys of the UI configuration sorted by name.
+func UIConfigKeys() ([]UIConfigKey, error) {
+	uiConfigOnce.Do(loadUIConfigSchema)
+	return uiConfigKeys, uiConfigErr
+}
+
+// ValidateUIConfig returns an error wrapping ErrInvalidUIConfig if settings
+// contains unknown keys or values not matching the schema of their key.
+func ValidateUIConfig(settings map[string]interface{}) error {
+	uiConfigOnce.Do(loadUIConfigSchema)
+	if uiConfigErr != nil {
+		return uiConfigErr
+	}
+
+	// Validate the JSON representation, numbers have to be float64
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidUIConfig, err.Error())
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	if err := uiConfigSchema.Validate(v); err != nil {
+		var ve *jsonschema.ValidationError
+		if errors.As(err, &ve) {
+			return fmt.Errorf("%w: %s", ErrInvalidUIConfig, uiConfigErrorMessage(ve))
+		}
+		return fmt.Errorf("%w: %s", ErrInvalidUIConfig, err.Error())
+	}
+	return nil
+}
+
+// uiConfigErrorMessage returns the messages of the innermost causes of ve,
+// which name the offending key.
+func uiConfigErrorMessage(ve *jsonschema.ValidationError) string {
+	if len(ve.Causes) == 0 {
+		if ve.InstanceLocation == "" {
+			return ve.Message
+		}
+		return fmt.Sprintf("%s: %s", strings.TrimPrefix(ve.InstanceLocation, "/"), ve.Message)
+	}
+	msgs := make([]string, 0, len(ve.Causes))
+	for _, cause := range ve.Causes {
+		msgs = append(msgs, uiConfigErrorMessage(cause))
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,46 @@
+// Copyright (C) NHR@FAU, University Erlangen-Nuremberg.
+// All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file.
+package schema
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateUIConfig(t *testing.T) {
+	for _, tc := range []struct {
+		settings map[string]interface{}
+		ok       bool
+	}{
+		{map[string]interface{}{"plot_general_lineWidth": 2, "plot_view_showRoofline": false}, true},
+		{map[string]interface{}{"job_view_selectedMetrics": []string{"flops_any"}}, true},
+		{map[string]interface{}{"job_view_selectedMetrics:fritz": []string{"flops_any"}}, true},
+		{map[string]interface{}{"plot_general_lineWidth:fritz": 2}, false},
+		{map[string]interface{}{"plot_general_lineWidth": "2"}, false},
+		{map[string]interface{}{"plot_general_lineWidth": 0}, false},
+		{map[string]interface{}{"analysis_view_scatterPlotMetrics": [][]string{{"flops_any"}}}, false},
+		{map[string]interface{}{"no_such_key": true}, false},
+	} {
+		err := ValidateUIConfig(tc.settings)
+		if tc.ok && err != nil {
+			t.Errorf("%v rejected: %v", tc.settings, err)
+		} else if !tc.ok && !errors.Is(err, ErrInvalidUIConfig) {
+			t.Errorf("%v accepted", tc.settings)
+		}
+	}
+
+	keys, err := UIConfigKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keys {
+		if key.Name == "job_view_selectedMetrics" && !key.PerCluster || key.Name == "plot_general_lineWidth" && key.PerCluster {
+			t.Errorf("wrong per cluster flag of %s", key.Name)
+		}
+		if key.Description == "" || len(key.Schema) == 0 {
+			t.Errorf("incomplete key %+v", key)
+		}
+	}
+}
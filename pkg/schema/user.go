@@ -31,6 +31,25 @@ const (
 	AuthViaAll
 )
 
+var authSourceNames = [...]string{"local", "ldap", "token", "oidc"}
+
+func (a AuthSource) String() string {
+	if a < AuthViaLocalPassword || a >= AuthViaAll {
+		return fmt.Sprintf("AuthSource(%d)", int(a))
+	}
+	return authSourceNames[a]
+}
+
+// ParseAuthSource returns the auth source named local, ldap, token or oidc.
+func ParseAuthSource(name string) (AuthSource, error) {
+	for i, n := range authSourceNames {
+		if strings.EqualFold(n, name) {
+			return AuthSource(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown auth source '%s'", name)
+}
+
 type AuthType int
 
 const (
@@ -47,6 +66,51 @@ type User struct {
 	AuthSource AuthSource `json:"authSource"`
 	Email      string     `json:"email"`
 	Projects   []string   `json:"projects"`
+	// Disabled users cannot log in, e.g. after deprovisioning by an
+	// identity provider
+	Disabled bool `json:"disabled,omitempty"`
+	// Scopes of the token the user authenticated with, empty if the token
+	// is not restricted or the user did not authenticate with a token
+	Scopes []string `json:"scopes,omitempty"`
+	// Machine the token the user authenticated with is bound to, agents
+	// with the api role may only push data for that machine
+	Machine string `json:"machine,omitempty"`
+}
+
+// Scopes restrict what a token can be used for, independent of the roles of
+// its user. ScopeAdmin includes all other scopes.
+const (
+	ScopeMachinesRead   = "machines:read"
+	ScopeMachinesWrite  = "machines:write"
+	ScopeInventoryWrite = "inventory:write"
+	ScopeLogsWrite      = "logs:write"
+	ScopeSecretsRead    = "secrets:read"
+	ScopeAdmin          = "admin"
+)
+
+var validScopes = []string{ScopeMachinesRead, ScopeMachinesWrite, ScopeInventoryWrite, ScopeLogsWrite, ScopeSecretsRead, ScopeAdmin}
+
+func IsValidScope(scope string) bool {
+	for _, s := range validScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the user may act within scope. Users without
+// scopes are not restricted.
+func (u *User) HasScope(scope string) bool {
+	if len(u.Scopes) == 0 {
+		return true
+	}
+	for _, s := range u.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
 }
 
 func (u *User) HasProject(project string) bool {
@@ -127,3 +127,17 @@ func TestHasAllNotRoles(t *testing.T) {
 		t.Fatalf(`User{Roles: ["user", "manager"]} -> HasNotRoles([]Role{RoleUser, RoleManager}): RESULT = %v, expected 'false'.`, result)
 	}
 }
+
+func TestHasScope(t *testing.T) {
+	u := User{Username: "testuser", Scopes: []string{ScopeInventoryWrite, ScopeLogsWrite}}
+
+	if !u.HasScope(ScopeLogsWrite) || u.HasScope(ScopeMachinesWrite) {
+		t.Fatalf(`User{Scopes: ["inventory:write", "logs:write"]} -> HasScope: expected only listed scopes`)
+	}
+
+	admin := User{Username: "testuser", Scopes: []string{ScopeAdmin}}
+	unscoped := User{Username: "testuser"}
+	if !admin.HasScope(ScopeMachinesWrite) || !unscoped.HasScope(ScopeAdmin) {
+		t.Fatalf(`HasScope: expected admin scope and no scopes to allow everything`)
+	}
+}
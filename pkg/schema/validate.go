@@ -11,6 +11,7 @@ import (
 	"io"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/Deepbinder-main/cc-backend/pkg/log"
 	"github.com/santhosh-tekuri/jsonschema/v5"
@@ -28,22 +29,33 @@ const (
 //go:embed schemas/*
 var schemaFiles embed.FS
 
-func Validate(k Kind, r io.Reader) (err error) {
+// compileLock serializes the registration of the loader, jsonschema.Loaders
+// is a plain map.
+var compileLock sync.Mutex
+
+// compileSchema compiles one of the embedded schema files.
+func compileSchema(name string) (*jsonschema.Schema, error) {
+	compileLock.Lock()
+	defer compileLock.Unlock()
 	jsonschema.Loaders["embedfs"] = func(s string) (io.ReadCloser, error) {
 		f := filepath.Join("schemas", strings.Split(s, "//")[1])
 		return schemaFiles.Open(f)
 	}
+	return jsonschema.Compile("embedfs://" + name)
+}
+
+func Validate(k Kind, r io.Reader) (err error) {
 	var s *jsonschema.Schema
 
 	switch k {
 	case Meta:
-		s, err = jsonschema.Compile("embedfs://job-meta.schema.json")
+		s, err = compileSchema("job-meta.schema.json")
 	case Data:
-		s, err = jsonschema.Compile("embedfs://job-data.schema.json")
+		s, err = compileSchema("job-data.schema.json")
 	case ClusterCfg:
-		s, err = jsonschema.Compile("embedfs://cluster.schema.json")
+		s, err = compileSchema("cluster.schema.json")
 	case Config:
-		s, err = jsonschema.Compile("embedfs://config.schema.json")
+		s, err = compileSchema("config.schema.json")
 	default:
 		return fmt.Errorf("SCHEMA/VALIDATE > unkown schema kind: %#v", k)
 	}